@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/config"
+	"github.com/DenisKhanov/shorterURL/internal/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrateUsage describes the arguments of the migrate subcommand.
+const migrateUsage = "usage: shortener migrate up|down [steps]|status [config flags]"
+
+// runMigrate executes the migrate subcommand.
+// args are the arguments after "migrate": the action, an optional number of steps for down
+// and the usual configuration flags, which are needed to get the database DSN.
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	action, args := args[0], args[1:]
+	steps := 1
+	if action == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			steps, args = n, args[1:]
+		}
+	}
+
+	// the rest of the arguments are parsed by config as regular flags
+	os.Args = append([]string{os.Args[0]}, args...)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.EnvDataBase == "" {
+		return errors.New("database DSN is not set, use -d flag or DATABASE_DSN env")
+	}
+	dbPool, err := pgxpool.New(ctx, cfg.EnvDataBase)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	migrator, err := migrations.NewMigrator(dbPool)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migrations applied\n", applied)
+	case "down":
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migrations reverted\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate action %q, %s", action, migrateUsage)
+	}
+	return nil
+}
@@ -4,11 +4,27 @@ import (
 	"context"
 	"github.com/DenisKhanov/shorterURL/internal/app"
 	"github.com/sirupsen/logrus"
+	"os"
 )
 
 func main() {
 	ctx := context.Background()
 
+	// subcommands are handled before the server configuration flags are parsed
+	if len(os.Args) > 1 {
+		subcommands := map[string]func(context.Context, []string) error{
+			"migrate": runMigrate,
+			"export":  runExport,
+			"import":  runImport,
+		}
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(ctx, os.Args[2:]); err != nil {
+				logrus.Fatalf("%s: %s", os.Args[1], err.Error())
+			}
+			return
+		}
+	}
+
 	a, err := app.NewApp(ctx)
 	if err != nil {
 		logrus.Fatalf("failed to init app: %s", err.Error())
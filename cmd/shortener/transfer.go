@@ -0,0 +1,780 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/config"
+	"github.com/DenisKhanov/shorterURL/internal/migrations"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	url2 "github.com/DenisKhanov/shorterURL/internal/repositories/url"
+	"github.com/DenisKhanov/shorterURL/internal/services/url"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Formats of the exported records: one JSON object per line or CSV with a header.
+const (
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+)
+
+// Kinds of the exported records. Records without a kind are links, as in the files exported before
+// the API keys and the accounts were transferred.
+const (
+	kindURL     = "url"
+	kindAPIKey  = "api_key"
+	kindAccount = "account"
+)
+
+// csvHeader is the first line of the records in CSV. The last columns may be missing in the files
+// exported before creation times, short domains, deletion times, redirect codes, change histories, API keys
+// and accounts were recorded. The change history is a JSON array of the former original URLs, the most recently replaced last.
+// A record of an API key has only the user ID, the creation time and the key columns set, its scopes are separated by spaces.
+// A record of an account has only the user ID, the creation time and the account columns set.
+var csvHeader = []string{"short_url", "original_url", "user_id", "expires_at", "is_deleted", "created_at", "domain",
+	"deleted_at", "redirect_code", "history", "kind", "key_id", "key_name", "scopes", "key_hash", "login", "password_hash"}
+
+// csvMinColumns is the number of columns in the files exported before creation times were recorded.
+const csvMinColumns = 5
+
+// transferRecord is an exported link, API key or account, kind tells which one is set.
+type transferRecord struct {
+	kind    string
+	url     models.URLRecord
+	apiKey  models.APIKey
+	account models.Account
+}
+
+// recordWriter writes records in one of the export formats.
+type recordWriter interface {
+	Write(record transferRecord) error
+	Flush() error
+}
+
+// recordReader reads records in one of the export formats, it returns io.EOF after the last record.
+type recordReader interface {
+	Read() (transferRecord, error)
+}
+
+// jsonlURL is a link written by jsonlWriter.
+type jsonlURL struct {
+	Kind string `json:"kind"`
+	models.URLRecord
+}
+
+// jsonlAPIKey is an API key written by jsonlWriter. Unlike models.APIKey, it has the user and the hash of the key.
+type jsonlAPIKey struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	Hash      string    `json:"key_hash"`
+}
+
+// jsonlAccount is an account written by jsonlWriter. Unlike models.Account, it has the password hash.
+type jsonlAccount struct {
+	Kind         string    `json:"kind"`
+	UserID       uuid.UUID `json:"user_id"`
+	Login        string    `json:"login"`
+	CreatedAt    time.Time `json:"created_at"`
+	PasswordHash string    `json:"password_hash"`
+}
+
+// jsonlWriter writes records as JSON objects, one per line.
+type jsonlWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(record transferRecord) error {
+	switch record.kind {
+	case kindAPIKey:
+		key := record.apiKey
+		return w.encoder.Encode(jsonlAPIKey{Kind: kindAPIKey, ID: key.ID, UserID: key.UserID, Name: key.Name,
+			Scopes: key.Scopes, CreatedAt: key.CreatedAt, Hash: key.Hash})
+	case kindAccount:
+		account := record.account
+		return w.encoder.Encode(jsonlAccount{Kind: kindAccount, UserID: account.UserID, Login: account.Login,
+			CreatedAt: account.CreatedAt, PasswordHash: account.PasswordHash})
+	default:
+		return w.encoder.Encode(jsonlURL{Kind: kindURL, URLRecord: record.url})
+	}
+}
+
+func (w *jsonlWriter) Flush() error { return w.writer.Flush() }
+
+// csvWriter writes records as CSV lines after the header.
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(record transferRecord) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	switch record.kind {
+	case kindAPIKey:
+		key := record.apiKey
+		return w.writer.Write([]string{"", "", key.UserID.String(), "", "", formatTime(&key.CreatedAt), "", "", "", "",
+			kindAPIKey, key.ID, key.Name, strings.Join(key.Scopes, " "), key.Hash, "", ""})
+	case kindAccount:
+		account := record.account
+		return w.writer.Write([]string{"", "", account.UserID.String(), "", "", formatTime(&account.CreatedAt), "", "", "", "",
+			kindAccount, "", "", "", "", account.Login, account.PasswordHash})
+	}
+	url := record.url
+	history, err := formatHistory(url.History)
+	if err != nil {
+		return err
+	}
+	return w.writer.Write([]string{url.ShortURL, url.OriginalURL, url.UserID.String(),
+		formatTime(url.ExpiresAt), strconv.FormatBool(url.DeletedFlag), formatTime(url.CreatedAt), url.Domain,
+		formatTime(url.DeletedAt), formatRedirectCode(url.RedirectCode), history, kindURL, "", "", "", "", "", ""})
+}
+
+func (w *csvWriter) Flush() error {
+	// the header is written even if there are no records
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// formatTime returns the time in CSV, empty if it isn't set.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// parseTime parses the time written by formatTime.
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatRedirectCode returns the redirect code in CSV, empty if the link uses the default code.
+func formatRedirectCode(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code)
+}
+
+// parseRedirectCode parses the redirect code written by formatRedirectCode.
+func parseRedirectCode(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// formatHistory returns the change history in CSV as a JSON array, empty if the original URL has never been changed.
+func formatHistory(history []models.URLChange) (string, error) {
+	if len(history) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseHistory parses the change history written by formatHistory.
+func parseHistory(value string) ([]models.URLChange, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var history []models.URLChange
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// newRecordWriter returns the writer of records in the format.
+func newRecordWriter(format string, w io.Writer) (recordWriter, error) {
+	switch format {
+	case formatJSONL:
+		writer := bufio.NewWriter(w)
+		return &jsonlWriter{writer: writer, encoder: json.NewEncoder(writer)}, nil
+	case formatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, use %s or %s", format, formatJSONL, formatCSV)
+	}
+}
+
+// jsonlReader reads records written by jsonlWriter.
+type jsonlReader struct {
+	decoder *json.Decoder
+	line    int
+}
+
+func (r *jsonlReader) Read() (transferRecord, error) {
+	var line json.RawMessage
+	r.line++
+	if err := r.decoder.Decode(&line); err != nil {
+		if errors.Is(err, io.EOF) {
+			return transferRecord{}, io.EOF
+		}
+		return transferRecord{}, fmt.Errorf("record %d: %w", r.line, err)
+	}
+	var kind struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(line, &kind); err != nil {
+		return transferRecord{}, fmt.Errorf("record %d: %w", r.line, err)
+	}
+	switch kind.Kind {
+	case "", kindURL:
+		record := transferRecord{kind: kindURL}
+		if err := json.Unmarshal(line, &record.url); err != nil {
+			return record, fmt.Errorf("record %d: %w", r.line, err)
+		}
+		return record, nil
+	case kindAPIKey:
+		var key jsonlAPIKey
+		if err := json.Unmarshal(line, &key); err != nil {
+			return transferRecord{}, fmt.Errorf("record %d: %w", r.line, err)
+		}
+		return transferRecord{kind: kindAPIKey, apiKey: models.APIKey{ID: key.ID, Name: key.Name, Scopes: key.Scopes,
+			CreatedAt: key.CreatedAt, UserID: key.UserID, Hash: key.Hash}}, nil
+	case kindAccount:
+		var account jsonlAccount
+		if err := json.Unmarshal(line, &account); err != nil {
+			return transferRecord{}, fmt.Errorf("record %d: %w", r.line, err)
+		}
+		return transferRecord{kind: kindAccount, account: models.Account{UserID: account.UserID, Login: account.Login,
+			CreatedAt: account.CreatedAt, PasswordHash: account.PasswordHash}}, nil
+	default:
+		return transferRecord{}, fmt.Errorf("record %d: unknown kind %q", r.line, kind.Kind)
+	}
+}
+
+// csvReader reads records written by csvWriter.
+type csvReader struct {
+	reader     *csv.Reader
+	headerRead bool
+}
+
+func (r *csvReader) Read() (transferRecord, error) {
+	if !r.headerRead {
+		header, err := r.reader.Read()
+		if err != nil {
+			return transferRecord{}, err
+		}
+		if len(header) < csvMinColumns || len(header) > len(csvHeader) || header[0] != csvHeader[0] {
+			return transferRecord{}, fmt.Errorf("CSV header must be %v", csvHeader)
+		}
+		r.reader.FieldsPerRecord = len(header)
+		r.headerRead = true
+	}
+	fields, err := r.reader.Read()
+	if err != nil {
+		return transferRecord{}, err
+	}
+	line, _ := r.reader.FieldPos(0)
+	kind := kindURL
+	if len(fields) > 10 && fields[10] != "" {
+		kind = fields[10]
+	}
+	switch kind {
+	case kindURL:
+		url, err := parseCSVURL(fields, line)
+		return transferRecord{kind: kindURL, url: url}, err
+	case kindAPIKey:
+		key, err := parseCSVAPIKey(fields, line)
+		return transferRecord{kind: kindAPIKey, apiKey: key}, err
+	case kindAccount:
+		account, err := parseCSVAccount(fields, line)
+		return transferRecord{kind: kindAccount, account: account}, err
+	default:
+		return transferRecord{}, fmt.Errorf("line %d: unknown kind %q", line, kind)
+	}
+}
+
+// parseCSVOwner parses the user ID and the creation time of a CSV record of an API key or an account.
+func parseCSVOwner(fields []string, line int) (uuid.UUID, time.Time, error) {
+	userID, err := uuid.Parse(fields[2])
+	if err != nil {
+		return userID, time.Time{}, fmt.Errorf("line %d: user ID is invalid: %w", line, err)
+	}
+	createdAt, err := parseTime(fields[5])
+	if err != nil {
+		return userID, time.Time{}, fmt.Errorf("line %d: creation time is invalid: %w", line, err)
+	}
+	if createdAt == nil {
+		return userID, time.Time{}, fmt.Errorf("line %d: creation time is missing", line)
+	}
+	return userID, *createdAt, nil
+}
+
+// parseCSVAPIKey parses the fields of a CSV record of an API key.
+func parseCSVAPIKey(fields []string, line int) (models.APIKey, error) {
+	var key models.APIKey
+	var err error
+	if len(fields) < 15 {
+		return key, fmt.Errorf("line %d: API key columns are missing", line)
+	}
+	if key.UserID, key.CreatedAt, err = parseCSVOwner(fields, line); err != nil {
+		return key, err
+	}
+	key.ID, key.Name, key.Hash = fields[11], fields[12], fields[14]
+	key.Scopes = strings.Fields(fields[13])
+	return key, nil
+}
+
+// parseCSVAccount parses the fields of a CSV record of an account.
+func parseCSVAccount(fields []string, line int) (models.Account, error) {
+	var account models.Account
+	var err error
+	if len(fields) < 17 {
+		return account, fmt.Errorf("line %d: account columns are missing", line)
+	}
+	if account.UserID, account.CreatedAt, err = parseCSVOwner(fields, line); err != nil {
+		return account, err
+	}
+	account.Login, account.PasswordHash = fields[15], fields[16]
+	return account, nil
+}
+
+// parseCSVURL parses the fields of a CSV record of a link.
+func parseCSVURL(fields []string, line int) (models.URLRecord, error) {
+	var record models.URLRecord
+	var err error
+	record.ShortURL, record.OriginalURL = fields[0], fields[1]
+	if record.UserID, err = uuid.Parse(fields[2]); err != nil {
+		return record, fmt.Errorf("line %d: user ID is invalid: %w", line, err)
+	}
+	if record.ExpiresAt, err = parseTime(fields[3]); err != nil {
+		return record, fmt.Errorf("line %d: expiration time is invalid: %w", line, err)
+	}
+	if record.DeletedFlag, err = strconv.ParseBool(fields[4]); err != nil {
+		return record, fmt.Errorf("line %d: deleted flag is invalid: %w", line, err)
+	}
+	if len(fields) > 5 {
+		if record.CreatedAt, err = parseTime(fields[5]); err != nil {
+			return record, fmt.Errorf("line %d: creation time is invalid: %w", line, err)
+		}
+	}
+	if len(fields) > 6 {
+		record.Domain = fields[6]
+	}
+	if len(fields) > 7 {
+		if record.DeletedAt, err = parseTime(fields[7]); err != nil {
+			return record, fmt.Errorf("line %d: deletion time is invalid: %w", line, err)
+		}
+	}
+	if len(fields) > 8 {
+		if record.RedirectCode, err = parseRedirectCode(fields[8]); err != nil {
+			return record, fmt.Errorf("line %d: redirect code is invalid: %w", line, err)
+		}
+	}
+	if len(fields) > 9 {
+		if record.History, err = parseHistory(fields[9]); err != nil {
+			return record, fmt.Errorf("line %d: history is invalid: %w", line, err)
+		}
+	}
+	return record, nil
+}
+
+// newRecordReader returns the reader of records in the format.
+func newRecordReader(format string, r io.Reader) (recordReader, error) {
+	switch format {
+	case formatJSONL:
+		return &jsonlReader{decoder: json.NewDecoder(bufio.NewReader(r))}, nil
+	case formatCSV:
+		// the number of fields is set by the header
+		reader := csv.NewReader(bufio.NewReader(r))
+		reader.FieldsPerRecord = -1
+		return &csvReader{reader: reader}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, use %s or %s", format, formatJSONL, formatCSV)
+	}
+}
+
+// openStorage opens the repository of the storage selected by the configuration, the same one the server uses.
+// The returned function saves the buffered changes and closes the storage.
+func openStorage(ctx context.Context, cfg *config.ENVConfig) (url.TransferRepository, func() error, error) {
+	switch cfg.EnvStorageType {
+	case config.StoragePostgres:
+		dbPool, err := pgxpool.New(ctx, cfg.EnvDataBase)
+		if err != nil {
+			return nil, nil, err
+		}
+		closePool := func() error {
+			dbPool.Close()
+			return nil
+		}
+		migrator, err := migrations.NewMigrator(dbPool)
+		if err == nil {
+			_, err = migrator.Up(ctx)
+		}
+		if err != nil {
+			dbPool.Close()
+			return nil, nil, err
+		}
+		repository, err := url2.NewURLInDBRepo(dbPool)
+		if err != nil {
+			dbPool.Close()
+			return nil, nil, err
+		}
+		return repository, closePool, nil
+	case config.StorageBolt:
+		repository, err := url2.NewURLInBoltRepo(cfg.EnvBoltPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repository, repository.Close, nil
+	default:
+		repository := url2.NewURLInMemoryRepo(cfg.EnvStoragePath, url2.FlushPolicy{Records: cfg.EnvFileFlushRecords})
+		return repository, repository.SaveBatchToFile, nil
+	}
+}
+
+// exportUsage describes the arguments of the export subcommand.
+const exportUsage = "usage: shortener export [-format jsonl|csv] [-output file] [config flags]"
+
+// runExport executes the export subcommand: it writes all URLs of the configured storage
+// with their owners, deleted flags and change histories, and then all API keys and accounts with their hashes,
+// to the output file or to stdout.
+func runExport(ctx context.Context, args []string) error {
+	var format, output string
+	flag.StringVar(&format, "format", formatJSONL, "Enter format of the exported records: jsonl or csv")
+	flag.StringVar(&output, "output", "-", "Enter path of the file to export the records to, - for stdout")
+	flag.Usage = func() { fmt.Fprintln(flag.CommandLine.Output(), exportUsage) }
+
+	// the arguments are parsed by config together with the regular flags
+	os.Args = append([]string{os.Args[0]}, args...)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	writer, err := newRecordWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	repository, closeStorage, err := openStorage(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	exported, err := exportRecords(ctx, repository, writer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d records, %d API keys and %d accounts exported\n", exported[kindURL], exported[kindAPIKey],
+		exported[kindAccount])
+	return nil
+}
+
+// exportRecords writes the links, the API keys and then the accounts of the repository and flushes the writer.
+// It returns the numbers of the exported records by kind.
+func exportRecords(ctx context.Context, repository url.TransferRepository, writer recordWriter) (map[string]int, error) {
+	exported := make(map[string]int, 3)
+	write := func(record transferRecord) error {
+		exported[record.kind]++
+		return writer.Write(record)
+	}
+	err := repository.ExportURLs(ctx, func(record models.URLRecord) error {
+		return write(transferRecord{kind: kindURL, url: record})
+	})
+	if err != nil {
+		return exported, err
+	}
+	err = repository.ExportAPIKeys(ctx, func(key models.APIKey) error {
+		return write(transferRecord{kind: kindAPIKey, apiKey: key})
+	})
+	if err != nil {
+		return exported, err
+	}
+	err = repository.ExportAccounts(ctx, func(account models.Account) error {
+		return write(transferRecord{kind: kindAccount, account: account})
+	})
+	if err != nil {
+		return exported, err
+	}
+	return exported, writer.Flush()
+}
+
+// importUsage describes the arguments of the import subcommand.
+const importUsage = "usage: shortener import [-format jsonl|csv] [-input file] [-batch-size n] [-dry-run] [config flags]"
+
+// runImport executes the import subcommand: it loads the records from the input file or from stdin
+// to the configured storage in batches and prints the report with the conflicting records.
+func runImport(ctx context.Context, args []string) error {
+	var format, input string
+	var batchSize int
+	var dryRun bool
+	flag.StringVar(&format, "format", formatJSONL, "Enter format of the imported records: jsonl or csv")
+	flag.StringVar(&input, "input", "-", "Enter path of the file to import the records from, - for stdin")
+	flag.IntVar(&batchSize, "batch-size", 1000, "Enter number of records saved at once")
+	flag.BoolVar(&dryRun, "dry-run", false, "Check the records and print the report without saving them")
+	flag.Usage = func() { fmt.Fprintln(flag.CommandLine.Output(), importUsage) }
+
+	// the arguments are parsed by config together with the regular flags
+	os.Args = append([]string{os.Args[0]}, args...)
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	reader, err := newRecordReader(format, r)
+	if err != nil {
+		return err
+	}
+
+	repository, closeStorage, err := openStorage(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	report, err := importRecords(ctx, repository, reader, batchSize, dryRun)
+	if closeErr := closeStorage(); err == nil {
+		err = closeErr
+	}
+	if printErr := printImportReport(os.Stdout, report, dryRun); err == nil {
+		err = printErr
+	}
+	return err
+}
+
+// importReport is the result of importing the links, the API keys and the accounts.
+type importReport struct {
+	urls     models.ImportReport
+	apiKeys  models.ImportItemsReport
+	accounts models.ImportItemsReport
+}
+
+// importRecords reads all records and imports them to the repository in batches of batchSize, links, API keys
+// and accounts separately. Records repeating the short URL or the original URL on the same short domain of an earlier record
+// of the input with another link are reported as conflicts, exact repeats are counted as existing, so every batch has distinct links.
+// API keys repeating the ID of an earlier key of the input and accounts repeating the login or the user of an earlier account
+// are checked the same way.
+// On error the report of the batches imported so far is returned.
+func importRecords(ctx context.Context, repository url.TransferRepository, reader recordReader,
+	batchSize int, dryRun bool) (importReport, error) {
+	var report importReport
+	seenShort := make(map[string]models.URLRecord)
+	seenOriginal := make(map[string]string)
+	seenKeys := make(map[string]models.APIKey)
+	seenLogins := make(map[string]models.Account)
+	seenAccountUsers := make(map[uuid.UUID]struct{})
+	batch := make([]models.URLRecord, 0, batchSize)
+	var keys []models.APIKey
+	var accounts []models.Account
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		batchReport, err := repository.ImportURLs(ctx, batch, dryRun)
+		if err != nil {
+			return err
+		}
+		report.urls.Merge(batchReport)
+		batch = batch[:0]
+		return nil
+	}
+	flushKeys := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		keysReport, err := repository.ImportAPIKeys(ctx, keys, dryRun)
+		if err != nil {
+			return err
+		}
+		report.apiKeys.Merge(keysReport)
+		keys = keys[:0]
+		return nil
+	}
+	flushAccounts := func() error {
+		if len(accounts) == 0 {
+			return nil
+		}
+		accountsReport, err := repository.ImportAccounts(ctx, accounts, dryRun)
+		if err != nil {
+			return err
+		}
+		report.accounts.Merge(accountsReport)
+		accounts = accounts[:0]
+		return nil
+	}
+	for {
+		read, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		if read.kind == kindAPIKey {
+			key := read.apiKey
+			if seen, ok := seenKeys[key.ID]; ok {
+				if seen.UserID == key.UserID && seen.Hash == key.Hash {
+					report.apiKeys.Existing++
+				} else {
+					report.apiKeys.Conflicts = append(report.apiKeys.Conflicts,
+						models.ImportItemConflict{ID: key.ID, UserID: key.UserID, Reason: models.ConflictItemDuplicate})
+				}
+				continue
+			}
+			seenKeys[key.ID] = key
+			keys = append(keys, key)
+			if len(keys) == batchSize {
+				if err = flushKeys(); err != nil {
+					return report, err
+				}
+			}
+			continue
+		}
+		if read.kind == kindAccount {
+			account := read.account
+			seen, loginSeen := seenLogins[account.Login]
+			_, userSeen := seenAccountUsers[account.UserID]
+			switch {
+			case loginSeen && seen.UserID == account.UserID && seen.PasswordHash == account.PasswordHash:
+				report.accounts.Existing++
+				continue
+			case loginSeen || userSeen:
+				report.accounts.Conflicts = append(report.accounts.Conflicts,
+					models.ImportItemConflict{ID: account.Login, UserID: account.UserID, Reason: models.ConflictItemDuplicate})
+				continue
+			}
+			seenLogins[account.Login] = account
+			seenAccountUsers[account.UserID] = struct{}{}
+			accounts = append(accounts, account)
+			if len(accounts) == batchSize {
+				if err = flushAccounts(); err != nil {
+					return report, err
+				}
+			}
+			continue
+		}
+
+		record := read.url
+		if seen, ok := seenShort[record.ShortURL]; ok {
+			if seen.OriginalURL == record.OriginalURL && seen.UserID == record.UserID {
+				report.urls.Existing++
+			} else {
+				report.urls.Conflicts = append(report.urls.Conflicts, models.ImportConflict{URLRecord: record, Reason: models.ConflictDuplicate})
+			}
+			continue
+		}
+		// original URLs are unique per short domain, the same as in the repositories
+		originalKey := record.Domain + "\x00" + record.OriginalURL
+		if _, ok := seenOriginal[originalKey]; ok {
+			report.urls.Conflicts = append(report.urls.Conflicts, models.ImportConflict{URLRecord: record, Reason: models.ConflictDuplicate})
+			continue
+		}
+		seenShort[record.ShortURL] = record
+		seenOriginal[originalKey] = record.ShortURL
+
+		batch = append(batch, record)
+		if len(batch) == batchSize {
+			if err = flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return report, err
+	}
+	if err := flushKeys(); err != nil {
+		return report, err
+	}
+	return report, flushAccounts()
+}
+
+// printImportReport prints the numbers of imported, existing and conflicting records, API keys and accounts and the conflicts.
+func printImportReport(w io.Writer, report importReport, dryRun bool) error {
+	imported := "imported"
+	if dryRun {
+		imported = "would be imported (dry run)"
+	}
+	fmt.Fprintf(w, "%d records %s, %d already stored, %d conflicts\n",
+		report.urls.Imported, imported, report.urls.Existing, len(report.urls.Conflicts))
+	fmt.Fprintf(w, "%d API keys %s, %d already stored, %d conflicts\n",
+		report.apiKeys.Imported, imported, report.apiKeys.Existing, len(report.apiKeys.Conflicts))
+	fmt.Fprintf(w, "%d accounts %s, %d already stored, %d conflicts\n",
+		report.accounts.Imported, imported, report.accounts.Existing, len(report.accounts.Conflicts))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(report.urls.Conflicts) > 0 {
+		fmt.Fprintln(tw, "SHORT URL\tORIGINAL URL\tUSER ID\tREASON")
+		for _, conflict := range report.urls.Conflicts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", conflict.ShortURL, conflict.OriginalURL, conflict.UserID, conflict.Reason)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(report.apiKeys.Conflicts) > 0 {
+		fmt.Fprintln(tw, "API KEY ID\tUSER ID\tREASON")
+		for _, conflict := range report.apiKeys.Conflicts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", conflict.ID, conflict.UserID, conflict.Reason)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(report.accounts.Conflicts) > 0 {
+		fmt.Fprintln(tw, "LOGIN\tUSER ID\tREASON")
+		for _, conflict := range report.accounts.Conflicts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", conflict.ID, conflict.UserID, conflict.Reason)
+		}
+	}
+	return tw.Flush()
+}
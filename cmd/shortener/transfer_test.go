@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	url2 "github.com/DenisKhanov/shorterURL/internal/repositories/url"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sliceReader returns the records one by one.
+type sliceReader struct {
+	records []transferRecord
+}
+
+func (r *sliceReader) Read() (transferRecord, error) {
+	if len(r.records) == 0 {
+		return transferRecord{}, io.EOF
+	}
+	record := r.records[0]
+	r.records = r.records[1:]
+	return record, nil
+}
+
+// urlRecords returns the transfer records of the links.
+func urlRecords(records []models.URLRecord) []transferRecord {
+	result := make([]transferRecord, 0, len(records))
+	for _, record := range records {
+		result = append(result, transferRecord{kind: kindURL, url: record})
+	}
+	return result
+}
+
+// accountRecords returns the transfer records of the accounts.
+func accountRecords(accounts []models.Account) []transferRecord {
+	result := make([]transferRecord, 0, len(accounts))
+	for _, account := range accounts {
+		result = append(result, transferRecord{kind: kindAccount, account: account})
+	}
+	return result
+}
+
+// apiKeyRecords returns the transfer records of the API keys.
+func apiKeyRecords(keys []models.APIKey) []transferRecord {
+	result := make([]transferRecord, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, transferRecord{kind: kindAPIKey, apiKey: key})
+	}
+	return result
+}
+
+func TestRecordFormats(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 6, time.UTC)
+	deletedAt := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	records := urlRecords([]models.URLRecord{
+		{ShortURL: "short1", OriginalURL: "http://example.com/?a=1,b=\"2\"", UserID: uuid.New(), ExpiresAt: &expiresAt,
+			RedirectCode: http.StatusMovedPermanently},
+		{ShortURL: "short2", OriginalURL: "http://example.com/2", UserID: uuid.New(), DeletedFlag: true, CreatedAt: &expiresAt,
+			Domain: "go.example.com", DeletedAt: &deletedAt, History: []models.URLChange{
+				{OriginalURL: "http://example.com/0", ChangedAt: deletedAt.Add(-time.Hour)},
+				{OriginalURL: "http://example.com/1,\"1\"", ChangedAt: deletedAt.Add(-time.Minute)},
+			}},
+	})
+	records = append(records, apiKeyRecords([]models.APIKey{
+		{ID: "key1", Name: "backend, \"prod\"", Scopes: []string{models.ScopeCreate, models.ScopeRead}, CreatedAt: deletedAt,
+			UserID: uuid.New(), Hash: "hash1"},
+		{ID: "key2", Name: "reports", Scopes: []string{}, CreatedAt: expiresAt, UserID: uuid.New(), Hash: "hash2"},
+	})...)
+	records = append(records, accountRecords([]models.Account{
+		{UserID: uuid.New(), Login: "alice", CreatedAt: deletedAt, PasswordHash: "$2a$10$hash,\"1\""},
+	})...)
+	for _, format := range []string{formatJSONL, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := newRecordWriter(format, &buf)
+			require.NoError(t, err)
+			for _, record := range records {
+				require.NoError(t, writer.Write(record))
+			}
+			require.NoError(t, writer.Flush())
+
+			reader, err := newRecordReader(format, &buf)
+			require.NoError(t, err)
+			var read []transferRecord
+			for {
+				record, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				read = append(read, record)
+			}
+			assert.Equal(t, records, read)
+		})
+	}
+
+	_, err := newRecordWriter("xml", io.Discard)
+	assert.Error(t, err)
+	reader, err := newRecordReader(formatCSV, strings.NewReader("id,url\n"))
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.Error(t, err)
+
+	// the files exported without creation times are read as well
+	userID := uuid.New()
+	reader, err = newRecordReader(formatCSV, strings.NewReader("short_url,original_url,user_id,expires_at,is_deleted\n"+
+		"short1,http://example.com/1,"+userID.String()+",,false\n"))
+	require.NoError(t, err)
+	record, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, transferRecord{kind: kindURL, url: models.URLRecord{ShortURL: "short1", OriginalURL: "http://example.com/1",
+		UserID: userID}}, record)
+
+	// the files exported without deletion times are read as well
+	reader, err = newRecordReader(formatCSV, strings.NewReader("short_url,original_url,user_id,expires_at,is_deleted,created_at,domain\n"+
+		"short1,http://example.com/1,"+userID.String()+",,true,,go.example.com\n"))
+	require.NoError(t, err)
+	record, err = reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, transferRecord{kind: kindURL, url: models.URLRecord{ShortURL: "short1", OriginalURL: "http://example.com/1",
+		UserID: userID, DeletedFlag: true, Domain: "go.example.com"}}, record)
+
+	reader, err = newRecordReader(formatCSV, strings.NewReader(strings.Join(csvHeader, ",")+"\n"+
+		"short1,http://example.com/1,"+userID.String()+",,false,,,,permanent,,url,,,,,,\n"))
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.ErrorContains(t, err, "redirect code is invalid")
+
+	reader, err = newRecordReader(formatCSV, strings.NewReader(strings.Join(csvHeader, ",")+"\n"+
+		"short1,http://example.com/1,"+userID.String()+",,false,,,,,not json,url,,,,,,\n"))
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.ErrorContains(t, err, "history is invalid")
+
+	reader, err = newRecordReader(formatJSONL, strings.NewReader(`{"kind":"user"}`+"\n"))
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.ErrorContains(t, err, `unknown kind "user"`)
+
+	// the files exported before the records had kinds are read as links
+	reader, err = newRecordReader(formatJSONL, strings.NewReader(`{"short_url":"short1","original_url":"http://example.com/1",`+
+		`"user_id":"`+userID.String()+`"}`+"\n"))
+	require.NoError(t, err)
+	record, err = reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, transferRecord{kind: kindURL, url: models.URLRecord{ShortURL: "short1", OriginalURL: "http://example.com/1",
+		UserID: userID}}, record)
+}
+
+func TestImportRecords(t *testing.T) {
+	userID := uuid.New()
+	records := []models.URLRecord{
+		{ShortURL: "short1", OriginalURL: "http://example.com/1", UserID: userID},
+		{ShortURL: "short2", OriginalURL: "http://example.com/2", UserID: userID, DeletedFlag: true},
+		{ShortURL: "short1", OriginalURL: "http://example.com/1", UserID: userID},
+		{ShortURL: "short1", OriginalURL: "http://example.com/other", UserID: userID},
+		{ShortURL: "short3", OriginalURL: "http://example.com/2", UserID: userID},
+		{ShortURL: "short4", OriginalURL: "http://example.com/4", UserID: userID},
+		// the same original URL on another short domain is another link
+		{ShortURL: "short5", OriginalURL: "http://example.com/1", UserID: userID, Domain: "go.example.com"},
+	}
+	wantReport := importReport{urls: models.ImportReport{
+		Imported: 4,
+		Existing: 1,
+		Conflicts: []models.ImportConflict{
+			{URLRecord: records[3], Reason: models.ConflictDuplicate},
+			{URLRecord: records[4], Reason: models.ConflictDuplicate},
+		},
+	}}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	repo := url2.NewURLInMemoryRepo(path, url2.DefaultFlushPolicy)
+	report, err := importRecords(ctx, repo, &sliceReader{records: urlRecords(records)}, 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, wantReport, report)
+	_, err = repo.GetRedirect(ctx, "short1")
+	assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+
+	report, err = importRecords(ctx, repo, &sliceReader{records: urlRecords(records)}, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, wantReport, report)
+	require.NoError(t, repo.SaveBatchToFile())
+
+	// the imported links are restored from the storage file with their owners and deleted flags
+	restored := url2.NewURLInMemoryRepo(path, url2.DefaultFlushPolicy)
+	urls, err := restored.GetUserURLs(context.WithValue(ctx, models.UserIDKey, userID), models.UserURLsQuery{})
+	require.NoError(t, err)
+	assert.Len(t, urls, 4)
+	redirect, err := restored.GetRedirect(ctx, "short5")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/1", redirect.OriginalURL)
+	_, err = restored.GetRedirect(ctx, "short2")
+	assert.ErrorIs(t, err, models.ErrURLDeleted)
+
+	var out bytes.Buffer
+	require.NoError(t, printImportReport(&out, report, false))
+	assert.Contains(t, out.String(), "4 records imported, 1 already stored, 2 conflicts")
+	assert.Contains(t, out.String(), models.ConflictDuplicate)
+}
+
+func TestImportRecords_History(t *testing.T) {
+	ctx := context.Background()
+	userCtx := context.WithValue(ctx, models.UserIDKey, uuid.New())
+	source := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "source.json"), url2.DefaultFlushPolicy)
+	require.NoError(t, source.StoreURL(userCtx, "http://example.com/1", "short1", models.URLOptions{}))
+	for _, originalURL := range []string{"http://example.com/2", "http://example.com/3"} {
+		_, err := source.UpdateURL(userCtx, "short1", originalURL)
+		require.NoError(t, err)
+	}
+	wantHistory, err := source.GetURLHistory(userCtx, "short1")
+	require.NoError(t, err)
+	require.Len(t, wantHistory, 2)
+
+	for _, format := range []string{formatJSONL, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := newRecordWriter(format, &buf)
+			require.NoError(t, err)
+			_, err = exportRecords(ctx, source, writer)
+			require.NoError(t, err)
+
+			reader, err := newRecordReader(format, &buf)
+			require.NoError(t, err)
+			target, err := url2.NewURLInBoltRepo(filepath.Join(t.TempDir(), "target.bolt"))
+			require.NoError(t, err)
+			defer target.Close()
+			report, err := importRecords(ctx, target, reader, 10, false)
+			require.NoError(t, err)
+			assert.Equal(t, 1, report.urls.Imported)
+
+			history, err := target.GetURLHistory(userCtx, "short1")
+			require.NoError(t, err)
+			require.Len(t, history, len(wantHistory))
+			for i := range wantHistory {
+				assert.Equal(t, wantHistory[i].OriginalURL, history[i].OriginalURL)
+				assert.True(t, wantHistory[i].ChangedAt.Equal(history[i].ChangedAt))
+			}
+		})
+	}
+}
+
+func TestImportRecords_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := []models.APIKey{
+		{ID: "key1", Name: "backend", Scopes: []string{models.ScopeCreate, models.ScopeRead}, CreatedAt: createdAt,
+			UserID: userID, Hash: "hash1"},
+		{ID: "key2", Name: "reports", Scopes: []string{models.ScopeRead}, CreatedAt: createdAt.Add(time.Minute),
+			UserID: userID, Hash: "hash2"},
+	}
+	source := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "source.json"), url2.DefaultFlushPolicy)
+	for _, key := range keys {
+		require.NoError(t, source.StoreAPIKey(ctx, key))
+	}
+
+	for _, format := range []string{formatJSONL, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := newRecordWriter(format, &buf)
+			require.NoError(t, err)
+			exported, err := exportRecords(ctx, source, writer)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]int{kindAPIKey: 2}, exported)
+
+			target, err := url2.NewURLInBoltRepo(filepath.Join(t.TempDir(), "target.bolt"))
+			require.NoError(t, err)
+			defer target.Close()
+			// the key ID taken by another key in the target isn't overwritten
+			taken := models.APIKey{ID: "key2", Name: "other", CreatedAt: createdAt, UserID: uuid.New(), Hash: "hash3"}
+			require.NoError(t, target.StoreAPIKey(ctx, taken))
+
+			reader, err := newRecordReader(format, &buf)
+			require.NoError(t, err)
+			report, err := importRecords(ctx, target, reader, 1, false)
+			require.NoError(t, err)
+			assert.Equal(t, models.ImportItemsReport{Imported: 1, Conflicts: []models.ImportItemConflict{
+				{ID: "key2", UserID: userID, Reason: models.ConflictAPIKeyTaken},
+			}}, report.apiKeys)
+
+			// the imported key keeps its user and hash
+			key, err := target.GetAPIKey(ctx, "key1")
+			require.NoError(t, err)
+			assert.Equal(t, keys[0], key)
+			key, err = target.GetAPIKey(ctx, "key2")
+			require.NoError(t, err)
+			assert.Equal(t, taken, key)
+
+			var out bytes.Buffer
+			require.NoError(t, printImportReport(&out, report, false))
+			assert.Contains(t, out.String(), "1 API keys imported, 0 already stored, 1 conflicts")
+			assert.Contains(t, out.String(), models.ConflictAPIKeyTaken)
+		})
+	}
+
+	// repeated keys of the input are checked against each other
+	repo := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), url2.DefaultFlushPolicy)
+	repeated := apiKeyRecords([]models.APIKey{keys[0], keys[0], {ID: "key1", UserID: uuid.New(), Hash: "hash3"}})
+	report, err := importRecords(ctx, repo, &sliceReader{records: repeated}, 10, true)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportItemsReport{Imported: 1, Existing: 1, Conflicts: []models.ImportItemConflict{
+		{ID: "key1", UserID: repeated[2].apiKey.UserID, Reason: models.ConflictItemDuplicate},
+	}}, report.apiKeys)
+	_, err = repo.GetAPIKey(ctx, "key1")
+	assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+}
+
+func TestImportRecords_Accounts(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	alice := models.Account{UserID: uuid.New(), Login: "alice", CreatedAt: createdAt, PasswordHash: string(hash)}
+	bob := models.Account{UserID: uuid.New(), Login: "bob", CreatedAt: createdAt, PasswordHash: "hash2"}
+	carol := models.Account{UserID: uuid.New(), Login: "carol", CreatedAt: createdAt, PasswordHash: "hash3"}
+	source := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "source.json"), url2.DefaultFlushPolicy)
+	for _, account := range []models.Account{alice, bob, carol} {
+		require.NoError(t, source.StoreAccount(ctx, account))
+	}
+
+	for _, format := range []string{formatJSONL, formatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := newRecordWriter(format, &buf)
+			require.NoError(t, err)
+			exported, err := exportRecords(ctx, source, writer)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]int{kindAccount: 3}, exported)
+
+			target, err := url2.NewURLInBoltRepo(filepath.Join(t.TempDir(), "target.bolt"))
+			require.NoError(t, err)
+			defer target.Close()
+			// the login of bob is taken in the target, the user of carol has registered with another login
+			require.NoError(t, target.StoreAccount(ctx, models.Account{UserID: uuid.New(), Login: "bob", CreatedAt: createdAt,
+				PasswordHash: "other"}))
+			require.NoError(t, target.StoreAccount(ctx, models.Account{UserID: carol.UserID, Login: "carol2", CreatedAt: createdAt,
+				PasswordHash: "hash3"}))
+
+			reader, err := newRecordReader(format, &buf)
+			require.NoError(t, err)
+			report, err := importRecords(ctx, target, reader, 2, false)
+			require.NoError(t, err)
+			assert.Equal(t, models.ImportItemsReport{Imported: 1, Conflicts: []models.ImportItemConflict{
+				{ID: "bob", UserID: bob.UserID, Reason: models.ConflictLoginTaken},
+				{ID: "carol", UserID: carol.UserID, Reason: models.ConflictUserHasAccount},
+			}}, report.accounts)
+
+			// the imported account keeps its user and its password
+			account, err := target.GetAccount(ctx, "alice")
+			require.NoError(t, err)
+			assert.Equal(t, alice, account)
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte("secret")))
+
+			var out bytes.Buffer
+			require.NoError(t, printImportReport(&out, report, false))
+			assert.Contains(t, out.String(), "1 accounts imported, 0 already stored, 2 conflicts")
+			assert.Contains(t, out.String(), models.ConflictUserHasAccount)
+		})
+	}
+
+	// repeated accounts of the input are checked against each other
+	repo := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), url2.DefaultFlushPolicy)
+	otherLogin := models.Account{UserID: alice.UserID, Login: "alice2", CreatedAt: createdAt, PasswordHash: "hash4"}
+	repeated := accountRecords([]models.Account{alice, alice, otherLogin})
+	report, err := importRecords(ctx, repo, &sliceReader{records: repeated}, 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, models.ImportItemsReport{Imported: 1, Existing: 1, Conflicts: []models.ImportItemConflict{
+		{ID: "alice2", UserID: alice.UserID, Reason: models.ConflictItemDuplicate},
+	}}, report.accounts)
+	account, err := repo.GetUserAccount(context.WithValue(ctx, models.UserIDKey, alice.UserID))
+	require.NoError(t, err)
+	assert.Equal(t, alice, account)
+}
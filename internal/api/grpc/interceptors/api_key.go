@@ -0,0 +1,95 @@
+// Package interceptors provides gRPC interceptors for handling authentication and authorization.
+// It includes functionality to enforce authentication for specific gRPC methods.
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// APIKeyMetadata is the metadata key of the requests of server-to-server clients carrying an API key.
+// The key can be sent as a bearer token of the authorization metadata as well,
+// bearer tokens without models.APIKeyPrefix aren't API keys and are ignored.
+const APIKeyMetadata = "x-api-key"
+
+// APIKeyAuthenticator defines the interface for authenticating the API keys of requests.
+type APIKeyAuthenticator interface {
+	// AuthenticateAPIKey returns the stored API key matching the key of a request.
+	// It returns models.ErrAPIKeyInvalid if the key is malformed, revoked or doesn't match.
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// scopeMethods specifies the scopes API keys need for the gRPC methods acting on the URLs of the user.
+// The other methods don't require a scope, except the tokenOnlyMethods.
+var scopeMethods = map[string]string{
+	grpcHandlersPath + "GetShortURL":      models.ScopeCreate,
+	grpcHandlersPath + "GetBatchShortURL": models.ScopeCreate,
+	grpcHandlersPath + "UpdateURL":        models.ScopeCreate,
+	grpcHandlersPath + "GetUserURLs":      models.ScopeRead,
+	grpcHandlersPath + "GetURLStats":      models.ScopeRead,
+	grpcHandlersPath + "GetURLHistory":    models.ScopeRead,
+	grpcHandlersPath + "GetDeleteJob":     models.ScopeRead,
+	grpcHandlersPath + "DelUserURLs":      models.ScopeDelete,
+	grpcHandlersPath + "RestoreURL":       models.ScopeDelete,
+}
+
+// tokenOnlyMethods specifies the gRPC methods that can't be called with an API key.
+var tokenOnlyMethods = map[string]struct{}{
+	grpcHandlersPath + "CreateAPIKey": {},
+	grpcHandlersPath + "ListAPIKeys":  {},
+	grpcHandlersPath + "RevokeAPIKey": {},
+	grpcHandlersPath + "Register":     {},
+	grpcHandlersPath + "Login":        {},
+}
+
+// requestAPIKey returns the API key of the request from the x-api-key metadata
+// or the bearer token of the authorization metadata, an empty string if the request has no key.
+// Bearer tokens of other issuers, such as JWTs or tokens of a gateway, are ignored,
+// so the request is authenticated by the token metadata as if it had no authorization metadata.
+func requestAPIKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(APIKeyMetadata); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	const bearer = "bearer "
+	values := md.Get("authorization")
+	if len(values) == 0 || len(values[0]) <= len(bearer) || !strings.EqualFold(values[0][:len(bearer)], bearer) {
+		return ""
+	}
+	token := strings.TrimSpace(values[0][len(bearer):])
+	if !strings.HasPrefix(token, models.APIKeyPrefix) {
+		return ""
+	}
+	return token
+}
+
+// authAPIKey authenticates the request to the method with the API key and adds the user ID of the key
+// and the key to the context. It returns the Unauthenticated code if the key is invalid
+// and the PermissionDenied code if the method can't be called with the key.
+func authAPIKey(ctx context.Context, apiKeys APIKeyAuthenticator, method, key string) (context.Context, error) {
+	apiKey, err := apiKeys.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyInvalid) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		logrus.Errorf("error authenticating API key: %v", err)
+		return nil, status.Error(codes.Internal, `error authenticating API key`)
+	}
+	if _, ok := tokenOnlyMethods[method]; ok {
+		return nil, status.Error(codes.PermissionDenied, models.ErrAPIKeyNotAllowed.Error())
+	}
+	if scope, ok := scopeMethods[method]; ok && !apiKey.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, models.ErrAPIKeyScope.Error())
+	}
+	ctx = context.WithValue(ctx, models.UserIDKey, apiKey.UserID)
+	return context.WithValue(ctx, models.APIKeyKey, apiKey), nil
+}
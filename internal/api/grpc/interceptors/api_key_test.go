@@ -0,0 +1,140 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DenisKhanov/shorterURL/internal/auth"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAPIKeys authenticates the API keys of the map, the key errStorageKey fails as a storage error.
+type fakeAPIKeys map[string]models.APIKey
+
+// errStorageKey is the API key that can't be authenticated because of a storage error.
+const errStorageKey = "shk_storage_error"
+
+func (f fakeAPIKeys) AuthenticateAPIKey(_ context.Context, key string) (models.APIKey, error) {
+	if key == errStorageKey {
+		return models.APIKey{}, errors.New("storage error")
+	}
+	apiKey, ok := f[key]
+	if !ok {
+		return models.APIKey{}, models.ErrAPIKeyInvalid
+	}
+	return apiKey, nil
+}
+
+// callAuthenticated calls the method through the private and the public interceptors chained as the app does
+// and returns the user ID the handler gets.
+func callAuthenticated(ctx context.Context, keys *auth.KeyRing, apiKeys APIKeyAuthenticator, method string) (uuid.UUID, error) {
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return ctx.Value(models.UserIDKey), nil
+	}
+	public := UnaryPublicAuthInterceptor(keys, apiKeys)
+	resp, err := UnaryPrivateAuthInterceptor(keys, apiKeys)(ctx, nil, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return public(ctx, req, info, handler)
+		})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return resp.(uuid.UUID), nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys, err := auth.GenerateKeyRing()
+	require.NoError(t, err)
+	tokenUserID := uuid.New()
+	token, err := keys.BuildUserJWTString(tokenUserID)
+	require.NoError(t, err)
+	keyUserID := uuid.New()
+	const (
+		readKey   = "shk_read_key"
+		createKey = "shk_create_key"
+	)
+	apiKeys := fakeAPIKeys{
+		readKey:   {ID: "read", Scopes: []string{models.ScopeRead}, UserID: keyUserID},
+		createKey: {ID: "create", Scopes: []string{models.ScopeCreate}, UserID: keyUserID},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		md             metadata.MD
+		expectedCode   codes.Code
+		expectedUserID uuid.UUID
+	}{
+		{name: "key with the scope", method: "GetShortURL",
+			md: metadata.Pairs(APIKeyMetadata, createKey), expectedCode: codes.OK, expectedUserID: keyUserID},
+		{name: "bearer key with the scope", method: "GetUserURLs",
+			md: metadata.Pairs("authorization", "Bearer "+readKey), expectedCode: codes.OK, expectedUserID: keyUserID},
+		{name: "key on a method without a scope", method: "GetOriginalURL",
+			md: metadata.Pairs(APIKeyMetadata, readKey), expectedCode: codes.OK, expectedUserID: keyUserID},
+		{name: "public method without the scope", method: "GetShortURL",
+			md: metadata.Pairs(APIKeyMetadata, readKey), expectedCode: codes.PermissionDenied},
+		{name: "private method without the scope", method: "DelUserURLs",
+			md: metadata.Pairs(APIKeyMetadata, readKey), expectedCode: codes.PermissionDenied},
+		{name: "key management with a key", method: "CreateAPIKey",
+			md: metadata.Pairs(APIKeyMetadata, createKey), expectedCode: codes.PermissionDenied},
+		{name: "register with a key", method: "Register",
+			md: metadata.Pairs(APIKeyMetadata, createKey), expectedCode: codes.PermissionDenied},
+		{name: "login with a bearer key", method: "Login",
+			md: metadata.Pairs("authorization", "Bearer "+createKey), expectedCode: codes.PermissionDenied},
+		{name: "invalid key on a public method", method: "GetShortURL",
+			md: metadata.Pairs(APIKeyMetadata, "shk_revoked", "token", token), expectedCode: codes.Unauthenticated},
+		{name: "invalid bearer key on a private method", method: "GetUserURLs",
+			md: metadata.Pairs("authorization", "Bearer shk_revoked", "token", token), expectedCode: codes.Unauthenticated},
+		{name: "storage error", method: "GetUserURLs",
+			md: metadata.Pairs(APIKeyMetadata, errStorageKey), expectedCode: codes.Internal},
+		{name: "token on a public method with a scope", method: "GetShortURL",
+			md: metadata.Pairs("token", token), expectedCode: codes.OK, expectedUserID: tokenUserID},
+		{name: "token on a private method with a scope", method: "GetUserURLs",
+			md: metadata.Pairs("token", token), expectedCode: codes.OK, expectedUserID: tokenUserID},
+		{name: "token on key management", method: "CreateAPIKey",
+			md: metadata.Pairs("token", token), expectedCode: codes.OK, expectedUserID: tokenUserID},
+		{name: "token on login", method: "Login",
+			md: metadata.Pairs("token", token), expectedCode: codes.OK, expectedUserID: tokenUserID},
+		{name: "other bearer token falls back to the token", method: "GetShortURL",
+			md: metadata.Pairs("authorization", "Bearer "+token, "token", token), expectedCode: codes.OK, expectedUserID: tokenUserID},
+		{name: "other bearer token on a private method", method: "GetUserURLs",
+			md: metadata.Pairs("authorization", "Bearer gateway-token", "token", token), expectedCode: codes.OK, expectedUserID: tokenUserID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			userID, err := callAuthenticated(ctx, keys, apiKeys, grpcHandlersPath+tt.method)
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedUserID != uuid.Nil {
+				assert.Equal(t, tt.expectedUserID, userID)
+			}
+		})
+	}
+}
+
+func TestAPIKeyMethods(t *testing.T) {
+	// a method either needs a scope or can't be called with a key, not both
+	for method := range scopeMethods {
+		_, tokenOnly := tokenOnlyMethods[method]
+		assert.False(t, tokenOnly, method)
+	}
+	for method := range tokenOnlyMethods {
+		_, scoped := scopeMethods[method]
+		assert.False(t, scoped, method)
+	}
+	// the private methods acting on the URLs of the user need a scope or can't be called with a key
+	for method := range authMethods {
+		_, scoped := scopeMethods[method]
+		_, tokenOnly := tokenOnlyMethods[method]
+		assert.True(t, scoped || tokenOnly, method)
+	}
+}
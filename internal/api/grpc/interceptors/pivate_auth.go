@@ -18,38 +18,58 @@ const grpcHandlersPath = "/shortener_v1.Shortener_v1/"
 
 // authMethods specifies the gRPC methods that require authentication.
 var authMethods = map[string]struct{}{
-	grpcHandlersPath + "GetUserURLs": {},
-	grpcHandlersPath + "DelUserURLs": {},
+	grpcHandlersPath + "GetUserURLs":   {},
+	grpcHandlersPath + "DelUserURLs":   {},
+	grpcHandlersPath + "GetURLStats":   {},
+	grpcHandlersPath + "UpdateURL":     {},
+	grpcHandlersPath + "GetURLHistory": {},
+	grpcHandlersPath + "RestoreURL":    {},
+	grpcHandlersPath + "GetDeleteJob":  {},
+	grpcHandlersPath + "CreateAPIKey":  {},
+	grpcHandlersPath + "ListAPIKeys":   {},
+	grpcHandlersPath + "RevokeAPIKey":  {},
 }
 
-// UnaryPrivateAuthInterceptor is a gRPC interceptor that enforces authentication for specific unary RPCs.
+// UnaryPrivateAuthInterceptor creates a gRPC interceptor that enforces authentication for specific unary RPCs
+// with the tokens verified with the keys of the ring.
 // It checks if the incoming context contains a valid token for accessing the specified methods.
 // If the token is valid, it extracts the user ID from the token and adds it to the context.
 // If the token is missing or invalid, it returns an error.
-func UnaryPrivateAuthInterceptor(ctx context.Context, req interface{},
-	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if _, exist := authMethods[info.FullMethod]; !exist {
-		return handler(ctx, req)
-	}
-	var tokenString string
-	var err error
-	var userID uuid.UUID
-	md, ok := metadata.FromIncomingContext(ctx)
-	if ok {
-		values := md.Get("token")
-		if len(values) > 0 {
-			// ключ содержит слайс строк, получаем первую строку
-			tokenString = values[0]
+// Requests with an API key in the metadata are authenticated as the user the key is bound to
+// if the key has the scope of the method.
+func UnaryPrivateAuthInterceptor(keys *auth.KeyRing, apiKeys APIKeyAuthenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, exist := authMethods[info.FullMethod]; !exist {
+			return handler(ctx, req)
+		}
+		if key := requestAPIKey(ctx); key != "" {
+			ctx, err := authAPIKey(ctx, apiKeys, info.FullMethod, key)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+		var tokenString string
+		var err error
+		var userID uuid.UUID
+		md, ok := metadata.FromIncomingContext(ctx)
+		if ok {
+			values := md.Get("token")
+			if len(values) > 0 {
+				// ключ содержит слайс строк, получаем первую строку
+				tokenString = values[0]
+			}
+		}
+		if !ok || len(tokenString) == 0 {
+			return nil, status.Error(codes.InvalidArgument, `missing token`)
+		}
+		userID, err = keys.GetUserID(tokenString)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, `invalid token`)
 		}
-	}
-	if !ok || len(tokenString) == 0 {
-		return nil, status.Error(codes.InvalidArgument, `missing token`)
-	}
-	userID, err = auth.GetUserID(tokenString)
-	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, `invalid token`)
-	}
 
-	ctx = context.WithValue(ctx, models.UserIDKey, userID)
-	return handler(ctx, req)
+		ctx = context.WithValue(ctx, models.UserIDKey, userID)
+		return handler(ctx, req)
+	}
 }
@@ -14,42 +14,74 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// UnaryPublicAuthInterceptor is a gRPC interceptor that performs public authentication.
+// UnaryPublicAuthInterceptor creates a gRPC interceptor that performs public authentication
+// with the tokens signed and verified with the keys of the ring.
 // It checks if the incoming context contains a valid token for public access.
 // If the token is missing or invalid, it generates a new token and sends it in the response header.
+// A valid token close to expiration is reissued to the same user and sent in the response header the same way.
 // If the token is valid, it extracts the user ID from the token and adds it to the context.
-func UnaryPublicAuthInterceptor(ctx context.Context, req interface{},
-	_ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	var tokenString string
-	var err error
-	var userID uuid.UUID
-	md, ok := metadata.FromIncomingContext(ctx)
-	if ok {
-		values := md.Get("token")
-		if len(values) > 0 {
-			tokenString = values[0]
+// Requests with an API key in the metadata are authenticated as the user the key is bound to without a token,
+// requests already authenticated with a key by UnaryPrivateAuthInterceptor are passed through.
+func UnaryPublicAuthInterceptor(keys *auth.KeyRing, apiKeys APIKeyAuthenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := ctx.Value(models.APIKeyKey).(models.APIKey); ok {
+			return handler(ctx, req)
 		}
-	}
-	if !ok || len(tokenString) == 0 || !auth.IsValidToken(tokenString) {
-		logrus.Info("Token in metadata missing or isn't valid")
-		tokenString, err = auth.BuildJWTString()
-		if err != nil {
-			logrus.Errorf("error generating token: %v", err)
-			return nil, status.Errorf(codes.Unauthenticated, `error generating token: %v`, err)
+		if key := requestAPIKey(ctx); key != "" {
+			ctx, err := authAPIKey(ctx, apiKeys, info.FullMethod, key)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+		var tokenString string
+		var err error
+		var userID uuid.UUID
+		md, ok := metadata.FromIncomingContext(ctx)
+		if ok {
+			values := md.Get("token")
+			if len(values) > 0 {
+				tokenString = values[0]
+			}
+		}
+		if !ok || len(tokenString) == 0 || !keys.IsValidToken(tokenString) {
+			logrus.Info("Token in metadata missing or isn't valid")
+			tokenString, err = keys.BuildJWTString()
+			if err != nil {
+				logrus.Errorf("error generating token: %v", err)
+				return nil, status.Errorf(codes.Unauthenticated, `error generating token: %v`, err)
+			}
+			if ctx, err = sendToken(ctx, tokenString); err != nil {
+				return nil, err
+			}
+		} else {
+			refreshed, err := keys.RefreshJWTString(tokenString)
+			if err != nil {
+				logrus.Errorf("error refreshing token: %v", err)
+			} else if refreshed != tokenString {
+				tokenString = refreshed
+				if ctx, err = sendToken(ctx, tokenString); err != nil {
+					return nil, err
+				}
+			}
 		}
 
-		if err = grpc.SendHeader(ctx, metadata.New(map[string]string{
-			string(models.TokenKey): tokenString})); err != nil {
-			return nil, status.Errorf(codes.Unknown, `error send token in metadata: %v`, err)
+		userID, err = keys.GetUserID(tokenString)
+		if err != nil {
+			logrus.Error(err)
+			return nil, status.Errorf(codes.Unauthenticated, `error get UUID from token: %v`, err)
 		}
-		ctx = context.WithValue(ctx, models.TokenKey, tokenString)
+		ctx = context.WithValue(ctx, models.UserIDKey, userID)
+		return handler(ctx, req)
 	}
+}
 
-	userID, err = auth.GetUserID(tokenString)
-	if err != nil {
-		logrus.Error(err)
-		return nil, status.Errorf(codes.Unauthenticated, `error get UUID from token: %v`, err)
+// sendToken sends the new token of the user in the response header and adds it to the context.
+func sendToken(ctx context.Context, tokenString string) (context.Context, error) {
+	if err := grpc.SendHeader(ctx, metadata.New(map[string]string{
+		string(models.TokenKey): tokenString})); err != nil {
+		return nil, status.Errorf(codes.Unknown, `error send token in metadata: %v`, err)
 	}
-	ctx = context.WithValue(ctx, models.UserIDKey, userID)
-	return handler(ctx, req)
+	return context.WithValue(ctx, models.TokenKey, tokenString), nil
 }
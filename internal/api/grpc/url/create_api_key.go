@@ -0,0 +1,33 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateAPIKey method within the ShortenerServer struct handles gRPC requests to create
+// an API key bound to the user for a server-to-server client. It delegates the creation to the service
+// layer's CreateAPIKey method and returns the key with its metadata along with a status error with the OK code.
+// The key itself is returned only in this response.
+//
+// If the name is empty or the scopes are empty or unknown, it returns the InvalidArgument code.
+// Other errors are returned with the Internal code.
+func (s *ShortenerServer) CreateAPIKey(ctx context.Context,
+	in *proto.CreateAPIKeyRequest) (*proto.CreateAPIKeyResponse, error) {
+	key, err := s.service.CreateAPIKey(ctx, models.APIKeyRequest{Name: in.Name, Scopes: in.Scopes})
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyRequestInvalid) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	return &proto.CreateAPIKeyResponse{ApiKey: protoAPIKey(key.APIKey), Key: key.Key},
+		status.Error(codes.OK, `API key created`)
+}
@@ -5,6 +5,8 @@ package url
 
 import (
 	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
 	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -12,14 +14,19 @@ import (
 
 // DelUserURLs method in the ShortenerServer struct handles gRPC requests
 // to asynchronously mark user URLs as deleted. It invokes the AsyncDeleteUserURLs
-// method of the service layer to perform the deletion operation. If an error occurs
-// during the deletion process, it constructs an appropriate error message and returns
-// a status error with the Internal code. Otherwise, it returns a status error with the
-// OK code and a message indicating that the URLs have been marked as deleted.
+// method of the service layer, which queues the deletion without waiting for the storage,
+// and returns the queued job, whose progress is returned by GetDeleteJob, along with
+// a status error with the OK code. If the deletion queue is full, it returns a status error
+// with the ResourceExhausted code, so the request should be retried later. Other errors
+// are returned with the Internal code.
 func (s *ShortenerServer) DelUserURLs(ctx context.Context,
 	in *proto.DelUserURLsRequest) (*proto.DelUserURLsResponse, error) {
-	if err := s.service.AsyncDeleteUserURLs(ctx, in.UrlsToDel); err != nil {
+	job, err := s.service.AsyncDeleteUserURLs(ctx, in.UrlsToDel)
+	if err != nil {
+		if errors.Is(err, models.ErrDeleteQueueFull) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
 		return nil, status.Errorf(codes.Internal, `error: %v`, err)
 	}
-	return nil, status.Error(codes.OK, `URLs marked as deleted`)
+	return &proto.DelUserURLsResponse{Job: protoDeleteJob(job)}, status.Error(codes.OK, `URLs queued for deletion`)
 }
@@ -5,6 +5,7 @@ package url
 
 import (
 	"context"
+	"errors"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
 	"github.com/sirupsen/logrus"
@@ -21,8 +22,11 @@ import (
 // along with a status error with the OK code and a message indicating that all URLs have
 // been compressed.
 //
-// If an error occurs during the compression process, it logs the error, constructs an
-// appropriate error message, and returns a status error with the Internal code.
+// The URLs are shortened on the domain from the request or, if it is empty, on the domain of the request host.
+// Invalid custom aliases, expirations, domains and redirect codes are reported with the InvalidArgument code and taken aliases
+// with the AlreadyExists code. If another error occurs during the compression process,
+// it logs the error, constructs an appropriate error message, and returns a status error
+// with the Internal code.
 func (s *ShortenerServer) GetBatchShortURL(ctx context.Context,
 	in *proto.GetBatchShortURLRequest) (*proto.GetBatchShortURLResponse, error) {
 	var response proto.GetBatchShortURLResponse
@@ -35,10 +39,25 @@ func (s *ShortenerServer) GetBatchShortURL(ctx context.Context,
 		batchURLRequests[i] = models.URLRequest{
 			CorrelationID: req.CorrelationId,
 			OriginalURL:   req.OriginalUrl,
+			Alias:         req.Alias,
+			TTL:           req.Ttl,
+			RedirectCode:  int(req.RedirectCode),
+		}
+		if req.ExpiresAt != nil {
+			expiresAt := req.ExpiresAt.AsTime()
+			batchURLRequests[i].ExpiresAt = &expiresAt
 		}
 	}
-	batchURLResponses, err := s.service.GetBatchShortURL(ctx, batchURLRequests)
+	domain := models.RequestDomain{Domain: in.Domain, Host: requestHost(ctx)}
+	batchURLResponses, err := s.service.GetBatchShortURL(ctx, domain, batchURLRequests)
 	if err != nil {
+		if errors.Is(err, models.ErrAliasInvalid) || errors.Is(err, models.ErrExpirationInvalid) ||
+			errors.Is(err, models.ErrDomainInvalid) || errors.Is(err, models.ErrRedirectCodeInvalid) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, models.ErrAliasTaken) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
 		logrus.Error(err)
 		return nil, status.Errorf(codes.Internal, `error: %v`, err)
 	}
@@ -0,0 +1,32 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetDeleteJob method within the ShortenerServer struct handles gRPC requests to
+// get the status of a deletion job of the user returned by DelUserURLs. It delegates
+// the lookup to the service layer's GetDeleteJob method and returns the job along with
+// a status error with the OK code.
+//
+// If the job doesn't exist, has been forgotten or belongs to another user, it returns
+// the NotFound code. Other errors are returned with the Internal code.
+func (s *ShortenerServer) GetDeleteJob(ctx context.Context,
+	in *proto.GetDeleteJobRequest) (*proto.GetDeleteJobResponse, error) {
+	job, err := s.service.GetDeleteJob(ctx, in.JobId)
+	if err != nil {
+		if errors.Is(err, models.ErrDeleteJobNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	return &proto.GetDeleteJobResponse{Job: protoDeleteJob(job)}, status.Error(codes.OK, `deletion job found`)
+}
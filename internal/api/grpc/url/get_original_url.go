@@ -9,7 +9,10 @@ import (
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"net"
 	"strings"
 )
 
@@ -17,12 +20,15 @@ import (
 // retrieve the original URL corresponding to a shortened URL. It extracts the
 // short URL from the request, then invokes the GetOriginalURL method of the
 // service layer to retrieve the corresponding original URL. If the retrieval
-// is successful, it constructs a response containing the original URL and returns
+// is successful, the click is recorded with the client info taken from the request
+// metadata, and it constructs a response containing the original URL and the
+// redirect code of the short URL and returns
 // it along with a status error with the OK code and a message indicating that the
 // original URL was successfully obtained.
 //
+// The short URL is resolved on the domain of the host the request has been sent to.
 // If an error occurs during the retrieval process, it checks if the error indicates
-// that the URL has been deleted. If so, it returns a status error with the NotFound
+// that the URL has been deleted or has expired. If so, it returns a status error with the NotFound
 // code and an appropriate error message. Otherwise, it returns a status error with
 // the InvalidArgument code and an appropriate error message.
 func (s *ShortenerServer) GetOriginalURL(ctx context.Context,
@@ -30,14 +36,42 @@ func (s *ShortenerServer) GetOriginalURL(ctx context.Context,
 	var response proto.GetOriginalURLResponse
 	parts := strings.Split(in.ShortUrl, "/")
 	shortURL := parts[len(parts)-1]
-	originURL, err := s.service.GetOriginalURL(ctx, shortURL)
+	redirect, err := s.service.GetOriginalURL(ctx, requestHost(ctx), shortURL, clickInfo(ctx))
 	if err != nil {
-		if errors.Is(err, models.ErrURLDeleted) {
+		if errors.Is(err, models.ErrURLDeleted) || errors.Is(err, models.ErrURLExpired) {
 
 			return nil, status.Errorf(codes.NotFound, err.Error())
 		}
 		return nil, status.Errorf(codes.InvalidArgument, `error: %v`, err)
 	}
-	response.OriginalUrl = originURL
+	response.OriginalUrl = redirect.OriginalURL
+	response.RedirectCode = int32(redirect.Code)
 	return &response, status.Error(codes.OK, `original url`)
 }
+
+// clickInfo collects the client info for the click statistics from the request metadata:
+// the referrer from "referer", the user agent from "user-agent" and the IP address
+// from "x-real-ip" or, if it is missing, from the peer address.
+func clickInfo(ctx context.Context) models.ClickInfo {
+	var client models.ClickInfo
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("referer"); len(values) > 0 {
+			client.Referrer = values[0]
+		}
+		if values := md.Get("user-agent"); len(values) > 0 {
+			client.UserAgent = values[0]
+		}
+		if values := md.Get("x-real-ip"); len(values) > 0 {
+			client.ClientIP = values[0]
+		}
+	}
+	if client.ClientIP == "" {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			client.ClientIP = p.Addr.String()
+			if host, _, err := net.SplitHostPort(client.ClientIP); err == nil {
+				client.ClientIP = host
+			}
+		}
+	}
+	return client
+}
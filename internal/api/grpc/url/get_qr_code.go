@@ -0,0 +1,48 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// GetQRCode method in the ShortenerServer struct handles gRPC requests to
+// render the QR code image of the full shortened URL. It extracts the short URL
+// from the request and invokes the GetQRCode method of the service layer with the
+// format, size, margin and error correction level of the request, unset fields take
+// the default values. The short URL is resolved on the domain of the host the request
+// has been sent to. It returns the image bytes with their MIME type along with a status
+// error with the OK code.
+//
+// If the parameters of the image are invalid, it returns a status error with the
+// InvalidArgument code. If the URL doesn't exist, has been deleted or has expired,
+// it returns a status error with the NotFound code. Otherwise, it returns a status
+// error with the Internal code.
+func (s *ShortenerServer) GetQRCode(ctx context.Context, in *proto.GetQRCodeRequest) (*proto.GetQRCodeResponse, error) {
+	parts := strings.Split(in.ShortUrl, "/")
+	shortURL := parts[len(parts)-1]
+	request := models.QRRequest{Format: in.Format, Size: int(in.Size), Level: in.Level}
+	if in.Margin != nil {
+		margin := int(*in.Margin)
+		request.Margin = &margin
+	}
+	code, err := s.service.GetQRCode(ctx, requestHost(ctx), shortURL, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrQRCodeInvalid):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, models.ErrOriginalURLNotFound), errors.Is(err, models.ErrURLDeleted),
+			errors.Is(err, models.ErrURLExpired):
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	return &proto.GetQRCodeResponse{Image: code.Data, ContentType: code.ContentType}, status.Error(codes.OK, `QR code`)
+}
@@ -27,8 +27,10 @@ func (s *ShortenerServer) GetServiceStats(ctx context.Context,
 		return nil, status.Errorf(codes.Internal, `error: %v`, err)
 	}
 	response.Stats = &proto.Stats{
-		CountUrls:  stats.CountURLs,
-		CountUsers: stats.CountUsers,
+		CountUrls:   stats.CountURLs,
+		CountUsers:  stats.CountUsers,
+		CacheHits:   stats.CacheHits,
+		CacheMisses: stats.CacheMisses,
 	}
 	return &response, status.Error(codes.OK, `stats got`)
 }
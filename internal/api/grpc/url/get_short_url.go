@@ -27,6 +27,9 @@ import (
 // If no shortened URL is found in the database, it constructs a response containing
 // the newly generated shortened URL and returns it along with a status error with the
 // OK code and a message indicating that the request was completed successfully.
+// The short URL is created on the domain from the request or, if it is empty, on the domain of the request host.
+// If the custom alias, the expiration, the domain or the redirect code from the request is invalid, it returns a status error
+// with the InvalidArgument code, and if the alias is already taken, with the AlreadyExists code.
 // If an unexpected error occurs during the process, it returns a status error with
 // the Unknown code and an appropriate error message.
 func (s *ShortenerServer) GetShortURL(ctx context.Context,
@@ -37,12 +40,21 @@ func (s *ShortenerServer) GetShortURL(ctx context.Context,
 	if err != nil || parsedLinc.Scheme == "" || parsedLinc.Host == "" {
 		return nil, status.Error(codes.InvalidArgument, `URL format isn't correct`)
 	}
-	shortURL, err := s.service.GetShortURL(ctx, linkString)
+	domain := models.RequestDomain{Domain: in.Domain, Host: requestHost(ctx)}
+	shortURL, err := s.service.GetShortURL(ctx, domain, linkString, in.Alias, expiration(in.ExpiresAt, in.Ttl),
+		int(in.RedirectCode))
 	if err != nil {
 		if errors.Is(err, models.ErrURLFound) {
 			response.ShortUrl = shortURL
 			return &response, status.Error(codes.OK, `short URL found in database`)
 		}
+		if errors.Is(err, models.ErrAliasInvalid) || errors.Is(err, models.ErrExpirationInvalid) ||
+			errors.Is(err, models.ErrDomainInvalid) || errors.Is(err, models.ErrRedirectCodeInvalid) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, models.ErrAliasTaken) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
 		return nil, status.Errorf(codes.Unknown, `error: %v`, err)
 	}
 	response.ShortUrl = shortURL
@@ -0,0 +1,44 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// GetURLStats method within the ShortenerServer struct handles gRPC requests to
+// retrieve the click statistics of a short URL owned by the user. The short URL
+// can be passed either as an ID or as a full link. If the retrieval is successful,
+// it constructs a response containing the total and per-day numbers of clicks and
+// returns it along with a status error with the OK code.
+//
+// If the short URL doesn't exist or belongs to another user, it returns a status
+// error with the NotFound code, and for other errors with the Internal code.
+func (s *ShortenerServer) GetURLStats(ctx context.Context,
+	in *proto.GetURLStatsRequest) (*proto.GetURLStatsResponse, error) {
+	parts := strings.Split(in.ShortUrl, "/")
+	shortURL := parts[len(parts)-1]
+	stats, err := s.service.GetURLStats(ctx, shortURL)
+	if err != nil {
+		if errors.Is(err, models.ErrURLNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	response := proto.GetURLStatsResponse{
+		ShortUrl:    stats.ShortURL,
+		TotalClicks: stats.TotalClicks,
+		Daily:       make([]*proto.DailyClicks, len(stats.Daily)),
+	}
+	for i, day := range stats.Daily {
+		response.Daily[i] = &proto.DailyClicks{Date: day.Date, Clicks: day.Clicks}
+	}
+	return &response, status.Error(codes.OK, `URL stats got`)
+}
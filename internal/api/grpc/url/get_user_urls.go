@@ -5,6 +5,8 @@ package url
 
 import (
 	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
 	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -13,25 +15,33 @@ import (
 //TODO добавить вывод статуса удаления URL
 
 // GetUserURLs method within the ShortenerServer struct handles requests in gRPC format to
-// retrieve user-specific URLs. It delegates the retrieval process to the service layer's GetUserURLs
-// method. If no URLs are found for the user, it returns a status error with the NotFound code and an
-// appropriate error message. Otherwise, it constructs a response containing all user URLs in
-// the appropriate format and returns it along with a status error with the OK code and a message
-// indicating that all user URLs have been successfully retrieved.
+// retrieve a page of user-specific URLs. It delegates the retrieval process to the service layer's GetUserURLs
+// method with the page size, page token, sort order and search query of the request. If the request
+// parameters are invalid, it returns a status error with the InvalidArgument code. If no URLs are found
+// for the user, it returns a status error with the NotFound code and an appropriate error message.
+// Otherwise, it constructs a response containing the page of user URLs and the token of the next page
+// in the appropriate format and returns it along with a status error with the OK code and a message
+// indicating that the user URLs have been successfully retrieved.
 func (s *ShortenerServer) GetUserURLs(ctx context.Context,
 	in *proto.GetUserURLsRequest) (*proto.GetUserURLsResponse, error) {
 	var response proto.GetUserURLsResponse
-	allUserShortURLs, err := s.service.GetUserURLs(ctx)
+	page, err := s.service.GetUserURLs(ctx, models.UserURLsRequest{
+		Limit:  int(in.PageSize),
+		Cursor: in.PageToken,
+		Sort:   in.Sort,
+		Search: in.Query,
+	})
 	if err != nil {
+		if errors.Is(err, models.ErrPageQueryInvalid) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		return nil, status.Error(codes.NotFound, `your short URLs not found`)
 	}
-	resultAllUserShortURLs := make([]*proto.URL, len(allUserShortURLs))
-	for i, res := range allUserShortURLs {
-		resultAllUserShortURLs[i] = &proto.URL{
-			ShortUrl:    res.ShortURL,
-			OriginalUrl: res.OriginalURL,
-		}
+	resultAllUserShortURLs := make([]*proto.URL, len(page.URLs))
+	for i, res := range page.URLs {
+		resultAllUserShortURLs[i] = protoURL(res)
 	}
 	response.UserUrls = resultAllUserShortURLs
+	response.NextPageToken = page.NextCursor
 	return &response, status.Error(codes.OK, `your all short URLs`)
 }
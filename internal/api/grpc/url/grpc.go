@@ -5,39 +5,90 @@ package url
 
 import (
 	"context"
+	"github.com/DenisKhanov/shorterURL/internal/auth"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	url2 "github.com/DenisKhanov/shorterURL/internal/services/url"
 	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
 )
 
 //go:generate mockgen -source=grpc.go -destination=mocks/grpc_mock.go -package=mocks
 type Service interface {
 	// GetStorageStatus checks the database connection or repository created.
 	GetStorageStatus(ctx context.Context) error
-	// GetShortURL takes original URL and returns its shortened version.
-	// If the URL has already been shortened, it returns the existing shortened URL.
-	// If the URL is new, it generates a new shortened URL.
+	// GetShortURL takes original URL, an optional custom alias, expiration and redirect code
+	// and returns its shortened version on the domain selected by the request.
+	// If the URL has already been shortened on the domain, it returns the existing shortened URL.
+	// If the URL is new, it uses the alias or generates a new shortened URL that expires as requested
+	// and is followed with the redirect code, 0 for the default code.
 	// Returns an error if the URL cannot be shortened or if any internal error occurs.
-	GetShortURL(ctx context.Context, url string) (string, error)
-	// GetOriginalURL takes a shortened URL and returns the original URL it points to.
-	// If the shortened URL does not exist or is invalid, an error is returned.
+	GetShortURL(ctx context.Context, domain models.RequestDomain, url, alias string, expiration models.Expiration,
+		redirectCode int) (string, error)
+	// GetOriginalURL takes a shortened URL requested on the host and returns the original URL it points to
+	// with the HTTP status code of the redirect.
+	// If the shortened URL does not exist, is invalid or belongs to another domain, an error is returned.
 	// Useful for redirecting shortened URLs to their original destinations.
-	GetOriginalURL(ctx context.Context, shortURL string) (string, error)
-	// GetBatchShortURL takes a slice of models.URLRequest objects, each containing a URL to be shortened,
-	// and returns a slice of models.URLResponse objects, each containing the original and shortened URL.
+	// Every successful call records a click with the client info for the statistics.
+	GetOriginalURL(ctx context.Context, host, shortURL string, client models.ClickInfo) (models.Redirect, error)
+	// GetQRCode renders the QR code image of the full shortened URL requested on the host
+	// with the format, size, margin and error correction level of the request.
+	// If the shortened URL does not exist, is deleted, expired or belongs to another domain, an error is returned.
+	GetQRCode(ctx context.Context, host, shortURL string, request models.QRRequest) (models.QRCode, error)
+	// GetBatchShortURL takes a slice of models.URLRequest objects, each containing a URL to be shortened
+	// on the domain selected by the request, and returns a slice of models.URLResponse objects,
+	// each containing the original and shortened URL.
 	// This method is intended for processing multiple URLs at once, improving efficiency for bulk operations.
 	// Returns an error if any of the URLs cannot be processed or if an internal error occurs.
-	GetBatchShortURL(ctx context.Context, batchURLRequests []models.URLRequest) ([]models.URLResponse, error)
-	// GetUserURLs takes a slice of models.URL objects for a specific user
-	GetUserURLs(ctx context.Context) ([]models.URL, error)
-	// AsyncDeleteUserURLs async runs requests to DB for mark user URLs as deleted
-	AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) error
+	GetBatchShortURL(ctx context.Context, domain models.RequestDomain, batchURLRequests []models.URLRequest) ([]models.URLResponse, error)
+	// GetUserURLs returns a page of the URLs of the user from the context ordered by creation time.
+	GetUserURLs(ctx context.Context, request models.UserURLsRequest) (models.UserURLsPage, error)
+	// AsyncDeleteUserURLs queues the deletion of the URLs of the user from the context
+	// and returns its job without waiting for the storage.
+	AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) (models.DeleteJob, error)
+	// GetDeleteJob returns the deletion job of the user from the context.
+	GetDeleteJob(ctx context.Context, jobID string) (models.DeleteJob, error)
 	// GetServiceStats retrieves the statistics of URLs and users from the service's repository.
 	GetServiceStats(ctx context.Context) (models.Stats, error)
+	// GetURLStats returns the click statistics of the short URL owned by the user from the context.
+	GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error)
+	// UpdateURL changes the original URL the short URL owned by the user from the context leads to
+	// and returns the updated URL. The replaced original URL is kept in the change history.
+	UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error)
+	// GetURLHistory returns the former original URLs of the short URL owned by the user from the context,
+	// the most recently replaced first.
+	GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error)
+	// RestoreURL clears the deleted flag of the short URL owned by the user from the context and returns the URL,
+	// if it has been deleted within the restore period.
+	RestoreURL(ctx context.Context, shortURL string) (models.URL, error)
+	// CreateAPIKey creates an API key with the scopes of the request bound to the user from the context
+	// and returns it with the key itself, which isn't stored.
+	CreateAPIKey(ctx context.Context, request models.APIKeyRequest) (models.NewAPIKey, error)
+	// GetAPIKeys returns the API keys of the user from the context ordered by creation time.
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// RevokeAPIKey removes the API key of the user from the context.
+	RevokeAPIKey(ctx context.Context, id string) error
+	// AuthenticateAPIKey returns the stored API key matching the key of a request.
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+	// Register creates an account with the credentials for the user from the context,
+	// or for a new user if the user from the context has an account already.
+	Register(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error)
+	// Login checks the credentials and returns the account,
+	// the links of the anonymous user from the context are claimed by the account.
+	Login(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error)
+}
+
+// TokenIssuer defines the interface for issuing the JWT tokens of the users of accounts.
+type TokenIssuer interface {
+	// BuildUserJWTString creates a token of the existing user.
+	BuildUserJWTString(userID uuid.UUID) (string, error)
 }
 
 // checking interface compliance at the compiler level
 var _ Service = (*url2.ShortURLServices)(nil)
+var _ TokenIssuer = (*auth.KeyRing)(nil)
 
 // ShortenerServer поддерживает все необходимые методы сервера.
 type ShortenerServer struct {
@@ -45,13 +96,86 @@ type ShortenerServer struct {
 	// для совместимости с будущими версиями
 	proto.UnimplementedShortenerV1Server
 	service Service
+	tokens  TokenIssuer
+}
+
+// expiration converts the expiration fields of a request into models.Expiration.
+func expiration(expiresAt *timestamppb.Timestamp, ttl int64) models.Expiration {
+	var result models.Expiration
+	if expiresAt != nil {
+		result.ExpiresAt = expiresAt.AsTime()
+	}
+	result.TTL = time.Duration(ttl) * time.Second
+	return result
+}
+
+// protoURL converts the user URL into its gRPC message.
+func protoURL(url models.URL) *proto.URL {
+	result := &proto.URL{
+		ShortUrl:     url.ShortURL,
+		OriginalUrl:  url.OriginalURL,
+		RedirectCode: int32(url.RedirectCode),
+	}
+	if url.ExpiresAt != nil {
+		result.ExpiresAt = timestamppb.New(*url.ExpiresAt)
+	}
+	if url.CreatedAt != nil {
+		result.CreatedAt = timestamppb.New(*url.CreatedAt)
+	}
+	return result
+}
+
+// protoDeleteJob converts the deletion job to its gRPC message.
+func protoDeleteJob(job models.DeleteJob) *proto.DeleteJob {
+	result := &proto.DeleteJob{
+		JobId:     job.ID,
+		Status:    job.Status,
+		Urls:      int64(job.URLs),
+		CreatedAt: timestamppb.New(job.CreatedAt),
+		Error:     job.Error,
+	}
+	if job.FinishedAt != nil {
+		result.FinishedAt = timestamppb.New(*job.FinishedAt)
+	}
+	return result
+}
+
+// protoAccount converts the account to its gRPC message without the password hash.
+func protoAccount(account models.Account) *proto.Account {
+	return &proto.Account{
+		UserId:    account.UserID.String(),
+		Login:     account.Login,
+		CreatedAt: timestamppb.New(account.CreatedAt),
+	}
+}
+
+// protoAPIKey converts the API key to its gRPC message without the hash of the key.
+func protoAPIKey(key models.APIKey) *proto.APIKey {
+	return &proto.APIKey{
+		Id:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: timestamppb.New(key.CreatedAt),
+	}
+}
+
+// requestHost returns the host the request has been sent to from the ":authority" metadata.
+func requestHost(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(":authority"); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
 }
 
 // NewShortenerServer function creates a new instance of the ShortenerServer struct with the
 // provided service. It initializes the service field of the ShortenerServer struct with the given
 // service instance and returns a pointer to the newly created ShortenerServer instance.
-func NewShortenerServer(service Service) *ShortenerServer {
+// The tokens issue the tokens of the users logged in to accounts.
+func NewShortenerServer(service Service, tokens TokenIssuer) *ShortenerServer {
 	return &ShortenerServer{
 		service: service,
+		tokens:  tokens,
 	}
 }
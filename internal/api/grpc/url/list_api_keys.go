@@ -0,0 +1,28 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListAPIKeys method within the ShortenerServer struct handles gRPC requests to list
+// the API keys of the user without the keys themselves. It delegates the retrieval to the service
+// layer's GetAPIKeys method and returns the keys ordered by creation time along with a status error
+// with the OK code. Errors are returned with the Internal code.
+func (s *ShortenerServer) ListAPIKeys(ctx context.Context,
+	_ *proto.ListAPIKeysRequest) (*proto.ListAPIKeysResponse, error) {
+	keys, err := s.service.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	result := make([]*proto.APIKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, protoAPIKey(key))
+	}
+	return &proto.ListAPIKeysResponse{ApiKeys: result}, status.Error(codes.OK, `API keys found`)
+}
@@ -0,0 +1,39 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Login method within the ShortenerServer struct handles gRPC requests to log the user in to the account
+// with the login and the password. It delegates the check to the service layer's Login method and returns
+// the account with the token of the account user and the number of links of the current anonymous user
+// claimed by the account along with a status error with the OK code.
+//
+// If the login doesn't exist or the password doesn't match, it returns the Unauthenticated code.
+// Other errors are returned with the Internal code.
+func (s *ShortenerServer) Login(ctx context.Context,
+	in *proto.LoginRequest) (*proto.LoginResponse, error) {
+	account, err := s.service.Login(ctx, models.Credentials{Login: in.Login, Password: in.Password})
+	if err != nil {
+		if errors.Is(err, models.ErrLoginFailed) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	token, err := s.tokens.BuildUserJWTString(account.UserID)
+	if err != nil {
+		logrus.Errorf("error generating token: %v", err)
+		return nil, status.Errorf(codes.Internal, `error generating token: %v`, err)
+	}
+	return &proto.LoginResponse{Account: protoAccount(account.Account), Token: token, ClaimedUrls: account.ClaimedURLs},
+		status.Error(codes.OK, `logged in`)
+}
@@ -0,0 +1,42 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Register method within the ShortenerServer struct handles gRPC requests to create an account
+// of the user with the login and the password. It delegates the creation to the service layer's Register method
+// and returns the account with the token of the account user along with a status error with the OK code.
+// The links of the current anonymous user stay with the account.
+//
+// If the credentials don't meet the requirements, it returns the InvalidArgument code,
+// and if the login is taken the AlreadyExists code. Other errors are returned with the Internal code.
+func (s *ShortenerServer) Register(ctx context.Context,
+	in *proto.RegisterRequest) (*proto.RegisterResponse, error) {
+	account, err := s.service.Register(ctx, models.Credentials{Login: in.Login, Password: in.Password})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrCredentialsInvalid):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, models.ErrLoginTaken):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	token, err := s.tokens.BuildUserJWTString(account.UserID)
+	if err != nil {
+		logrus.Errorf("error generating token: %v", err)
+		return nil, status.Errorf(codes.Internal, `error generating token: %v`, err)
+	}
+	return &proto.RegisterResponse{Account: protoAccount(account.Account), Token: token},
+		status.Error(codes.OK, `account registered`)
+}
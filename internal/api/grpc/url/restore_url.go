@@ -0,0 +1,40 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// RestoreURL method within the ShortenerServer struct handles gRPC requests to
+// restore a short URL of the user marked as deleted, so it redirects again. The short URL
+// can be passed either as an ID or as a full link. It delegates the restore to the service
+// layer's RestoreURL method and returns the restored URL along with a status error with the OK code.
+// A short URL that isn't deleted is returned as is.
+//
+// If the short URL doesn't exist or belongs to another user, it returns the NotFound code,
+// and if the restore period of the deleted URL is over the FailedPrecondition code.
+// Other errors are returned with the Internal code.
+func (s *ShortenerServer) RestoreURL(ctx context.Context,
+	in *proto.RestoreURLRequest) (*proto.RestoreURLResponse, error) {
+	parts := strings.Split(in.ShortUrl, "/")
+	shortURL := parts[len(parts)-1]
+	restored, err := s.service.RestoreURL(ctx, shortURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrURLNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, models.ErrRestorePeriodOver):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	return &proto.RestoreURLResponse{Url: protoURL(restored)}, status.Error(codes.OK, `URL restored`)
+}
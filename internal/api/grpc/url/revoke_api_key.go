@@ -0,0 +1,30 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RevokeAPIKey method within the ShortenerServer struct handles gRPC requests to revoke
+// an API key of the user, requests with the key are rejected afterwards. It delegates the removal
+// to the service layer's RevokeAPIKey method and returns a status error with the OK code.
+//
+// If the key doesn't exist or belongs to another user, it returns the NotFound code.
+// Other errors are returned with the Internal code.
+func (s *ShortenerServer) RevokeAPIKey(ctx context.Context,
+	in *proto.RevokeAPIKeyRequest) (*proto.RevokeAPIKeyResponse, error) {
+	if err := s.service.RevokeAPIKey(ctx, in.Id); err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	return &proto.RevokeAPIKeyResponse{}, status.Error(codes.OK, `API key revoked`)
+}
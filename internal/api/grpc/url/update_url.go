@@ -0,0 +1,49 @@
+// Package url package provides functionality for handling gRPC communication related to URL shortening.
+// It includes interfaces and structs for defining gRPC services and servers, as well as methods
+// for interacting with the URL shortening service.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	proto "github.com/DenisKhanov/shorterURL/pkg/shortener_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/url"
+	"strings"
+)
+
+// UpdateURL method within the ShortenerServer struct handles gRPC requests to
+// change the original URL a short URL owned by the user leads to. The short URL
+// can be passed either as an ID or as a full link. It validates the format of the
+// new original URL and delegates the change to the service layer's UpdateURL method,
+// which keeps the replaced original URL in the change history. If the change is
+// successful, it returns the updated URL along with a status error with the OK code.
+//
+// If the new URL format is incorrect, it returns a status error with the InvalidArgument code.
+// If the short URL doesn't exist or belongs to another user, it returns the NotFound code,
+// if it is deleted or expired the FailedPrecondition code, and if another link on the domain
+// leads to the new URL the AlreadyExists code. Other errors are returned with the Internal code.
+func (s *ShortenerServer) UpdateURL(ctx context.Context,
+	in *proto.UpdateURLRequest) (*proto.UpdateURLResponse, error) {
+	parsedLinc, err := url.Parse(in.OriginalUrl)
+	if err != nil || parsedLinc.Scheme == "" || parsedLinc.Host == "" {
+		return nil, status.Error(codes.InvalidArgument, `URL format isn't correct`)
+	}
+	parts := strings.Split(in.ShortUrl, "/")
+	shortURL := parts[len(parts)-1]
+	updated, err := s.service.UpdateURL(ctx, shortURL, in.OriginalUrl)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrURLNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, models.ErrURLDeleted), errors.Is(err, models.ErrURLExpired):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, models.ErrOriginalURLTaken):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, `error: %v`, err)
+	}
+	return &proto.UpdateURLResponse{Url: protoURL(updated)}, status.Error(codes.OK, `URL updated`)
+}
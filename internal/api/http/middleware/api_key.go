@@ -0,0 +1,94 @@
+// Package middleware provides HTTP middleware for handlers.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the header of the requests of server-to-server clients carrying an API key.
+// The key can be sent as a bearer token of the Authorization header as well,
+// bearer tokens without models.APIKeyPrefix aren't API keys and are ignored.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator defines the interface for authenticating the API keys of requests.
+type APIKeyAuthenticator interface {
+	// AuthenticateAPIKey returns the stored API key matching the key of a request.
+	// It returns models.ErrAPIKeyInvalid if the key is malformed, revoked or doesn't match.
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// requestAPIKey returns the API key of the request from the X-API-Key header
+// or the bearer token of the Authorization header, an empty string if the request has no key.
+// Bearer tokens of other issuers, such as JWTs or tokens of a gateway, are ignored,
+// so the request is authenticated by the cookie as if it had no Authorization header.
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+	return bearerAPIKey(c.GetHeader("Authorization"))
+}
+
+// bearerAPIKey returns the bearer token of the Authorization header value if it is an API key,
+// an empty string otherwise.
+func bearerAPIKey(header string) string {
+	const bearer = "Bearer "
+	if len(header) <= len(bearer) || !strings.EqualFold(header[:len(bearer)], bearer) {
+		return ""
+	}
+	token := strings.TrimSpace(header[len(bearer):])
+	if !strings.HasPrefix(token, models.APIKeyPrefix) {
+		return ""
+	}
+	return token
+}
+
+// authAPIKey authenticates the request with the API key and adds the user ID of the key and the key to the context.
+// It aborts the request with 401 Unauthorized and returns false if the key is invalid.
+func authAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, key string) bool {
+	apiKey, err := apiKeys.AuthenticateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyInvalid) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			logrus.Errorf("error authenticating API key: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error authenticating API key"})
+		}
+		return false
+	}
+	ctx := context.WithValue(c.Request.Context(), models.UserIDKey, apiKey.UserID)
+	ctx = context.WithValue(ctx, models.APIKeyKey, apiKey)
+	c.Request = c.Request.WithContext(ctx)
+	return true
+}
+
+// RequireScope provides middleware for the routes of requests that can be made with an API key having the scope.
+// Requests made with an API key without the scope are rejected with 403 Forbidden,
+// requests authenticated with the user token are passed through.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := c.Request.Context().Value(models.APIKeyKey).(models.APIKey)
+		if ok && !apiKey.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": models.ErrAPIKeyScope.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// TokenOnly provides middleware for the routes of requests that can't be made with an API key,
+// such as managing the API keys themselves. Requests made with an API key are rejected with 403 Forbidden.
+func TokenOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Request.Context().Value(models.APIKeyKey).(models.APIKey); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": models.ErrAPIKeyNotAllowed.Error()})
+			return
+		}
+		c.Next()
+	}
+}
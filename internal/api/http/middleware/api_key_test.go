@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DenisKhanov/shorterURL/internal/auth"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPIKeys authenticates the API keys of the map, the key errStorageKey fails as a storage error.
+type fakeAPIKeys map[string]models.APIKey
+
+// errStorageKey is the API key that can't be authenticated because of a storage error.
+const errStorageKey = "shk_storage_error"
+
+func (f fakeAPIKeys) AuthenticateAPIKey(_ context.Context, key string) (models.APIKey, error) {
+	if key == errStorageKey {
+		return models.APIKey{}, errors.New("storage error")
+	}
+	apiKey, ok := f[key]
+	if !ok {
+		return models.APIKey{}, models.ErrAPIKeyInvalid
+	}
+	return apiKey, nil
+}
+
+// newAPIKeyRouter returns the router with the authentication of the routes as the app sets it up,
+// the routes respond with the user ID of the request.
+func newAPIKeyRouter(keys *auth.KeyRing, apiKeys APIKeyAuthenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	userID := func(c *gin.Context) {
+		c.String(http.StatusOK, c.Request.Context().Value(models.UserIDKey).(uuid.UUID).String())
+	}
+	publicRoutes := router.Group("/")
+	publicRoutes.Use(AuthPublic(keys, apiKeys))
+	publicRoutes.POST("/", RequireScope(models.ScopeCreate), userID)
+	publicRoutes.GET("/:id", userID)
+	publicRoutes.POST("/api/user/register", TokenOnly(), userID)
+	publicRoutes.POST("/api/user/login", TokenOnly(), userID)
+
+	privateRoutes := router.Group("/")
+	privateRoutes.Use(AuthPrivate(keys, apiKeys))
+	privateRoutes.GET("/api/user/urls", RequireScope(models.ScopeRead), userID)
+	privateRoutes.DELETE("/api/user/urls", RequireScope(models.ScopeDelete), userID)
+	privateRoutes.POST("/api/user/keys", TokenOnly(), userID)
+	return router
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys, err := auth.GenerateKeyRing()
+	require.NoError(t, err)
+	tokenUserID := uuid.New()
+	token, err := keys.BuildUserJWTString(tokenUserID)
+	require.NoError(t, err)
+	keyUserID := uuid.New()
+	const (
+		readKey   = "shk_read_key"
+		createKey = "shk_create_key"
+	)
+	apiKeys := fakeAPIKeys{
+		readKey:   {ID: "read", Scopes: []string{models.ScopeRead}, UserID: keyUserID},
+		createKey: {ID: "create", Scopes: []string{models.ScopeCreate}, UserID: keyUserID},
+	}
+	router := newAPIKeyRouter(keys, apiKeys)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		headers        map[string]string
+		cookie         string
+		expectedStatus int
+		expectedUserID uuid.UUID
+	}{
+		{name: "key with the scope", method: http.MethodPost, path: "/",
+			headers: map[string]string{APIKeyHeader: createKey}, expectedStatus: http.StatusOK, expectedUserID: keyUserID},
+		{name: "bearer key with the scope", method: http.MethodGet, path: "/api/user/urls",
+			headers: map[string]string{"Authorization": "Bearer " + readKey}, expectedStatus: http.StatusOK, expectedUserID: keyUserID},
+		{name: "public route without the scope", method: http.MethodPost, path: "/",
+			headers: map[string]string{APIKeyHeader: readKey}, expectedStatus: http.StatusForbidden},
+		{name: "private route without the scope", method: http.MethodDelete, path: "/api/user/urls",
+			headers: map[string]string{APIKeyHeader: readKey}, expectedStatus: http.StatusForbidden},
+		{name: "key management with a key", method: http.MethodPost, path: "/api/user/keys",
+			headers: map[string]string{APIKeyHeader: createKey}, expectedStatus: http.StatusForbidden},
+		{name: "register with a key", method: http.MethodPost, path: "/api/user/register",
+			headers: map[string]string{APIKeyHeader: createKey}, expectedStatus: http.StatusForbidden},
+		{name: "login with a bearer key", method: http.MethodPost, path: "/api/user/login",
+			headers: map[string]string{"Authorization": "Bearer " + createKey}, expectedStatus: http.StatusForbidden},
+		{name: "invalid key on a public route", method: http.MethodGet, path: "/short",
+			headers: map[string]string{APIKeyHeader: "shk_revoked"}, cookie: token, expectedStatus: http.StatusUnauthorized},
+		{name: "invalid bearer key on a private route", method: http.MethodGet, path: "/api/user/urls",
+			headers: map[string]string{"Authorization": "Bearer shk_revoked"}, cookie: token, expectedStatus: http.StatusUnauthorized},
+		{name: "storage error", method: http.MethodGet, path: "/api/user/urls",
+			headers: map[string]string{APIKeyHeader: errStorageKey}, expectedStatus: http.StatusInternalServerError},
+		{name: "cookie on a public route with a scope", method: http.MethodPost, path: "/",
+			cookie: token, expectedStatus: http.StatusOK, expectedUserID: tokenUserID},
+		{name: "cookie on a private route with a scope", method: http.MethodGet, path: "/api/user/urls",
+			cookie: token, expectedStatus: http.StatusOK, expectedUserID: tokenUserID},
+		{name: "cookie on key management", method: http.MethodPost, path: "/api/user/keys",
+			cookie: token, expectedStatus: http.StatusOK, expectedUserID: tokenUserID},
+		{name: "cookie on login", method: http.MethodPost, path: "/api/user/login",
+			cookie: token, expectedStatus: http.StatusOK, expectedUserID: tokenUserID},
+		{name: "other bearer token falls back to the cookie", method: http.MethodGet, path: "/short",
+			headers: map[string]string{"Authorization": "Bearer " + token}, cookie: token,
+			expectedStatus: http.StatusOK, expectedUserID: tokenUserID},
+		{name: "other bearer token on a private route", method: http.MethodGet, path: "/api/user/urls",
+			headers: map[string]string{"Authorization": "Bearer gateway-token"}, cookie: token,
+			expectedStatus: http.StatusOK, expectedUserID: tokenUserID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: TokenCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedUserID != uuid.Nil {
+				assert.Equal(t, tt.expectedUserID.String(), w.Body.String())
+			}
+			if _, ok := tt.headers[APIKeyHeader]; ok {
+				// requests with a key don't get a cookie
+				assert.Empty(t, w.Result().Cookies())
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth_NewUserWithOtherBearerToken(t *testing.T) {
+	keys, err := auth.GenerateKeyRing()
+	require.NoError(t, err)
+	router := newAPIKeyRouter(keys, fakeAPIKeys{})
+
+	// a request with a bearer token of another issuer and without a cookie gets a new user as before API keys
+	req := httptest.NewRequest(http.MethodGet, "/short", nil)
+	req.Header.Set("Authorization", "Bearer gateway-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, TokenCookie, cookies[0].Name)
+	userID, err := keys.GetUserID(cookies[0].Value)
+	require.NoError(t, err)
+	assert.Equal(t, userID.String(), w.Body.String())
+}
@@ -13,18 +13,30 @@ import (
 // AuthPrivate provides authentication middleware for private routes.
 // It checks the user token and only allows access if the token is valid.
 // This middleware ensures that only authenticated users can access certain routes.
-func AuthPrivate() gin.HandlerFunc {
+// Tokens are verified with the keys of the ring, valid tokens close to expiration are reissued
+// to the same user in the cookie.
+// Requests with an API key are authenticated as the user the key is bound to,
+// requests with an invalid key are rejected with 401 Unauthorized.
+func AuthPrivate(keys *auth.KeyRing, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := requestAPIKey(c); key != "" {
+			if authAPIKey(c, apiKeys, key) {
+				c.Next()
+			}
+			return
+		}
 		var tokenString string
 		var err error
 		var userID uuid.UUID
-		tokenString, err = c.Cookie("user_token")
+		tokenString, err = c.Cookie(TokenCookie)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		}
-		userID, err = auth.GetUserID(tokenString)
+		userID, err = keys.GetUserID(tokenString)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			refreshToken(c, keys, tokenString)
 		}
 		ctx := context.WithValue(c.Request.Context(), models.UserIDKey, userID)
 		c.Request = c.Request.WithContext(ctx)
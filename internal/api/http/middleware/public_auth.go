@@ -14,24 +14,36 @@ import (
 // AuthPublic provides authentication middleware for public routes.
 // It manages user tokens, generating new tokens if necessary, and adds user ID to the context.
 // This middleware is useful for routes that require user identification but not strict authentication.
-func AuthPublic() gin.HandlerFunc {
+// Tokens are signed and verified with the keys of the ring, valid tokens close to expiration are reissued
+// to the same user in the cookie.
+// Requests with an API key are authenticated as the user the key is bound to without a cookie,
+// requests with an invalid key are rejected with 401 Unauthorized.
+func AuthPublic(keys *auth.KeyRing, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := requestAPIKey(c); key != "" {
+			if authAPIKey(c, apiKeys, key) {
+				c.Next()
+			}
+			return
+		}
 		var tokenString string
 		var err error
 		var userID uuid.UUID
 
-		tokenString, err = c.Cookie("user_token")
+		tokenString, err = c.Cookie(TokenCookie)
 		// если токен не найден в куке, то генерируем новый и добавляем его в куки
-		if err != nil || !auth.IsValidToken(tokenString) {
+		if err != nil || !keys.IsValidToken(tokenString) {
 			logrus.Info("Cookie not found or token in cookie not found")
-			tokenString, err = auth.BuildJWTString()
+			tokenString, err = keys.BuildJWTString()
 			if err != nil {
 				logrus.Errorf("error generating token: %v", err)
 				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error generating token"})
 			}
-			c.SetCookie("user_token", tokenString, 0, "/", "", false, true)
+			SetTokenCookie(c, tokenString)
+		} else {
+			tokenString = refreshToken(c, keys, tokenString)
 		}
-		userID, err = auth.GetUserID(tokenString)
+		userID, err = keys.GetUserID(tokenString)
 		if err != nil {
 			logrus.Error(err)
 			return
@@ -42,3 +54,25 @@ func AuthPublic() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// TokenCookie is the cookie keeping the JWT token of the user.
+const TokenCookie = "user_token"
+
+// SetTokenCookie sets the user_token cookie with the token.
+func SetTokenCookie(c *gin.Context, tokenString string) {
+	c.SetCookie(TokenCookie, tokenString, 0, "/", "", false, true)
+}
+
+// refreshToken reissues the valid token if it is close to expiration and sets the new token in the cookie.
+// It returns the token the request is authenticated with, the original token if it isn't refreshed.
+func refreshToken(c *gin.Context, keys *auth.KeyRing, tokenString string) string {
+	refreshed, err := keys.RefreshJWTString(tokenString)
+	if err != nil {
+		logrus.Errorf("error refreshing token: %v", err)
+		return tokenString
+	}
+	if refreshed != tokenString {
+		SetTokenCookie(c, refreshed)
+	}
+	return refreshed
+}
@@ -0,0 +1,35 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// CreateAPIKey creates an API key bound to the current user for a server-to-server client.
+// It expects a JSON object with the 'name' of the client and the 'scopes' of the key in the request body.
+// Returns the key with its ID, name, scopes and creation time as a JSON object with HTTP status 201 Created,
+// the 'key' itself is returned only in this response.
+// Sends HTTP status 400 Bad Request for malformed JSON, an empty name or unknown scopes,
+// or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) CreateAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request models.APIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON"})
+		return
+	}
+	key, err := h.service.CreateAPIKey(ctx, request)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyRequestInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
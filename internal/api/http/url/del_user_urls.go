@@ -2,15 +2,22 @@
 package url
 
 import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"net/http"
 )
 
+// DeleteJobsPath is the path of the status of the deletion jobs, the job ID is appended to it.
+const DeleteJobsPath = "/api/user/deletions/"
+
 // DelUserURLs marks specified URLs as deleted asynchronously.
 // Expects a JSON array of URL IDs to delete in the request body.
-// Acknowledges the deletion request with HTTP status 202 Accepted.
-// Returns HTTP status 400 Bad Request for malformed JSON input.
+// Acknowledges the deletion request with HTTP status 202 Accepted, the queued job as a JSON object
+// and the Location header of its status.
+// Returns HTTP status 400 Bad Request for malformed JSON input, 503 Service Unavailable
+// if the deletion queue is full, or HTTP status 500 Internal Server Error for other errors.
 func (h *Handlers) DelUserURLs(c *gin.Context) {
 	ctx := c.Request.Context()
 	var URLSToDel []string
@@ -19,9 +26,16 @@ func (h *Handlers) DelUserURLs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.service.AsyncDeleteUserURLs(ctx, URLSToDel); err != nil {
+	job, err := h.service.AsyncDeleteUserURLs(ctx, URLSToDel)
+	if err != nil {
+		if errors.Is(err, models.ErrDeleteQueueFull) {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.Status(http.StatusAccepted)
+	c.Header("Location", DeleteJobsPath+job.ID)
+	c.JSON(http.StatusAccepted, job)
 }
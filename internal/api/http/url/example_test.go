@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/api/http/middleware"
+	"github.com/DenisKhanov/shorterURL/internal/auth"
 	"net/http"
 	"net/http/httptest"
 
@@ -22,9 +23,10 @@ func ExampleHandlers_GetShortURL() {
 	router := gin.Default()
 
 	myHandler := Handlers{}
+	keys, _ := auth.GenerateKeyRing()
 
 	publicRoutes := router.Group("/")
-	publicRoutes.Use(middleware.AuthPublic())
+	publicRoutes.Use(middleware.AuthPublic(keys, nil))
 	publicRoutes.Use(middleware.LogrusLog())
 	publicRoutes.Use(middleware.GZIPCompress())
 	publicRoutes.POST("/", myHandler.GetShortURL)
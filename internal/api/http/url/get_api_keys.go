@@ -0,0 +1,20 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetAPIKeys returns the API keys of the current user without the keys themselves
+// as a JSON array with HTTP status 200 OK, an empty array if the user has no keys.
+// Sends HTTP status 500 Internal Server Error if the keys can't be read.
+func (h *Handlers) GetAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	keys, err := h.service.GetAPIKeys(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
@@ -2,6 +2,7 @@
 package url
 
 import (
+	"errors"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -10,8 +11,13 @@ import (
 
 // GetBatchShortURL converts multiple URLs to their shortened versions in batch.
 // Expects a JSON array of URL objects in the request body.
+// Each object may contain an optional 'alias' field with a custom short URL.
 // Returns a JSON array of objects containing original and shortened URLs.
-// On failure, sends HTTP status 500 Internal Server Error.
+// Objects may also limit the lifetime of the short URL with 'expires_at' or 'ttl' fields
+// and select its redirect code with the 'redirect_code' field.
+// All URLs are shortened on the short domain selected by the domain query parameter or the Host header.
+// Sends HTTP status 400 Bad Request for an invalid URL, alias, expiration, domain or redirect code, HTTP status 409 Conflict
+// if an alias is taken, and HTTP status 500 Internal Server Error on other failures.
 func (h *Handlers) GetBatchShortURL(c *gin.Context) {
 	ctx := c.Request.Context()
 	var batchURLRequests []models.URLRequest
@@ -26,9 +32,17 @@ func (h *Handlers) GetBatchShortURL(c *gin.Context) {
 			return
 		}
 	}
-	batchURLResponses, err := h.service.GetBatchShortURL(ctx, batchURLRequests)
+	batchURLResponses, err := h.service.GetBatchShortURL(ctx, requestDomain(c), batchURLRequests)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, models.ErrAliasInvalid), errors.Is(err, models.ErrExpirationInvalid),
+			errors.Is(err, models.ErrDomainInvalid), errors.Is(err, models.ErrRedirectCodeInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrAliasTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 	c.JSON(http.StatusCreated, batchURLResponses)
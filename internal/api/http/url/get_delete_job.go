@@ -0,0 +1,28 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetDeleteJob returns the status of a deletion job of the current user.
+// The job ID is expected as a URL parameter.
+// Returns the job as a JSON object with HTTP status 200 OK.
+// Sends HTTP status 404 Not Found if the job doesn't exist, has been forgotten or belongs to another user,
+// or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) GetDeleteJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	job, err := h.service.GetDeleteJob(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrDeleteJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
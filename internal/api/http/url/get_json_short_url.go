@@ -10,10 +10,11 @@ import (
 )
 
 // GetJSONShortURL converts a long URL to its shortened version using JSON input.
-// Expects a JSON object with a 'URL' field in the request body.
+// Expects a JSON object with a 'URL' field and optional 'alias', 'expires_at', 'ttl' and 'redirect_code' fields
+// in the request body, the short domain may be selected by the domain query parameter.
 // Returns a JSON object containing the shortened URL on success.
-// Sends HTTP status 400 Bad Request for malformed JSON or invalid URL,
-// or HTTP status 409 Conflict if the URL is already shortened.
+// Sends HTTP status 400 Bad Request for malformed JSON, invalid URL, alias, expiration, domain or redirect code,
+// or HTTP status 409 Conflict if the URL is already shortened or the alias is taken.
 func (h *Handlers) GetJSONShortURL(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -29,12 +30,17 @@ func (h *Handlers) GetJSONShortURL(c *gin.Context) {
 		return
 	}
 
-	result, err := h.service.GetShortURL(ctx, dataURL.URL)
+	expiration := models.URLRequest{ExpiresAt: dataURL.ExpiresAt, TTL: dataURL.TTL}.Expiration()
+	result, err := h.service.GetShortURL(ctx, requestDomain(c), dataURL.URL, dataURL.Alias, expiration, dataURL.RedirectCode)
 	if err != nil {
 		if errors.Is(err, models.ErrURLFound) {
 			c.JSON(http.StatusConflict, gin.H{"result": result})
 			return
 		}
+		if errors.Is(err, models.ErrAliasTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,21 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// jwksMaxAge is how long in seconds clients may cache the published keys.
+// A new key is added to the key ring before it is made active, so a cached set knows the key of new tokens
+// if the key ring is rotated in steps longer than this.
+const jwksMaxAge = "300"
+
+// GetJWKS method in the Handlers struct handles the HTTP request for the JSON Web Key Set of the public keys
+// verifying the tokens of users, so other services can verify the tokens by their kid header without a shared secret.
+// It responds with the set as a JSON object with HTTP status 200 OK; the set is empty if tokens are signed only with HS256 keys.
+// The response may be cached for jwksMaxAge seconds.
+func (h *Handlers) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age="+jwksMaxAge)
+	c.JSON(http.StatusOK, h.tokens.JWKS())
+}
@@ -3,28 +3,51 @@ package url
 
 import (
 	"errors"
+	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"time"
 )
 
+// permanentRedirectMaxAge is how long clients and proxies may cache a permanent redirect.
+// It is limited, so that changes and deletions of the short URL reach the clients eventually.
+const permanentRedirectMaxAge = 24 * time.Hour
+
 // GetOriginalURL retrieves the original URL from a shortened URL ID.
-// The shortened URL ID is expected as a URL parameter.
-// Redirects to the original URL using HTTP 307 Temporary Redirect.
-// Returns HTTP status 410 Gone if the URL is marked as deleted,
+// The shortened URL ID is expected as a URL parameter, it is resolved on the domain of the Host header.
+// Redirects to the original URL with the redirect code of the short URL and records the click
+// with the referrer, user agent and IP address of the client.
+// Permanent redirects (301, 308) may be cached for permanentRedirectMaxAge, temporary redirects (302, 307)
+// are not stored by clients, so every click reaches the service and is counted.
+// Returns HTTP status 410 Gone if the URL is marked as deleted or has expired,
 // or HTTP status 400 Bad Request for other errors.
 func (h *Handlers) GetOriginalURL(c *gin.Context) {
 	ctx := c.Request.Context()
 	shortURL := c.Param("id")
-	originURL, err := h.service.GetOriginalURL(ctx, shortURL)
+	client := models.ClickInfo{
+		Referrer:  c.Request.Referer(),
+		UserAgent: c.Request.UserAgent(),
+		ClientIP:  c.ClientIP(),
+	}
+	redirect, err := h.service.GetOriginalURL(ctx, c.Request.Host, shortURL, client)
 	if err != nil {
-		if errors.Is(err, models.ErrURLDeleted) {
+		if errors.Is(err, models.ErrURLDeleted) || errors.Is(err, models.ErrURLExpired) {
 			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	c.Header("Location", originURL)
-	c.Status(http.StatusTemporaryRedirect)
+	c.Header("Location", redirect.OriginalURL)
+	c.Header("Cache-Control", redirectCacheControl(redirect.Code))
+	c.Status(redirect.Code)
+}
+
+// redirectCacheControl returns the Cache-Control header of the redirect with the HTTP status code.
+func redirectCacheControl(code int) string {
+	if models.IsPermanentRedirect(code) {
+		return fmt.Sprintf("public, max-age=%d", int(permanentRedirectMaxAge.Seconds()))
+	}
+	return "no-store"
 }
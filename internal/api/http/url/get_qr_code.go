@@ -0,0 +1,41 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetQRCode returns the QR code image of the full shortened URL.
+// The shortened URL ID is expected as a URL parameter, it is resolved on the domain of the Host header.
+// The format (png or svg), size in pixels, margin in modules and error correction level (L, M, Q or H)
+// are selected by the query parameters, missing parameters take the default values.
+// The image doesn't change while the short URL exists, so it may be cached by clients.
+// Returns HTTP status 400 Bad Request if the parameters are invalid, 404 Not Found if the URL doesn't exist,
+// 410 Gone if the URL is marked as deleted or has expired, or 500 Internal Server Error for other errors.
+func (h *Handlers) GetQRCode(c *gin.Context) {
+	ctx := c.Request.Context()
+	request, err := requestQRCode(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	code, err := h.service.GetQRCode(ctx, c.Request.Host, c.Param("id"), request)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrQRCodeInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrOriginalURLNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrURLDeleted) || errors.Is(err, models.ErrURLExpired):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, code.ContentType, code.Data)
+}
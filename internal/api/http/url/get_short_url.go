@@ -10,9 +10,10 @@ import (
 )
 
 // GetShortURL converts a long URL to its shortened version.
-// It reads the raw URL from the request body.
+// It reads the raw URL from the request body, the short domain may be selected by the domain query parameter
+// and the redirect code by the redirect_code query parameter.
 // Returns the shortened URL on success with HTTP status 201 Created.
-// On failure, returns HTTP status 400 Bad Request for invalid input or URL format,
+// On failure, returns HTTP status 400 Bad Request for invalid input, URL format, domain or redirect code,
 // or HTTP status 409 Conflict if the URL is already shortened.
 func (h *Handlers) GetShortURL(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -28,7 +29,12 @@ func (h *Handlers) GetShortURL(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": errors.New("URL format isn't correct").Error()})
 		return
 	}
-	shortURL, err := h.service.GetShortURL(ctx, linkString)
+	redirectCode, err := requestRedirectCode(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	shortURL, err := h.service.GetShortURL(ctx, requestDomain(c), linkString, "", models.Expiration{}, redirectCode)
 	if err != nil {
 		if errors.Is(err, models.ErrURLFound) {
 			c.String(http.StatusConflict, shortURL)
@@ -0,0 +1,28 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetURLHistory returns the former original URLs of a short URL owned by the current user.
+// The short URL ID is expected as a URL parameter, user identification is from the context.
+// Returns a JSON array of the replaced original URLs with the times they were replaced, the latest first.
+// Sends HTTP status 404 Not Found if the URL doesn't exist or belongs to another user,
+// or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) GetURLHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+	history, err := h.service.GetURLHistory(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrURLNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
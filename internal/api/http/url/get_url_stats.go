@@ -0,0 +1,28 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetURLStats returns the click statistics of a short URL owned by the current user.
+// The short URL ID is expected as a URL parameter, user identification is from the context.
+// Returns a JSON object with the total and per-day numbers of clicks.
+// Sends HTTP status 404 Not Found if the URL doesn't exist or belongs to another user,
+// or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) GetURLStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	stats, err := h.service.GetURLStats(ctx, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrURLNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
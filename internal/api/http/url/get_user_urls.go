@@ -2,22 +2,54 @@
 package url
 
 import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 )
 
 //TODO добавить вывод статуса удаления URL
 
-// GetUserURLS retrieves all URLs associated with the current user.
-// Does not require any parameters; user identification is from the context.
-// Returns a JSON array of URLs associated with the user.
-// If no URLs are found, returns HTTP status 204 No Content.
+// NextCursorHeader is the response header with the cursor of the next page of the user URLs.
+const NextCursorHeader = "X-Next-Cursor"
+
+// GetUserURLS retrieves a page of URLs associated with the current user, user identification is from the context.
+// Optional query parameters: limit is the max number of URLs on the page, cursor is the value
+// of the X-Next-Cursor header of the previous page, sort is created_asc (default) or created_desc,
+// q is a case-insensitive substring of the original URLs to list.
+// Returns a JSON array of URLs and the X-Next-Cursor header if there is a next page.
+// If no URLs are found, returns HTTP status 204 No Content,
+// if the query parameters are invalid, returns HTTP status 400 Bad Request.
 func (h *Handlers) GetUserURLS(c *gin.Context) {
 	ctx := c.Request.Context()
-	allUserShortURLs, err := h.service.GetUserURLs(ctx)
+	request := models.UserURLsRequest{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Search: c.Query("q"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if request.Limit, err = strconv.Atoi(limit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number"})
+			return
+		}
+	}
+	page, err := h.service.GetUserURLs(ctx, request)
 	if err != nil {
+		if errors.Is(err, models.ErrPageQueryInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNoContent, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, allUserShortURLs)
+	if len(page.URLs) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if page.NextCursor != "" {
+		c.Header(NextCursorHeader, page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page.URLs)
 }
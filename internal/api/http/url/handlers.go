@@ -3,9 +3,15 @@ package url
 
 import (
 	"context"
+	"fmt"
+	"github.com/DenisKhanov/shorterURL/internal/auth"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	url2 "github.com/DenisKhanov/shorterURL/internal/services/url"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"strconv"
+	"time"
 )
 
 // Service defines the interface for URL shortening and retrieval operations.
@@ -15,44 +21,162 @@ import (
 type Service interface {
 	// GetStorageStatus checks the database connection or repository created.
 	GetStorageStatus(ctx context.Context) error
-	// GetShortURL takes original URL and returns its shortened version.
-	// If the URL has already been shortened, it returns the existing shortened URL.
-	// If the URL is new, it generates a new shortened URL.
+	// GetShortURL takes original URL, an optional custom alias, expiration and redirect code
+	// and returns its shortened version on the domain selected by the request.
+	// If the URL has already been shortened on the domain, it returns the existing shortened URL.
+	// If the URL is new, it uses the alias or generates a new shortened URL that expires as requested
+	// and is followed with the redirect code, 0 for the default code.
 	// Returns an error if the URL cannot be shortened or if any internal error occurs.
-	GetShortURL(ctx context.Context, url string) (string, error)
-	// GetOriginalURL takes a shortened URL and returns the original URL it points to.
-	// If the shortened URL does not exist or is invalid, an error is returned.
+	GetShortURL(ctx context.Context, domain models.RequestDomain, url, alias string, expiration models.Expiration,
+		redirectCode int) (string, error)
+	// GetOriginalURL takes a shortened URL requested on the host and returns the original URL it points to
+	// with the HTTP status code of the redirect.
+	// If the shortened URL does not exist, is invalid or belongs to another domain, an error is returned.
 	// Useful for redirecting shortened URLs to their original destinations.
-	GetOriginalURL(ctx context.Context, shortURL string) (string, error)
-	// GetBatchShortURL takes a slice of models.URLRequest objects, each containing a URL to be shortened,
-	// and returns a slice of models.URLResponse objects, each containing the original and shortened URL.
+	// Every successful call records a click with the client info for the statistics.
+	GetOriginalURL(ctx context.Context, host, shortURL string, client models.ClickInfo) (models.Redirect, error)
+	// GetQRCode renders the QR code image of the full shortened URL requested on the host
+	// with the format, size, margin and error correction level of the request.
+	// If the shortened URL does not exist, is deleted, expired or belongs to another domain, an error is returned.
+	GetQRCode(ctx context.Context, host, shortURL string, request models.QRRequest) (models.QRCode, error)
+	// GetBatchShortURL takes a slice of models.URLRequest objects, each containing a URL to be shortened
+	// on the domain selected by the request, and returns a slice of models.URLResponse objects,
+	// each containing the original and shortened URL.
 	// This method is intended for processing multiple URLs at once, improving efficiency for bulk operations.
 	// Returns an error if any of the URLs cannot be processed or if an internal error occurs.
-	GetBatchShortURL(ctx context.Context, batchURLRequests []models.URLRequest) ([]models.URLResponse, error)
-	// GetUserURLs takes a slice of models.URL objects for a specific user
-	GetUserURLs(ctx context.Context) ([]models.URL, error)
-	// AsyncDeleteUserURLs async runs requests to DB for mark user URLs as deleted
-	AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) error
+	GetBatchShortURL(ctx context.Context, domain models.RequestDomain, batchURLRequests []models.URLRequest) ([]models.URLResponse, error)
+	// GetUserURLs returns a page of the URLs of the user from the context ordered by creation time.
+	GetUserURLs(ctx context.Context, request models.UserURLsRequest) (models.UserURLsPage, error)
+	// AsyncDeleteUserURLs queues the deletion of the URLs of the user from the context
+	// and returns its job without waiting for the storage.
+	AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) (models.DeleteJob, error)
+	// GetDeleteJob returns the deletion job of the user from the context.
+	GetDeleteJob(ctx context.Context, jobID string) (models.DeleteJob, error)
 	// GetServiceStats retrieves the statistics of URLs and users from the service's repository.
 	GetServiceStats(ctx context.Context) (models.Stats, error)
+	// GetURLStats returns the click statistics of the short URL owned by the user from the context.
+	GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error)
+	// UpdateURL changes the original URL the short URL owned by the user from the context leads to
+	// and returns the updated URL. The replaced original URL is kept in the change history.
+	UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error)
+	// GetURLHistory returns the former original URLs of the short URL owned by the user from the context,
+	// the most recently replaced first.
+	GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error)
+	// RestoreURL clears the deleted flag of the short URL owned by the user from the context and returns the URL,
+	// if it has been deleted within the restore period.
+	RestoreURL(ctx context.Context, shortURL string) (models.URL, error)
+	// CreateAPIKey creates an API key with the scopes of the request bound to the user from the context
+	// and returns it with the key itself, which isn't stored.
+	CreateAPIKey(ctx context.Context, request models.APIKeyRequest) (models.NewAPIKey, error)
+	// GetAPIKeys returns the API keys of the user from the context ordered by creation time.
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// RevokeAPIKey removes the API key of the user from the context.
+	RevokeAPIKey(ctx context.Context, id string) error
+	// AuthenticateAPIKey returns the stored API key matching the key of a request.
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+	// Register creates an account with the credentials for the user from the context,
+	// or for a new user if the user from the context has an account already.
+	Register(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error)
+	// Login checks the credentials and returns the account,
+	// the links of the anonymous user from the context are claimed by the account.
+	Login(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error)
+}
+
+// TokenIssuer defines the interface for issuing the JWT tokens of the users of accounts
+// and publishing the public keys verifying the tokens.
+type TokenIssuer interface {
+	// BuildUserJWTString creates a token of the existing user.
+	BuildUserJWTString(userID uuid.UUID) (string, error)
+	// JWKS returns the public keys verifying the tokens by their kid.
+	JWKS() auth.JWKSet
 }
 
 // checking interface compliance at the compiler level
 var _ Service = (*url2.ShortURLServices)(nil)
+var _ TokenIssuer = (*auth.KeyRing)(nil)
 
 // Handlers is a struct that contains HTTP request handlers and a database connection pool.
 type Handlers struct {
 	service Service
+	tokens  TokenIssuer
 }
 
 // URLProcessing is a struct used for JSON processing in some of the handlers.
+// Alias is an optional custom short URL, ExpiresAt and TTL (in seconds) optionally limit its lifetime.
+// RedirectCode is the HTTP status code the short URL is followed with, 0 for the configured default.
 type URLProcessing struct {
+	URL          string     `json:"url"`
+	Alias        string     `json:"alias,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	TTL          int64      `json:"ttl,omitempty"`
+	RedirectCode int        `json:"redirect_code,omitempty"`
+}
+
+// URLUpdate is a struct used for JSON processing of the request changing the original URL of a short URL.
+type URLUpdate struct {
 	URL string `json:"url"`
 }
 
 // NewHandlers creates a new *Handlers instance with the provided service and database connection pool.
-func NewHandlers(service Service, subnetsStr string) *Handlers {
+// The tokens issue the tokens of the users logged in to accounts.
+func NewHandlers(service Service, tokens TokenIssuer, subnetsStr string) *Handlers {
 	return &Handlers{
 		service: service,
+		tokens:  tokens,
+	}
+}
+
+// DomainParam is the query parameter of the create requests selecting the short domain of the new links.
+const DomainParam = "domain"
+
+// requestDomain returns the short domain selected by the create request: the domain query parameter,
+// or the Host header the request has been sent to.
+func requestDomain(c *gin.Context) models.RequestDomain {
+	return models.RequestDomain{Domain: c.Query(DomainParam), Host: c.Request.Host}
+}
+
+// RedirectCodeParam is the query parameter of the text create request selecting the redirect code of the new link.
+const RedirectCodeParam = "redirect_code"
+
+// requestRedirectCode returns the redirect code selected by the redirect_code query parameter, 0 if it is missing.
+// Whether the code is a supported redirect is checked by the service.
+func requestRedirectCode(c *gin.Context) (int, error) {
+	param := c.Query(RedirectCodeParam)
+	if param == "" {
+		return 0, nil
+	}
+	code, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", models.ErrRedirectCodeInvalid, param)
+	}
+	return code, nil
+}
+
+// Query parameters of the QR code request.
+const (
+	QRFormatParam = "format"
+	QRSizeParam   = "size"
+	QRMarginParam = "margin"
+	QRLevelParam  = "level"
+)
+
+// requestQRCode returns the QR code image selected by the query parameters, missing parameters are left zero.
+// Whether the values are in range is checked by the service.
+func requestQRCode(c *gin.Context) (models.QRRequest, error) {
+	request := models.QRRequest{Format: c.Query(QRFormatParam), Level: c.Query(QRLevelParam)}
+	if param := c.Query(QRSizeParam); param != "" {
+		size, err := strconv.Atoi(param)
+		if err != nil {
+			return models.QRRequest{}, fmt.Errorf("%w: size %q", models.ErrQRCodeInvalid, param)
+		}
+		request.Size = size
+	}
+	if param := c.Query(QRMarginParam); param != "" {
+		margin, err := strconv.Atoi(param)
+		if err != nil {
+			return models.QRRequest{}, fmt.Errorf("%w: margin %q", models.ErrQRCodeInvalid, param)
+		}
+		request.Margin = &margin
 	}
+	return request, nil
 }
@@ -4,16 +4,22 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/api/http/middleware"
 	"github.com/DenisKhanov/shorterURL/internal/api/http/url/mocks"
+	"github.com/DenisKhanov/shorterURL/internal/auth"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestHandlers_GetShortURL(t *testing.T) {
@@ -21,6 +27,7 @@ func TestHandlers_GetShortURL(t *testing.T) {
 	tests := []struct {
 		name             string
 		inputURL         string
+		query            string
 		expectedShortURL string
 		expectedStatus   int
 		mockSetup        func(mockService *mocks.MockService)
@@ -31,7 +38,7 @@ func TestHandlers_GetShortURL(t *testing.T) {
 			expectedShortURL: "94UUE",
 			expectedStatus:   http.StatusCreated,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "http://original.url").Return("94UUE", nil).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, 0).Return("94UUE", nil).AnyTimes()
 			},
 		},
 		{
@@ -40,7 +47,7 @@ func TestHandlers_GetShortURL(t *testing.T) {
 			expectedShortURL: "{\"error\":\"URL format isn't correct\"}",
 			expectedStatus:   http.StatusBadRequest,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "original.url").Return("", nil).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "original.url", "", models.Expiration{}, 0).Return("", nil).AnyTimes()
 			},
 		}, {
 			name:             "POST service get error",
@@ -48,7 +55,7 @@ func TestHandlers_GetShortURL(t *testing.T) {
 			expectedShortURL: "{\"error\":\"some error\"}",
 			expectedStatus:   http.StatusBadRequest,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "http://original.url").Return("", errors.New("some error")).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, 0).Return("", errors.New("some error")).AnyTimes()
 			},
 		},
 		{
@@ -57,7 +64,48 @@ func TestHandlers_GetShortURL(t *testing.T) {
 			expectedShortURL: "",
 			expectedStatus:   http.StatusConflict,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "http://original.url").Return("", models.ErrURLFound).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, 0).Return("", models.ErrURLFound).AnyTimes()
+			},
+		},
+		{
+			name:             "POST URL on the short domain",
+			inputURL:         "http://original.url",
+			query:            "?domain=go.example.com",
+			expectedShortURL: "https://go.example.com/94UUE",
+			expectedStatus:   http.StatusCreated,
+			mockSetup: func(mockService *mocks.MockService) {
+				domain := models.RequestDomain{Domain: "go.example.com", Host: "example.com"}
+				mockService.EXPECT().GetShortURL(gomock.Any(), domain, "http://original.url", "", models.Expiration{}, 0).Return("https://go.example.com/94UUE", nil)
+			},
+		},
+		{
+			name:             "POST URL with redirect code",
+			inputURL:         "http://original.url",
+			query:            "?redirect_code=308",
+			expectedShortURL: "http://localhost:8080/94UUE",
+			expectedStatus:   http.StatusCreated,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, http.StatusPermanentRedirect).
+					Return("http://localhost:8080/94UUE", nil)
+			},
+		},
+		{
+			name:             "POST URL with malformed redirect code",
+			inputURL:         "http://original.url",
+			query:            "?redirect_code=permanent",
+			expectedShortURL: "{\"error\":\"redirect code is invalid: \\\"permanent\\\"\"}",
+			expectedStatus:   http.StatusBadRequest,
+			mockSetup:        func(mockService *mocks.MockService) {},
+		},
+		{
+			name:             "POST URL on the unknown short domain",
+			inputURL:         "http://original.url",
+			query:            "?domain=unknown.com",
+			expectedShortURL: "{\"error\":\"short domain is not configured: unknown.com\"}",
+			expectedStatus:   http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, 0).
+					Return("", fmt.Errorf("%w: unknown.com", models.ErrDomainInvalid))
 			},
 		},
 	}
@@ -75,7 +123,7 @@ func TestHandlers_GetShortURL(t *testing.T) {
 			r.POST("/", handler.GetShortURL)
 
 			// Создание HTTP запроса и рекордера
-			req := httptest.NewRequest("POST", "/", bytes.NewBufferString(tt.inputURL))
+			req := httptest.NewRequest("POST", "/"+tt.query, bytes.NewBufferString(tt.inputURL))
 			w := httptest.NewRecorder()
 
 			// Выполнение запроса через Gin
@@ -89,19 +137,43 @@ func TestHandlers_GetShortURL(t *testing.T) {
 
 func TestHandlers_GetOriginalURL(t *testing.T) {
 	tests := []struct {
-		name           string
-		shortURL       string
-		expectedStatus int
-		expectedURL    string
-		mockSetup      func(mockService *mocks.MockService)
+		name                 string
+		shortURL             string
+		expectedStatus       int
+		expectedURL          string
+		expectedCacheControl string
+		mockSetup            func(mockService *mocks.MockService)
 	}{
 		{
-			name:           "GET valid shortURL",
-			shortURL:       "/94UUE",
-			expectedStatus: http.StatusTemporaryRedirect,
-			expectedURL:    "http://original.url",
+			name:                 "GET valid shortURL",
+			shortURL:             "/94UUE",
+			expectedStatus:       http.StatusTemporaryRedirect,
+			expectedURL:          "http://original.url",
+			expectedCacheControl: "no-store",
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any(), "94UUE", gomock.Any()).Return(models.Redirect{OriginalURL: "http://original.url", Code: http.StatusTemporaryRedirect}, nil).AnyTimes()
+			},
+		},
+		{
+			name:                 "GET permanent redirect",
+			shortURL:             "/94UUE",
+			expectedStatus:       http.StatusMovedPermanently,
+			expectedURL:          "http://original.url",
+			expectedCacheControl: "public, max-age=86400",
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any(), "94UUE", gomock.Any()).
+					Return(models.Redirect{OriginalURL: "http://original.url", Code: http.StatusMovedPermanently}, nil)
+			},
+		},
+		{
+			name:                 "GET found redirect",
+			shortURL:             "/94UUE",
+			expectedStatus:       http.StatusFound,
+			expectedURL:          "http://original.url",
+			expectedCacheControl: "no-store",
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetOriginalURL(gomock.Any(), "94UUE").Return("http://original.url", nil).AnyTimes()
+				mockService.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any(), "94UUE", gomock.Any()).
+					Return(models.Redirect{OriginalURL: "http://original.url", Code: http.StatusFound}, nil)
 			},
 		},
 		{
@@ -110,7 +182,7 @@ func TestHandlers_GetOriginalURL(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedURL:    "",
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetOriginalURL(gomock.Any(), "94UUE").Return("", errors.New("some error")).AnyTimes()
+				mockService.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any(), "94UUE", gomock.Any()).Return(models.Redirect{}, errors.New("some error")).AnyTimes()
 			},
 		},
 		{
@@ -119,7 +191,16 @@ func TestHandlers_GetOriginalURL(t *testing.T) {
 			expectedStatus: http.StatusGone,
 			expectedURL:    "",
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetOriginalURL(gomock.Any(), "94UUE").Return("", models.ErrURLDeleted).AnyTimes()
+				mockService.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any(), "94UUE", gomock.Any()).Return(models.Redirect{}, models.ErrURLDeleted).AnyTimes()
+			},
+		},
+		{
+			name:           "GET service get error models.ErrURLExpired",
+			shortURL:       "/94UUE",
+			expectedStatus: http.StatusGone,
+			expectedURL:    "",
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetOriginalURL(gomock.Any(), gomock.Any(), "94UUE", gomock.Any()).Return(models.Redirect{}, models.ErrURLExpired).AnyTimes()
 			},
 		},
 	}
@@ -146,6 +227,111 @@ func TestHandlers_GetOriginalURL(t *testing.T) {
 			// Проверки
 			assert.Equal(t, tt.expectedStatus, w.Code)
 			assert.Equal(t, tt.expectedURL, w.Header().Get("Location"))
+			assert.Equal(t, tt.expectedCacheControl, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestHandlers_GetQRCode(t *testing.T) {
+	margin := 0
+	tests := []struct {
+		name                string
+		query               string
+		expectedStatus      int
+		expectedBody        string
+		expectedContentType string
+		mockSetup           func(mockService *mocks.MockService)
+	}{
+		{
+			name:                "default image",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        "png data",
+			expectedContentType: "image/png",
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetQRCode(gomock.Any(), "example.com", "short", models.QRRequest{}).
+					Return(models.QRCode{ContentType: "image/png", Data: []byte("png data")}, nil)
+			},
+		},
+		{
+			name:                "query parameters",
+			query:               "?format=svg&size=512&margin=0&level=H",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        "<svg/>",
+			expectedContentType: "image/svg+xml",
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetQRCode(gomock.Any(), "example.com", "short",
+					models.QRRequest{Format: "svg", Size: 512, Margin: &margin, Level: "H"}).
+					Return(models.QRCode{ContentType: "image/svg+xml", Data: []byte("<svg/>")}, nil)
+			},
+		},
+		{
+			name:           "malformed size",
+			query:          "?size=big",
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(mockService *mocks.MockService) {},
+		},
+		{
+			name:           "malformed margin",
+			query:          "?margin=none",
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(mockService *mocks.MockService) {},
+		},
+		{
+			name:           "invalid parameters",
+			query:          "?format=gif",
+			expectedStatus: http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetQRCode(gomock.Any(), "example.com", "short", models.QRRequest{Format: "gif"}).
+					Return(models.QRCode{}, fmt.Errorf("%w: format", models.ErrQRCodeInvalid))
+			},
+		},
+		{
+			name:           "URL not found",
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetQRCode(gomock.Any(), "example.com", "short", models.QRRequest{}).
+					Return(models.QRCode{}, models.ErrOriginalURLNotFound)
+			},
+		},
+		{
+			name:           "URL expired",
+			expectedStatus: http.StatusGone,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetQRCode(gomock.Any(), "example.com", "short", models.QRRequest{}).
+					Return(models.QRCode{}, models.ErrURLExpired)
+			},
+		},
+		{
+			name:           "service error",
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetQRCode(gomock.Any(), "example.com", "short", models.QRRequest{}).
+					Return(models.QRCode{}, errors.New("encoding error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.GET("/:id/qr", handler.GetQRCode)
+
+			req := httptest.NewRequest("GET", "/short/qr"+tt.query, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+				assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+			}
 		})
 	}
 }
@@ -166,7 +352,7 @@ func TestHandlers_GetJSONShortURL(t *testing.T) {
 			expectedJSON:   `{"result": "http://localhost:8080/94UUE"}`,
 			expectedStatus: http.StatusCreated,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "http://original.url").Return("http://localhost:8080/94UUE", nil).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, 0).Return("http://localhost:8080/94UUE", nil).AnyTimes()
 			},
 		},
 		{
@@ -176,7 +362,7 @@ func TestHandlers_GetJSONShortURL(t *testing.T) {
 			expectedStatus: http.StatusConflict,
 			expectedError:  models.ErrURLFound,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "http://original.url").Return("http://localhost:8080/94UUE", models.ErrURLFound).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, 0).Return("http://localhost:8080/94UUE", models.ErrURLFound).AnyTimes()
 			},
 		},
 		{
@@ -186,7 +372,7 @@ func TestHandlers_GetJSONShortURL(t *testing.T) {
 			expectedStatus: http.StatusConflict,
 			expectedError:  models.ErrURLFound,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "http://original.url").Return("http://localhost:8080/94UUE", models.ErrURLFound).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, 0).Return("http://localhost:8080/94UUE", models.ErrURLFound).AnyTimes()
 			},
 		},
 		{
@@ -195,7 +381,73 @@ func TestHandlers_GetJSONShortURL(t *testing.T) {
 			expectedJSON:   ``,
 			expectedStatus: http.StatusBadRequest,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetShortURL(gomock.Any(), "invalid-url").Return("", nil).AnyTimes()
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "invalid-url", "", models.Expiration{}, 0).Return("", nil).AnyTimes()
+			},
+		},
+		{
+			name:           "POST Valid URL with redirect code",
+			inputJSON:      `{"url": "http://original.url", "redirect_code": 301}`,
+			expectedJSON:   `{"result": "http://localhost:8080/94UUE"}`,
+			expectedStatus: http.StatusCreated,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, http.StatusMovedPermanently).
+					Return("http://localhost:8080/94UUE", nil)
+			},
+		},
+		{
+			name:           "POST URL with unsupported redirect code",
+			inputJSON:      `{"url": "http://original.url", "redirect_code": 303}`,
+			expectedJSON:   `{"error": "redirect code is invalid: 303, must be 301, 302, 307 or 308"}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{}, http.StatusSeeOther).
+					Return("", fmt.Errorf("%w: 303, must be 301, 302, 307 or 308", models.ErrRedirectCodeInvalid))
+			},
+		},
+		{
+			name:           "POST Valid URL with custom alias",
+			inputJSON:      `{"url": "http://original.url", "alias": "my-link"}`,
+			expectedJSON:   `{"result": "http://localhost:8080/my-link"}`,
+			expectedStatus: http.StatusCreated,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "my-link", models.Expiration{}, 0).Return("http://localhost:8080/my-link", nil).AnyTimes()
+			},
+		},
+		{
+			name:           "POST Valid URL with TTL",
+			inputJSON:      `{"url": "http://original.url", "ttl": 60}`,
+			expectedJSON:   `{"result": "http://localhost:8080/94UUE"}`,
+			expectedStatus: http.StatusCreated,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{TTL: time.Minute}, 0).Return("http://localhost:8080/94UUE", nil).AnyTimes()
+			},
+		},
+		{
+			name:           "POST Valid URL with expiration in the past",
+			inputJSON:      `{"url": "http://original.url", "expires_at": "2020-01-01T00:00:00Z"}`,
+			expectedJSON:   `{"error": "expiration is invalid"}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				expiresAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "", models.Expiration{ExpiresAt: expiresAt}, 0).Return("", models.ErrExpirationInvalid).AnyTimes()
+			},
+		},
+		{
+			name:           "POST Valid URL with taken alias",
+			inputJSON:      `{"url": "http://original.url", "alias": "my-link"}`,
+			expectedJSON:   `{"error": "alias is already taken"}`,
+			expectedStatus: http.StatusConflict,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "my-link", models.Expiration{}, 0).Return("", models.ErrAliasTaken).AnyTimes()
+			},
+		},
+		{
+			name:           "POST Valid URL with invalid alias",
+			inputJSON:      `{"url": "http://original.url", "alias": "ping"}`,
+			expectedJSON:   `{"error": "alias is invalid"}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetShortURL(gomock.Any(), gomock.Any(), "http://original.url", "ping", models.Expiration{}, 0).Return("", models.ErrAliasInvalid).AnyTimes()
 			},
 		},
 		{
@@ -250,7 +502,7 @@ func TestHandlers_GetBatchShortURL(t *testing.T) {
 			expectedJSON:   `[{"correlation_id": "id1", "short_url": "http://localhost:8080/short1"}, {"correlation_id": "id2", "short_url": "http://localhost:8080/short2"}]`,
 			expectedStatus: http.StatusCreated,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetBatchShortURL(gomock.Any(), gomock.Any()).Return([]models.URLResponse{
+				mockService.EXPECT().GetBatchShortURL(gomock.Any(), gomock.Any(), gomock.Any()).Return([]models.URLResponse{
 					{CorrelationID: "id1", ShortURL: "http://localhost:8080/short1"},
 					{CorrelationID: "id2", ShortURL: "http://localhost:8080/short2"},
 				}, nil).AnyTimes()
@@ -269,7 +521,7 @@ func TestHandlers_GetBatchShortURL(t *testing.T) {
 			inputJSON:      `[{"correlation_id": "id1", "original_url": "http://original1.url"}, {"correlation_id": "id2", "original_url": "http://original2.url"}]`,
 			expectedStatus: http.StatusInternalServerError,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetBatchShortURL(gomock.Any(), gomock.Any()).Return(nil, errors.New("service error")).AnyTimes()
+				mockService.EXPECT().GetBatchShortURL(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("service error")).AnyTimes()
 			},
 		},
 	}
@@ -306,28 +558,67 @@ func TestHandlers_GetBatchShortURL(t *testing.T) {
 
 func TestHandlers_GetUserURLS(t *testing.T) {
 	tests := []struct {
-		name           string
-		expectedJSON   string
-		expectedStatus int
-		mockSetup      func(mockService *mocks.MockService)
+		name               string
+		query              string
+		expectedJSON       string
+		expectedStatus     int
+		expectedNextCursor string
+		mockSetup          func(mockService *mocks.MockService)
 	}{
 		{
 			name:           "User has URLs",
 			expectedJSON:   `[{"original_url": "http://original1.url", "short_url": "http://localhost:8080/short1"}, {"original_url": "http://original2.url", "short_url": "http://localhost:8080/short2"}]`,
 			expectedStatus: http.StatusOK,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetUserURLS(gomock.Any()).Return([]models.URL{
+				mockService.EXPECT().GetUserURLs(gomock.Any(), models.UserURLsRequest{}).Return(models.UserURLsPage{URLs: []models.URL{
 					{OriginalURL: "http://original1.url", ShortURL: "http://localhost:8080/short1"},
 					{OriginalURL: "http://original2.url", ShortURL: "http://localhost:8080/short2"},
+				}}, nil).AnyTimes()
+			},
+		},
+		{
+			name:               "Page with next cursor",
+			query:              "?limit=1&cursor=abc&sort=created_desc&q=example",
+			expectedJSON:       `[{"original_url": "http://example.url", "short_url": "http://localhost:8080/short1"}]`,
+			expectedStatus:     http.StatusOK,
+			expectedNextCursor: "def",
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUserURLs(gomock.Any(), models.UserURLsRequest{
+					Limit: 1, Cursor: "abc", Sort: models.SortCreatedDesc, Search: "example",
+				}).Return(models.UserURLsPage{
+					URLs:       []models.URL{{OriginalURL: "http://example.url", ShortURL: "http://localhost:8080/short1"}},
+					NextCursor: "def",
 				}, nil).AnyTimes()
 			},
 		},
+		{
+			name:           "Limit isn't a number",
+			query:          "?limit=ten",
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(mockService *mocks.MockService) {},
+		},
+		{
+			name:           "Invalid page query",
+			query:          "?sort=random",
+			expectedStatus: http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUserURLs(gomock.Any(), gomock.Any()).Return(models.UserURLsPage{}, models.ErrPageQueryInvalid).AnyTimes()
+			},
+		},
+		{
+			name:           "Empty page",
+			query:          "?q=unknown",
+			expectedStatus: http.StatusNoContent,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUserURLs(gomock.Any(), gomock.Any()).Return(models.UserURLsPage{}, nil).AnyTimes()
+			},
+		},
 		{
 			name:           "User has no URLs",
 			expectedJSON:   "",
 			expectedStatus: http.StatusNoContent,
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().GetUserURLS(gomock.Any()).Return(nil, errors.New("any error")).AnyTimes()
+				mockService.EXPECT().GetUserURLs(gomock.Any(), gomock.Any()).Return(models.UserURLsPage{}, errors.New("any error")).AnyTimes()
 			},
 		},
 	}
@@ -345,13 +636,14 @@ func TestHandlers_GetUserURLS(t *testing.T) {
 			r.GET("/api/user-urls", handler.GetUserURLS)
 
 			// Создание HTTP запроса и рекордера
-			req := httptest.NewRequest("GET", "/api/user-urls", nil)
+			req := httptest.NewRequest("GET", "/api/user-urls"+tt.query, nil)
 			w := httptest.NewRecorder()
 
 			// Выполнение запроса через Gin
 			r.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedNextCursor, w.Header().Get(NextCursorHeader))
 			if tt.expectedJSON != "" {
 				var actual, expected []models.URL
 				json.Unmarshal(w.Body.Bytes(), &actual)
@@ -363,18 +655,24 @@ func TestHandlers_GetUserURLS(t *testing.T) {
 }
 
 func TestHandlers_DelUserURLS(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	job := models.DeleteJob{ID: "job1", Status: models.DeleteJobQueued, URLs: 2, CreatedAt: createdAt}
 	tests := []struct {
-		name           string
-		inputJSON      string
-		expectedStatus int
-		mockSetup      func(mockService *mocks.MockService)
+		name             string
+		inputJSON        string
+		expectedStatus   int
+		expectedJSON     string
+		expectedLocation string
+		mockSetup        func(mockService *mocks.MockService)
 	}{
 		{
-			name:           "Valid JSON Request",
-			inputJSON:      `["id1", "id2"]`,
-			expectedStatus: http.StatusAccepted,
+			name:             "Valid JSON Request",
+			inputJSON:        `["id1", "id2"]`,
+			expectedStatus:   http.StatusAccepted,
+			expectedJSON:     `{"job_id":"job1","status":"queued","urls":2,"created_at":"2024-01-02T03:04:05Z"}`,
+			expectedLocation: "/api/user/deletions/job1",
 			mockSetup: func(mockService *mocks.MockService) {
-				mockService.EXPECT().AsyncDeleteUserURLs(gomock.Any(), []string{"id1", "id2"}).AnyTimes()
+				mockService.EXPECT().AsyncDeleteUserURLs(gomock.Any(), []string{"id1", "id2"}).Return(job, nil)
 			},
 		},
 		{
@@ -385,6 +683,22 @@ func TestHandlers_DelUserURLS(t *testing.T) {
 				// В этом случае mockService не должен вызывать AsyncDeleteUserURLs
 			},
 		},
+		{
+			name:           "Queue is full",
+			inputJSON:      `["id1"]`,
+			expectedStatus: http.StatusServiceUnavailable,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().AsyncDeleteUserURLs(gomock.Any(), []string{"id1"}).Return(models.DeleteJob{}, models.ErrDeleteQueueFull)
+			},
+		},
+		{
+			name:           "Service error",
+			inputJSON:      `["id1"]`,
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().AsyncDeleteUserURLs(gomock.Any(), []string{"id1"}).Return(models.DeleteJob{}, errors.New("invalid user context"))
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -406,8 +720,69 @@ func TestHandlers_DelUserURLS(t *testing.T) {
 			// Выполнение запроса через Gin
 			r.ServeHTTP(w, req)
 
-			// Проверки остаются теми же
 			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			}
+			assert.Equal(t, tt.expectedLocation, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestHandlers_GetDeleteJob(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	finishedAt := createdAt.Add(time.Second)
+	tests := []struct {
+		name           string
+		expectedJSON   string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "job found",
+			expectedJSON:   `{"job_id":"job1","status":"failed","urls":1,"created_at":"2024-01-02T03:04:05Z","finished_at":"2024-01-02T03:04:06Z","error":"storage error"}`,
+			expectedStatus: http.StatusOK,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetDeleteJob(gomock.Any(), "job1").Return(models.DeleteJob{
+					ID: "job1", Status: models.DeleteJobFailed, URLs: 1, CreatedAt: createdAt, FinishedAt: &finishedAt, Error: "storage error",
+				}, nil)
+			},
+		},
+		{
+			name:           "job not found",
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetDeleteJob(gomock.Any(), "job1").Return(models.DeleteJob{}, models.ErrDeleteJobNotFound)
+			},
+		},
+		{
+			name:           "service error",
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetDeleteJob(gomock.Any(), "job1").Return(models.DeleteJob{}, errors.New("invalid user context"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.GET("/api/user/deletions/:id", handler.GetDeleteJob)
+
+			req := httptest.NewRequest("GET", "/api/user/deletions/job1", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			}
 		})
 	}
 }
@@ -497,3 +872,663 @@ func TestHandlers_MiddlewareCompress(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlers_MiddlewareAuthRefresh(t *testing.T) {
+	const secret = "secret-of-the-test-signing-key-32"
+	keys, err := auth.NewSecretKeyRing(secret)
+	require.NoError(t, err)
+	userID := uuid.New()
+	// token signs a token of the user expiring in the time left
+	token := func(left time.Duration) string {
+		claims := auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(left))},
+			UserID:           userID,
+			AuthTime:         jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		}
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return tokenString
+	}
+	tests := []struct {
+		name          string
+		middleware    gin.HandlerFunc
+		token         string
+		wantRefreshed bool
+	}{
+		{name: "public fresh token", middleware: middleware.AuthPublic(keys, nil), token: token(auth.TokenExp)},
+		{name: "public token close to expiration", middleware: middleware.AuthPublic(keys, nil), token: token(time.Minute), wantRefreshed: true},
+		{name: "private fresh token", middleware: middleware.AuthPrivate(keys, nil), token: token(auth.TokenExp)},
+		{name: "private token close to expiration", middleware: middleware.AuthPrivate(keys, nil), token: token(time.Minute), wantRefreshed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(tt.middleware)
+			var gotUserID interface{}
+			r.GET("/test", func(c *gin.Context) {
+				gotUserID = c.Request.Context().Value(models.UserIDKey)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.AddCookie(&http.Cookie{Name: "user_token", Value: tt.token})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, userID, gotUserID)
+			cookies := w.Result().Cookies()
+			if !tt.wantRefreshed {
+				assert.Empty(t, cookies)
+				return
+			}
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "user_token", cookies[0].Name)
+			assert.NotEqual(t, tt.token, cookies[0].Value)
+			refreshedUserID, err := keys.GetUserID(cookies[0].Value)
+			require.NoError(t, err)
+			assert.Equal(t, userID, refreshedUserID)
+		})
+	}
+}
+
+func TestHandlers_GetURLStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		expectedJSON   string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "URL has clicks",
+			expectedJSON:   `{"short_url":"http://localhost:8080/94UUE","total_clicks":3,"daily":[{"date":"2024-01-01","clicks":1},{"date":"2024-01-02","clicks":2}]}`,
+			expectedStatus: http.StatusOK,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetURLStats(gomock.Any(), "94UUE").Return(models.URLStats{
+					ShortURL:    "http://localhost:8080/94UUE",
+					TotalClicks: 3,
+					Daily: []models.DailyClicks{
+						{Date: "2024-01-01", Clicks: 1},
+						{Date: "2024-01-02", Clicks: 2},
+					},
+				}, nil)
+			},
+		},
+		{
+			name:           "URL not found",
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetURLStats(gomock.Any(), "94UUE").Return(models.URLStats{}, models.ErrURLNotFound)
+			},
+		},
+		{
+			name:           "Service error",
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetURLStats(gomock.Any(), "94UUE").Return(models.URLStats{}, errors.New("any error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.GET("/api/user/urls/:id/stats", handler.GetURLStats)
+
+			req := httptest.NewRequest("GET", "/api/user/urls/94UUE/stats", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_UpdateURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedJSON   string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "URL updated",
+			body:           `{"url":"http://new.url"}`,
+			expectedJSON:   `{"short_url":"http://localhost:8080/94UUE","original_url":"http://new.url"}`,
+			expectedStatus: http.StatusOK,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().UpdateURL(gomock.Any(), "94UUE", "http://new.url").
+					Return(models.URL{ShortURL: "http://localhost:8080/94UUE", OriginalURL: "http://new.url"}, nil)
+			},
+		},
+		{
+			name:           "Invalid URL",
+			body:           `{"url":"new.url"}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(mockService *mocks.MockService) {},
+		},
+		{
+			name:           "Malformed JSON",
+			body:           `{"url":`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(mockService *mocks.MockService) {},
+		},
+		{
+			name:           "URL not found",
+			body:           `{"url":"http://new.url"}`,
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().UpdateURL(gomock.Any(), "94UUE", "http://new.url").Return(models.URL{}, models.ErrURLNotFound)
+			},
+		},
+		{
+			name:           "URL deleted",
+			body:           `{"url":"http://new.url"}`,
+			expectedStatus: http.StatusGone,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().UpdateURL(gomock.Any(), "94UUE", "http://new.url").Return(models.URL{}, models.ErrURLDeleted)
+			},
+		},
+		{
+			name:           "Original URL taken",
+			body:           `{"url":"http://new.url"}`,
+			expectedStatus: http.StatusConflict,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().UpdateURL(gomock.Any(), "94UUE", "http://new.url").Return(models.URL{}, models.ErrOriginalURLTaken)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.PATCH("/api/user/urls/:id", handler.UpdateURL)
+
+			req := httptest.NewRequest("PATCH", "/api/user/urls/94UUE", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_GetURLHistory(t *testing.T) {
+	changedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name           string
+		expectedJSON   string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "URL has history",
+			expectedJSON:   `[{"original_url":"http://old.url","changed_at":"2024-01-02T03:04:05Z"}]`,
+			expectedStatus: http.StatusOK,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetURLHistory(gomock.Any(), "94UUE").
+					Return([]models.URLChange{{OriginalURL: "http://old.url", ChangedAt: changedAt}}, nil)
+			},
+		},
+		{
+			name:           "URL not found",
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetURLHistory(gomock.Any(), "94UUE").Return(nil, models.ErrURLNotFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.GET("/api/user/urls/:id/history", handler.GetURLHistory)
+
+			req := httptest.NewRequest("GET", "/api/user/urls/94UUE/history", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_RestoreURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		expectedJSON   string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "URL restored",
+			expectedJSON:   `{"short_url":"http://localhost:8080/94UUE","original_url":"http://original.url"}`,
+			expectedStatus: http.StatusOK,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().RestoreURL(gomock.Any(), "94UUE").
+					Return(models.URL{ShortURL: "http://localhost:8080/94UUE", OriginalURL: "http://original.url"}, nil)
+			},
+		},
+		{
+			name:           "URL not found",
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().RestoreURL(gomock.Any(), "94UUE").Return(models.URL{}, models.ErrURLNotFound)
+			},
+		},
+		{
+			name:           "restore period is over",
+			expectedStatus: http.StatusGone,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().RestoreURL(gomock.Any(), "94UUE").Return(models.URL{}, models.ErrRestorePeriodOver)
+			},
+		},
+		{
+			name:           "storage error",
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().RestoreURL(gomock.Any(), "94UUE").Return(models.URL{}, errors.New("storage error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.POST("/api/user/urls/:id/restore", handler.RestoreURL)
+
+			req := httptest.NewRequest("POST", "/api/user/urls/94UUE/restore", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_CreateAPIKey(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name           string
+		body           string
+		expectedJSON   string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "key created",
+			body:           `{"name":"backend","scopes":["create","read"]}`,
+			expectedJSON:   `{"id":"0123456789abcdef","name":"backend","scopes":["create","read"],"created_at":"2024-01-02T03:04:05Z","key":"shk_0123456789abcdef_secret"}`,
+			expectedStatus: http.StatusCreated,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().CreateAPIKey(gomock.Any(), models.APIKeyRequest{Name: "backend", Scopes: []string{"create", "read"}}).
+					Return(models.NewAPIKey{
+						APIKey: models.APIKey{ID: "0123456789abcdef", Name: "backend", Scopes: []string{"create", "read"},
+							CreatedAt: createdAt, UserID: uuid.New(), Hash: "hash"},
+						Key: "shk_0123456789abcdef_secret",
+					}, nil)
+			},
+		},
+		{
+			name:           "malformed JSON",
+			body:           `{"name":`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(mockService *mocks.MockService) {},
+		},
+		{
+			name:           "unknown scope",
+			body:           `{"name":"backend","scopes":["admin"]}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).
+					Return(models.NewAPIKey{}, fmt.Errorf("%w: unknown scope", models.ErrAPIKeyRequestInvalid))
+			},
+		},
+		{
+			name:           "storage error",
+			body:           `{"name":"backend","scopes":["read"]}`,
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Return(models.NewAPIKey{}, errors.New("storage error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.POST("/api/user/keys", handler.CreateAPIKey)
+
+			req := httptest.NewRequest("POST", "/api/user/keys", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON != "" {
+				assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlers_RevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "key revoked",
+			expectedStatus: http.StatusNoContent,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().RevokeAPIKey(gomock.Any(), "0123456789abcdef").Return(nil)
+			},
+		},
+		{
+			name:           "key not found",
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().RevokeAPIKey(gomock.Any(), "0123456789abcdef").Return(models.ErrAPIKeyNotFound)
+			},
+		},
+		{
+			name:           "storage error",
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().RevokeAPIKey(gomock.Any(), "0123456789abcdef").Return(errors.New("storage error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService}
+			r.DELETE("/api/user/keys/:id", handler.RevokeAPIKey)
+
+			req := httptest.NewRequest("DELETE", "/api/user/keys/0123456789abcdef", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestHandlers_MiddlewareAPIKey(t *testing.T) {
+	keys, err := auth.GenerateKeyRing()
+	require.NoError(t, err)
+	userID := uuid.New()
+	readKey := models.APIKey{ID: "0123456789abcdef", Scopes: []string{models.ScopeRead}, UserID: userID}
+	tests := []struct {
+		name           string
+		private        bool
+		route          []gin.HandlerFunc
+		header         string
+		value          string
+		authResult     error
+		expectedStatus int
+	}{
+		{name: "key in X-API-Key header", header: "X-API-Key", value: "shk_key", expectedStatus: http.StatusOK},
+		{name: "key in Authorization header", header: "Authorization", value: "Bearer shk_key", expectedStatus: http.StatusOK},
+		{name: "private route", private: true, header: "X-API-Key", value: "shk_key", expectedStatus: http.StatusOK},
+		{name: "key with the scope", route: []gin.HandlerFunc{middleware.RequireScope(models.ScopeRead)},
+			header: "X-API-Key", value: "shk_key", expectedStatus: http.StatusOK},
+		{name: "key without the scope", route: []gin.HandlerFunc{middleware.RequireScope(models.ScopeDelete)},
+			header: "X-API-Key", value: "shk_key", expectedStatus: http.StatusForbidden},
+		{name: "token only route", route: []gin.HandlerFunc{middleware.TokenOnly()},
+			header: "X-API-Key", value: "shk_key", expectedStatus: http.StatusForbidden},
+		{name: "invalid key", header: "X-API-Key", value: "shk_key", authResult: models.ErrAPIKeyInvalid,
+			expectedStatus: http.StatusUnauthorized},
+		{name: "invalid key on private route", private: true, header: "Authorization", value: "Bearer shk_key",
+			authResult: models.ErrAPIKeyInvalid, expectedStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			if tt.authResult != nil {
+				mockService.EXPECT().AuthenticateAPIKey(gomock.Any(), "shk_key").Return(models.APIKey{}, tt.authResult)
+			} else {
+				mockService.EXPECT().AuthenticateAPIKey(gomock.Any(), "shk_key").Return(readKey, nil)
+			}
+			r := gin.New()
+			if tt.private {
+				r.Use(middleware.AuthPrivate(keys, mockService))
+			} else {
+				r.Use(middleware.AuthPublic(keys, mockService))
+			}
+			var gotUserID interface{}
+			handlers := append(tt.route, func(c *gin.Context) {
+				gotUserID = c.Request.Context().Value(models.UserIDKey)
+				c.Status(http.StatusOK)
+			})
+			r.GET("/test", handlers...)
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Empty(t, w.Result().Cookies())
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, userID, gotUserID)
+			}
+		})
+	}
+}
+
+func TestHandlers_Accounts(t *testing.T) {
+	keys, err := auth.GenerateKeyRing()
+	require.NoError(t, err)
+	userID := uuid.New()
+	account := models.Account{UserID: userID, Login: "alice",
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), PasswordHash: "hash"}
+	credentials := models.Credentials{Login: "alice", Password: "password1"}
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedJSON   string
+		expectedStatus int
+		mockSetup      func(mockService *mocks.MockService)
+	}{
+		{
+			name:           "account registered",
+			path:           "/api/user/register",
+			body:           `{"login":"alice","password":"password1"}`,
+			expectedJSON:   `{"user_id":"` + userID.String() + `","login":"alice","created_at":"2024-01-02T03:04:05Z","claimed_urls":0}`,
+			expectedStatus: http.StatusCreated,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().Register(gomock.Any(), credentials).Return(models.AccountLogin{Account: account}, nil)
+			},
+		},
+		{
+			name:           "malformed JSON",
+			path:           "/api/user/register",
+			body:           `{"login":`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(mockService *mocks.MockService) {},
+		},
+		{
+			name:           "invalid credentials",
+			path:           "/api/user/register",
+			body:           `{"login":"al","password":"password1"}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().Register(gomock.Any(), gomock.Any()).
+					Return(models.AccountLogin{}, fmt.Errorf("%w: short login", models.ErrCredentialsInvalid))
+			},
+		},
+		{
+			name:           "login taken",
+			path:           "/api/user/register",
+			body:           `{"login":"alice","password":"password1"}`,
+			expectedStatus: http.StatusConflict,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().Register(gomock.Any(), gomock.Any()).Return(models.AccountLogin{}, models.ErrLoginTaken)
+			},
+		},
+		{
+			name:           "logged in",
+			path:           "/api/user/login",
+			body:           `{"login":"alice","password":"password1"}`,
+			expectedJSON:   `{"user_id":"` + userID.String() + `","login":"alice","created_at":"2024-01-02T03:04:05Z","claimed_urls":2}`,
+			expectedStatus: http.StatusOK,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().Login(gomock.Any(), credentials).Return(models.AccountLogin{Account: account, ClaimedURLs: 2}, nil)
+			},
+		},
+		{
+			name:           "wrong password",
+			path:           "/api/user/login",
+			body:           `{"login":"alice","password":"password2"}`,
+			expectedStatus: http.StatusUnauthorized,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().Login(gomock.Any(), gomock.Any()).Return(models.AccountLogin{}, models.ErrLoginFailed)
+			},
+		},
+		{
+			name:           "storage error",
+			path:           "/api/user/login",
+			body:           `{"login":"alice","password":"password1"}`,
+			expectedStatus: http.StatusInternalServerError,
+			mockSetup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().Login(gomock.Any(), gomock.Any()).Return(models.AccountLogin{}, errors.New("storage error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockService := mocks.NewMockService(ctrl)
+			tt.mockSetup(mockService)
+			handler := Handlers{service: mockService, tokens: keys}
+			r.POST("/api/user/register", handler.Register)
+			r.POST("/api/user/login", handler.Login)
+
+			req := httptest.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedJSON == "" {
+				assert.Empty(t, w.Result().Cookies())
+				return
+			}
+			assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+			// the cookie holds the token of the account user
+			var token string
+			for _, cookie := range w.Result().Cookies() {
+				if cookie.Name == middleware.TokenCookie {
+					token = cookie.Value
+				}
+			}
+			tokenUserID, err := keys.GetUserID(token)
+			require.NoError(t, err)
+			assert.Equal(t, userID, tokenUserID)
+		})
+	}
+}
+
+func TestHandlers_GetJWKS(t *testing.T) {
+	tests := []struct {
+		name         string
+		jwks         auth.JWKSet
+		expectedJSON string
+	}{
+		{
+			name: "published keys",
+			jwks: auth.JWKSet{Keys: []auth.JWK{
+				{Kty: "OKP", Kid: "ed", Use: "sig", Alg: auth.AlgEdDSA, Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+				{Kty: "RSA", Kid: "rsa", Use: "sig", Alg: auth.AlgRS256, N: "0vx7agoebGcQSuu", E: "AQAB"},
+			}},
+			expectedJSON: `{"keys":[
+				{"kty":"OKP","kid":"ed","use":"sig","alg":"EdDSA","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+				{"kty":"RSA","kid":"rsa","use":"sig","alg":"RS256","n":"0vx7agoebGcQSuu","e":"AQAB"}]}`,
+		},
+		{
+			name:         "only HS256 keys",
+			jwks:         auth.JWKSet{Keys: []auth.JWK{}},
+			expectedJSON: `{"keys":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.Default()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockTokens := mocks.NewMockTokenIssuer(ctrl)
+			mockTokens.EXPECT().JWKS().Return(tt.jwks)
+			handler := Handlers{tokens: mockTokens}
+			r.GET("/.well-known/jwks.json", handler.GetJWKS)
+
+			req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+			assert.JSONEq(t, tt.expectedJSON, w.Body.String())
+		})
+	}
+}
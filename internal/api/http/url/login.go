@@ -0,0 +1,44 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/api/http/middleware"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Login logs the current user in to the account with the 'login' and the 'password' of the JSON object
+// in the request body. The links of the current anonymous user are claimed by the account.
+// Sets the token of the account user in the user_token cookie and returns the account
+// with the number of claimed links as a JSON object with HTTP status 200 OK.
+// Sends HTTP status 400 Bad Request for malformed JSON, 401 Unauthorized if the login doesn't exist
+// or the password doesn't match, or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) Login(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var credentials models.Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON"})
+		return
+	}
+	account, err := h.service.Login(ctx, credentials)
+	if err != nil {
+		if errors.Is(err, models.ErrLoginFailed) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	token, err := h.tokens.BuildUserJWTString(account.UserID)
+	if err != nil {
+		logrus.Errorf("error generating token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error generating token"})
+		return
+	}
+	middleware.SetTokenCookie(c, token)
+	c.JSON(http.StatusOK, account)
+}
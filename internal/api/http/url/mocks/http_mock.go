@@ -8,8 +8,10 @@ import (
 	context "context"
 	reflect "reflect"
 
+	auth "github.com/DenisKhanov/shorterURL/internal/auth"
 	models "github.com/DenisKhanov/shorterURL/internal/models"
 	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
 )
 
 // MockService is a mock of Service interface.
@@ -36,11 +38,12 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 }
 
 // AsyncDeleteUserURLs mocks base method.
-func (m *MockService) AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) error {
+func (m *MockService) AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) (models.DeleteJob, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AsyncDeleteUserURLs", ctx, URLSToDel)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(models.DeleteJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // AsyncDeleteUserURLs indicates an expected call of AsyncDeleteUserURLs.
@@ -49,49 +52,139 @@ func (mr *MockServiceMockRecorder) AsyncDeleteUserURLs(ctx, URLSToDel interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AsyncDeleteUserURLs", reflect.TypeOf((*MockService)(nil).AsyncDeleteUserURLs), ctx, URLSToDel)
 }
 
+// AuthenticateAPIKey mocks base method.
+func (m *MockService) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAPIKey", ctx, key)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateAPIKey indicates an expected call of AuthenticateAPIKey.
+func (mr *MockServiceMockRecorder) AuthenticateAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAPIKey", reflect.TypeOf((*MockService)(nil).AuthenticateAPIKey), ctx, key)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockService) CreateAPIKey(ctx context.Context, request models.APIKeyRequest) (models.NewAPIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, request)
+	ret0, _ := ret[0].(models.NewAPIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockServiceMockRecorder) CreateAPIKey(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockService)(nil).CreateAPIKey), ctx, request)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockService) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", ctx)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockServiceMockRecorder) GetAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockService)(nil).GetAPIKeys), ctx)
+}
+
 // GetBatchShortURL mocks base method.
-func (m *MockService) GetBatchShortURL(ctx context.Context, batchURLRequests []models.URLRequest) ([]models.URLResponse, error) {
+func (m *MockService) GetBatchShortURL(ctx context.Context, domain models.RequestDomain, batchURLRequests []models.URLRequest) ([]models.URLResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBatchShortURL", ctx, batchURLRequests)
+	ret := m.ctrl.Call(m, "GetBatchShortURL", ctx, domain, batchURLRequests)
 	ret0, _ := ret[0].([]models.URLResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetBatchShortURL indicates an expected call of GetBatchShortURL.
-func (mr *MockServiceMockRecorder) GetBatchShortURL(ctx, batchURLRequests interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetBatchShortURL(ctx, domain, batchURLRequests interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatchShortURL", reflect.TypeOf((*MockService)(nil).GetBatchShortURL), ctx, batchURLRequests)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatchShortURL", reflect.TypeOf((*MockService)(nil).GetBatchShortURL), ctx, domain, batchURLRequests)
+}
+
+// GetDeleteJob mocks base method.
+func (m *MockService) GetDeleteJob(ctx context.Context, jobID string) (models.DeleteJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeleteJob", ctx, jobID)
+	ret0, _ := ret[0].(models.DeleteJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeleteJob indicates an expected call of GetDeleteJob.
+func (mr *MockServiceMockRecorder) GetDeleteJob(ctx, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeleteJob", reflect.TypeOf((*MockService)(nil).GetDeleteJob), ctx, jobID)
 }
 
 // GetOriginalURL mocks base method.
-func (m *MockService) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
+func (m *MockService) GetOriginalURL(ctx context.Context, host, shortURL string, client models.ClickInfo) (models.Redirect, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOriginalURL", ctx, shortURL)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "GetOriginalURL", ctx, host, shortURL, client)
+	ret0, _ := ret[0].(models.Redirect)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOriginalURL indicates an expected call of GetOriginalURL.
-func (mr *MockServiceMockRecorder) GetOriginalURL(ctx, shortURL interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetOriginalURL(ctx, host, shortURL, client interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOriginalURL", reflect.TypeOf((*MockService)(nil).GetOriginalURL), ctx, host, shortURL, client)
+}
+
+// GetQRCode mocks base method.
+func (m *MockService) GetQRCode(ctx context.Context, host, shortURL string, request models.QRRequest) (models.QRCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQRCode", ctx, host, shortURL, request)
+	ret0, _ := ret[0].(models.QRCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQRCode indicates an expected call of GetQRCode.
+func (mr *MockServiceMockRecorder) GetQRCode(ctx, host, shortURL, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQRCode", reflect.TypeOf((*MockService)(nil).GetQRCode), ctx, host, shortURL, request)
+}
+
+// GetServiceStats mocks base method.
+func (m *MockService) GetServiceStats(ctx context.Context) (models.Stats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetServiceStats", ctx)
+	ret0, _ := ret[0].(models.Stats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetServiceStats indicates an expected call of GetServiceStats.
+func (mr *MockServiceMockRecorder) GetServiceStats(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOriginalURL", reflect.TypeOf((*MockService)(nil).GetOriginalURL), ctx, shortURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServiceStats", reflect.TypeOf((*MockService)(nil).GetServiceStats), ctx)
 }
 
 // GetShortURL mocks base method.
-func (m *MockService) GetShortURL(ctx context.Context, url string) (string, error) {
+func (m *MockService) GetShortURL(ctx context.Context, domain models.RequestDomain, url, alias string, expiration models.Expiration, redirectCode int) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetShortURL", ctx, url)
+	ret := m.ctrl.Call(m, "GetShortURL", ctx, domain, url, alias, expiration, redirectCode)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetShortURL indicates an expected call of GetShortURL.
-func (mr *MockServiceMockRecorder) GetShortURL(ctx, url interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetShortURL(ctx, domain, url, alias, expiration, redirectCode interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortURL", reflect.TypeOf((*MockService)(nil).GetShortURL), ctx, url)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortURL", reflect.TypeOf((*MockService)(nil).GetShortURL), ctx, domain, url, alias, expiration, redirectCode)
 }
 
 // GetStorageStatus mocks base method.
@@ -108,32 +201,173 @@ func (mr *MockServiceMockRecorder) GetStorageStatus(ctx interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageStatus", reflect.TypeOf((*MockService)(nil).GetStorageStatus), ctx)
 }
 
-// GetUserURLS mocks base method.
-func (m *MockService) GetUserURLs(ctx context.Context) ([]models.URL, error) {
+// GetURLHistory mocks base method.
+func (m *MockService) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserURLs", ctx)
-	ret0, _ := ret[0].([]models.URL)
+	ret := m.ctrl.Call(m, "GetURLHistory", ctx, shortURL)
+	ret0, _ := ret[0].([]models.URLChange)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserURLS indicates an expected call of GetUserURLS.
-func (mr *MockServiceMockRecorder) GetUserURLS(ctx interface{}) *gomock.Call {
+// GetURLHistory indicates an expected call of GetURLHistory.
+func (mr *MockServiceMockRecorder) GetURLHistory(ctx, shortURL interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURLs", reflect.TypeOf((*MockService)(nil).GetUserURLs), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLHistory", reflect.TypeOf((*MockService)(nil).GetURLHistory), ctx, shortURL)
 }
 
-// ServiceStats mocks base method.
-func (m *MockService) GetServiceStats(ctx context.Context) (models.Stats, error) {
+// GetURLStats mocks base method.
+func (m *MockService) GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetServiceStats", ctx)
-	ret0, _ := ret[0].(models.Stats)
+	ret := m.ctrl.Call(m, "GetURLStats", ctx, shortURL)
+	ret0, _ := ret[0].(models.URLStats)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ServiceStats indicates an expected call of ServiceStats.
-func (mr *MockServiceMockRecorder) ServiceStats(ctx interface{}) *gomock.Call {
+// GetURLStats indicates an expected call of GetURLStats.
+func (mr *MockServiceMockRecorder) GetURLStats(ctx, shortURL interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServiceStats", reflect.TypeOf((*MockService)(nil).GetServiceStats), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLStats", reflect.TypeOf((*MockService)(nil).GetURLStats), ctx, shortURL)
+}
+
+// GetUserURLs mocks base method.
+func (m *MockService) GetUserURLs(ctx context.Context, request models.UserURLsRequest) (models.UserURLsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserURLs", ctx, request)
+	ret0, _ := ret[0].(models.UserURLsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserURLs indicates an expected call of GetUserURLs.
+func (mr *MockServiceMockRecorder) GetUserURLs(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURLs", reflect.TypeOf((*MockService)(nil).GetUserURLs), ctx, request)
+}
+
+// Login mocks base method.
+func (m *MockService) Login(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", ctx, credentials)
+	ret0, _ := ret[0].(models.AccountLogin)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockServiceMockRecorder) Login(ctx, credentials interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockService)(nil).Login), ctx, credentials)
+}
+
+// Register mocks base method.
+func (m *MockService) Register(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Register", ctx, credentials)
+	ret0, _ := ret[0].(models.AccountLogin)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Register indicates an expected call of Register.
+func (mr *MockServiceMockRecorder) Register(ctx, credentials interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockService)(nil).Register), ctx, credentials)
+}
+
+// RestoreURL mocks base method.
+func (m *MockService) RestoreURL(ctx context.Context, shortURL string) (models.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreURL", ctx, shortURL)
+	ret0, _ := ret[0].(models.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreURL indicates an expected call of RestoreURL.
+func (mr *MockServiceMockRecorder) RestoreURL(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreURL", reflect.TypeOf((*MockService)(nil).RestoreURL), ctx, shortURL)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockService) RevokeAPIKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockServiceMockRecorder) RevokeAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockService)(nil).RevokeAPIKey), ctx, id)
+}
+
+// UpdateURL mocks base method.
+func (m *MockService) UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURL", ctx, shortURL, originalURL)
+	ret0, _ := ret[0].(models.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateURL indicates an expected call of UpdateURL.
+func (mr *MockServiceMockRecorder) UpdateURL(ctx, shortURL, originalURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURL", reflect.TypeOf((*MockService)(nil).UpdateURL), ctx, shortURL, originalURL)
+}
+
+// MockTokenIssuer is a mock of TokenIssuer interface.
+type MockTokenIssuer struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenIssuerMockRecorder
+}
+
+// MockTokenIssuerMockRecorder is the mock recorder for MockTokenIssuer.
+type MockTokenIssuerMockRecorder struct {
+	mock *MockTokenIssuer
+}
+
+// NewMockTokenIssuer creates a new mock instance.
+func NewMockTokenIssuer(ctrl *gomock.Controller) *MockTokenIssuer {
+	mock := &MockTokenIssuer{ctrl: ctrl}
+	mock.recorder = &MockTokenIssuerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenIssuer) EXPECT() *MockTokenIssuerMockRecorder {
+	return m.recorder
+}
+
+// BuildUserJWTString mocks base method.
+func (m *MockTokenIssuer) BuildUserJWTString(userID uuid.UUID) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BuildUserJWTString", userID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BuildUserJWTString indicates an expected call of BuildUserJWTString.
+func (mr *MockTokenIssuerMockRecorder) BuildUserJWTString(userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildUserJWTString", reflect.TypeOf((*MockTokenIssuer)(nil).BuildUserJWTString), userID)
+}
+
+// JWKS mocks base method.
+func (m *MockTokenIssuer) JWKS() auth.JWKSet {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(auth.JWKSet)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockTokenIssuerMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockTokenIssuer)(nil).JWKS))
 }
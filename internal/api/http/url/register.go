@@ -0,0 +1,48 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/api/http/middleware"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Register creates an account of the current user with the 'login' and the 'password' of the JSON object
+// in the request body. The links of the current anonymous user stay with the account; if the current user
+// has an account already, the new account is created for a new user.
+// Sets the token of the account user in the user_token cookie and returns the account
+// as a JSON object with HTTP status 201 Created.
+// Sends HTTP status 400 Bad Request for malformed JSON or credentials not meeting the requirements,
+// 409 Conflict if the login is taken, or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) Register(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var credentials models.Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON"})
+		return
+	}
+	account, err := h.service.Register(ctx, credentials)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrCredentialsInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrLoginTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	token, err := h.tokens.BuildUserJWTString(account.UserID)
+	if err != nil {
+		logrus.Errorf("error generating token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error generating token"})
+		return
+	}
+	middleware.SetTokenCookie(c, token)
+	c.JSON(http.StatusCreated, account)
+}
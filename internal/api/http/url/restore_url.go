@@ -0,0 +1,32 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RestoreURL restores a short URL of the current user marked as deleted, so it redirects again.
+// The short URL ID is expected as a URL parameter. A short URL that isn't deleted is left as is.
+// Returns the restored URL as a JSON object with HTTP status 200 OK.
+// Sends HTTP status 404 Not Found if the short URL doesn't exist or belongs to another user,
+// 410 Gone if the restore period of the deleted URL is over,
+// or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) RestoreURL(c *gin.Context) {
+	ctx := c.Request.Context()
+	restored, err := h.service.RestoreURL(ctx, c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrURLNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrRestorePeriodOver):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, restored)
+}
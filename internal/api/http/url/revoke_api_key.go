@@ -0,0 +1,27 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RevokeAPIKey revokes an API key of the current user, requests with the key are rejected afterwards.
+// The key ID is expected as a URL parameter.
+// Returns HTTP status 204 No Content on success.
+// Sends HTTP status 404 Not Found if the key doesn't exist or belongs to another user,
+// or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) RevokeAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.RevokeAPIKey(ctx, c.Param("id")); err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
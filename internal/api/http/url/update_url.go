@@ -0,0 +1,48 @@
+// Package url provides HTTP request handlers and middleware for the URL shortening application.
+package url
+
+import (
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+)
+
+// UpdateURL changes the original URL a short URL owned by the current user leads to.
+// The short URL ID is expected as a URL parameter and a JSON object with the new 'url' in the request body.
+// The replaced original URL is kept in the change history of the short URL.
+// Returns the updated URL as a JSON object with HTTP status 200 OK.
+// Sends HTTP status 400 Bad Request for malformed JSON or an invalid URL, 404 Not Found if the short URL
+// doesn't exist or belongs to another user, 410 Gone if it is deleted or expired, 409 Conflict if another
+// link on the domain leads to the URL, or HTTP status 500 Internal Server Error for other errors.
+func (h *Handlers) UpdateURL(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var update URLUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON"})
+		return
+	}
+	parsedLinc, err := url.Parse(update.URL)
+	if err != nil || parsedLinc.Scheme == "" || parsedLinc.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL format isn't correct"})
+		return
+	}
+
+	updated, err := h.service.UpdateURL(ctx, c.Param("id"), update.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrURLNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrURLDeleted), errors.Is(err, models.ErrURLExpired):
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrOriginalURLTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	myGRPC "github.com/DenisKhanov/shorterURL/internal/api/grpc/interceptors"
 	"github.com/DenisKhanov/shorterURL/internal/api/http/middleware"
+	"github.com/DenisKhanov/shorterURL/internal/auth"
 	"github.com/DenisKhanov/shorterURL/internal/config"
 	"github.com/DenisKhanov/shorterURL/internal/logcfg"
+	"github.com/DenisKhanov/shorterURL/internal/migrations"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/DenisKhanov/shorterURL/internal/services/url"
 	"github.com/DenisKhanov/shorterURL/internal/tls"
@@ -17,6 +19,7 @@ import (
 	realip "github.com/thanhhh/gin-gonic-realip"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -34,6 +37,7 @@ type App struct {
 	config          *config.ENVConfig // The configuration object for the application
 	dbPool          *pgxpool.Pool     // The connection pool to the database
 	trustedSubnets  []*net.IPNet      //The collection trusted subnet
+	keyRing         *auth.KeyRing     // The keys signing and verifying JWT tokens of users
 	serverHTTP      *http.Server      // The serverHTTP instance
 	serverGRPC      *grpc.Server      //The serverGRPC instance
 }
@@ -58,7 +62,9 @@ func (a *App) initDeps(ctx context.Context) error {
 	inits := []func(context.Context) error{
 		a.initConfig,
 		a.initTrustedSubnets,
+		a.initKeyRing,
 		a.initDBConnection,
+		a.initMigrations,
 		a.initServiceProvider,
 		a.initShortenerHTTPServer,
 		a.initShortenerGRPCServer,
@@ -103,9 +109,34 @@ func (a *App) initTrustedSubnets(_ context.Context) error {
 	return nil
 }
 
+// initKeyRing loads the JWT signing keys from the key ring file or creates the single key from the secret.
+// If neither is configured, tokens are signed and verified with the secret built into the former versions,
+// so the tokens users have stay valid after upgrade and restart until the keys are configured.
+// Tokens are refreshed for the configured maximum lifetime.
+func (a *App) initKeyRing(_ context.Context) error {
+	var err error
+	switch {
+	case a.config.EnvJWTKeysFile != "":
+		a.keyRing, err = auth.LoadKeyRing(a.config.EnvJWTKeysFile)
+	case a.config.EnvJWTSecret != "":
+		a.keyRing, err = auth.NewSecretKeyRing(a.config.EnvJWTSecret)
+	default:
+		logrus.Warn("JWT signing keys aren't configured, tokens are signed with the public built-in secret, " +
+			"configure JWT_KEYS_FILE or JWT_SECRET and keep the built-in key as legacy until its tokens expire")
+		a.keyRing, err = auth.NewBuiltinKeyRing()
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Error loading JWT signing keys")
+		return err
+	}
+	a.keyRing.SetMaxLifetime(a.config.EnvTokenMaxLifetime)
+	logrus.Infof("JWT tokens are signed with key %q", a.keyRing.ActiveKeyID())
+	return nil
+}
+
 // initDBConnection initializes the connection to the database.
 func (a *App) initDBConnection(ctx context.Context) error {
-	if a.config.EnvDataBase != "" {
+	if a.config.EnvStorageType == config.StoragePostgres {
 		confPool, err := pgxpool.ParseConfig(a.config.EnvDataBase)
 		if err != nil {
 			logrus.WithError(err).Error("Error parsing config")
@@ -122,41 +153,88 @@ func (a *App) initDBConnection(ctx context.Context) error {
 	return nil
 }
 
+// initMigrations applies pending database schema migrations if the database is used.
+func (a *App) initMigrations(ctx context.Context) error {
+	if a.dbPool == nil {
+		return nil
+	}
+	migrator, err := migrations.NewMigrator(a.dbPool)
+	if err != nil {
+		logrus.WithError(err).Error("Error loading migrations")
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Error applying migrations")
+		return err
+	}
+	logrus.Infof("Database schema is up to date, %d migrations applied", applied)
+	return nil
+}
+
 // initServiceProvider initializes the service provider for dependency injection.
 func (a *App) initServiceProvider(_ context.Context) error {
-	a.serviceProvider = newServiceProvider()
+	a.serviceProvider = newServiceProvider(a.config, a.dbPool, a.keyRing)
 	return nil
 }
 
 // initShortenerHTTPServer initializes the http_shortener serverHTTP with middleware and routes.
 func (a *App) initShortenerHTTPServer(_ context.Context) error {
-	myHandler := a.serviceProvider.ShortenerHandler(a.dbPool, a.config.EnvBaseURL, a.config.EnvStoragePath, a.config.EnvSubnet)
+	myHandler := a.serviceProvider.ShortenerHandler()
 
 	// Установка переменной окружения для включения режима разработки
 	gin.SetMode(gin.DebugMode)
 	router := gin.Default()
 	// Use the pprof middleware
 	pprof.Register(router)
+	// Requests with an API key are authenticated as the user of the key,
+	// the routes they can reach are limited by the scopes of the key
+	shortenerService := a.serviceProvider.ShortenerService()
+	requireCreate := middleware.RequireScope(models.ScopeCreate)
+	requireRead := middleware.RequireScope(models.ScopeRead)
+	requireDelete := middleware.RequireScope(models.ScopeDelete)
+
 	//Public middleware routers group
 	publicRoutes := router.Group("/")
-	publicRoutes.Use(middleware.AuthPublic())
+	publicRoutes.Use(middleware.AuthPublic(a.keyRing, shortenerService))
 	publicRoutes.Use(middleware.LogrusLog())
 	publicRoutes.Use(middleware.GZIPCompress())
 
-	publicRoutes.POST("/", myHandler.GetShortURL)
+	publicRoutes.POST("/", requireCreate, myHandler.GetShortURL)
 	publicRoutes.GET("/ping", myHandler.GetStorageStatus)
 	publicRoutes.GET("/:id", myHandler.GetOriginalURL)
-	publicRoutes.POST("/api/shorten", myHandler.GetJSONShortURL)
-	publicRoutes.POST("/api/shorten/batch", myHandler.GetBatchShortURL)
+	publicRoutes.GET("/:id/qr", myHandler.GetQRCode)
+	publicRoutes.POST("/api/shorten", requireCreate, myHandler.GetJSONShortURL)
+	publicRoutes.POST("/api/shorten/batch", requireCreate, myHandler.GetBatchShortURL)
+	// Accounts are registered and logged in to only by the user with the token, not by the clients with the keys
+	publicRoutes.POST("/api/user/register", middleware.TokenOnly(), myHandler.Register)
+	publicRoutes.POST("/api/user/login", middleware.TokenOnly(), myHandler.Login)
 
 	//Private middleware routers group
 	privateRoutes := router.Group("/")
-	privateRoutes.Use(middleware.AuthPrivate())
+	privateRoutes.Use(middleware.AuthPrivate(a.keyRing, shortenerService))
 	privateRoutes.Use(middleware.LogrusLog())
 	privateRoutes.Use(middleware.GZIPCompress())
 
-	privateRoutes.GET("/api/user/urls", myHandler.GetUserURLS)
-	privateRoutes.DELETE("/api/user/urls", myHandler.DelUserURLs)
+	privateRoutes.GET("/api/user/urls", requireRead, myHandler.GetUserURLS)
+	privateRoutes.DELETE("/api/user/urls", requireDelete, myHandler.DelUserURLs)
+	privateRoutes.GET("/api/user/urls/:id/stats", requireRead, myHandler.GetURLStats)
+	privateRoutes.PATCH("/api/user/urls/:id", requireCreate, myHandler.UpdateURL)
+	privateRoutes.GET("/api/user/urls/:id/history", requireRead, myHandler.GetURLHistory)
+	privateRoutes.POST("/api/user/urls/:id/restore", requireDelete, myHandler.RestoreURL)
+	privateRoutes.GET("/api/user/deletions/:id", requireRead, myHandler.GetDeleteJob)
+
+	// API keys are managed only by the user with the token, not by the clients with the keys
+	keyRoutes := privateRoutes.Group("/api/user/keys")
+	keyRoutes.Use(middleware.TokenOnly())
+	keyRoutes.POST("", myHandler.CreateAPIKey)
+	keyRoutes.GET("", myHandler.GetAPIKeys)
+	keyRoutes.DELETE("/:id", myHandler.RevokeAPIKey)
+
+	// Public keys verifying the tokens of users for other services, requests don't need a user
+	wellKnownRoutes := router.Group("/.well-known")
+	wellKnownRoutes.Use(middleware.LogrusLog())
+	wellKnownRoutes.GET("/jwks.json", myHandler.GetJWKS)
 
 	//Only trusted subnet middleware
 	trustSubnetRouter := router.Group("/")
@@ -179,7 +257,8 @@ func (a *App) initShortenerGRPCServer(_ context.Context) error {
 
 	s := grpc.NewServer(grpc.ChainUnaryInterceptor(myGRPC.UnaryLoggerInterceptor,
 		myGRPC.UnaryTrustedSubnetsInterceptor(a.trustedSubnets),
-		myGRPC.UnaryPrivateAuthInterceptor, myGRPC.UnaryPublicAuthInterceptor),
+		myGRPC.UnaryPrivateAuthInterceptor(a.keyRing, a.serviceProvider.ShortenerService()),
+		myGRPC.UnaryPublicAuthInterceptor(a.keyRing, a.serviceProvider.ShortenerService())),
 	)
 	reflection.Register(s)
 	a.serverGRPC = s
@@ -206,6 +285,55 @@ func (a *App) runShortenerServers() {
 		}
 	}()
 
+	// run background removal of expired URLs and of deleted URLs whose restore period is over
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	reaperDone := make(chan struct{})
+	go func() {
+		a.serviceProvider.ShortenerService().RunExpiredURLsReaper(reaperCtx,
+			a.config.EnvExpiredCleanupInterval, a.config.EnvExpiredRetention)
+		close(reaperDone)
+	}()
+	purgerDone := make(chan struct{})
+	go func() {
+		a.serviceProvider.ShortenerService().RunDeletedURLsPurger(reaperCtx, a.config.EnvDeletedPurgeInterval)
+		close(purgerDone)
+	}()
+
+	// run background compaction and flushing of the storage file
+	compactCtx, stopCompaction := context.WithCancel(context.Background())
+	defer stopCompaction()
+	flushCtx, stopFlusher := context.WithCancel(context.Background())
+	defer stopFlusher()
+	flushDone := make(chan struct{})
+	if repository, ok := a.serviceProvider.storageRepository.(url.InMemoryRepository); ok {
+		go repository.RunCompaction(compactCtx, a.config.EnvFileCompactInterval)
+		go func() {
+			repository.RunFlusher(flushCtx)
+			close(flushDone)
+		}()
+	} else {
+		close(flushDone)
+	}
+
+	// run background saving of click events
+	clicksCtx, stopClicks := context.WithCancel(context.Background())
+	defer stopClicks()
+	clicksDone := make(chan struct{})
+	go func() {
+		a.serviceProvider.ShortenerService().RunClickWriter(clicksCtx)
+		close(clicksDone)
+	}()
+
+	// run background deletion of user URLs
+	deleteCtx, stopDeletions := context.WithCancel(context.Background())
+	defer stopDeletions()
+	deletionsDone := make(chan struct{})
+	go func() {
+		a.serviceProvider.ShortenerService().RunDeleteWorkers(deleteCtx, a.config.EnvDeleteWorkers)
+		close(deletionsDone)
+	}()
+
 	// run HTTP server
 	go func() {
 		if a.config.EnvTLS != "" {
@@ -231,6 +359,8 @@ func (a *App) runShortenerServers() {
 
 	sig := <-signalChan
 	logrus.Infof("Shutting down HTTP & gRPC servers with signal : %v...", sig)
+	stopReaper()
+	stopCompaction()
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
@@ -251,17 +381,41 @@ func (a *App) runShortenerServers() {
 		wg.Done()
 	}()
 
+	// requests in flight are finished before the storage is flushed and closed
+	wg.Wait()
+
+	// the removals in progress finish before the storage is flushed and closed,
+	// so their tombstones are saved and they don't run against a closed storage
+	<-reaperDone
+	<-purgerDone
+
+	// the queued deletions are marked before the storage is closed
+	stopDeletions()
+	<-deletionsDone
+
+	// the queued clicks are saved before the storage is closed
+	stopClicks()
+	<-clicksDone
+
+	// the flusher saves the buffered changes once more when it stops
+	stopFlusher()
+	<-flushDone
+
 	//TODO избавиться от приведения типов
 
-	//If the input shutdown signal, batch URLs saving to file
-	if a.dbPool == nil {
-		err := a.serviceProvider.shortenerRepository.(url.InMemoryRepository).SaveBatchToFile()
-		if err != nil {
+	//If the input shutdown signal, batch URLs saving to file or closing the storage
+	switch repository := a.serviceProvider.storageRepository.(type) {
+	case url.InMemoryRepository:
+		if err := repository.SaveBatchToFile(); err != nil {
 			logrus.WithError(err).Error("Error save memory in file")
 		}
-	} else {
+	case io.Closer:
+		if err := repository.Close(); err != nil {
+			logrus.WithError(err).Error("Error close storage")
+		}
+	}
+	if a.dbPool != nil {
 		a.dbPool.Close()
 	}
-	wg.Wait()
 	logrus.Info("Server exited")
 }
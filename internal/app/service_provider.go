@@ -3,58 +3,98 @@ package app
 import (
 	url3 "github.com/DenisKhanov/shorterURL/internal/api/grpc/url"
 	url4 "github.com/DenisKhanov/shorterURL/internal/api/http/url"
+	"github.com/DenisKhanov/shorterURL/internal/auth"
+	"github.com/DenisKhanov/shorterURL/internal/config"
 	url2 "github.com/DenisKhanov/shorterURL/internal/repositories/url"
 	"github.com/DenisKhanov/shorterURL/internal/services/url"
+	"github.com/DenisKhanov/shorterURL/internal/services/url/cache"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
 // serviceProvider manages the dependency injection for http_shortener-related components.
 type serviceProvider struct {
-	shortenerRepository url.Repository        // Repository for http_shortener-related data
-	shortenerService    url4.Service          // Service for http_shortener-related operations
+	config              *config.ENVConfig     // The configuration object for the application
+	dbPool              *pgxpool.Pool         // The connection pool to the database, nil for other storage types
+	keyRing             *auth.KeyRing         // The keys signing the tokens of the users logged in to accounts
+	storageRepository   url.Repository        // Repository of the selected storage type
+	shortenerRepository url.Repository        // Repository for http_shortener-related data, cached if the cache is on
+	shortenerService    *url.ShortURLServices // Service for http_shortener-related operations
 	shortenerHandler    *url4.Handlers        // Handler for http_shortener-related HTTP endpoints
 	shortenerGRPC       *url3.ShortenerServer //GRPC for http_shortener-related operations
 }
 
 // newServiceProvider creates a new instance of the service provider.
-func newServiceProvider() *serviceProvider {
-	return &serviceProvider{}
+func newServiceProvider(cfg *config.ENVConfig, dbPool *pgxpool.Pool, keyRing *auth.KeyRing) *serviceProvider {
+	return &serviceProvider{
+		config:  cfg,
+		dbPool:  dbPool,
+		keyRing: keyRing,
+	}
 }
 
-// ShortenerRepository returns the repository for user-related data.
-// If dbPool is nil, it initializes an in-memory repository, otherwise initializes a database repository.
-func (s *serviceProvider) ShortenerRepository(dbPool *pgxpool.Pool, storagePath string) url.Repository {
+// StorageRepository returns the repository of the storage.
+// The repository is selected by the storage type: in-memory, PostgreSQL or embedded bbolt repository.
+func (s *serviceProvider) StorageRepository() url.Repository {
 	var err error
-	if s.shortenerRepository == nil {
-		if dbPool == nil {
-			s.shortenerRepository = url2.NewURLInMemoryRepo(storagePath)
-		} else {
-			if s.shortenerRepository, err = url2.NewURLInDBRepo(dbPool); err != nil {
+	if s.storageRepository == nil {
+		switch s.config.EnvStorageType {
+		case config.StoragePostgres:
+			if s.storageRepository, err = url2.NewURLInDBRepo(s.dbPool); err != nil {
 				//TODO лучше вернуть ошибку из метода и обработать ее выше
 				logrus.Fatal(err)
 			}
+		case config.StorageBolt:
+			if s.storageRepository, err = url2.NewURLInBoltRepo(s.config.EnvBoltPath); err != nil {
+				logrus.Fatal(err)
+			}
+		default:
+			s.storageRepository = url2.NewURLInMemoryRepo(s.config.EnvStoragePath, url2.FlushPolicy{
+				Records:  s.config.EnvFileFlushRecords,
+				Interval: s.config.EnvFileFlushInterval,
+			})
+		}
+	}
+	return s.storageRepository
+}
+
+// ShortenerRepository returns the repository for user-related data.
+// If the cache size is positive, the storage repository is wrapped with the cache of redirect lookups.
+func (s *serviceProvider) ShortenerRepository() url.Repository {
+	if s.shortenerRepository == nil {
+		s.shortenerRepository = s.StorageRepository()
+		if s.config.EnvCacheSize > 0 {
+			s.shortenerRepository = cache.NewCachedRepository(s.shortenerRepository,
+				s.config.EnvCacheSize, s.config.EnvCacheTTL, s.config.EnvCacheNegativeTTL)
 		}
 	}
 	return s.shortenerRepository
 }
 
 // ShortenerService returns the service for user-related operations.
-func (s *serviceProvider) ShortenerService(dbPool *pgxpool.Pool, baseUrl, storagePath string) url4.Service {
+func (s *serviceProvider) ShortenerService() *url.ShortURLServices {
 	if s.shortenerService == nil {
 		s.shortenerService = url.NewShortURLServices(
-			s.ShortenerRepository(dbPool, storagePath),
+			s.ShortenerRepository(),
 			url.ShortURLServices{},
-			baseUrl,
+			url.NewDomains(s.config.EnvBaseURL, s.config.EnvShortDomains),
+			url.NewAliasPolicy(
+				s.config.EnvAliasCharset,
+				s.config.EnvAliasMinLength,
+				s.config.EnvAliasMaxLength,
+				s.config.EnvAliasReserved,
+			),
+			s.config.EnvDeletedRestorePeriod,
+			s.config.EnvRedirectCode,
 		)
 	}
 	return s.shortenerService
 }
 
 // ShortenerHandler returns the handler for user-related HTTP endpoints.
-func (s *serviceProvider) ShortenerHandler(dbPool *pgxpool.Pool, baseUrl, storagePath, subnetsStr string) *url4.Handlers {
+func (s *serviceProvider) ShortenerHandler() *url4.Handlers {
 	if s.shortenerHandler == nil {
-		userHandler := url4.NewHandlers(s.ShortenerService(dbPool, baseUrl, storagePath), subnetsStr)
+		userHandler := url4.NewHandlers(s.ShortenerService(), s.keyRing, s.config.EnvSubnet)
 		s.shortenerHandler = userHandler
 	}
 	return s.shortenerHandler
@@ -63,7 +103,7 @@ func (s *serviceProvider) ShortenerHandler(dbPool *pgxpool.Pool, baseUrl, storag
 // ShortenerGRPC returns the handler for user-related HTTP endpoints.
 func (s *serviceProvider) ShortenerGRPC() *url3.ShortenerServer {
 	if s.shortenerGRPC == nil {
-		shortenerGRPC := url3.NewShortenerServer(s.shortenerService)
+		shortenerGRPC := url3.NewShortenerServer(s.ShortenerService(), s.keyRing)
 		s.shortenerGRPC = shortenerGRPC
 	}
 	return s.shortenerGRPC
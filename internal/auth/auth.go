@@ -11,31 +11,51 @@ import (
 )
 
 // Claims is a structure that includes standard JWT claims and UserID.
+// AuthTime is the time the first token of the user has been issued, it is kept when the token is refreshed.
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID uuid.UUID
+	UserID   uuid.UUID
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 }
 
 // const for generate token
 const (
 	// TokenExp defines the expiration duration for JWT tokens.
 	TokenExp = time.Hour * 3
-	// SecretKey is the secret key used for signing JWT tokens.
-	SecretKey = "SnJSkf123jlLKNfsNln"
+	// RefreshBefore is the time left before the expiration of a token, when it is reissued.
+	RefreshBefore = TokenExp / 2
+	// DefaultMaxLifetime is the default time since the first token of the user, after which tokens are no longer refreshed.
+	DefaultMaxLifetime = 30 * 24 * time.Hour
 )
 
-// BuildJWTString creates a token with the HS256 signature algorithm and Claims statements and returns it as a string.
-func BuildJWTString() (string, error) {
-	userID := GenerateUniqueID()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+// BuildJWTString creates a token with Claims statements and returns it as a string.
+// The token is issued to a new user and is signed with the active key of the ring using the algorithm of the key,
+// its kid is set in the token header.
+func (r *KeyRing) BuildJWTString() (string, error) {
+	return r.BuildUserJWTString(GenerateUniqueID())
+}
+
+// BuildUserJWTString creates a token of the existing user, such as the user of an account the user has logged in to.
+// The token is signed the same way as by BuildJWTString, its lifetime starts anew.
+func (r *KeyRing) BuildUserJWTString(userID uuid.UUID) (string, error) {
+	now := time.Now()
+	return r.signClaims(Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			// когда создан токен
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)),
+			ExpiresAt: jwt.NewNumericDate(r.expiresAt(now, now)),
 		},
-		UserID: userID,
+		UserID:   userID,
+		AuthTime: jwt.NewNumericDate(now),
 	})
+}
+
+// signClaims creates a token with the claims signed with the active key of the ring.
+func (r *KeyRing) signClaims(claims Claims) (string, error) {
+	key := r.keys[r.active]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = r.active
 	// создаём строку токена
-	tokenString, err := token.SignedString([]byte(SecretKey))
+	tokenString, err := token.SignedString(key.sign)
 	if err != nil {
 		logrus.Error(err)
 		return "", err
@@ -49,21 +69,9 @@ func GenerateUniqueID() uuid.UUID {
 }
 
 // GetUserID we check the validity of the token and if it is valid, then we get and return the UserID from it
-func GetUserID(tokenString string) (uuid.UUID, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signed method: %v", t.Header["alg"])
-		}
-		return []byte(SecretKey), nil
-	})
+func (r *KeyRing) GetUserID(tokenString string) (uuid.UUID, error) {
+	claims, err := r.parseClaims(tokenString)
 	if err != nil {
-		logrus.Error(err)
-		return uuid.Nil, err
-	}
-	if !token.Valid {
-		err = fmt.Errorf("token is not valid")
-		logrus.Error(err)
 		return uuid.Nil, err
 	}
 	logrus.Infof("Token is valid, userID: %v", claims.UserID)
@@ -71,22 +79,43 @@ func GetUserID(tokenString string) (uuid.UUID, error) {
 }
 
 // IsValidToken method to check the token for validity, we return bool
-func IsValidToken(tokenString string) bool {
+func (r *KeyRing) IsValidToken(tokenString string) bool {
+	_, err := r.parseClaims(tokenString)
+	return err == nil
+}
+
+// parseClaims checks the signature and the expiration of the token and returns its claims.
+func (r *KeyRing) parseClaims(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signed method: %v", t.Header["alg"])
-		}
-		return []byte(SecretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, r.verificationKey)
 	if err != nil {
 		logrus.Error(err)
-		return false
+		return nil, err
 	}
 	if !token.Valid {
 		err = fmt.Errorf("token is not valid")
 		logrus.Error(err)
-		return false
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verificationKey returns the secret or the public key of the key the token is signed with,
+// selected by the kid header of the token. Tokens without a kid are verified with the legacy key of the ring.
+// The algorithm of the token must be the algorithm of the key, so a token can't pass the public key
+// of an asymmetric key off as an HMAC secret.
+// It returns an error wrapping ErrKeyUnknown if the key isn't in the ring or is retired.
+func (r *KeyRing) verificationKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = r.legacy
+	}
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKeyUnknown, kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signed method: %v", t.Header["alg"])
 	}
-	return true
+	return key.verify, nil
 }
@@ -19,7 +19,7 @@ func TestBuildJWTString(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := BuildJWTString()
+			got, err := testKeyRing(t).BuildJWTString()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("BuildJWTString() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -48,7 +48,8 @@ func TestGenerateUniqueID(t *testing.T) {
 }
 
 func TestGetUserID(t *testing.T) {
-	token, _ := BuildJWTString()
+	keys := testKeyRing(t)
+	token, _ := keys.BuildJWTString()
 	tests := []struct {
 		name    string
 		args    args
@@ -59,7 +60,7 @@ func TestGetUserID(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetUserID(tt.args.tokenString)
+			got, err := keys.GetUserID(tt.args.tokenString)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetUserID() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -72,7 +73,8 @@ func TestGetUserID(t *testing.T) {
 }
 
 func TestIsValidToken(t *testing.T) {
-	validToken, _ := BuildJWTString()
+	keys := testKeyRing(t)
+	validToken, _ := keys.BuildJWTString()
 	tests := []struct {
 		name string
 		args args
@@ -83,9 +85,19 @@ func TestIsValidToken(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := IsValidToken(tt.args.tokenString); got != tt.want {
+			if got := keys.IsValidToken(tt.args.tokenString); got != tt.want {
 				t.Errorf("IsValidToken() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+// testKeyRing creates a key ring of a single random key.
+func testKeyRing(t *testing.T) *KeyRing {
+	t.Helper()
+	keys, err := GenerateKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
@@ -0,0 +1,55 @@
+// Package auth provides functions for handling authentication, JWT token creation,
+// and validation.
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK is a public key of the key ring in the JSON Web Key format (RFC 7517).
+// RSA keys have the modulus N and the exponent E, Ed25519 keys have the curve Crv and the public key X,
+// all values are base64url encoded without padding.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the JSON Web Key Set of the public keys verifying the tokens of the key ring.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the non-retired RS256 and EdDSA keys of the ring sorted by kid,
+// so other services can verify tokens by their kid header. Secrets of HS256 keys are never published,
+// tokens signed with them can be verified only by the services sharing the secret.
+func (r *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(r.keys))}
+	for kid, key := range r.keys {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pemKeys encodes the private key and its public key in PEM.
+func pemKeys(t *testing.T, private crypto.Signer) (privatePEM, publicPEM string) {
+	t.Helper()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+// rsaKey generates an RSA key of the size for RS256 keys.
+func rsaKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return key
+}
+
+// edKey generates an Ed25519 key for EdDSA keys.
+func edKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+// jwkPublicKey decodes the public key of the JWK as a service verifying the tokens does.
+func jwkPublicKey(t *testing.T, jwk JWK) interface{} {
+	t.Helper()
+	decode := func(value string) []byte {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		require.NoError(t, err)
+		return data
+	}
+	switch jwk.Kty {
+	case "RSA":
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(decode(jwk.N)), E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64())}
+	case "OKP":
+		return ed25519.PublicKey(decode(jwk.X))
+	}
+	t.Fatalf("unexpected key type %q", jwk.Kty)
+	return nil
+}
+
+func TestKeyRing_AsymmetricKeys(t *testing.T) {
+	rsaPrivate, rsaPublic := pemKeys(t, rsaKey(t, 2048))
+	edPrivate, edPublic := pemKeys(t, edKey(t))
+	tests := []struct {
+		name    string
+		alg     string
+		private string
+		public  string
+	}{
+		{name: "RS256", alg: AlgRS256, private: rsaPrivate, public: rsaPublic},
+		{name: "EdDSA", alg: AlgEdDSA, private: edPrivate, public: edPublic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewKeyRing("k1", "", []Key{{ID: "k1", Alg: tt.alg, PrivateKey: tt.private}})
+			require.NoError(t, err)
+			userID := uuid.New()
+			token, err := signer.BuildUserJWTString(userID)
+			require.NoError(t, err)
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, parsed.Header["alg"])
+			assert.Equal(t, "k1", parsed.Header["kid"])
+			got, err := signer.GetUserID(token)
+			require.NoError(t, err)
+			assert.Equal(t, userID, got)
+
+			// a ring with only the public key verifies the token of the key
+			verifier, err := NewKeyRing("k2", "", []Key{{ID: "k1", Alg: tt.alg, PublicKey: tt.public}, {ID: "k2", Secret: secret1}})
+			require.NoError(t, err)
+			got, err = verifier.GetUserID(token)
+			require.NoError(t, err)
+			assert.Equal(t, userID, got)
+
+			// another service verifies the token with the published key
+			jwks := signer.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, jwks, verifier.JWKS())
+			claims := &Claims{}
+			_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+				return jwkPublicKey(t, jwks.Keys[0]), nil
+			}, jwt.WithValidMethods([]string{tt.alg}))
+			require.NoError(t, err)
+			assert.Equal(t, userID, claims.UserID)
+
+			// the public key passed off as an HMAC secret doesn't verify a forged token
+			forged := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: uuid.New()})
+			forged.Header["kid"] = "k1"
+			forgedString, err := forged.SignedString([]byte(tt.public))
+			require.NoError(t, err)
+			assert.False(t, signer.IsValidToken(forgedString))
+		})
+	}
+}
+
+func TestKeyRing_JWKS(t *testing.T) {
+	rsaPrivate := rsaKey(t, 2048)
+	rsaPEM, _ := pemKeys(t, rsaPrivate)
+	edPrivate := edKey(t)
+	_, edPEM := pemKeys(t, edPrivate)
+	keys, err := NewKeyRing("rsa", "", []Key{
+		{ID: "rsa", Alg: AlgRS256, PrivateKey: rsaPEM},
+		{ID: "hmac", Secret: secret1},
+		{ID: "ed", Alg: AlgEdDSA, PublicKey: edPEM},
+		{ID: "old", Alg: AlgRS256, Retired: true},
+	})
+	require.NoError(t, err)
+
+	// secrets of HS256 keys and retired keys aren't published
+	assert.Equal(t, JWKSet{Keys: []JWK{
+		{Kty: "OKP", Kid: "ed", Use: "sig", Alg: AlgEdDSA, Crv: "Ed25519",
+			X: base64.RawURLEncoding.EncodeToString(edPrivate.Public().(ed25519.PublicKey))},
+		{Kty: "RSA", Kid: "rsa", Use: "sig", Alg: AlgRS256,
+			N: base64.RawURLEncoding.EncodeToString(rsaPrivate.N.Bytes()), E: "AQAB"},
+	}}, keys.JWKS())
+
+	secretOnly, err := NewSecretKeyRing(secret1)
+	require.NoError(t, err)
+	assert.Equal(t, JWKSet{Keys: []JWK{}}, secretOnly.JWKS())
+}
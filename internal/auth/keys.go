@@ -0,0 +1,196 @@
+// Package auth provides functions for handling authentication, JWT token creation,
+// and validation.
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultKeyID is the kid of the single key created from a secret, used when no key ring file is configured.
+const DefaultKeyID = "default"
+
+// BuiltinKeyID is the kid of the key of BuiltinSecret, used when neither a key ring file nor a secret is configured.
+const BuiltinKeyID = "builtin"
+
+// BuiltinSecret is the secret built into the versions of the service before the key ring,
+// which signed the tokens users still have. It is public, so it is replaced by configured keys
+// and removed from the key ring once the tokens signed with it have expired.
+const BuiltinSecret = "SnJSkf123jlLKNfsNln"
+
+// minSecretLength is the length of HS256 secrets in bytes below which a warning is logged.
+const minSecretLength = 32
+
+// ErrKeyUnknown is an error indicating that a token is signed with a key that isn't in the key ring or is retired.
+var ErrKeyUnknown = errors.New("token signing key is unknown")
+
+// Key is a key signing JWT tokens, identified by the kid header of the tokens it signs.
+// Alg is the signature algorithm: AlgHS256 by default, AlgRS256 or AlgEdDSA.
+// An HS256 key has a secret set inline or read from SecretFile. An RS256 or EdDSA key has a PEM encoded
+// private key set inline or read from PrivateKeyFile; a key with only a public key, inline or from PublicKeyFile,
+// verifies the tokens it has signed but can't be active. File paths are relative to the key ring file.
+// A retired key no longer verifies tokens, it is kept in the key ring file only for the record.
+type Key struct {
+	ID             string `json:"kid"`
+	Alg            string `json:"alg,omitempty"`
+	Secret         string `json:"secret,omitempty"`
+	SecretFile     string `json:"secret_file,omitempty"`
+	PrivateKey     string `json:"private_key,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKey      string `json:"public_key,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+	Retired        bool   `json:"retired,omitempty"`
+}
+
+// keyRingFile is the JSON key ring file.
+// Active is the kid of the key signing new tokens, Legacy is the kid of the key verifying tokens
+// without a kid header, issued before the keys have been identified.
+type keyRingFile struct {
+	Active string `json:"active"`
+	Legacy string `json:"legacy,omitempty"`
+	Keys   []Key  `json:"keys"`
+}
+
+// KeyRing holds the keys signing and verifying the JWT tokens of users.
+// New tokens are signed with the active key, tokens signed with any other non-retired key stay valid,
+// so a key is rotated without invalidating the tokens users already have:
+// a new key is added to the ring of every instance first, then it is made active,
+// and the former key is retired once the tokens signed with it have expired.
+// The public keys of the asymmetric keys are published by JWKS, so other services verify tokens without a secret.
+type KeyRing struct {
+	active      string
+	legacy      string
+	keys        map[string]signingKey // the non-retired keys by kid
+	maxLifetime time.Duration         // time since the first token of a user, after which tokens aren't refreshed
+}
+
+// NewKeyRing creates a KeyRing signing tokens with the active key.
+// Tokens without a kid header are verified with the legacy key, they are rejected if legacy is empty.
+// It returns an error if a key has no kid, kids are repeated, a key can't be parsed for its algorithm,
+// or the active key is missing, retired or has no secret or private key to sign tokens.
+func NewKeyRing(active, legacy string, keys []Key) (*KeyRing, error) {
+	ring := &KeyRing{active: active, legacy: legacy, keys: make(map[string]signingKey, len(keys))}
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("JWT signing key without kid")
+		}
+		if _, ok := seen[key.ID]; ok {
+			return nil, fmt.Errorf("JWT signing key %q is repeated", key.ID)
+		}
+		seen[key.ID] = struct{}{}
+		if key.Retired {
+			continue
+		}
+		signing, err := newSigningKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[key.ID] = signing
+	}
+	activeKey, ok := ring.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("active JWT signing key %q is missing or retired", active)
+	}
+	if activeKey.sign == nil {
+		return nil, fmt.Errorf("active JWT signing key %q has no private key", active)
+	}
+	if _, ok := ring.keys[legacy]; legacy != "" && !ok {
+		return nil, fmt.Errorf("legacy JWT signing key %q is missing or retired", legacy)
+	}
+	return ring, nil
+}
+
+// NewSecretKeyRing creates a KeyRing of the single key DefaultKeyID with the secret.
+// The key also verifies tokens without a kid header, so the secret of the tokens issued
+// before the key ring has been introduced keeps them valid.
+func NewSecretKeyRing(secret string) (*KeyRing, error) {
+	return NewKeyRing(DefaultKeyID, DefaultKeyID, []Key{{ID: DefaultKeyID, Secret: secret}})
+}
+
+// NewBuiltinKeyRing creates a KeyRing of the single key BuiltinKeyID with BuiltinSecret.
+// The key also verifies tokens without a kid header, so the tokens issued by the former versions
+// stay valid after upgrade and the tokens it signs stay valid after restart and across instances.
+func NewBuiltinKeyRing() (*KeyRing, error) {
+	return NewKeyRing(BuiltinKeyID, BuiltinKeyID, []Key{{ID: BuiltinKeyID, Secret: BuiltinSecret}})
+}
+
+// GenerateKeyRing creates a KeyRing of a single random key.
+// Tokens signed with it become invalid when the process restarts, and aren't accepted by other instances.
+func GenerateKeyRing() (*KeyRing, error) {
+	secret := make([]byte, minSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	id := "generated-" + hex.EncodeToString(secret[:4])
+	return NewKeyRing(id, "", []Key{{ID: id, Secret: hex.EncodeToString(secret)}})
+}
+
+// LoadKeyRing reads the KeyRing from the JSON key ring file.
+// Secrets of the keys with a SecretFile are read from the files, trailing line breaks are trimmed.
+// PEM encoded keys of the keys with a PrivateKeyFile or a PublicKeyFile are read from the files as they are.
+func LoadKeyRing(path string) (*KeyRing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWT key ring: %w", err)
+	}
+	var file keyRingFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing JWT key ring %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for i, key := range file.Keys {
+		if key.Retired {
+			continue
+		}
+		if key.SecretFile != "" {
+			secret, err := readKeyFile(dir, key.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading secret of JWT signing key %q: %w", key.ID, err)
+			}
+			file.Keys[i].Secret = strings.TrimRight(secret, "\r\n")
+		}
+		if key.PrivateKeyFile != "" {
+			if file.Keys[i].PrivateKey, err = readKeyFile(dir, key.PrivateKeyFile); err != nil {
+				return nil, fmt.Errorf("reading private key of JWT signing key %q: %w", key.ID, err)
+			}
+		}
+		if key.PublicKeyFile != "" {
+			if file.Keys[i].PublicKey, err = readKeyFile(dir, key.PublicKeyFile); err != nil {
+				return nil, fmt.Errorf("reading public key of JWT signing key %q: %w", key.ID, err)
+			}
+		}
+	}
+	return NewKeyRing(file.Active, file.Legacy, file.Keys)
+}
+
+// readKeyFile reads the file of a key, a relative path is resolved against the directory of the key ring file.
+func readKeyFile(dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SetMaxLifetime sets the time since the first token of a user, after which the tokens of the user are no longer refreshed
+// and the user gets a new identity when the last token expires. Tokens aren't refreshed if it is less than TokenExp,
+// which is the lifetime of tokens of a ring created without it.
+func (r *KeyRing) SetMaxLifetime(maxLifetime time.Duration) {
+	r.maxLifetime = maxLifetime
+}
+
+// ActiveKeyID returns the kid of the key signing new tokens.
+func (r *KeyRing) ActiveKeyID() string {
+	return r.active
+}
@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	secret1 = "first-secret-of-at-least-32-bytes"
+	secret2 = "second-secret-of-at-least-32-byte"
+)
+
+// legacyToken signs a token without a kid header, as tokens were issued before the key ring.
+func legacyToken(t *testing.T, secret string, userID uuid.UUID) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp))},
+		UserID:           userID,
+	})
+	tokenString, err := token.SignedString([]byte(secret))
+	require.NoError(t, err)
+	return tokenString
+}
+
+func TestNewKeyRing(t *testing.T) {
+	_, edPublic := pemKeys(t, edKey(t))
+	shortRSAPrivate, _ := pemKeys(t, rsaKey(t, 1024))
+	tests := []struct {
+		name    string
+		active  string
+		legacy  string
+		keys    []Key
+		wantErr string
+	}{
+		{
+			name:   "active and retired keys",
+			active: "k2",
+			keys:   []Key{{ID: "k1", Secret: secret1}, {ID: "k2", Secret: secret2}, {ID: "k0", Retired: true}},
+		},
+		{
+			name:    "key without kid",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Secret: secret1}, {Secret: secret2}},
+			wantErr: "JWT signing key without kid",
+		},
+		{
+			name:    "repeated kid",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Secret: secret1}, {ID: "k1", Secret: secret2}},
+			wantErr: `JWT signing key "k1" is repeated`,
+		},
+		{
+			name:    "key without secret",
+			active:  "k1",
+			keys:    []Key{{ID: "k1"}},
+			wantErr: `JWT signing key "k1" has no secret`,
+		},
+		{
+			name:    "retired active key",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Secret: secret1, Retired: true}},
+			wantErr: `active JWT signing key "k1" is missing or retired`,
+		},
+		{
+			name:    "missing legacy key",
+			active:  "k1",
+			legacy:  "k0",
+			keys:    []Key{{ID: "k1", Secret: secret1}},
+			wantErr: `legacy JWT signing key "k0" is missing or retired`,
+		},
+		{
+			name:    "unsupported alg",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Alg: "ES256", PrivateKey: "..."}},
+			wantErr: `JWT signing key "k1" has unsupported alg "ES256"`,
+		},
+		{
+			name:    "asymmetric key without keys",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Alg: AlgEdDSA, Secret: secret1}},
+			wantErr: `JWT signing key "k1" has no private or public key`,
+		},
+		{
+			name:    "malformed private key",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Alg: AlgRS256, PrivateKey: "not a PEM key"}},
+			wantErr: `parsing private key of JWT signing key "k1": invalid key: Key must be a PEM encoded PKCS1 or PKCS8 key`,
+		},
+		{
+			name:    "active key with only the public key",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Alg: AlgEdDSA, PublicKey: edPublic}},
+			wantErr: `active JWT signing key "k1" has no private key`,
+		},
+		{
+			name:    "short RSA key",
+			active:  "k1",
+			keys:    []Key{{ID: "k1", Alg: AlgRS256, PrivateKey: shortRSAPrivate}},
+			wantErr: `RSA key of JWT signing key "k1" has 1024 bits, at least 2048 are required`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeyRing(tt.active, tt.legacy, tt.keys)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.active, keys.ActiveKeyID())
+		})
+	}
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	before, err := NewKeyRing("k1", "", []Key{{ID: "k1", Secret: secret1}})
+	require.NoError(t, err)
+	token, err := before.BuildJWTString()
+	require.NoError(t, err)
+	userID, err := before.GetUserID(token)
+	require.NoError(t, err)
+
+	// the new key signs new tokens, the former key keeps verifying the issued tokens
+	rotated, err := NewKeyRing("k2", "", []Key{{ID: "k1", Secret: secret1}, {ID: "k2", Secret: secret2}})
+	require.NoError(t, err)
+	got, err := rotated.GetUserID(token)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got)
+	newToken, err := rotated.BuildJWTString()
+	require.NoError(t, err)
+	assert.False(t, before.IsValidToken(newToken))
+
+	// the retired key no longer verifies tokens
+	retired, err := NewKeyRing("k2", "", []Key{{ID: "k1", Retired: true}, {ID: "k2", Secret: secret2}})
+	require.NoError(t, err)
+	assert.False(t, retired.IsValidToken(token))
+	assert.True(t, retired.IsValidToken(newToken))
+	_, err = retired.GetUserID(token)
+	assert.ErrorIs(t, err, ErrKeyUnknown)
+}
+
+func TestKeyRing_LegacyTokens(t *testing.T) {
+	userID := uuid.New()
+	token := legacyToken(t, secret1, userID)
+
+	keys, err := NewSecretKeyRing(secret1)
+	require.NoError(t, err)
+	got, err := keys.GetUserID(token)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got)
+
+	withoutLegacy, err := NewKeyRing("k1", "", []Key{{ID: "k1", Secret: secret1}})
+	require.NoError(t, err)
+	assert.False(t, withoutLegacy.IsValidToken(token))
+
+	anotherSecret, err := NewSecretKeyRing(secret2)
+	require.NoError(t, err)
+	assert.False(t, anotherSecret.IsValidToken(token))
+}
+
+func TestNewBuiltinKeyRing(t *testing.T) {
+	userID := uuid.New()
+	token := legacyToken(t, BuiltinSecret, userID)
+
+	keys, err := NewBuiltinKeyRing()
+	require.NoError(t, err)
+	assert.Equal(t, BuiltinKeyID, keys.ActiveKeyID())
+	got, err := keys.GetUserID(token)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got)
+
+	// tokens it signs are verified after restart
+	newToken, err := keys.BuildJWTString()
+	require.NoError(t, err)
+	restarted, err := NewBuiltinKeyRing()
+	require.NoError(t, err)
+	assert.True(t, restarted.IsValidToken(newToken))
+
+	// rotation: the built-in key is kept as legacy while a configured key signs new tokens, then removed
+	rotating, err := NewKeyRing("k1", BuiltinKeyID, []Key{{ID: "k1", Secret: secret1}, {ID: BuiltinKeyID, Secret: BuiltinSecret}})
+	require.NoError(t, err)
+	assert.True(t, rotating.IsValidToken(token))
+	assert.True(t, rotating.IsValidToken(newToken))
+	rotated, err := NewKeyRing("k1", "", []Key{{ID: "k1", Secret: secret1}})
+	require.NoError(t, err)
+	assert.False(t, rotated.IsValidToken(token))
+	assert.False(t, rotated.IsValidToken(newToken))
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "k2.secret"), []byte(secret2+"\n"), 0600))
+	path := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"active": "k2",
+		"legacy": "k1",
+		"keys": [
+			{"kid": "k1", "secret": "`+secret1+`"},
+			{"kid": "k2", "secret_file": "k2.secret"},
+			{"kid": "k0", "secret_file": "missing.secret", "retired": true}
+		]
+	}`), 0600))
+
+	keys, err := LoadKeyRing(path)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keys.ActiveKeyID())
+	assert.True(t, keys.IsValidToken(legacyToken(t, secret1, uuid.New())))
+
+	// a token of the key read from the file is verified by a ring with the same secret inline
+	token, err := keys.BuildJWTString()
+	require.NoError(t, err)
+	inline, err := NewKeyRing("k2", "", []Key{{ID: "k2", Secret: secret2}})
+	require.NoError(t, err)
+	assert.True(t, inline.IsValidToken(token))
+
+	_, err = LoadKeyRing(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestLoadKeyRing_PEMFiles(t *testing.T) {
+	dir := t.TempDir()
+	rsaPrivate, _ := pemKeys(t, rsaKey(t, 2048))
+	_, edPublic := pemKeys(t, edKey(t))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rsa.pem"), []byte(rsaPrivate), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ed.pub.pem"), []byte(edPublic), 0600))
+	path := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"active": "rsa",
+		"keys": [
+			{"kid": "rsa", "alg": "RS256", "private_key_file": "rsa.pem"},
+			{"kid": "ed", "alg": "EdDSA", "public_key_file": "ed.pub.pem"},
+			{"kid": "old", "alg": "RS256", "private_key_file": "missing.pem", "retired": true}
+		]
+	}`), 0600))
+
+	keys, err := LoadKeyRing(path)
+	require.NoError(t, err)
+	assert.Equal(t, "rsa", keys.ActiveKeyID())
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "ed", jwks.Keys[0].Kid)
+	assert.Equal(t, "rsa", jwks.Keys[1].Kid)
+
+	// a token of the key read from the file is verified by a ring with the same key inline
+	token, err := keys.BuildJWTString()
+	require.NoError(t, err)
+	inline, err := NewKeyRing("rsa", "", []Key{{ID: "rsa", Alg: AlgRS256, PrivateKey: rsaPrivate}})
+	require.NoError(t, err)
+	assert.True(t, inline.IsValidToken(token))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"active": "rsa",
+		"keys": [{"kid": "rsa", "alg": "RS256", "private_key_file": "missing.pem"}]
+	}`), 0600))
+	_, err = LoadKeyRing(path)
+	assert.ErrorContains(t, err, `reading private key of JWT signing key "rsa"`)
+}
@@ -0,0 +1,58 @@
+// Package auth provides functions for handling authentication, JWT token creation,
+// and validation.
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// RefreshJWTString reissues a valid token expiring in less than RefreshBefore, so that active users keep
+// their UserID instead of getting a new identity when the token expires.
+// The new token has the same UserID and AuthTime, is signed with the active key of the ring and expires
+// in TokenExp, but not later than the maximum lifetime after AuthTime.
+// It returns the token itself if it doesn't need to be refreshed or has reached the maximum lifetime,
+// and an error if the token isn't valid.
+func (r *KeyRing) RefreshJWTString(tokenString string) (string, error) {
+	claims, err := r.parseClaims(tokenString)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if claims.ExpiresAt == nil || claims.ExpiresAt.Sub(now) >= RefreshBefore {
+		return tokenString, nil
+	}
+	// tokens issued before AuthTime has been introduced were valid for TokenExp
+	authTime := claims.ExpiresAt.Add(-TokenExp)
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime.Time
+	}
+	expiresAt := r.expiresAt(authTime, now)
+	if !expiresAt.After(claims.ExpiresAt.Time) {
+		return tokenString, nil
+	}
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	claims.AuthTime = jwt.NewNumericDate(authTime)
+	refreshed, err := r.signClaims(*claims)
+	if err != nil {
+		return "", err
+	}
+	logrus.Infof("Token of user %v is refreshed", claims.UserID)
+	return refreshed, nil
+}
+
+// expiresAt returns the expiration time of a token issued now to the user first authenticated at authTime:
+// in TokenExp, but not later than the maximum lifetime after authTime.
+func (r *KeyRing) expiresAt(authTime, now time.Time) time.Time {
+	expiresAt := now.Add(TokenExp)
+	maxLifetime := r.maxLifetime
+	if maxLifetime < TokenExp {
+		maxLifetime = TokenExp
+	}
+	if limit := authTime.Add(maxLifetime); expiresAt.After(limit) {
+		return limit
+	}
+	return expiresAt
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRing_RefreshJWTString(t *testing.T) {
+	keys, err := NewKeyRing("k2", "", []Key{{ID: "k1", Secret: secret1}, {ID: "k2", Secret: secret2}})
+	require.NoError(t, err)
+	keys.SetMaxLifetime(DefaultMaxLifetime)
+	formerKeys, err := NewKeyRing("k1", "", []Key{{ID: "k1", Secret: secret1}})
+	require.NoError(t, err)
+	now := time.Now()
+	userID := uuid.New()
+
+	tests := []struct {
+		name          string
+		keys          *KeyRing
+		expiresAt     time.Time
+		authTime      *time.Time
+		wantRefreshed bool
+		wantExpiresAt time.Time
+		wantAuthTime  time.Time
+	}{
+		{
+			name:      "fresh token",
+			keys:      keys,
+			expiresAt: now.Add(TokenExp - time.Minute),
+			authTime:  timePtr(now.Add(-time.Minute)),
+		},
+		{
+			name:          "token close to expiration",
+			keys:          keys,
+			expiresAt:     now.Add(30 * time.Minute),
+			authTime:      timePtr(now.Add(-10 * time.Hour)),
+			wantRefreshed: true,
+			wantExpiresAt: now.Add(TokenExp),
+			wantAuthTime:  now.Add(-10 * time.Hour),
+		},
+		{
+			name:          "token signed with the former key",
+			keys:          formerKeys,
+			expiresAt:     now.Add(30 * time.Minute),
+			authTime:      timePtr(now.Add(-time.Hour)),
+			wantRefreshed: true,
+			wantExpiresAt: now.Add(TokenExp),
+			wantAuthTime:  now.Add(-time.Hour),
+		},
+		{
+			name:          "token close to the maximum lifetime",
+			keys:          keys,
+			expiresAt:     now.Add(30 * time.Minute),
+			authTime:      timePtr(now.Add(time.Hour - DefaultMaxLifetime)),
+			wantRefreshed: true,
+			wantExpiresAt: now.Add(time.Hour),
+			wantAuthTime:  now.Add(time.Hour - DefaultMaxLifetime),
+		},
+		{
+			name:      "token at the maximum lifetime",
+			keys:      keys,
+			expiresAt: now.Add(30 * time.Minute),
+			authTime:  timePtr(now.Add(30*time.Minute - DefaultMaxLifetime)),
+		},
+		{
+			name:          "token without auth time",
+			keys:          keys,
+			expiresAt:     now.Add(30 * time.Minute),
+			wantRefreshed: true,
+			wantExpiresAt: now.Add(TokenExp),
+			wantAuthTime:  now.Add(30*time.Minute - TokenExp),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := Claims{
+				RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(tt.expiresAt)},
+				UserID:           userID,
+			}
+			if tt.authTime != nil {
+				claims.AuthTime = jwt.NewNumericDate(*tt.authTime)
+			}
+			token, err := tt.keys.signClaims(claims)
+			require.NoError(t, err)
+
+			refreshed, err := keys.RefreshJWTString(token)
+			require.NoError(t, err)
+			if !tt.wantRefreshed {
+				assert.Equal(t, token, refreshed)
+				return
+			}
+			require.NotEqual(t, token, refreshed)
+			got, err := keys.parseClaims(refreshed)
+			require.NoError(t, err)
+			assert.Equal(t, userID, got.UserID)
+			assert.WithinDuration(t, tt.wantExpiresAt, got.ExpiresAt.Time, time.Second)
+			assert.WithinDuration(t, tt.wantAuthTime, got.AuthTime.Time, time.Second)
+			parsed, _, err := jwt.NewParser().ParseUnverified(refreshed, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, "k2", parsed.Header["kid"])
+		})
+	}
+}
+
+func TestKeyRing_RefreshJWTString_Invalid(t *testing.T) {
+	keys := testKeyRing(t)
+	expired, err := keys.signClaims(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))},
+		UserID:           uuid.New(),
+	})
+	require.NoError(t, err)
+
+	for _, token := range []string{expired, "not a token"} {
+		_, err = keys.RefreshJWTString(token)
+		assert.Error(t, err)
+	}
+}
+
+func TestKeyRing_BuildJWTString_Claims(t *testing.T) {
+	keys := testKeyRing(t)
+	token, err := keys.BuildJWTString()
+	require.NoError(t, err)
+	claims, err := keys.parseClaims(token)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), claims.AuthTime.Time, time.Second)
+	assert.WithinDuration(t, time.Now().Add(TokenExp), claims.ExpiresAt.Time, time.Second)
+}
+
+func TestKeyRing_BuildUserJWTString(t *testing.T) {
+	keys := testKeyRing(t)
+	userID := uuid.New()
+	token, err := keys.BuildUserJWTString(userID)
+	require.NoError(t, err)
+	got, err := keys.GetUserID(token)
+	require.NoError(t, err)
+	assert.Equal(t, userID, got)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
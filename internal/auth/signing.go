@@ -0,0 +1,112 @@
+// Package auth provides functions for handling authentication, JWT token creation,
+// and validation.
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Signature algorithms of the keys, set in the alg field of a key.
+const (
+	// AlgHS256 signs tokens with HMAC SHA-256 and a secret shared by every party verifying them, it is the default.
+	AlgHS256 = "HS256"
+	// AlgRS256 signs tokens with RSA PKCS #1 v1.5 and SHA-256, the public key verifying them is published in the JWKS.
+	AlgRS256 = "RS256"
+	// AlgEdDSA signs tokens with Ed25519, the public key verifying them is published in the JWKS.
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSAKeyBits is the smallest size of the RSA keys accepted for RS256.
+const minRSAKeyBits = 2048
+
+// signingKey is a key of the ring parsed for signing and verifying tokens.
+// Keys of asymmetric algorithms set only by the public key verify tokens but can't sign them.
+type signingKey struct {
+	method jwt.SigningMethod
+	sign   interface{} // the secret or the private key, nil if the key only verifies tokens
+	verify interface{} // the secret or the public key
+}
+
+// newSigningKey parses the secret or the PEM encoded keys of the key according to its algorithm.
+// The public key of an asymmetric key is derived from the private key if it is set.
+func newSigningKey(key Key) (signingKey, error) {
+	switch key.Alg {
+	case "", AlgHS256:
+		if key.Secret == "" {
+			return signingKey{}, fmt.Errorf("JWT signing key %q has no secret", key.ID)
+		}
+		if len(key.Secret) < minSecretLength {
+			logrus.Warnf("JWT signing key %q is shorter than %d bytes", key.ID, minSecretLength)
+		}
+		secret := []byte(key.Secret)
+		return signingKey{method: jwt.SigningMethodHS256, sign: secret, verify: secret}, nil
+	case AlgRS256:
+		return newRSAKey(key)
+	case AlgEdDSA:
+		return newEdDSAKey(key)
+	default:
+		return signingKey{}, fmt.Errorf("JWT signing key %q has unsupported alg %q", key.ID, key.Alg)
+	}
+}
+
+// newRSAKey parses the RSA keys of the RS256 key, keys shorter than minRSAKeyBits are rejected.
+func newRSAKey(key Key) (signingKey, error) {
+	result := signingKey{method: jwt.SigningMethodRS256}
+	var public *rsa.PublicKey
+	switch {
+	case key.PrivateKey != "":
+		private, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey))
+		if err != nil {
+			return signingKey{}, fmt.Errorf("parsing private key of JWT signing key %q: %w", key.ID, err)
+		}
+		result.sign, public = private, &private.PublicKey
+	case key.PublicKey != "":
+		var err error
+		if public, err = jwt.ParseRSAPublicKeyFromPEM([]byte(key.PublicKey)); err != nil {
+			return signingKey{}, fmt.Errorf("parsing public key of JWT signing key %q: %w", key.ID, err)
+		}
+	default:
+		return signingKey{}, fmt.Errorf("JWT signing key %q has no private or public key", key.ID)
+	}
+	if bits := public.N.BitLen(); bits < minRSAKeyBits {
+		return signingKey{}, fmt.Errorf("RSA key of JWT signing key %q has %d bits, at least %d are required",
+			key.ID, bits, minRSAKeyBits)
+	}
+	result.verify = public
+	return result, nil
+}
+
+// newEdDSAKey parses the Ed25519 keys of the EdDSA key.
+func newEdDSAKey(key Key) (signingKey, error) {
+	result := signingKey{method: jwt.SigningMethodEdDSA}
+	switch {
+	case key.PrivateKey != "":
+		private, err := jwt.ParseEdPrivateKeyFromPEM([]byte(key.PrivateKey))
+		if err != nil {
+			return signingKey{}, fmt.Errorf("parsing private key of JWT signing key %q: %w", key.ID, err)
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return signingKey{}, fmt.Errorf("private key of JWT signing key %q is not an Ed25519 key", key.ID)
+		}
+		result.sign, result.verify = private, signer.Public()
+	case key.PublicKey != "":
+		public, err := jwt.ParseEdPublicKeyFromPEM([]byte(key.PublicKey))
+		if err != nil {
+			return signingKey{}, fmt.Errorf("parsing public key of JWT signing key %q: %w", key.ID, err)
+		}
+		result.verify = public
+	default:
+		return signingKey{}, fmt.Errorf("JWT signing key %q has no private or public key", key.ID)
+	}
+	if _, ok := result.verify.(ed25519.PublicKey); !ok {
+		return signingKey{}, fmt.Errorf("JWT signing key %q is not an Ed25519 key", key.ID)
+	}
+	return result, nil
+}
@@ -3,11 +3,32 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/DenisKhanov/shorterURL/internal/auth"
+	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/caarlos0/env"
 	"github.com/sirupsen/logrus"
+	"net/url"
 	"os"
+	"strings"
+	"time"
+)
+
+// Default settings of custom aliases.
+const (
+	// DefaultAliasCharset lists the characters allowed in custom aliases by default.
+	DefaultAliasCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+	// DefaultAliasReserved lists the aliases colliding with the service routes.
+	DefaultAliasReserved = "ping,api,debug"
+)
+
+// Storage types selected by STORAGE_TYPE.
+const (
+	StorageMemory   = "memory"   // StorageMemory keeps URLs in memory and appends them to the JSON file
+	StoragePostgres = "postgres" // StoragePostgres keeps URLs in the PostgreSQL database from DATABASE_DSN
+	StorageBolt     = "bolt"     // StorageBolt keeps URLs in the embedded bbolt database file
 )
 
 // ENVConfig holds configuration settings extracted from environment variables.
@@ -22,6 +43,38 @@ type ENVConfig struct {
 	EnvTLS         string `env:"ENABLE_TLS"`
 	EnvSubnet      string `env:"TRUSTED_SUBNET"`
 	EnvGRPC        string `env:"GRPC_SERVER"`
+
+	EnvStorageType string `env:"STORAGE_TYPE"`
+	EnvBoltPath    string `env:"BOLT_STORAGE_PATH"`
+
+	EnvFileFlushRecords    int           `env:"FILE_FLUSH_RECORDS"`
+	EnvFileFlushInterval   time.Duration `env:"FILE_FLUSH_INTERVAL"`
+	EnvFileCompactInterval time.Duration `env:"FILE_COMPACT_INTERVAL"`
+
+	EnvCacheSize        int           `env:"CACHE_SIZE"`
+	EnvCacheTTL         time.Duration `env:"CACHE_TTL"`
+	EnvCacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL"`
+
+	EnvAliasCharset   string `env:"ALIAS_CHARSET"`
+	EnvAliasMinLength int    `env:"ALIAS_MIN_LENGTH"`
+	EnvAliasMaxLength int    `env:"ALIAS_MAX_LENGTH"`
+	EnvAliasReserved  string `env:"ALIAS_RESERVED"`
+
+	EnvExpiredCleanupInterval time.Duration `env:"EXPIRED_CLEANUP_INTERVAL"`
+	EnvExpiredRetention       time.Duration `env:"EXPIRED_RETENTION"`
+
+	EnvDeletedRestorePeriod time.Duration `env:"DELETED_RESTORE_PERIOD"`
+	EnvDeletedPurgeInterval time.Duration `env:"DELETED_PURGE_INTERVAL"`
+	EnvDeleteWorkers        int           `env:"DELETE_WORKERS"`
+
+	EnvShortDomains string `env:"SHORT_DOMAINS"`
+
+	EnvRedirectCode int `env:"REDIRECT_CODE"`
+
+	EnvJWTKeysFile string `env:"JWT_KEYS_FILE"`
+	EnvJWTSecret   string `env:"JWT_SECRET"`
+
+	EnvTokenMaxLifetime time.Duration `env:"TOKEN_MAX_LIFETIME"`
 }
 
 // NewConfig creates a new ENVConfig instance by parsing command line flags and environment variables.
@@ -47,6 +100,60 @@ func NewConfig() (*ENVConfig, error) {
 
 	flag.StringVar(&cfg.EnvGRPC, "g", ":3200", "Enter gRPC server address or use GRPC_SERVER env")
 
+	flag.StringVar(&cfg.EnvStorageType, "storage-type", "", "Enter storage type memory, postgres or bolt (by default postgres if the database DSN is set, "+
+		"otherwise memory) or use STORAGE_TYPE env")
+
+	flag.StringVar(&cfg.EnvBoltPath, "bolt-storage-path", "/tmp/short-url.bolt", "Enter path of the bolt storage file or use BOLT_STORAGE_PATH env")
+
+	flag.IntVar(&cfg.EnvFileFlushRecords, "file-flush-records", 100, "Enter number of records written to the storage file at once, "+
+		"1 writes every change, or use FILE_FLUSH_RECORDS env")
+
+	flag.DurationVar(&cfg.EnvFileFlushInterval, "file-flush-interval", time.Second, "Enter max time a change waits before writing "+
+		"to the storage file, 0 disables writing by time, or use FILE_FLUSH_INTERVAL env")
+
+	flag.DurationVar(&cfg.EnvFileCompactInterval, "file-compact-interval", time.Hour, "Enter interval of the storage file compaction, 0 disables it, "+
+		"or use FILE_COMPACT_INTERVAL env")
+
+	flag.IntVar(&cfg.EnvCacheSize, "cache-size", 10000, "Enter max number of cached redirect lookups, 0 disables the cache, or use CACHE_SIZE env")
+
+	flag.DurationVar(&cfg.EnvCacheTTL, "cache-ttl", time.Minute, "Enter how long found URLs are cached or use CACHE_TTL env")
+
+	flag.DurationVar(&cfg.EnvCacheNegativeTTL, "cache-negative-ttl", 5*time.Second, "Enter how long missing and deleted URLs are cached or use CACHE_NEGATIVE_TTL env")
+
+	flag.StringVar(&cfg.EnvAliasCharset, "alias-charset", DefaultAliasCharset, "Enter characters allowed in custom aliases or use ALIAS_CHARSET env")
+
+	flag.IntVar(&cfg.EnvAliasMinLength, "alias-min-length", 3, "Enter min length of custom aliases or use ALIAS_MIN_LENGTH env")
+
+	flag.IntVar(&cfg.EnvAliasMaxLength, "alias-max-length", 64, "Enter max length of custom aliases or use ALIAS_MAX_LENGTH env")
+
+	flag.StringVar(&cfg.EnvAliasReserved, "alias-reserved", DefaultAliasReserved, "Enter comma separated aliases reserved for service routes or use ALIAS_RESERVED env")
+
+	flag.DurationVar(&cfg.EnvExpiredCleanupInterval, "expired-cleanup-interval", time.Minute, "Enter interval of removing expired URLs or use EXPIRED_CLEANUP_INTERVAL env")
+
+	flag.DurationVar(&cfg.EnvExpiredRetention, "expired-retention", 24*time.Hour, "Enter how long expired URLs are kept before removing or use EXPIRED_RETENTION env")
+
+	flag.DurationVar(&cfg.EnvDeletedRestorePeriod, "deleted-restore-period", 7*24*time.Hour, "Enter how long deleted URLs can be restored "+
+		"before removing or use DELETED_RESTORE_PERIOD env")
+
+	flag.DurationVar(&cfg.EnvDeletedPurgeInterval, "deleted-purge-interval", time.Hour, "Enter interval of removing deleted URLs "+
+		"whose restore period is over, 0 disables it, or use DELETED_PURGE_INTERVAL env")
+
+	flag.IntVar(&cfg.EnvDeleteWorkers, "delete-workers", 4, "Enter number of workers marking queued URLs as deleted or use DELETE_WORKERS env")
+
+	flag.StringVar(&cfg.EnvShortDomains, "short-domains", "", "Enter comma separated base URLs of the additional short domains as https://host "+
+		"or use SHORT_DOMAINS env")
+
+	flag.IntVar(&cfg.EnvRedirectCode, "redirect-code", models.DefaultRedirectCode, "Enter HTTP status code of redirects "+
+		"by new short URLs unless another code is requested, 301, 302, 307 or 308, or use REDIRECT_CODE env")
+
+	flag.StringVar(&cfg.EnvJWTKeysFile, "jwt-keys-file", "", "Enter path to the JSON key ring file of JWT signing keys or use JWT_KEYS_FILE env")
+
+	flag.StringVar(&cfg.EnvJWTSecret, "jwt-secret", "", "Enter secret of the single JWT signing key used without key ring file "+
+		"or use JWT_SECRET env")
+
+	flag.DurationVar(&cfg.EnvTokenMaxLifetime, "token-max-lifetime", auth.DefaultMaxLifetime, "Enter time since the first token "+
+		"of a user, after which the tokens of the user aren't refreshed, or use TOKEN_MAX_LIFETIME env")
+
 	flag.Parse()
 
 	// Parse config from JSON file if provided
@@ -69,9 +176,89 @@ func NewConfig() (*ENVConfig, error) {
 		return nil, err
 	}
 
+	if err = cfg.setStorageType(); err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	if cfg.EnvFileFlushRecords < 1 {
+		err = fmt.Errorf("number of records written to the storage file at once must be positive, got %d", cfg.EnvFileFlushRecords)
+		logrus.Error(err)
+		return nil, err
+	}
+
+	if cfg.EnvDeletedRestorePeriod < 0 {
+		err = fmt.Errorf("restore period of deleted URLs mustn't be negative, got %v", cfg.EnvDeletedRestorePeriod)
+		logrus.Error(err)
+		return nil, err
+	}
+
+	if cfg.EnvDeleteWorkers < 1 {
+		err = fmt.Errorf("number of deletion workers must be positive, got %d", cfg.EnvDeleteWorkers)
+		logrus.Error(err)
+		return nil, err
+	}
+
+	if err = checkShortDomains(cfg.EnvShortDomains); err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+
+	if !models.IsRedirectCode(cfg.EnvRedirectCode) {
+		err = fmt.Errorf("redirect code must be 301, 302, 307 or 308, got %d", cfg.EnvRedirectCode)
+		logrus.Error(err)
+		return nil, err
+	}
+
+	if cfg.EnvJWTKeysFile != "" && cfg.EnvJWTSecret != "" {
+		err = errors.New("JWT keys file and JWT secret can't be set together")
+		logrus.Error(err)
+		return nil, err
+	}
+
+	if cfg.EnvTokenMaxLifetime < auth.TokenExp {
+		err = fmt.Errorf("token max lifetime must be at least %s, got %s", auth.TokenExp, cfg.EnvTokenMaxLifetime)
+		logrus.Error(err)
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// checkShortDomains checks that the additional short domains are comma separated http or https base URLs.
+func checkShortDomains(domains string) error {
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.TrimSpace(domain); domain == "" {
+			continue
+		}
+		parsed, err := url.Parse(domain)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("short domain %q must be a base URL as https://host", domain)
+		}
+	}
+	return nil
+}
+
+// setStorageType checks the storage type. If it isn't set, PostgreSQL is used when
+// the database DSN is set, otherwise the in-memory storage.
+func (cfg *ENVConfig) setStorageType() error {
+	switch cfg.EnvStorageType {
+	case "":
+		cfg.EnvStorageType = StorageMemory
+		if cfg.EnvDataBase != "" {
+			cfg.EnvStorageType = StoragePostgres
+		}
+	case StorageMemory, StorageBolt:
+	case StoragePostgres:
+		if cfg.EnvDataBase == "" {
+			return fmt.Errorf("storage type %s requires DATABASE_DSN", StoragePostgres)
+		}
+	default:
+		return fmt.Errorf("unknown storage type %q", cfg.EnvStorageType)
+	}
+	return nil
+}
+
 // getConfigFilePath returns the path to the config file specified by the -c flag or the CONFIG environment variable.
 func getConfigFilePath() string {
 	cfgFile := os.Getenv("CONFIG")
@@ -122,6 +309,72 @@ func setConfigFromFile(path string, cfg1 *ENVConfig) error {
 	if flag.Lookup("g") == nil {
 		cfg1.EnvSubnet = cfgFromFile.EnvSubnet
 	}
+	if flag.Lookup("storage-type") == nil {
+		cfg1.EnvStorageType = cfgFromFile.EnvStorageType
+	}
+	if flag.Lookup("bolt-storage-path") == nil {
+		cfg1.EnvBoltPath = cfgFromFile.EnvBoltPath
+	}
+	if flag.Lookup("file-flush-records") == nil {
+		cfg1.EnvFileFlushRecords = cfgFromFile.EnvFileFlushRecords
+	}
+	if flag.Lookup("file-flush-interval") == nil {
+		cfg1.EnvFileFlushInterval = cfgFromFile.EnvFileFlushInterval
+	}
+	if flag.Lookup("file-compact-interval") == nil {
+		cfg1.EnvFileCompactInterval = cfgFromFile.EnvFileCompactInterval
+	}
+	if flag.Lookup("cache-size") == nil {
+		cfg1.EnvCacheSize = cfgFromFile.EnvCacheSize
+	}
+	if flag.Lookup("cache-ttl") == nil {
+		cfg1.EnvCacheTTL = cfgFromFile.EnvCacheTTL
+	}
+	if flag.Lookup("cache-negative-ttl") == nil {
+		cfg1.EnvCacheNegativeTTL = cfgFromFile.EnvCacheNegativeTTL
+	}
+	if flag.Lookup("alias-charset") == nil {
+		cfg1.EnvAliasCharset = cfgFromFile.EnvAliasCharset
+	}
+	if flag.Lookup("alias-min-length") == nil {
+		cfg1.EnvAliasMinLength = cfgFromFile.EnvAliasMinLength
+	}
+	if flag.Lookup("alias-max-length") == nil {
+		cfg1.EnvAliasMaxLength = cfgFromFile.EnvAliasMaxLength
+	}
+	if flag.Lookup("alias-reserved") == nil {
+		cfg1.EnvAliasReserved = cfgFromFile.EnvAliasReserved
+	}
+	if flag.Lookup("expired-cleanup-interval") == nil {
+		cfg1.EnvExpiredCleanupInterval = cfgFromFile.EnvExpiredCleanupInterval
+	}
+	if flag.Lookup("expired-retention") == nil {
+		cfg1.EnvExpiredRetention = cfgFromFile.EnvExpiredRetention
+	}
+	if flag.Lookup("deleted-restore-period") == nil {
+		cfg1.EnvDeletedRestorePeriod = cfgFromFile.EnvDeletedRestorePeriod
+	}
+	if flag.Lookup("deleted-purge-interval") == nil {
+		cfg1.EnvDeletedPurgeInterval = cfgFromFile.EnvDeletedPurgeInterval
+	}
+	if flag.Lookup("delete-workers") == nil {
+		cfg1.EnvDeleteWorkers = cfgFromFile.EnvDeleteWorkers
+	}
+	if flag.Lookup("short-domains") == nil {
+		cfg1.EnvShortDomains = cfgFromFile.EnvShortDomains
+	}
+	if flag.Lookup("redirect-code") == nil {
+		cfg1.EnvRedirectCode = cfgFromFile.EnvRedirectCode
+	}
+	if flag.Lookup("jwt-keys-file") == nil {
+		cfg1.EnvJWTKeysFile = cfgFromFile.EnvJWTKeysFile
+	}
+	if flag.Lookup("jwt-secret") == nil {
+		cfg1.EnvJWTSecret = cfgFromFile.EnvJWTSecret
+	}
+	if flag.Lookup("token-max-lifetime") == nil {
+		cfg1.EnvTokenMaxLifetime = cfgFromFile.EnvTokenMaxLifetime
+	}
 	return nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -28,6 +29,26 @@ func TestNewConfig(t *testing.T) {
 				EnvLogLevel:    "info",
 				EnvDataBase:    "",
 				EnvGRPC:        ":3200",
+				EnvStorageType: StorageMemory,
+				EnvBoltPath:    "/tmp/short-url.bolt",
+
+				EnvFileFlushRecords:       100,
+				EnvFileFlushInterval:      time.Second,
+				EnvFileCompactInterval:    time.Hour,
+				EnvCacheSize:              10000,
+				EnvCacheTTL:               time.Minute,
+				EnvCacheNegativeTTL:       5 * time.Second,
+				EnvAliasCharset:           DefaultAliasCharset,
+				EnvAliasMinLength:         3,
+				EnvAliasMaxLength:         64,
+				EnvAliasReserved:          DefaultAliasReserved,
+				EnvExpiredCleanupInterval: time.Minute,
+				EnvExpiredRetention:       24 * time.Hour,
+				EnvDeletedRestorePeriod:   7 * 24 * time.Hour,
+				EnvDeletedPurgeInterval:   time.Hour,
+				EnvDeleteWorkers:          4,
+				EnvRedirectCode:           307,
+				EnvTokenMaxLifetime:       720 * time.Hour,
 			},
 		},
 		{
@@ -52,6 +73,26 @@ func TestNewConfig(t *testing.T) {
 				EnvTLS:         "disable",
 				EnvSubnet:      "1.1.1.1",
 				EnvGRPC:        ":3000",
+				EnvStorageType: StoragePostgres,
+				EnvBoltPath:    "/tmp/short-url.bolt",
+
+				EnvFileFlushRecords:       100,
+				EnvFileFlushInterval:      time.Second,
+				EnvFileCompactInterval:    time.Hour,
+				EnvCacheSize:              10000,
+				EnvCacheTTL:               time.Minute,
+				EnvCacheNegativeTTL:       5 * time.Second,
+				EnvAliasCharset:           DefaultAliasCharset,
+				EnvAliasMinLength:         3,
+				EnvAliasMaxLength:         64,
+				EnvAliasReserved:          DefaultAliasReserved,
+				EnvExpiredCleanupInterval: time.Minute,
+				EnvExpiredRetention:       24 * time.Hour,
+				EnvDeletedRestorePeriod:   7 * 24 * time.Hour,
+				EnvDeletedPurgeInterval:   time.Hour,
+				EnvDeleteWorkers:          4,
+				EnvRedirectCode:           307,
+				EnvTokenMaxLifetime:       720 * time.Hour,
 			},
 		},
 		{
@@ -75,8 +116,116 @@ func TestNewConfig(t *testing.T) {
 				EnvTLS:         "enable",
 				EnvSubnet:      "2.2.2.2",
 				EnvGRPC:        ":1000",
+				EnvStorageType: StoragePostgres,
+				EnvBoltPath:    "/tmp/short-url.bolt",
+
+				EnvFileFlushRecords:       100,
+				EnvFileFlushInterval:      time.Second,
+				EnvFileCompactInterval:    time.Hour,
+				EnvCacheSize:              10000,
+				EnvCacheTTL:               time.Minute,
+				EnvCacheNegativeTTL:       5 * time.Second,
+				EnvAliasCharset:           DefaultAliasCharset,
+				EnvAliasMinLength:         3,
+				EnvAliasMaxLength:         64,
+				EnvAliasReserved:          DefaultAliasReserved,
+				EnvExpiredCleanupInterval: time.Minute,
+				EnvExpiredRetention:       24 * time.Hour,
+				EnvDeletedRestorePeriod:   7 * 24 * time.Hour,
+				EnvDeletedPurgeInterval:   time.Hour,
+				EnvDeleteWorkers:          4,
+				EnvRedirectCode:           307,
+				EnvTokenMaxLifetime:       720 * time.Hour,
 			},
 		},
+		{
+			name: "bolt storage",
+			envVars: map[string]string{
+				"STORAGE_TYPE":      "bolt",
+				"BOLT_STORAGE_PATH": "/tmp/test.bolt",
+			},
+			expectedConfig: &ENVConfig{
+				EnvServAdr:     "localhost:8080",
+				EnvBaseURL:     "http://localhost:8080",
+				EnvStoragePath: "/tmp/short-url-db.json",
+				EnvLogLevel:    "info",
+				EnvGRPC:        ":3200",
+				EnvStorageType: StorageBolt,
+				EnvBoltPath:    "/tmp/test.bolt",
+
+				EnvFileFlushRecords:       100,
+				EnvFileFlushInterval:      time.Second,
+				EnvFileCompactInterval:    time.Hour,
+				EnvCacheSize:              10000,
+				EnvCacheTTL:               time.Minute,
+				EnvCacheNegativeTTL:       5 * time.Second,
+				EnvAliasCharset:           DefaultAliasCharset,
+				EnvAliasMinLength:         3,
+				EnvAliasMaxLength:         64,
+				EnvAliasReserved:          DefaultAliasReserved,
+				EnvExpiredCleanupInterval: time.Minute,
+				EnvExpiredRetention:       24 * time.Hour,
+				EnvDeletedRestorePeriod:   7 * 24 * time.Hour,
+				EnvDeletedPurgeInterval:   time.Hour,
+				EnvDeleteWorkers:          4,
+				EnvRedirectCode:           307,
+				EnvTokenMaxLifetime:       720 * time.Hour,
+			},
+		},
+		{
+			name:           "unknown storage type",
+			flagArgs:       []string{"-storage-type", "redis"},
+			expectedConfig: nil,
+			expectedError:  errors.New(`unknown storage type "redis"`),
+		},
+		{
+			name:           "postgres storage without DSN",
+			flagArgs:       []string{"-storage-type", "postgres"},
+			expectedConfig: nil,
+			expectedError:  errors.New("storage type postgres requires DATABASE_DSN"),
+		},
+		{
+			name:           "invalid number of flushed records",
+			flagArgs:       []string{"-file-flush-records", "0"},
+			expectedConfig: nil,
+			expectedError:  errors.New("number of records written to the storage file at once must be positive, got 0"),
+		},
+		{
+			name:           "negative restore period",
+			flagArgs:       []string{"-deleted-restore-period", "-1h"},
+			expectedConfig: nil,
+			expectedError:  errors.New("restore period of deleted URLs mustn't be negative, got -1h0m0s"),
+		},
+		{
+			name:           "no deletion workers",
+			flagArgs:       []string{"-delete-workers", "0"},
+			expectedConfig: nil,
+			expectedError:  errors.New("number of deletion workers must be positive, got 0"),
+		},
+		{
+			name:           "unsupported redirect code",
+			flagArgs:       []string{"-redirect-code", "303"},
+			expectedConfig: nil,
+			expectedError:  errors.New("redirect code must be 301, 302, 307 or 308, got 303"),
+		},
+		{
+			name:           "JWT keys file and secret",
+			flagArgs:       []string{"-jwt-keys-file", "/etc/shortener/keys.json", "-jwt-secret", "secret"},
+			expectedConfig: nil,
+			expectedError:  errors.New("JWT keys file and JWT secret can't be set together"),
+		},
+		{
+			name:           "token max lifetime shorter than token",
+			flagArgs:       []string{"-token-max-lifetime", "1h"},
+			expectedConfig: nil,
+			expectedError:  errors.New("token max lifetime must be at least 3h0m0s, got 1h0m0s"),
+		},
+		{
+			name:           "invalid short domain",
+			flagArgs:       []string{"-short-domains", "https://go.example.com, example.org"},
+			expectedConfig: nil,
+			expectedError:  errors.New(`short domain "example.org" must be a base URL as https://host`),
+		},
 		{
 			name: "flag -c error find file",
 			flagArgs: []string{
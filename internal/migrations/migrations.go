@@ -0,0 +1,242 @@
+// Package migrations provides versioned schema migrations for the PostgreSQL storage.
+// Migrations are embedded into the binary as pairs of SQL files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, and applied versions
+// are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed sql/*.sql
+var embeddedSQL embed.FS
+
+// lockID is the key of the PostgreSQL advisory lock that prevents several
+// instances of the service from migrating the same database at the same time.
+const lockID int64 = 7_340_176_110_275_101_952
+
+// fileNamePattern matches migration file names, e.g. 0001_create_shorted_url.up.sql.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version int64  // Version is the unique ascending number of the migration
+	Name    string // Name is a short description taken from the file name
+	Up      string // Up is the SQL applying the migration
+	Down    string // Down is the SQL reverting the migration
+}
+
+// Status describes whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	Applied   bool      // Applied reports whether the migration is recorded in schema_migrations
+	AppliedAt time.Time // AppliedAt is the time the migration was applied
+}
+
+// Migrator applies and reverts migrations on a PostgreSQL database.
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator with the migrations embedded into the binary.
+func NewMigrator(db *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := loadMigrations(embeddedSQL, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations reads the migration files from dir and returns them sorted by version.
+// Every version must have both up and down files.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		parts := fileNamePattern.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// withLock runs fn on a single connection holding the migrations advisory lock.
+// The schema_migrations table is created before fn is called.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migrations lock: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); unlockErr != nil {
+			logrus.Errorf("release migrations lock: %v", unlockErr)
+		}
+	}()
+
+	const createQuery = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(250) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+	if _, err = conn.Exec(ctx, createQuery); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migration versions with the time they were applied.
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// apply runs the migration SQL and updates schema_migrations in a single transaction.
+func apply(ctx context.Context, conn *pgxpool.Conn, sql, recordQuery string, args ...any) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, recordQuery, args...)
+		return err
+	})
+}
+
+// Up applies all pending migrations in ascending order and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var count int
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			const recordQuery = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+			if err = apply(ctx, conn, migration.Up, recordQuery, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			logrus.Infof("Applied migration %d_%s", migration.Version, migration.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts up to steps last applied migrations in descending order and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, errors.New("number of steps must be positive")
+	}
+	var count int
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			const recordQuery = `DELETE FROM schema_migrations WHERE version = $1`
+			if err = apply(ctx, conn, migration.Down, recordQuery, migration.Version); err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			logrus.Infof("Reverted migration %d_%s", migration.Version, migration.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status returns all known migrations with their applied state.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = make([]Status, len(m.migrations))
+		for i, migration := range m.migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+		}
+		return nil
+	})
+	return statuses, err
+}
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"sql/0002_second.up.sql":   {Data: []byte("UP 2")},
+				"sql/0002_second.down.sql": {Data: []byte("DOWN 2")},
+				"sql/0001_first.up.sql":    {Data: []byte("UP 1")},
+				"sql/0001_first.down.sql":  {Data: []byte("DOWN 1")},
+			},
+			want: []Migration{
+				{Version: 1, Name: "first", Up: "UP 1", Down: "DOWN 1"},
+				{Version: 2, Name: "second", Up: "UP 2", Down: "DOWN 2"},
+			},
+		},
+		{
+			name: "missing down file",
+			fsys: fstest.MapFS{
+				"sql/0001_first.up.sql": {Data: []byte("UP 1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid file name",
+			fsys: fstest.MapFS{
+				"sql/first.sql": {Data: []byte("UP 1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "different names for one version",
+			fsys: fstest.MapFS{
+				"sql/0001_first.up.sql":   {Data: []byte("UP 1")},
+				"sql/0001_other.down.sql": {Data: []byte("DOWN 1")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadMigrations(tt.fsys, "sql")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(embeddedSQL, "sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "migration versions must be sequential")
+	}
+}
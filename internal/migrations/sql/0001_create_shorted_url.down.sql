@@ -0,0 +1 @@
+DROP TABLE IF EXISTS shorted_URL;
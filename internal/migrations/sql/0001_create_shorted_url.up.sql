@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS shorted_URL (
+    user_id UUID NOT NULL,
+    short_url VARCHAR(250) NOT NULL,
+    original_url VARCHAR(4096) NOT NULL UNIQUE,
+    deleted_flag bool NOT NULL DEFAULT false
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS shorted_url_user_id_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS shorted_url_user_id_idx ON shorted_URL (user_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS shorted_url_short_url_key;
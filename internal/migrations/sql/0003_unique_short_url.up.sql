@@ -0,0 +1,23 @@
+-- Databases of the versions that didn't retry short URL collisions may hold duplicate short URLs.
+-- They are reported with a clear error instead of failing on the unique index, see README for the manual fix.
+DO $$
+DECLARE
+    total      BIGINT;
+    duplicates TEXT;
+BEGIN
+    SELECT count(*), string_agg(format('%s (%s rows)', short_url, copies), ', ' ORDER BY short_url)
+    INTO total, duplicates
+    FROM (SELECT short_url, count(*) AS copies
+          FROM shorted_URL
+          GROUP BY short_url
+          HAVING count(*) > 1) AS repeated;
+    IF total > 0 THEN
+        RAISE EXCEPTION 'shorted_URL has % duplicate short URLs, keep one row of each and run the migration again (see README, "Дубликаты коротких ссылок"): %',
+            total, left(duplicates, 2000)
+            USING ERRCODE = 'unique_violation';
+    END IF;
+END
+$$;
+
+DROP INDEX IF EXISTS shorted_url_short_url_key;
+CREATE UNIQUE INDEX IF NOT EXISTS shorted_url_short_url_key ON shorted_URL (short_url);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS shorted_url_expires_at_idx;
+ALTER TABLE shorted_URL DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,2 @@
+ALTER TABLE shorted_URL ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS shorted_url_expires_at_idx ON shorted_URL (expires_at) WHERE expires_at IS NOT NULL;
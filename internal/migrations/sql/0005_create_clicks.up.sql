@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS clicks (
+    id BIGSERIAL PRIMARY KEY,
+    short_url VARCHAR(250) NOT NULL REFERENCES shorted_URL (short_url) ON DELETE CASCADE,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS clicks_short_url_clicked_at_idx ON clicks (short_url, clicked_at);
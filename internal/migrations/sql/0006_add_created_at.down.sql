@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS shorted_url_user_id_created_at_idx;
+ALTER TABLE shorted_URL DROP COLUMN IF EXISTS created_at;
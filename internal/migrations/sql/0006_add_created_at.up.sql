@@ -0,0 +1,2 @@
+ALTER TABLE shorted_URL ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS shorted_url_user_id_created_at_idx ON shorted_URL (user_id, created_at, short_url);
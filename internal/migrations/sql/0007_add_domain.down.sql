@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS shorted_url_domain_original_url_key;
+ALTER TABLE shorted_URL ADD CONSTRAINT shorted_url_original_url_key UNIQUE (original_url);
+ALTER TABLE shorted_URL DROP COLUMN IF EXISTS domain;
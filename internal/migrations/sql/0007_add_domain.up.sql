@@ -0,0 +1,3 @@
+ALTER TABLE shorted_URL ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE shorted_URL DROP CONSTRAINT IF EXISTS shorted_url_original_url_key;
+CREATE UNIQUE INDEX IF NOT EXISTS shorted_url_domain_original_url_key ON shorted_URL (domain, original_url);
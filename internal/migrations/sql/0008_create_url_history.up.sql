@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS url_history (
+    id BIGSERIAL PRIMARY KEY,
+    short_url VARCHAR(250) NOT NULL REFERENCES shorted_URL (short_url) ON DELETE CASCADE,
+    original_url VARCHAR(4096) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS url_history_short_url_changed_at_idx ON url_history (short_url, changed_at);
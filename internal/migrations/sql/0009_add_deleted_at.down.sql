@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS shorted_url_deleted_at_idx;
+ALTER TABLE shorted_URL DROP COLUMN IF EXISTS deleted_at;
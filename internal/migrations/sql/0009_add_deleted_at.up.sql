@@ -0,0 +1,2 @@
+ALTER TABLE shorted_URL ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS shorted_url_deleted_at_idx ON shorted_URL (deleted_at) WHERE deleted_flag;
@@ -0,0 +1 @@
+ALTER TABLE shorted_URL DROP COLUMN IF EXISTS redirect_code;
@@ -0,0 +1 @@
+ALTER TABLE shorted_URL ADD COLUMN IF NOT EXISTS redirect_code SMALLINT NOT NULL DEFAULT 0;
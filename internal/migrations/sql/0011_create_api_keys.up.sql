@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    name VARCHAR(250) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id, created_at);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS accounts (
+    user_id UUID PRIMARY KEY,
+    login VARCHAR(64) NOT NULL,
+    password_hash VARCHAR(72) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT accounts_login_key UNIQUE (login)
+);
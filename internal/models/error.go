@@ -1,10 +1,118 @@
 // Package models defines common models and errors for the application.
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // ErrURLFound is an error indicating that a short URL is found in the database.
 var ErrURLFound = errors.New("short URL found in database")
 
 // ErrURLDeleted is an error indicating that a short URL is marked as deleted.
 var ErrURLDeleted = errors.New("short URL marked as deleted")
+
+// ErrURLExpired is an error indicating that a short URL has reached its expiration time.
+var ErrURLExpired = errors.New("short URL has expired")
+
+// ErrRestorePeriodOver is an error indicating that a deleted short URL can't be restored anymore,
+// because it was deleted earlier than the restore period allows.
+var ErrRestorePeriodOver = errors.New("restore period of the deleted short URL is over")
+
+// ErrExpirationInvalid is an error indicating that the requested expiration of a short URL can't be applied.
+var ErrExpirationInvalid = errors.New("expiration is invalid")
+
+// ErrOriginalURLNotFound is an error indicating that a short URL doesn't exist in the storage.
+var ErrOriginalURLNotFound = errors.New("original URL not found")
+
+// ErrURLNotFound is an error indicating that a short URL doesn't exist or belongs to another user.
+var ErrURLNotFound = errors.New("short URL not found")
+
+// ErrShortURLConflict is an error indicating that a short URL is already taken by another link.
+var ErrShortURLConflict = errors.New("short URL already exists")
+
+// ErrOriginalURLTaken is an error indicating that the original URL is already shortened by another link on the domain.
+var ErrOriginalURLTaken = errors.New("original URL is already shortened by another link")
+
+// ErrAliasInvalid is an error indicating that a custom alias doesn't satisfy the alias policy.
+var ErrAliasInvalid = errors.New("alias is invalid")
+
+// ErrAliasTaken is an error indicating that a custom alias is already used by another link.
+var ErrAliasTaken = errors.New("alias is already taken")
+
+// ErrRedirectCodeInvalid is an error indicating that the requested redirect code of a short URL isn't supported.
+var ErrRedirectCodeInvalid = errors.New("redirect code is invalid")
+
+// ErrQRCodeInvalid is an error indicating that the requested format, size, margin or error correction level of a QR code is invalid.
+var ErrQRCodeInvalid = errors.New("QR code parameters are invalid")
+
+// ErrDomainInvalid is an error indicating that the requested short domain isn't configured.
+var ErrDomainInvalid = errors.New("short domain is not configured")
+
+// ErrPageQueryInvalid is an error indicating that the limit, cursor or sort order of a listing is invalid.
+var ErrPageQueryInvalid = errors.New("page query is invalid")
+
+// ErrDeleteQueueFull is an error indicating that the deletion queue is full and the deletion should be retried later.
+var ErrDeleteQueueFull = errors.New("deletion queue is full")
+
+// ErrDeleteJobNotFound is an error indicating that a deletion job doesn't exist, has been forgotten or belongs to another user.
+var ErrDeleteJobNotFound = errors.New("deletion job not found")
+
+// ErrAPIKeyNotFound is an error indicating that an API key doesn't exist or belongs to another user.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrAPIKeyInvalid is an error indicating that an API key of a request is malformed, revoked or doesn't exist.
+var ErrAPIKeyInvalid = errors.New("API key is invalid")
+
+// ErrAPIKeyRequestInvalid is an error indicating that the name or the scopes of a new API key are invalid.
+var ErrAPIKeyRequestInvalid = errors.New("API key request is invalid")
+
+// ErrAPIKeyScope is an error indicating that the API key of a request lacks the scope of the operation.
+var ErrAPIKeyScope = errors.New("API key lacks the scope of the operation")
+
+// ErrAPIKeyNotAllowed is an error indicating that an operation requires the user token and can't be done with an API key.
+var ErrAPIKeyNotAllowed = errors.New("operation isn't allowed with an API key")
+
+// ErrCredentialsInvalid is an error indicating that the login or the password of a new account don't meet the requirements.
+var ErrCredentialsInvalid = errors.New("login or password is invalid")
+
+// ErrLoginTaken is an error indicating that the login of a new account is taken by another account.
+var ErrLoginTaken = errors.New("login is taken")
+
+// ErrAccountNotFound is an error indicating that an account doesn't exist.
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrLoginFailed is an error indicating that the login doesn't exist or the password doesn't match.
+var ErrLoginFailed = errors.New("wrong login or password")
+
+// ShortURLConflictError is returned by repositories when a short URL to store is already taken.
+// It matches ErrShortURLConflict with errors.Is.
+type ShortURLConflictError struct {
+	ShortURL string // ShortURL is the taken short URL
+}
+
+// Error implements the error interface.
+func (e *ShortURLConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrShortURLConflict, e.ShortURL)
+}
+
+// Is reports whether the target is ErrShortURLConflict.
+func (e *ShortURLConflictError) Is(target error) bool {
+	return target == ErrShortURLConflict
+}
+
+// OriginalURLConflictError is returned by repositories when the original URL to store has been shortened
+// by another link on the domain since it was looked up. It matches ErrOriginalURLTaken with errors.Is.
+type OriginalURLConflictError struct {
+	ShortURL string // ShortURL is the short URL the original URL is stored under
+}
+
+// Error implements the error interface.
+func (e *OriginalURLConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrOriginalURLTaken, e.ShortURL)
+}
+
+// Is reports whether the target is ErrOriginalURLTaken.
+func (e *OriginalURLConflictError) Is(target error) bool {
+	return target == ErrOriginalURLTaken
+}
@@ -1,10 +1,111 @@
 // Package models defines common models and errors for the application.
 package models
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
 // URLRequest represents a request to shorten a URL.
+// Alias is an optional custom short URL requested instead of a generated one.
+// ExpiresAt and TTL (in seconds) optionally limit the lifetime of the short URL, only one of them can be set.
+// RedirectCode is the HTTP status code the short URL is followed with, 0 for the configured default.
 type URLRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTL           int64      `json:"ttl,omitempty"`
+	RedirectCode  int        `json:"redirect_code,omitempty"`
+}
+
+// Expiration returns the requested lifetime of the short URL.
+func (r URLRequest) Expiration() Expiration {
+	var expiration Expiration
+	if r.ExpiresAt != nil {
+		expiration.ExpiresAt = *r.ExpiresAt
+	}
+	expiration.TTL = time.Duration(r.TTL) * time.Second
+	return expiration
+}
+
+// Expiration describes when a short URL stops working: at the fixed time ExpiresAt
+// or after TTL from the moment it is created. The zero value means the URL never expires.
+type Expiration struct {
+	ExpiresAt time.Time
+	TTL       time.Duration
+}
+
+// URLOptions holds the properties of a short URL saved along with the mapping.
+// A zero ExpiresAt means the short URL never expires.
+// Domain is the short domain the URL is bound to, empty for the default domain of BASE_URL.
+// RedirectCode is the HTTP status code the short URL is followed with, 0 for DefaultRedirectCode.
+type URLOptions struct {
+	ExpiresAt    time.Time
+	Domain       string
+	RedirectCode int
+}
+
+// DefaultRedirectCode is the HTTP status code of the redirect by the short URLs stored without a redirect code,
+// either before the codes were recorded or without the code in an import.
+const DefaultRedirectCode = http.StatusTemporaryRedirect
+
+// IsRedirectCode reports whether a short URL can be followed with the HTTP status code:
+// 301 Moved Permanently, 302 Found, 307 Temporary Redirect or 308 Permanent Redirect.
+func IsRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// IsPermanentRedirect reports whether the HTTP status code is a permanent redirect, which clients may cache.
+func IsPermanentRedirect(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// RequestDomain identifies the short domain a request creates links on.
+// Domain is the domain selected explicitly, Host is the Host header of the request used when Domain is empty.
+type RequestDomain struct {
+	Domain string
+	Host   string
+}
+
+// Redirect is the result of the lookup of a short URL followed by a client.
+// Domain is the short domain the URL is bound to, empty for the default domain.
+// Code is the HTTP status code of the redirect, 0 if the URL is stored without a redirect code.
+// ExpiresAt is the time the URL stops redirecting, zero if it never expires.
+type Redirect struct {
+	OriginalURL string
+	Domain      string
+	Code        int
+	ExpiresAt   time.Time
+}
+
+// Image formats of QR codes.
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+)
+
+// QRRequest describes the QR code image of a short URL, zero fields take the default values of the service.
+// Format is QRFormatPNG or QRFormatSVG, Size is the width and height of the image in pixels,
+// Margin is the width of the quiet zone around the code in modules, nil for the default margin,
+// Level is the error correction level: L, M, Q or H.
+type QRRequest struct {
+	Format string
+	Size   int
+	Margin *int
+	Level  string
+}
+
+// QRCode is a rendered QR code image with its MIME type.
+type QRCode struct {
+	ContentType string
+	Data        []byte
 }
 
 // URLResponse represents the response containing the shortened URL.
@@ -14,15 +115,263 @@ type URLResponse struct {
 }
 
 // URL represents a mapping between a short URL and its original counterpart.
+// ExpiresAt is set only for short URLs with a limited lifetime,
+// CreatedAt isn't set for short URLs saved in the storage file before creation times were recorded.
+// Domain is the short domain the URL is bound to, it is a part of the full ShortURL returned to clients.
+// RedirectCode is the HTTP status code the short URL is followed with, 0 if the URL is stored without a redirect code.
 type URL struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL     string     `json:"short_url"`
+	OriginalURL  string     `json:"original_url"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	Domain       string     `json:"-"`
+	RedirectCode int        `json:"redirect_code,omitempty"`
+}
+
+// Orders of the user URLs listing.
+const (
+	SortCreatedAsc  = "created_asc"  // SortCreatedAsc lists the oldest URLs first, it is the default order
+	SortCreatedDesc = "created_desc" // SortCreatedDesc lists the newest URLs first
+)
+
+// UserURLsRequest is a request of a page of the user URLs as it is received by the API.
+// Cursor is the opaque NextCursor of the previous page, empty for the first page.
+// Search is a case-insensitive substring of the original URLs to list, empty to list all URLs.
+type UserURLsRequest struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Search string
+}
+
+// UserURLsPage is a page of the user URLs, NextCursor is empty for the last page.
+type UserURLsPage struct {
+	URLs       []URL
+	NextCursor string
+}
+
+// URLCursor is a position in the user URLs listing: the creation time and the short URL of the last listed URL.
+// The URLs are ordered by creation time, URLs created at the same time are ordered by short URL.
+type URLCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ShortURL  string    `json:"short_url"`
+}
+
+// UserURLsQuery selects the user URLs from a repository.
+type UserURLsQuery struct {
+	Limit  int        // Limit is the max number of URLs to return, 0 returns all URLs
+	After  *URLCursor // After skips the URLs up to the cursor position inclusive, nil starts from the first URL
+	Desc   bool       // Desc lists the newest URLs first
+	Search string     // Search is a case-insensitive substring of the original URLs to return
+}
+
+// ClickInfo describes the client following a short URL.
+type ClickInfo struct {
+	Referrer  string
+	UserAgent string
+	ClientIP  string
+}
+
+// Click is a single redirect by a short URL.
+type Click struct {
+	ClickInfo
+	ShortURL  string
+	Timestamp time.Time
+}
+
+// DailyClicks is the number of clicks by a short URL during one day (UTC).
+type DailyClicks struct {
+	Date   string `json:"date"` // Date is the day in YYYY-MM-DD format
+	Clicks int64  `json:"clicks"`
+}
+
+// URLStats represents the click statistics of a short URL.
+type URLStats struct {
+	ShortURL    string        `json:"short_url"`
+	Domain      string        `json:"-"` // Domain is the short domain the URL is bound to
+	TotalClicks int64         `json:"total_clicks"`
+	Daily       []DailyClicks `json:"daily"`
+}
+
+// URLChange is a former original URL of a short URL in its change history.
+type URLChange struct {
+	OriginalURL string    `json:"original_url"`
+	ChangedAt   time.Time `json:"changed_at"` // ChangedAt is the time the original URL was replaced
+}
+
+// URLDeletion is a request of a user to delete short URLs. Only the short URLs of the user are deleted.
+type URLDeletion struct {
+	UserID    uuid.UUID
+	ShortURLs []string
+}
+
+// Statuses of a DeleteJob.
+const (
+	DeleteJobQueued  = "queued"  // the job waits in the deletion queue
+	DeleteJobRunning = "running" // the short URLs of the job are being deleted
+	DeleteJobDone    = "done"    // the short URLs of the job are deleted
+	DeleteJobFailed  = "failed"  // the short URLs of the job aren't deleted because of Error
+)
+
+// DeleteJob is a background deletion of short URLs requested by a user.
+type DeleteJob struct {
+	ID         string     `json:"job_id"`
+	Status     string     `json:"status"`
+	URLs       int        `json:"urls"` // URLs is the number of short URLs requested to delete
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
 }
 
 // Stats represent service info count
 type Stats struct {
-	CountURLs  uint32 `json:"urls"`
-	CountUsers uint32 `json:"users"`
+	CountURLs   uint32 `json:"urls"`
+	CountUsers  uint32 `json:"users"`
+	CacheHits   uint64 `json:"cache_hits,omitempty"`   // CacheHits is the number of redirect lookups served by the cache
+	CacheMisses uint64 `json:"cache_misses,omitempty"` // CacheMisses is the number of redirect lookups passed to the storage
+}
+
+// URLRecord is a short URL with its owner and state, as it is exported from a storage and imported to another one.
+type URLRecord struct {
+	ShortURL     string     `json:"short_url"`
+	OriginalURL  string     `json:"original_url"`
+	UserID       uuid.UUID  `json:"user_id"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	DeletedFlag  bool       `json:"is_deleted,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	Domain       string     `json:"domain,omitempty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	RedirectCode int        `json:"redirect_code,omitempty"`
+	// History keeps the former original URLs, the most recently replaced last
+	History []URLChange `json:"history,omitempty"`
+}
+
+// Reasons why a URLRecord can't be imported.
+const (
+	ConflictShortURLTaken     = "short URL is taken by another original URL"
+	ConflictOtherOwner        = "short URL belongs to another user"
+	ConflictOriginalShortened = "original URL is shortened to another short URL"
+	ConflictDuplicate         = "short URL or original URL repeats an earlier record of the input"
+)
+
+// ImportConflict is a record that isn't imported because it contradicts a stored link.
+type ImportConflict struct {
+	URLRecord
+	Reason string `json:"reason"`
+}
+
+// ImportReport is the result of importing records to a storage.
+type ImportReport struct {
+	Imported  int              // Imported is the number of saved records, or of records that would be saved in a dry run
+	Existing  int              // Existing is the number of records skipped because the same link is already stored
+	Conflicts []ImportConflict // Conflicts are the records skipped because they contradict stored links
+}
+
+// Merge adds the results of other to the report.
+func (r *ImportReport) Merge(other ImportReport) {
+	r.Imported += other.Imported
+	r.Existing += other.Existing
+	r.Conflicts = append(r.Conflicts, other.Conflicts...)
+}
+
+// Reasons why an API key or an account can't be imported.
+const (
+	ConflictAPIKeyTaken    = "API key ID is taken by another key"
+	ConflictLoginTaken     = "login is taken by another account"
+	ConflictUserHasAccount = "user already has another account"
+	ConflictItemDuplicate  = "ID or user repeats an earlier record of the input"
+)
+
+// ImportItemConflict is an API key or an account that isn't imported because it contradicts a stored one.
+// ID is the ID of the API key or the login of the account.
+type ImportItemConflict struct {
+	ID     string    `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	Reason string    `json:"reason"`
+}
+
+// ImportItemsReport is the result of importing API keys or accounts to a storage.
+type ImportItemsReport struct {
+	Imported  int                  // Imported is the number of saved items, or of items that would be saved in a dry run
+	Existing  int                  // Existing is the number of items skipped because the same item is already stored
+	Conflicts []ImportItemConflict // Conflicts are the items skipped because they contradict stored items
+}
+
+// Merge adds the results of other to the report.
+func (r *ImportItemsReport) Merge(other ImportItemsReport) {
+	r.Imported += other.Imported
+	r.Existing += other.Existing
+	r.Conflicts = append(r.Conflicts, other.Conflicts...)
+}
+
+// Scopes of API keys.
+const (
+	ScopeCreate = "create" // ScopeCreate allows shortening URLs and changing their original URLs
+	ScopeRead   = "read"   // ScopeRead allows listing URLs of the user, their statistics, history and deletion jobs
+	ScopeDelete = "delete" // ScopeDelete allows deleting and restoring URLs
+)
+
+// IsScope reports whether the scope of an API key is known.
+func IsScope(scope string) bool {
+	return scope == ScopeCreate || scope == ScopeRead || scope == ScopeDelete
+}
+
+// APIKeyPrefix starts every API key, it tells API keys from other bearer tokens of the Authorization header.
+const APIKeyPrefix = "shk_"
+
+// APIKeyRequest is a request to create an API key with the scopes, Name describes the client using the key.
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKey is a key authenticating a server-to-server client as the user the key is bound to.
+// The key itself isn't stored, only Hash, the hex SHA-256 hash of its secret part.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"-"`
+	Hash      string    `json:"-"`
+}
+
+// HasScope reports whether the API key has the scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIKey is a created API key with the key itself, which is returned only once.
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Credentials are the login and the password of a registered account.
+type Credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// Account is a registered account of the user UserID, the user keeps the links across devices by logging in.
+// The password isn't stored, only PasswordHash, its bcrypt hash.
+type Account struct {
+	UserID       uuid.UUID `json:"user_id"`
+	Login        string    `json:"login"`
+	CreatedAt    time.Time `json:"created_at"`
+	PasswordHash string    `json:"-"`
+}
+
+// AccountLogin is the account a user has registered or logged in to,
+// ClaimedURLs is the number of links of the former anonymous user moved to the account.
+type AccountLogin struct {
+	Account
+	ClaimedURLs int64 `json:"claimed_urls"`
 }
 
 // CTXKey is the type used as a context key for storing user ID.
@@ -32,6 +381,7 @@ type CTXKey string
 const (
 	TokenKey      CTXKey = "token"          // TokenKey is the specific key used in the context to store token.
 	UserIDKey     CTXKey = "userID"         // UserIDKey is the specific key used in the context to store user ID.
+	APIKeyKey     CTXKey = "apiKey"         // APIKeyKey is the specific key used in the context to store the API key of the request.
 	CertPEM       string = "cert.pem"       // CertPEM is the file name for TLS cert
 	PrivateKeyPEM string = "privateKey.pem" // PrivateKeyPEM is the file name for TLS private key
 )
@@ -0,0 +1,17 @@
+package url
+
+import (
+	"sort"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// sortAPIKeys orders the API keys by creation time, keys created at the same time by ID.
+func sortAPIKeys(keys []models.APIKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
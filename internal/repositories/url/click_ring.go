@@ -0,0 +1,66 @@
+// Package repositories provides implementations of data storage for managing shortened URLs.
+// It includes functionality to store, retrieve, and delete URLs using in-memory and file-based storage.
+package url
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// clickRingSize is the number of the latest clicks kept by the in-memory repository.
+const clickRingSize = 10000
+
+// clickRing keeps the latest clicks in a fixed-size ring buffer, overwriting the oldest ones.
+type clickRing struct {
+	mu     sync.RWMutex
+	clicks []models.Click
+	next   int // next is the position of the next click to write
+	full   bool
+}
+
+// newClickRing creates an empty clickRing with the given capacity.
+func newClickRing(size int) *clickRing {
+	return &clickRing{clicks: make([]models.Click, size)}
+}
+
+// add appends the clicks to the ring.
+func (r *clickRing) add(clicks []models.Click) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, click := range clicks {
+		r.clicks[r.next] = click
+		r.next++
+		if r.next == len(r.clicks) {
+			r.next = 0
+			r.full = true
+		}
+	}
+}
+
+// stats counts the clicks of the short URL kept in the ring, grouped by day in UTC.
+func (r *clickRing) stats(shortURL string) models.URLStats {
+	r.mu.RLock()
+	size := r.next
+	if r.full {
+		size = len(r.clicks)
+	}
+	daily := make(map[string]int64)
+	var total int64
+	for _, click := range r.clicks[:size] {
+		if click.ShortURL == shortURL {
+			daily[click.Timestamp.UTC().Format(time.DateOnly)]++
+			total++
+		}
+	}
+	r.mu.RUnlock()
+
+	stats := models.URLStats{TotalClicks: total, Daily: make([]models.DailyClicks, 0, len(daily))}
+	for date, clicks := range daily {
+		stats.Daily = append(stats.Daily, models.DailyClicks{Date: date, Clicks: clicks})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool { return stats.Daily[i].Date < stats.Daily[j].Date })
+	return stats
+}
@@ -0,0 +1,13 @@
+package url
+
+import "github.com/DenisKhanov/shorterURL/internal/models"
+
+// reverseHistory returns a copy of the change history kept in the order of changes,
+// listing the most recently replaced original URL first.
+func reverseHistory(history []models.URLChange) []models.URLChange {
+	reversed := make([]models.URLChange, len(history))
+	for i, change := range history {
+		reversed[len(history)-1-i] = change
+	}
+	return reversed
+}
@@ -0,0 +1,1157 @@
+// Package repositories provides implementations of data storage for managing shortened URLs.
+// It includes functionality to store, retrieve, and manage shortened URLs in an embedded bbolt database.
+package url
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// Names of the buckets of the bbolt database.
+var (
+	bucketURLs      = []byte("urls")      // short URL -> boltURL in JSON
+	bucketOriginals = []byte("originals") // originalKey of the domain and the original URL -> short URL
+	bucketUsers     = []byte("users")     // user ID -> nested bucket with the short URLs of the user
+	bucketExpires   = []byte("expires")   // expiresKey -> empty value, ordered by expiration time
+	bucketClicks    = []byte("clicks")    // short URL -> nested bucket with the number of clicks per day
+	bucketDeleted   = []byte("deleted")   // deletedKey -> empty value, ordered by deletion time
+	bucketAPIKeys   = []byte("api_keys")  // API key ID -> boltAPIKey in JSON
+	bucketAccounts  = []byte("accounts")  // login -> boltAccount in JSON
+	bucketUserLogin = []byte("logins")    // user ID -> login of the account of the user
+)
+
+// boltAccount is an account kept in the bbolt database.
+type boltAccount struct {
+	UserID       uuid.UUID `json:"user_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	PasswordHash string    `json:"password_hash"`
+}
+
+// account returns the account with the login.
+func (a boltAccount) account(login string) models.Account {
+	return models.Account{UserID: a.UserID, Login: login, CreatedAt: a.CreatedAt, PasswordHash: a.PasswordHash}
+}
+
+// boltAPIKey is an API key kept in the bbolt database.
+type boltAPIKey struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	Hash      string    `json:"hash"`
+}
+
+// apiKey returns the API key with the ID.
+func (k boltAPIKey) apiKey(id string) models.APIKey {
+	return models.APIKey{ID: id, UserID: k.UserID, Name: k.Name, Scopes: k.Scopes, CreatedAt: k.CreatedAt, Hash: k.Hash}
+}
+
+// boltURL is the state of a short URL kept in the bbolt database.
+type boltURL struct {
+	OriginalURL string             `json:"original_url"`
+	UserID      uuid.UUID          `json:"user_id"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty"`
+	DeletedFlag bool               `json:"is_deleted,omitempty"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty"`
+	Domain      string             `json:"domain,omitempty"`
+	History     []models.URLChange `json:"history,omitempty"`    // History keeps the former original URLs, the most recently replaced last
+	DeletedAt   *time.Time         `json:"deleted_at,omitempty"` // nil if the short URL has been deleted before deletion times were recorded
+	// RedirectCode is the HTTP status code of the redirect, 0 if the short URL has been saved before the codes were recorded
+	RedirectCode int `json:"redirect_code,omitempty"`
+}
+
+// userURL returns the short URL as it is listed among the user URLs.
+func (u boltURL) userURL(shortURL string) models.URL {
+	return models.URL{
+		ShortURL:     shortURL,
+		OriginalURL:  u.OriginalURL,
+		ExpiresAt:    u.ExpiresAt,
+		CreatedAt:    u.CreatedAt,
+		Domain:       u.Domain,
+		RedirectCode: u.RedirectCode,
+	}
+}
+
+// originalKey returns the key of the original URL in the originals bucket.
+func (u boltURL) originalKey() []byte {
+	return []byte(originalKey(u.Domain, u.OriginalURL))
+}
+
+// expiredBefore reports whether the short URL has a limited lifetime that ended before t.
+func (u boltURL) expiredBefore(t time.Time) bool {
+	return u.ExpiresAt != nil && !t.Before(*u.ExpiresAt)
+}
+
+// URLInBoltRepo represents the repository for storing and retrieving URLs in an embedded bbolt database file.
+// Every change is written in a single transaction together with the indexes of original URLs,
+// users and expiration times, so the data survives a restart without an external database server.
+// Clicks are kept as the number of clicks per day in UTC.
+type URLInBoltRepo struct {
+	db *bolt.DB
+}
+
+// NewURLInBoltRepo opens the bbolt database file, creating it and its buckets if needed.
+// The file is locked while the repository is open, so it must be closed by Close.
+func NewURLInBoltRepo(path string) (*URLInBoltRepo, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		logrus.Error("bolt storage isn't opened ", err)
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		// the deletion index is added to the files created without it
+		indexDeleted := tx.Bucket(bucketDeleted) == nil
+		for _, name := range [][]byte{bucketURLs, bucketOriginals, bucketUsers, bucketExpires, bucketClicks, bucketDeleted, bucketAPIKeys,
+			bucketAccounts, bucketUserLogin} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if !indexDeleted {
+			return nil
+		}
+		return tx.Bucket(bucketURLs).ForEach(func(shortURL, data []byte) error {
+			var url boltURL
+			if err := json.Unmarshal(data, &url); err != nil {
+				return fmt.Errorf("error decoding short URL %s: %w", shortURL, err)
+			}
+			if !url.DeletedFlag {
+				return nil
+			}
+			return tx.Bucket(bucketDeleted).Put(deletedKey(url, string(shortURL)), []byte{})
+		})
+	})
+	if err != nil {
+		logrus.Error("bolt buckets aren't created ", err)
+		db.Close()
+		return nil, err
+	}
+	return &URLInBoltRepo{db: db}, nil
+}
+
+// Close closes the bbolt database file.
+func (b *URLInBoltRepo) Close() error {
+	return b.db.Close()
+}
+
+// Ping checks that the bbolt database is open.
+func (b *URLInBoltRepo) Ping(_ context.Context) error {
+	if b.db == nil {
+		return errors.New("bolt storage isn't initialised")
+	}
+	return b.db.View(func(*bolt.Tx) error { return nil })
+}
+
+// expiresKey builds the key of the expiration index: the expiration time in nanoseconds
+// followed by the short URL, so the keys are ordered by expiration time.
+func expiresKey(expiresAt time.Time, shortURL string) []byte {
+	key := make([]byte, 8, 8+len(shortURL))
+	binary.BigEndian.PutUint64(key, uint64(expiresAt.UnixNano()))
+	return append(key, shortURL...)
+}
+
+// deletedKey builds the key of the deletion index: the deletion time in nanoseconds
+// followed by the short URL, so the keys are ordered by deletion time.
+// Short URLs deleted before deletion times were recorded are keyed by zero and come first.
+func deletedKey(url boltURL, shortURL string) []byte {
+	var deletedAt int64
+	if url.DeletedAt != nil {
+		deletedAt = url.DeletedAt.UnixNano()
+	}
+	key := make([]byte, 8, 8+len(shortURL))
+	binary.BigEndian.PutUint64(key, uint64(deletedAt))
+	return append(key, shortURL...)
+}
+
+// markDeleted sets the deleted flag and the deletion time of the short URL and indexes it by the deletion time.
+func markDeleted(tx *bolt.Tx, shortURL string, url boltURL, deletedAt *time.Time) error {
+	url.DeletedFlag = true
+	url.DeletedAt = deletedAt
+	if err := putURL(tx, shortURL, url); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketDeleted).Put(deletedKey(url, shortURL), []byte{})
+}
+
+// getURL reads the state of the short URL and reports whether it exists.
+func getURL(tx *bolt.Tx, shortURL string) (boltURL, bool, error) {
+	data := tx.Bucket(bucketURLs).Get([]byte(shortURL))
+	if data == nil {
+		return boltURL{}, false, nil
+	}
+	var url boltURL
+	if err := json.Unmarshal(data, &url); err != nil {
+		return boltURL{}, false, fmt.Errorf("error decoding short URL %s: %w", shortURL, err)
+	}
+	return url, true, nil
+}
+
+// putURL writes the state of the short URL.
+func putURL(tx *bolt.Tx, shortURL string, url boltURL) error {
+	data, err := json.Marshal(url)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketURLs).Put([]byte(shortURL), data)
+}
+
+// insertURL saves the URL mapping created at createdAt with all its indexes in the transaction.
+// If the same mapping is already stored, nothing is saved and no error is returned.
+// It returns models.ShortURLConflictError if the short URL is taken by another original URL
+// and models.OriginalURLConflictError if the original URL has already been shortened to another short URL.
+func insertURL(tx *bolt.Tx, userID uuid.UUID, originalURL, shortURL string, options models.URLOptions, createdAt time.Time) error {
+	existing, exists, err := getURL(tx, shortURL)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if existing.OriginalURL == originalURL && existing.Domain == options.Domain {
+			return nil
+		}
+		return &models.ShortURLConflictError{ShortURL: shortURL}
+	}
+	url := boltURL{OriginalURL: originalURL, UserID: userID, ExpiresAt: timePtr(options.ExpiresAt), CreatedAt: timePtr(createdAt),
+		Domain: options.Domain, RedirectCode: options.RedirectCode}
+	originals := tx.Bucket(bucketOriginals)
+	if stored := originals.Get(url.originalKey()); stored != nil {
+		return &models.OriginalURLConflictError{ShortURL: string(stored)}
+	}
+	if err = putURL(tx, shortURL, url); err != nil {
+		return err
+	}
+	if err = originals.Put(url.originalKey(), []byte(shortURL)); err != nil {
+		return err
+	}
+	userURLs, err := tx.Bucket(bucketUsers).CreateBucketIfNotExists([]byte(userID.String()))
+	if err != nil {
+		return err
+	}
+	if err = userURLs.Put([]byte(shortURL), []byte{}); err != nil {
+		return err
+	}
+	if url.ExpiresAt != nil {
+		return tx.Bucket(bucketExpires).Put(expiresKey(*url.ExpiresAt, shortURL), []byte{})
+	}
+	return nil
+}
+
+// removeURL deletes the short URL from all buckets together with its clicks.
+func removeURL(tx *bolt.Tx, shortURL string, url boltURL) error {
+	if err := tx.Bucket(bucketURLs).Delete([]byte(shortURL)); err != nil {
+		return err
+	}
+	originals := tx.Bucket(bucketOriginals)
+	if string(originals.Get(url.originalKey())) == shortURL {
+		if err := originals.Delete(url.originalKey()); err != nil {
+			return err
+		}
+	}
+	users := tx.Bucket(bucketUsers)
+	if userURLs := users.Bucket([]byte(url.UserID.String())); userURLs != nil {
+		if err := userURLs.Delete([]byte(shortURL)); err != nil {
+			return err
+		}
+		// the user without URLs is not counted in the statistics
+		if key, _ := userURLs.Cursor().First(); key == nil {
+			if err := users.DeleteBucket([]byte(url.UserID.String())); err != nil {
+				return err
+			}
+		}
+	}
+	if url.ExpiresAt != nil {
+		if err := tx.Bucket(bucketExpires).Delete(expiresKey(*url.ExpiresAt, shortURL)); err != nil {
+			return err
+		}
+	}
+	if url.DeletedFlag {
+		if err := tx.Bucket(bucketDeleted).Delete(deletedKey(url, shortURL)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(bucketClicks).DeleteBucket([]byte(shortURL)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return err
+	}
+	return nil
+}
+
+// StoreURL saves a mapping between an original URL and its shortened version with its options in the bbolt database.
+// It returns models.ShortURLConflictError if the short URL is already taken,
+// models.OriginalURLConflictError if the original URL has been shortened to another short URL
+// or another error if the saving process fails.
+func (b *URLInBoltRepo) StoreURL(ctx context.Context, originalURL, shortURL string, options models.URLOptions) error {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return insertURL(tx, userID, originalURL, shortURL, options, time.Now())
+	})
+	if err != nil {
+		logrus.Error("url don't save in bolt storage ", err)
+	}
+	return err
+}
+
+// GetRedirect retrieves the original URL and the domain of a given shortened URL from the bbolt database.
+// It returns models.ErrOriginalURLNotFound if the short URL doesn't exist, models.ErrURLDeleted if it is deleted
+// and models.ErrURLExpired if the short URL has expired but hasn't been removed yet.
+func (b *URLInBoltRepo) GetRedirect(_ context.Context, shortURL string) (models.Redirect, error) {
+	var url boltURL
+	var exists bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		url, exists, err = getURL(tx, shortURL)
+		return err
+	})
+	if err != nil {
+		logrus.Error("error querying for short URL: ", err)
+		return models.Redirect{}, fmt.Errorf("error querying for short URL: %w", err)
+	}
+	if !exists {
+		return models.Redirect{}, models.ErrOriginalURLNotFound
+	}
+	if url.DeletedFlag {
+		return models.Redirect{}, models.ErrURLDeleted
+	}
+	if url.expiredBefore(time.Now()) {
+		return models.Redirect{}, models.ErrURLExpired
+	}
+	redirect := models.Redirect{OriginalURL: url.OriginalURL, Domain: url.Domain, Code: url.RedirectCode}
+	if url.ExpiresAt != nil {
+		redirect.ExpiresAt = *url.ExpiresAt
+	}
+	return redirect, nil
+}
+
+// GetShortURL retrieves the shortened version of a given original URL on the domain from the bbolt database.
+// It returns the shortened URL and any error encountered during the retrieval.
+func (b *URLInBoltRepo) GetShortURL(_ context.Context, domain, originalURL string) (string, error) {
+	var shortURL string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		shortURL = string(tx.Bucket(bucketOriginals).Get([]byte(originalKey(domain, originalURL))))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if shortURL == "" {
+		return "", errors.New("short URL not found")
+	}
+	return shortURL, nil
+}
+
+// StoreBatchURL saves multiple URL mappings in the bbolt database in a batch operation.
+// The input is a map where keys are shortened URLs and values are the corresponding original URLs,
+// and a map of original URLs to their options.
+// The batch is saved in a single transaction: if any short URL is already taken, nothing is saved
+// and models.ShortURLConflictError with this short URL is returned.
+func (b *URLInBoltRepo) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string,
+	options map[string]models.URLOptions) error {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+	}
+	createdAt := time.Now()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for shortURL, originalURL := range batchURLtoStores {
+			// original URLs that are already shortened are skipped, the same way the database ignores such an insert
+			var stored *models.OriginalURLConflictError
+			if err := insertURL(tx, userID, originalURL, shortURL, options[originalURL], createdAt); err != nil &&
+				!errors.As(err, &stored) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("urls don't save in bolt storage ", err)
+	}
+	return err
+}
+
+// GetShortBatchURL retrieves multiple shortened URLs on the domain corresponding to a batch of original URLs from the bbolt database.
+// The input is a slice of URLRequest objects containing original URLs.
+//
+//	It returns found in database a map of original URLs to their shortened counterparts and any error encountered during the retrieval.
+func (b *URLInBoltRepo) GetShortBatchURL(_ context.Context, domain string, batchURLRequests []models.URLRequest) (map[string]string, error) {
+	var shortsURL = make(map[string]string, len(batchURLRequests))
+	err := b.db.View(func(tx *bolt.Tx) error {
+		originals := tx.Bucket(bucketOriginals)
+		for _, request := range batchURLRequests {
+			if shortURL := originals.Get([]byte(originalKey(domain, request.OriginalURL))); shortURL != nil {
+				shortsURL[request.OriginalURL] = string(shortURL)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shortsURL, nil
+}
+
+// GetUserURLs returns the URLs of the user from the context selected by the query from the bbolt database.
+// The short URLs of a user are indexed by short URL, so all of them are read and ordered by creation time.
+func (b *URLInBoltRepo) GetUserURLs(ctx context.Context, query models.UserURLsQuery) ([]models.URL, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+	}
+	var allUserShortURLs []models.URL
+	err := b.db.View(func(tx *bolt.Tx) error {
+		userURLs := tx.Bucket(bucketUsers).Bucket([]byte(userID.String()))
+		if userURLs == nil {
+			return errors.New("userID not found")
+		}
+		return userURLs.ForEach(func(shortURL, _ []byte) error {
+			url, exists, err := getURL(tx, string(shortURL))
+			if err != nil || !exists {
+				return err
+			}
+			allUserShortURLs = append(allUserShortURLs, url.userURL(string(shortURL)))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortURLs(allUserShortURLs)
+	return pageURLs(allUserShortURLs, query), nil
+}
+
+// MarkURLsAsDeleted marks the short URLs of the deletions of many users as deleted in the bbolt database
+// in a single transaction and indexes them by the deletion time.
+// Only URLs owned by the user of the deletion are marked.
+func (b *URLInBoltRepo) MarkURLsAsDeleted(_ context.Context, deletions []models.URLDeletion) error {
+	if len(deletions) == 0 {
+		return nil
+	}
+	var marked, requested int
+	deletedAt := time.Now().UTC()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		marked, requested = 0, 0
+		for _, deletion := range deletions {
+			requested += len(deletion.ShortURLs)
+			for _, shortURL := range deletion.ShortURLs {
+				url, exists, err := getURL(tx, shortURL)
+				if err != nil {
+					return err
+				}
+				if !exists || url.UserID != deletion.UserID || url.DeletedFlag {
+					continue
+				}
+				if err = markDeleted(tx, shortURL, url, &deletedAt); err != nil {
+					return err
+				}
+				marked++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("Failed to mark URLs as deleted: ", err)
+		return err
+	}
+	logrus.Infof("Complete mark URLs as deleted: %d of %d", marked, requested)
+	return nil
+}
+
+// GetStats retrieves the statistics of URLs and users from the bbolt database.
+func (b *URLInBoltRepo) GetStats(_ context.Context) (models.Stats, error) {
+	var stats models.Stats
+	err := b.db.View(func(tx *bolt.Tx) error {
+		stats.CountURLs = uint32(tx.Bucket(bucketURLs).Stats().KeyN)
+		return tx.Bucket(bucketUsers).ForEach(func(_, _ []byte) error {
+			stats.CountUsers++
+			return nil
+		})
+	})
+	if err != nil {
+		logrus.Error("error counting urls or users: ", err)
+		return models.Stats{}, fmt.Errorf("error counting urls or users: %w", err)
+	}
+	return stats, nil
+}
+
+// DeleteExpiredURLs removes short URLs expired before the given time from the bbolt database
+// and returns how many were removed. The expired URLs are found by the ordered expiration index.
+func (b *URLInBoltRepo) DeleteExpiredURLs(_ context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		// keys are collected first, because the bucket mustn't be changed while a cursor walks over it
+		var expired []string
+		cursor := tx.Bucket(bucketExpires).Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			if int64(binary.BigEndian.Uint64(key[:8])) >= before.UnixNano() {
+				break
+			}
+			expired = append(expired, string(key[8:]))
+		}
+		for _, shortURL := range expired {
+			url, exists, err := getURL(tx, shortURL)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			if err = removeURL(tx, shortURL, url); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("error deleting expired URLs: ", err)
+		return 0, fmt.Errorf("error deleting expired URLs: %w", err)
+	}
+	return deleted, nil
+}
+
+// RestoreURL clears the deleted flag of the short URL owned by the user from the context in the bbolt database,
+// removes it from the deletion index and returns the URL. A short URL that isn't deleted is returned unchanged.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user
+// and models.ErrRestorePeriodOver if it was deleted at or before deletedAfter.
+func (b *URLInBoltRepo) RestoreURL(ctx context.Context, shortURL string, deletedAfter time.Time) (models.URL, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URL{}, fmt.Errorf("invalid user context")
+	}
+	var restored boltURL
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		url, exists, err := getURL(tx, shortURL)
+		if err != nil {
+			return err
+		}
+		if !exists || url.UserID != userID {
+			return models.ErrURLNotFound
+		}
+		restored = url
+		if !url.DeletedFlag {
+			return nil
+		}
+		if url.DeletedAt == nil || !url.DeletedAt.After(deletedAfter) {
+			return models.ErrRestorePeriodOver
+		}
+		if err = tx.Bucket(bucketDeleted).Delete(deletedKey(url, shortURL)); err != nil {
+			return err
+		}
+		restored.DeletedFlag, restored.DeletedAt = false, nil
+		return putURL(tx, shortURL, restored)
+	})
+	if err != nil {
+		return models.URL{}, err
+	}
+	return restored.userURL(shortURL), nil
+}
+
+// PurgeDeletedURLs removes short URLs deleted before the given time from the bbolt database
+// and returns how many were removed. The deleted URLs are found by the ordered deletion index.
+func (b *URLInBoltRepo) PurgeDeletedURLs(_ context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		// keys are collected first, because the bucket mustn't be changed while a cursor walks over it
+		var deleted []string
+		cursor := tx.Bucket(bucketDeleted).Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			if int64(binary.BigEndian.Uint64(key[:8])) >= before.UnixNano() {
+				break
+			}
+			deleted = append(deleted, string(key[8:]))
+		}
+		for _, shortURL := range deleted {
+			url, exists, err := getURL(tx, shortURL)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			if err = removeURL(tx, shortURL, url); err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("error purging deleted URLs: ", err)
+		return 0, fmt.Errorf("error purging deleted URLs: %w", err)
+	}
+	return purged, nil
+}
+
+// StoreClicks adds click events to the daily counters of their short URLs in a single transaction.
+// Clicks of short URLs removed in the meantime are skipped.
+func (b *URLInBoltRepo) StoreClicks(_ context.Context, clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(bucketURLs)
+		for _, click := range clicks {
+			if urls.Get([]byte(click.ShortURL)) == nil {
+				continue
+			}
+			daily, err := tx.Bucket(bucketClicks).CreateBucketIfNotExists([]byte(click.ShortURL))
+			if err != nil {
+				return err
+			}
+			day := []byte(click.Timestamp.UTC().Format(time.DateOnly))
+			var counter [8]byte
+			if count := daily.Get(day); count != nil {
+				binary.BigEndian.PutUint64(counter[:], binary.BigEndian.Uint64(count)+1)
+			} else {
+				binary.BigEndian.PutUint64(counter[:], 1)
+			}
+			if err = daily.Put(day, counter[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("clicks don't save in bolt storage ", err)
+		return fmt.Errorf("error saving clicks: %w", err)
+	}
+	return nil
+}
+
+// GetURLStats returns the total and daily clicks of the short URL owned by the user from the context.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (b *URLInBoltRepo) GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URLStats{}, fmt.Errorf("invalid user context")
+	}
+	stats := models.URLStats{Daily: []models.DailyClicks{}}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		url, exists, err := getURL(tx, shortURL)
+		if err != nil {
+			return err
+		}
+		if !exists || url.UserID != userID {
+			return models.ErrURLNotFound
+		}
+		stats.Domain = url.Domain
+		daily := tx.Bucket(bucketClicks).Bucket([]byte(shortURL))
+		if daily == nil {
+			return nil
+		}
+		// the days are ordered, because the keys are dates in the YYYY-MM-DD format
+		return daily.ForEach(func(day, count []byte) error {
+			clicks := int64(binary.BigEndian.Uint64(count))
+			stats.Daily = append(stats.Daily, models.DailyClicks{Date: string(day), Clicks: clicks})
+			stats.TotalClicks += clicks
+			return nil
+		})
+	})
+	if err != nil {
+		return models.URLStats{}, err
+	}
+	return stats, nil
+}
+
+// ExportURLs calls fn for every stored short URL with its owner, deleted flag and change history until fn returns an error.
+// The URLs are read in a single read transaction ordered by short URL.
+func (b *URLInBoltRepo) ExportURLs(_ context.Context, fn func(record models.URLRecord) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketURLs).ForEach(func(shortURL, data []byte) error {
+			var url boltURL
+			if err := json.Unmarshal(data, &url); err != nil {
+				return fmt.Errorf("error decoding short URL %s: %w", shortURL, err)
+			}
+			return fn(models.URLRecord{
+				ShortURL:     string(shortURL),
+				OriginalURL:  url.OriginalURL,
+				UserID:       url.UserID,
+				ExpiresAt:    url.ExpiresAt,
+				DeletedFlag:  url.DeletedFlag,
+				CreatedAt:    url.CreatedAt,
+				Domain:       url.Domain,
+				DeletedAt:    url.DeletedAt,
+				RedirectCode: url.RedirectCode,
+				History:      url.History,
+			})
+		})
+	})
+}
+
+// ImportURLs saves the records keeping their short URLs, owners, deleted flags and change histories in a single transaction.
+// Records of already stored links and records contradicting stored links are skipped and counted in the report.
+// In a dry run the records are only checked in a read transaction.
+func (b *URLInBoltRepo) ImportURLs(_ context.Context, records []models.URLRecord, dryRun bool) (models.ImportReport, error) {
+	var report models.ImportReport
+	importRecords := func(tx *bolt.Tx) error {
+		report = models.ImportReport{}
+		originals := tx.Bucket(bucketOriginals)
+		for _, record := range records {
+			var byShort *storedLink
+			url, exists, err := getURL(tx, record.ShortURL)
+			if err != nil {
+				return err
+			}
+			if exists {
+				byShort = &storedLink{OriginalURL: url.OriginalURL, UserID: url.UserID, Domain: url.Domain}
+			}
+			shortOfOriginal := originals.Get([]byte(originalKey(record.Domain, record.OriginalURL)))
+			stored, reason := checkImport(record, byShort, string(shortOfOriginal))
+			if !addChecked(&report, record, stored, reason) || dryRun {
+				continue
+			}
+			createdAt := recordCreatedAt(record, time.Now())
+			if err = insertURL(tx, record.UserID, record.OriginalURL, record.ShortURL, recordOptions(record), createdAt); err != nil {
+				return err
+			}
+			if !record.DeletedFlag && len(record.History) == 0 {
+				continue
+			}
+			if url, _, err = getURL(tx, record.ShortURL); err != nil {
+				return err
+			}
+			url.History = record.History
+			if record.DeletedFlag {
+				err = markDeleted(tx, record.ShortURL, url, record.DeletedAt)
+			} else {
+				err = putURL(tx, record.ShortURL, url)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if dryRun {
+		err = b.db.View(importRecords)
+	} else {
+		err = b.db.Update(importRecords)
+	}
+	if err != nil {
+		logrus.Error("urls aren't imported to bolt storage ", err)
+		return models.ImportReport{}, err
+	}
+	return report, nil
+}
+
+// UpdateURL changes the original URL of the short URL owned by the user from the context
+// and saves the replaced original URL in its history in a single transaction.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user,
+// models.ErrURLDeleted or models.ErrURLExpired if it can't be followed anymore
+// and models.ErrOriginalURLTaken if another link on the domain leads to the original URL.
+func (b *URLInBoltRepo) UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URL{}, fmt.Errorf("invalid user context")
+	}
+	var updated boltURL
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		url, exists, err := getURL(tx, shortURL)
+		if err != nil {
+			return err
+		}
+		if !exists || url.UserID != userID {
+			return models.ErrURLNotFound
+		}
+		if url.DeletedFlag {
+			return models.ErrURLDeleted
+		}
+		if url.expiredBefore(time.Now()) {
+			return models.ErrURLExpired
+		}
+		updated = url
+		if url.OriginalURL == originalURL {
+			return nil
+		}
+		updated.OriginalURL = originalURL
+		updated.History = append(updated.History, models.URLChange{OriginalURL: url.OriginalURL, ChangedAt: time.Now().UTC()})
+		originals := tx.Bucket(bucketOriginals)
+		if originals.Get(updated.originalKey()) != nil {
+			return models.ErrOriginalURLTaken
+		}
+		if err = originals.Delete(url.originalKey()); err != nil {
+			return err
+		}
+		if err = originals.Put(updated.originalKey(), []byte(shortURL)); err != nil {
+			return err
+		}
+		return putURL(tx, shortURL, updated)
+	})
+	if err != nil {
+		return models.URL{}, err
+	}
+	return updated.userURL(shortURL), nil
+}
+
+// GetURLHistory returns the former original URLs of the short URL owned by the user from the context,
+// the most recently replaced first.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (b *URLInBoltRepo) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return nil, fmt.Errorf("invalid user context")
+	}
+	var history []models.URLChange
+	err := b.db.View(func(tx *bolt.Tx) error {
+		url, exists, err := getURL(tx, shortURL)
+		if err != nil {
+			return err
+		}
+		if !exists || url.UserID != userID {
+			return models.ErrURLNotFound
+		}
+		history = reverseHistory(url.History)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// StoreAPIKey saves the API key in the api_keys bucket.
+func (b *URLInBoltRepo) StoreAPIKey(_ context.Context, key models.APIKey) error {
+	data, err := json.Marshal(boltAPIKey{UserID: key.UserID, Name: key.Name, Scopes: key.Scopes, CreatedAt: key.CreatedAt, Hash: key.Hash})
+	if err != nil {
+		return err
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAPIKeys).Put([]byte(key.ID), data)
+	})
+	if err != nil {
+		logrus.Error("error saving API key: ", err)
+		return fmt.Errorf("error saving API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns the API key by its ID from the api_keys bucket.
+// It returns models.ErrAPIKeyNotFound if the key doesn't exist.
+func (b *URLInBoltRepo) GetAPIKey(_ context.Context, id string) (models.APIKey, error) {
+	var key models.APIKey
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketAPIKeys).Get([]byte(id))
+		if data == nil {
+			return models.ErrAPIKeyNotFound
+		}
+		var stored boltAPIKey
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("error decoding API key %s: %w", id, err)
+		}
+		key = stored.apiKey(id)
+		return nil
+	})
+	return key, err
+}
+
+// GetUserAPIKeys returns the API keys of the user from the context ordered by creation time.
+// Users have few keys, so the api_keys bucket is scanned instead of being indexed by user.
+func (b *URLInBoltRepo) GetUserAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return nil, fmt.Errorf("invalid user context")
+	}
+	keys := []models.APIKey{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAPIKeys).ForEach(func(id, data []byte) error {
+			var stored boltAPIKey
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return fmt.Errorf("error decoding API key %s: %w", id, err)
+			}
+			if stored.UserID == userID {
+				keys = append(keys, stored.apiKey(string(id)))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+// DeleteAPIKey removes the API key of the user from the context from the api_keys bucket.
+// It returns models.ErrAPIKeyNotFound if the key doesn't exist or belongs to another user.
+func (b *URLInBoltRepo) DeleteAPIKey(ctx context.Context, id string) error {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return fmt.Errorf("invalid user context")
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketAPIKeys)
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return models.ErrAPIKeyNotFound
+		}
+		var stored boltAPIKey
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("error decoding API key %s: %w", id, err)
+		}
+		if stored.UserID != userID {
+			return models.ErrAPIKeyNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// ExportAPIKeys calls fn for every API key with its user and hash until fn returns an error.
+// The keys are read in a single read transaction ordered by ID.
+func (b *URLInBoltRepo) ExportAPIKeys(_ context.Context, fn func(key models.APIKey) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAPIKeys).ForEach(func(id, data []byte) error {
+			var stored boltAPIKey
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return fmt.Errorf("error decoding API key %s: %w", id, err)
+			}
+			return fn(stored.apiKey(string(id)))
+		})
+	})
+}
+
+// ImportAPIKeys saves the API keys keeping their IDs, users and hashes in a single transaction.
+// Keys already stored and keys whose IDs are taken by other keys are skipped and counted in the report.
+// In a dry run the keys are only checked in a read transaction.
+func (b *URLInBoltRepo) ImportAPIKeys(_ context.Context, keys []models.APIKey, dryRun bool) (models.ImportItemsReport, error) {
+	var report models.ImportItemsReport
+	importKeys := func(tx *bolt.Tx) error {
+		report = models.ImportItemsReport{}
+		bucket := tx.Bucket(bucketAPIKeys)
+		for _, key := range keys {
+			var stored *models.APIKey
+			if data := bucket.Get([]byte(key.ID)); data != nil {
+				var storedKey boltAPIKey
+				if err := json.Unmarshal(data, &storedKey); err != nil {
+					return fmt.Errorf("error decoding API key %s: %w", key.ID, err)
+				}
+				apiKey := storedKey.apiKey(key.ID)
+				stored = &apiKey
+			}
+			exists, reason := checkAPIKeyImport(key, stored)
+			if !addCheckedItem(&report, key.ID, key.UserID, exists, reason) || dryRun {
+				continue
+			}
+			data, err := json.Marshal(boltAPIKey{UserID: key.UserID, Name: key.Name, Scopes: key.Scopes, CreatedAt: key.CreatedAt,
+				Hash: key.Hash})
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put([]byte(key.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if dryRun {
+		err = b.db.View(importKeys)
+	} else {
+		err = b.db.Update(importKeys)
+	}
+	if err != nil {
+		logrus.Error("API keys aren't imported to bolt storage ", err)
+		return models.ImportItemsReport{}, err
+	}
+	return report, nil
+}
+
+// StoreAccount saves the account in the accounts bucket and indexes it by its user.
+// It returns models.ErrLoginTaken if another account has the login.
+func (b *URLInBoltRepo) StoreAccount(_ context.Context, account models.Account) error {
+	data, err := json.Marshal(boltAccount{UserID: account.UserID, CreatedAt: account.CreatedAt, PasswordHash: account.PasswordHash})
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		accounts := tx.Bucket(bucketAccounts)
+		if accounts.Get([]byte(account.Login)) != nil {
+			return models.ErrLoginTaken
+		}
+		logins := tx.Bucket(bucketUserLogin)
+		if logins.Get([]byte(account.UserID.String())) != nil {
+			return fmt.Errorf("user %s already has an account", account.UserID)
+		}
+		if err := accounts.Put([]byte(account.Login), data); err != nil {
+			return err
+		}
+		return logins.Put([]byte(account.UserID.String()), []byte(account.Login))
+	})
+}
+
+// getAccount reads the account with the login.
+// It returns models.ErrAccountNotFound if the account doesn't exist.
+func getAccount(tx *bolt.Tx, login []byte) (models.Account, error) {
+	data := tx.Bucket(bucketAccounts).Get(login)
+	if data == nil {
+		return models.Account{}, models.ErrAccountNotFound
+	}
+	var stored boltAccount
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return models.Account{}, fmt.Errorf("error decoding account %s: %w", login, err)
+	}
+	return stored.account(string(login)), nil
+}
+
+// GetAccount returns the account with the login from the accounts bucket.
+// It returns models.ErrAccountNotFound if the account doesn't exist.
+func (b *URLInBoltRepo) GetAccount(_ context.Context, login string) (models.Account, error) {
+	var account models.Account
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		account, err = getAccount(tx, []byte(login))
+		return err
+	})
+	return account, err
+}
+
+// GetUserAccount returns the account of the user from the context from the accounts bucket.
+// It returns models.ErrAccountNotFound if the user hasn't registered.
+func (b *URLInBoltRepo) GetUserAccount(ctx context.Context) (models.Account, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.Account{}, fmt.Errorf("invalid user context")
+	}
+	var account models.Account
+	err := b.db.View(func(tx *bolt.Tx) error {
+		login := tx.Bucket(bucketUserLogin).Get([]byte(userID.String()))
+		if login == nil {
+			return models.ErrAccountNotFound
+		}
+		var err error
+		account, err = getAccount(tx, login)
+		return err
+	})
+	return account, err
+}
+
+// ExportAccounts calls fn for every account with its password hash until fn returns an error.
+// The accounts are read in a single read transaction ordered by login.
+func (b *URLInBoltRepo) ExportAccounts(_ context.Context, fn func(account models.Account) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketAccounts).ForEach(func(login, data []byte) error {
+			var stored boltAccount
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return fmt.Errorf("error decoding account %s: %w", login, err)
+			}
+			return fn(stored.account(string(login)))
+		})
+	})
+}
+
+// ImportAccounts saves the accounts keeping their logins, users and password hashes in a single transaction.
+// Accounts already stored and accounts whose logins are taken or whose users have other accounts
+// are skipped and counted in the report. In a dry run the accounts are only checked in a read transaction.
+func (b *URLInBoltRepo) ImportAccounts(_ context.Context, accounts []models.Account, dryRun bool) (models.ImportItemsReport, error) {
+	var report models.ImportItemsReport
+	importAccounts := func(tx *bolt.Tx) error {
+		report = models.ImportItemsReport{}
+		logins := tx.Bucket(bucketUserLogin)
+		for _, account := range accounts {
+			var byLogin *models.Account
+			stored, err := getAccount(tx, []byte(account.Login))
+			switch {
+			case err == nil:
+				byLogin = &stored
+			case !errors.Is(err, models.ErrAccountNotFound):
+				return err
+			}
+			loginOfUser := logins.Get([]byte(account.UserID.String()))
+			exists, reason := checkAccountImport(account, byLogin, string(loginOfUser))
+			if !addCheckedItem(&report, account.Login, account.UserID, exists, reason) || dryRun {
+				continue
+			}
+			data, err := json.Marshal(boltAccount{UserID: account.UserID, CreatedAt: account.CreatedAt,
+				PasswordHash: account.PasswordHash})
+			if err != nil {
+				return err
+			}
+			if err = tx.Bucket(bucketAccounts).Put([]byte(account.Login), data); err != nil {
+				return err
+			}
+			if err = logins.Put([]byte(account.UserID.String()), []byte(account.Login)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if dryRun {
+		err = b.db.View(importAccounts)
+	} else {
+		err = b.db.Update(importAccounts)
+	}
+	if err != nil {
+		logrus.Error("accounts aren't imported to bolt storage ", err)
+		return models.ImportItemsReport{}, err
+	}
+	return report, nil
+}
+
+// ClaimURLs moves the short URLs of the user fromUserID to the user from the context in a single transaction
+// and returns how many were moved. The bucket of the former user is removed.
+func (b *URLInBoltRepo) ClaimURLs(ctx context.Context, fromUserID uuid.UUID) (int64, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return 0, fmt.Errorf("invalid user context")
+	}
+	if userID == fromUserID {
+		return 0, nil
+	}
+	var claimed int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(bucketUsers)
+		fromURLs := users.Bucket([]byte(fromUserID.String()))
+		if fromURLs == nil {
+			return nil
+		}
+		userURLs, err := users.CreateBucketIfNotExists([]byte(userID.String()))
+		if err != nil {
+			return err
+		}
+		err = fromURLs.ForEach(func(shortURL, _ []byte) error {
+			url, exists, err := getURL(tx, string(shortURL))
+			if err != nil || !exists || url.UserID != fromUserID {
+				return err
+			}
+			url.UserID = userID
+			if err = putURL(tx, string(shortURL), url); err != nil {
+				return err
+			}
+			claimed++
+			return userURLs.Put(shortURL, []byte{})
+		})
+		if err != nil {
+			return err
+		}
+		// the user without URLs is not counted in the statistics
+		if key, _ := userURLs.Cursor().First(); key == nil {
+			if err = users.DeleteBucket([]byte(userID.String())); err != nil {
+				return err
+			}
+		}
+		return users.DeleteBucket([]byte(fromUserID.String()))
+	})
+	if err != nil {
+		logrus.Error("error claiming URLs: ", err)
+		return 0, fmt.Errorf("error claiming URLs: %w", err)
+	}
+	return claimed, nil
+}
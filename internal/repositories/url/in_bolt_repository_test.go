@@ -0,0 +1,88 @@
+package url
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestURLInBoltRepo_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.bolt")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	repo, err := NewURLInBoltRepo(path)
+	require.NoError(t, err)
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com", "short", models.URLOptions{ExpiresAt: expiresAt}))
+	require.NoError(t, repo.StoreURL(ctx, "http://deleted.com", "deleted", models.URLOptions{}))
+	require.NoError(t, repo.MarkURLsAsDeleted(ctx, []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"deleted"}}}))
+	require.NoError(t, repo.StoreClicks(ctx, []models.Click{{ShortURL: "short", Timestamp: time.Now()}}))
+	require.NoError(t, repo.Close())
+
+	// the data is read back from the file
+	reopened, err := NewURLInBoltRepo(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.NoError(t, reopened.Ping(ctx))
+
+	redirect, err := reopened.GetRedirect(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", redirect.OriginalURL)
+	_, err = reopened.GetRedirect(ctx, "deleted")
+	assert.ErrorIs(t, err, models.ErrURLDeleted)
+
+	urls, err := reopened.GetUserURLs(ctx, models.UserURLsQuery{})
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+	for _, url := range urls {
+		if url.ShortURL == "short" {
+			require.NotNil(t, url.ExpiresAt)
+			assert.True(t, expiresAt.Equal(*url.ExpiresAt))
+		}
+	}
+	stats, err := reopened.GetURLStats(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+}
+
+func TestURLInBoltRepo_IndexDeleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.bolt")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+
+	repo, err := NewURLInBoltRepo(path)
+	require.NoError(t, err)
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com", "short", models.URLOptions{}))
+	require.NoError(t, repo.StoreURL(ctx, "http://deleted.com", "deleted", models.URLOptions{}))
+	require.NoError(t, repo.MarkURLsAsDeleted(ctx, []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"deleted"}}}))
+	// the file is turned into one written before deletion times were recorded
+	require.NoError(t, repo.db.Update(func(tx *bolt.Tx) error {
+		url, _, err := getURL(tx, "deleted")
+		if err != nil {
+			return err
+		}
+		url.DeletedAt = nil
+		if err = putURL(tx, "deleted", url); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(bucketDeleted)
+	}))
+	require.NoError(t, repo.Close())
+
+	// the deleted URL is indexed when the file is opened, so it is purged
+	reopened, err := NewURLInBoltRepo(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	purged, err := reopened.PurgeDeletedURLs(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = reopened.GetRedirect(ctx, "deleted")
+	assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+	_, err = reopened.GetRedirect(ctx, "short")
+	require.NoError(t, err)
+}
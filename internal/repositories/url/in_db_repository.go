@@ -4,15 +4,38 @@ package url
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
 )
 
+// shortURLConstraint is the name of the unique index on shorted_URL.short_url.
+const shortURLConstraint = "shorted_url_short_url_key"
+
+// loginConstraint is the name of the unique index of account logins.
+const loginConstraint = "accounts_login_key"
+
+// uniqueViolationCode is the PostgreSQL error code of a unique constraint violation.
+const uniqueViolationCode = "23505"
+
+// conflictError converts a violation of the unique short URL index into models.ShortURLConflictError.
+// Other errors are returned unchanged.
+func conflictError(err error, shortURL string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == shortURLConstraint {
+		return &models.ShortURLConflictError{ShortURL: shortURL}
+	}
+	return err
+}
+
 // URLInDBRepo represents the repository for storing and retrieving URLs in the PostgreSQL database.
 type URLInDBRepo struct {
 	UserID uuid.UUID     `json:"id"`
@@ -20,37 +43,15 @@ type URLInDBRepo struct {
 }
 
 // NewURLInDBRepo creates a new instance of URLInDBRepo with the provided database connection pool.
-// It also initializes the database table for storing shortened URLs.
+// The database schema is expected to be prepared by the migrations package.
 func NewURLInDBRepo(DB *pgxpool.Pool) (*URLInDBRepo, error) {
 	storage := &URLInDBRepo{
 		UserID: uuid.Nil,
 		DB:     DB,
 	}
-	if err := storage.createBDTable(); err != nil {
-		logrus.Errorf("error create DB table: %v", err)
-	}
 	return storage, nil
 }
 
-// createBDTable creates the "shorted_URL" table in the database if it doesn't already exist.
-func (d *URLInDBRepo) createBDTable() error {
-	ctx := context.Background()
-	sqlQuery := `
-		CREATE TABLE IF NOT EXISTS shorted_URL (
-		user_id UUID NOT NULL,
-		short_url VARCHAR(250) NOT NULL,
-		original_url VARCHAR(4096) NOT NULL UNIQUE,
-		deleted_flag bool NOT NULL DEFAULT false
-	)`
-	_, err := d.DB.Exec(ctx, sqlQuery)
-	if err != nil {
-		logrus.Errorf("don't create table shorted_URL: %v", err)
-		return err
-	}
-	logrus.Info("Successfully created table shorted_URL")
-	return nil
-}
-
 func (d *URLInDBRepo) Ping(ctx context.Context) error {
 	if d.DB != nil {
 		if err := d.Ping(ctx); err != nil {
@@ -63,110 +64,175 @@ func (d *URLInDBRepo) Ping(ctx context.Context) error {
 	return errors.New("DB is not initialised")
 }
 
-// StoreURL saves a mapping between an original URL and its shortened version in the database.
-// It returns an error if the saving process fails.
-func (d *URLInDBRepo) StoreURL(ctx context.Context, originalURL, shortURL string) error {
+// StoreURL saves a mapping between an original URL and its shortened version with its options in the database.
+// It returns models.ShortURLConflictError if the short URL is already taken,
+// models.OriginalURLConflictError if the original URL has been shortened to another short URL
+// or another error if the saving process fails.
+func (d *URLInDBRepo) StoreURL(ctx context.Context, originalURL, shortURL string, options models.URLOptions) error {
 	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
 	if !ok {
 		logrus.Errorf("context value is not userID: %v", userID)
 	}
-	const sqlQuery = `INSERT INTO shorted_URL (user_id, original_url, short_url) VALUES ($1, $2, $3) ON CONFLICT (original_url) DO NOTHING`
-	_, err := d.DB.Exec(ctx, sqlQuery, userID, originalURL, shortURL)
+	const sqlQuery = `INSERT INTO shorted_URL (user_id, original_url, short_url, expires_at, domain, redirect_code)
+					  VALUES ($1, $2, $3, $4, $5, $6)
+					  ON CONFLICT (domain, original_url) DO NOTHING`
+	tag, err := d.DB.Exec(ctx, sqlQuery, userID, originalURL, shortURL, timePtr(options.ExpiresAt), options.Domain,
+		options.RedirectCode)
 	if err != nil {
 		logrus.Error("url don't save in database ", err)
-		return err
+		return conflictError(err, shortURL)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	// the original URL may have been shortened by a concurrent request since the service looked it up
+	const selectQuery = `SELECT short_url FROM shorted_URL WHERE domain = $1 AND original_url = $2`
+	var stored string
+	if err = d.DB.QueryRow(ctx, selectQuery, options.Domain, originalURL).Scan(&stored); err != nil {
+		logrus.Error("error querying for short URL: ", err)
+		return fmt.Errorf("error querying for short URL: %w", err)
+	}
+	if stored != shortURL {
+		return &models.OriginalURLConflictError{ShortURL: stored}
 	}
 	return nil
 }
 
 // StoreBatchURL saves multiple URL mappings in the database in a batch operation.
-// The input is a map where keys are shortened URLs and values are the corresponding original URLs.
-// It returns an error if the batch saving process fails.
-func (d *URLInDBRepo) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string) error {
+// The input is a map where keys are shortened URLs and values are the corresponding original URLs,
+// and a map of original URLs to their options.
+// The whole batch is inserted with one query in a single transaction: if any short URL is already taken,
+// nothing is saved and models.ShortURLConflictError with this short URL is returned.
+// Original URLs that are already shortened are skipped.
+func (d *URLInDBRepo) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string,
+	options map[string]models.URLOptions) error {
 	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
 	if !ok {
 		logrus.Errorf("context value is not userID: %v", userID)
 	}
+	if len(batchURLtoStores) == 0 {
+		return nil
+	}
+	shortURLs := make([]string, 0, len(batchURLtoStores))
+	originalURLs := make([]string, 0, len(batchURLtoStores))
+	expiresAt := make([]*time.Time, 0, len(batchURLtoStores))
+	domains := make([]string, 0, len(batchURLtoStores))
+	redirectCodes := make([]int, 0, len(batchURLtoStores))
+	for shortURL, originalURL := range batchURLtoStores {
+		shortURLs = append(shortURLs, shortURL)
+		originalURLs = append(originalURLs, originalURL)
+		expiresAt = append(expiresAt, timePtr(options[originalURL].ExpiresAt))
+		domains = append(domains, options[originalURL].Domain)
+		redirectCodes = append(redirectCodes, options[originalURL].RedirectCode)
+	}
+
 	tx, err := d.DB.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	const sqlQuery = `INSERT INTO shorted_URL (user_id, original_url, short_url) VALUES ($1, $2, $3) ON CONFLICT (original_url) DO NOTHING`
-	_, err = tx.Prepare(ctx, "store_batch_url", sqlQuery)
+	defer tx.Rollback(ctx)
+	// rows taken by either unique index are skipped, only the inserted short URLs are returned
+	const insertQuery = `INSERT INTO shorted_URL (user_id, short_url, original_url, expires_at, domain, redirect_code)
+						 SELECT $1::uuid, r.short_url, r.original_url, r.expires_at, r.domain, r.redirect_code
+						 FROM unnest($2::varchar[], $3::varchar[], $4::timestamptz[], $5::varchar[], $6::smallint[])
+						 AS r(short_url, original_url, expires_at, domain, redirect_code)
+						 ON CONFLICT DO NOTHING
+						 RETURNING short_url`
+	rows, err := tx.Query(ctx, insertQuery, userID, shortURLs, originalURLs, expiresAt, domains, redirectCodes)
 	if err != nil {
+		logrus.Error("urls don't save in database ", err)
 		return err
 	}
-	for shortURL, originalURL := range batchURLtoStores {
-		_, err = tx.Exec(ctx, "store_batch_url", userID, originalURL, shortURL)
-		if err != nil {
-			logrus.Error("url don't save in database ", err)
-			tx.Rollback(ctx)
-			return err
+	inserted, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		logrus.Error("urls don't save in database ", err)
+		return err
+	}
+	if len(inserted) < len(shortURLs) {
+		// a skipped row is a conflict if its short URL is stored for another original URL or domain
+		const conflictQuery = `SELECT s.short_url FROM shorted_URL s
+							   JOIN unnest($1::varchar[], $2::varchar[], $3::varchar[]) AS r(short_url, original_url, domain)
+							   ON s.short_url = r.short_url
+							   WHERE s.original_url <> r.original_url OR s.domain <> r.domain
+							   LIMIT 1`
+		var shortURL string
+		err = tx.QueryRow(ctx, conflictQuery, shortURLs, originalURLs, domains).Scan(&shortURL)
+		switch {
+		case err == nil:
+			logrus.Errorf("url don't save in database: short URL %s is taken", shortURL)
+			return &models.ShortURLConflictError{ShortURL: shortURL}
+		case !errors.Is(err, pgx.ErrNoRows):
+			logrus.Error("error querying for taken short URLs: ", err)
+			return fmt.Errorf("error querying for taken short URLs: %w", err)
 		}
 	}
 	return tx.Commit(ctx)
 }
 
-// MarkURLsAsDeleted marks user URLs as deleted in DB
-func (d *URLInDBRepo) MarkURLsAsDeleted(ctx context.Context, URLSToDel []string) error {
-	if len(URLSToDel) == 0 {
-		return nil
-	}
-	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
-	if !ok {
-		logrus.Errorf("context value is not userID: %v", userID)
-		return fmt.Errorf("invalid user context")
+// MarkURLsAsDeleted marks the short URLs of the deletions of many users as deleted in DB
+// with a single UPDATE and records when they were deleted.
+// Only URLs owned by the user of the deletion are marked, the deletion time of already deleted URLs isn't changed.
+func (d *URLInDBRepo) MarkURLsAsDeleted(ctx context.Context, deletions []models.URLDeletion) error {
+	var shortURLs, userIDs []string
+	for _, deletion := range deletions {
+		for _, shortURL := range deletion.ShortURLs {
+			shortURLs = append(shortURLs, shortURL)
+			userIDs = append(userIDs, deletion.UserID.String())
+		}
 	}
-	tx, err := d.DB.Begin(ctx)
-	if err != nil {
-		logrus.Error("Failed to begin transaction: ", err)
-		return err
+	if len(shortURLs) == 0 {
+		return nil
 	}
-	defer func() {
-		if err != nil {
-			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				logrus.Errorf("Failed to rollback transaction: %v", rollbackErr)
-			}
-		}
-	}()
-
-	const sqlQuery = `UPDATE shorted_URL SET deleted_flag = true WHERE short_url = ANY($1) AND user_id = $2`
-	_, err = tx.Exec(ctx, sqlQuery, URLSToDel, userID)
+	const sqlQuery = `UPDATE shorted_URL s SET deleted_flag = true, deleted_at = now()
+					  FROM unnest($1::varchar[], $2::uuid[]) AS d(short_url, user_id)
+					  WHERE s.short_url = d.short_url AND s.user_id = d.user_id AND NOT s.deleted_flag`
+	tag, err := d.DB.Exec(ctx, sqlQuery, shortURLs, userIDs)
 	if err != nil {
 		logrus.Error("Failed to mark URLs as deleted: ", err)
 		return err
 	}
-	logrus.Infof("Complete mark URLs as deleted: %s, %d", URLSToDel, userID)
-	return tx.Commit(ctx)
+	logrus.Infof("Complete mark URLs as deleted: %d of %d", tag.RowsAffected(), len(shortURLs))
+	return nil
 }
 
-// GetOriginalURL retrieves the original URL corresponding to a given shortened URL from the database.
-// It returns the original URL and any error encountered during the retrieval.
-func (d *URLInDBRepo) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
-	const selectQuery = `SELECT original_url, deleted_flag FROM shorted_URL WHERE short_url = $1`
-	var originalURL string
+// GetRedirect retrieves the original URL and the domain of a given shortened URL from the database.
+// It returns models.ErrOriginalURLNotFound if the short URL doesn't exist, models.ErrURLDeleted if it is deleted
+// and models.ErrURLExpired if the short URL has expired but hasn't been removed yet.
+func (d *URLInDBRepo) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	const selectQuery = `SELECT original_url, domain, redirect_code, deleted_flag, expires_at, expires_at <= now()
+						 FROM shorted_URL WHERE short_url = $1`
+	var redirect models.Redirect
 	var deletedFlag bool
-	err := d.DB.QueryRow(ctx, selectQuery, shortURL).Scan(&originalURL, &deletedFlag)
+	var expiresAt *time.Time
+	var expired *bool
+	err := d.DB.QueryRow(ctx, selectQuery, shortURL).Scan(&redirect.OriginalURL, &redirect.Domain, &redirect.Code,
+		&deletedFlag, &expiresAt, &expired)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", fmt.Errorf("original URL not found")
+			return models.Redirect{}, models.ErrOriginalURLNotFound
 		}
 		logrus.Error("error querying for short URL: ", err)
 
-		return "", fmt.Errorf("error querying for short URL: %w", err)
+		return models.Redirect{}, fmt.Errorf("error querying for short URL: %w", err)
 	}
 	if deletedFlag {
-		return "", models.ErrURLDeleted
+		return models.Redirect{}, models.ErrURLDeleted
+	}
+	if expired != nil && *expired {
+		return models.Redirect{}, models.ErrURLExpired
+	}
+	if expiresAt != nil {
+		redirect.ExpiresAt = *expiresAt
 	}
-	return originalURL, nil
+	return redirect, nil
 }
 
-// GetShortURL retrieves the shortened version of a given original URL from the database.
+// GetShortURL retrieves the shortened version of a given original URL on the domain from the database.
 // It returns the shortened URL and any error encountered during the retrieval.
-func (d *URLInDBRepo) GetShortURL(ctx context.Context, originalURL string) (string, error) {
-	const selectQuery = `SELECT short_url FROM shorted_URL WHERE original_url = $1`
+func (d *URLInDBRepo) GetShortURL(ctx context.Context, domain, originalURL string) (string, error) {
+	const selectQuery = `SELECT short_url FROM shorted_URL WHERE domain = $1 AND original_url = $2`
 	var shortURL string
-	err := d.DB.QueryRow(ctx, selectQuery, originalURL).Scan(&shortURL)
+	err := d.DB.QueryRow(ctx, selectQuery, domain, originalURL).Scan(&shortURL)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return "", fmt.Errorf("short URL not found: %w", err)
@@ -178,29 +244,60 @@ func (d *URLInDBRepo) GetShortURL(ctx context.Context, originalURL string) (stri
 	return shortURL, nil
 }
 
-// GetUserURLs takes a slice of models.URL objects for a specific user from DB
-func (d *URLInDBRepo) GetUserURLs(ctx context.Context) ([]models.URL, error) {
-	const selectQuery = `SELECT short_url,original_url FROM shorted_URL WHERE user_id = $1`
+// Queries of the user URLs pages. The rows are ordered by creation time and short URL,
+// so the page after the cursor is read by the index on (user_id, created_at, short_url).
+// The search string is matched as a case-insensitive substring of the original URL within the user URLs.
+const (
+	userURLsAscQuery = `SELECT short_url, original_url, expires_at, created_at, domain, redirect_code FROM shorted_URL
+						WHERE user_id = $1 AND ($2 = '' OR original_url ILIKE '%' || $2 || '%')
+						AND ($3::timestamptz IS NULL OR (created_at, short_url) > ($3, $4))
+						ORDER BY created_at, short_url LIMIT $5`
+	userURLsDescQuery = `SELECT short_url, original_url, expires_at, created_at, domain, redirect_code FROM shorted_URL
+						 WHERE user_id = $1 AND ($2 = '' OR original_url ILIKE '%' || $2 || '%')
+						 AND ($3::timestamptz IS NULL OR (created_at, short_url) < ($3, $4))
+						 ORDER BY created_at DESC, short_url DESC LIMIT $5`
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetUserURLs returns the URLs of the user from the context selected by the query from DB.
+func (d *URLInDBRepo) GetUserURLs(ctx context.Context, query models.UserURLsQuery) ([]models.URL, error) {
 	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
 	if !ok {
 		logrus.Errorf("context value is not userID: %v", userID)
 	}
-	rows, err := d.DB.Query(ctx, selectQuery, userID)
+	selectQuery := userURLsAscQuery
+	if query.Desc {
+		selectQuery = userURLsDescQuery
+	}
+	var afterTime *time.Time
+	var afterShortURL string
+	if query.After != nil {
+		afterTime, afterShortURL = &query.After.CreatedAt, query.After.ShortURL
+	}
+	// NULL limit returns all rows
+	var limit *int
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+	rows, err := d.DB.Query(ctx, selectQuery, userID, likeEscaper.Replace(query.Search), afterTime, afterShortURL, limit)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("userID not found: %w", err)
-		}
 		logrus.Error("error querying for user usersURLS: ", err)
 		return nil, fmt.Errorf("error querying for user usersURLS: %w", err)
 	}
 	defer rows.Close()
 
-	var allUserShortURLs []models.URL
+	allUserShortURLs := make([]models.URL, 0, max(query.Limit, 0))
 	for rows.Next() {
 		rowResult := models.URL{}
-		if err = rows.Scan(&rowResult.ShortURL, &rowResult.OriginalURL); err != nil {
+		var createdAt time.Time
+		if err = rows.Scan(&rowResult.ShortURL, &rowResult.OriginalURL, &rowResult.ExpiresAt, &createdAt,
+			&rowResult.Domain, &rowResult.RedirectCode); err != nil {
 			logrus.Error(err)
+			return nil, err
 		}
+		rowResult.CreatedAt = &createdAt
 		allUserShortURLs = append(allUserShortURLs, rowResult)
 	}
 	if err = rows.Err(); err != nil {
@@ -210,35 +307,36 @@ func (d *URLInDBRepo) GetUserURLs(ctx context.Context) ([]models.URL, error) {
 	return allUserShortURLs, nil
 }
 
-// GetShortBatchURL retrieves multiple shortened URLs corresponding to a batch of original URLs from the database.
-// The input is a slice of URLRequest objects containing original URLs.
+// GetShortBatchURL retrieves multiple shortened URLs on the domain corresponding to a batch of original URLs from the database.
+// The input is a slice of URLRequest objects containing original URLs, all of them are looked up with one query.
 //
 //	It returns found in database a map of original URLs to their shortened counterparts and any error encountered during the retrieval.
-func (d *URLInDBRepo) GetShortBatchURL(ctx context.Context, batchURLRequests []models.URLRequest) (map[string]string, error) {
+func (d *URLInDBRepo) GetShortBatchURL(ctx context.Context, domain string, batchURLRequests []models.URLRequest) (map[string]string, error) {
 	var shortsURL = make(map[string]string, len(batchURLRequests))
-	var shortURL string
-	if d.DB == nil {
-		fmt.Println("Repository not pool")
+	originalURLs := make([]string, 0, len(batchURLRequests))
+	for _, request := range batchURLRequests {
+		originalURLs = append(originalURLs, request.OriginalURL)
 	}
-	tx, err := d.DB.Begin(ctx)
+	const selectQuery = `SELECT original_url, short_url FROM shorted_URL WHERE domain = $1 AND original_url = ANY($2)`
+	rows, err := d.DB.Query(ctx, selectQuery, domain, originalURLs)
 	if err != nil {
-		return nil, err
+		logrus.Error("error querying for original URLs: ", err)
+		return nil, fmt.Errorf("error querying for original URLs: %w", err)
 	}
-	const selectQuery = `SELECT short_url FROM shorted_URL WHERE original_url = $1`
-	for _, request := range batchURLRequests {
-		err = tx.QueryRow(ctx, selectQuery, request.OriginalURL).Scan(&shortURL)
-		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				continue
-			}
-			tx.Rollback(ctx)
-			logrus.Error("error querying for original URL: ", err)
-			return nil, fmt.Errorf("error querying for original URL: %w", err)
+	defer rows.Close()
+	for rows.Next() {
+		var originalURL, shortURL string
+		if err = rows.Scan(&originalURL, &shortURL); err != nil {
+			logrus.Error(err)
+			return nil, err
 		}
-		shortsURL[request.OriginalURL] = shortURL
+		shortsURL[originalURL] = shortURL
 	}
-
-	return shortsURL, tx.Commit(ctx)
+	if err = rows.Err(); err != nil {
+		logrus.Error("error querying for original URLs: ", err)
+		return nil, fmt.Errorf("error querying for original URLs: %w", err)
+	}
+	return shortsURL, nil
 }
 
 // GetStats retrieves the statistics of URLs and users from the database.
@@ -258,3 +356,770 @@ func (d *URLInDBRepo) GetStats(ctx context.Context) (models.Stats, error) {
 	stats := models.Stats{CountURLs: urls, CountUsers: users}
 	return stats, nil
 }
+
+// DeleteExpiredURLs removes short URLs expired before the given time from the database
+// and returns how many were removed.
+func (d *URLInDBRepo) DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	const deleteQuery = `DELETE FROM shorted_URL WHERE expires_at < $1`
+	tag, err := d.DB.Exec(ctx, deleteQuery, before)
+	if err != nil {
+		logrus.Error("error deleting expired URLs: ", err)
+		return 0, fmt.Errorf("error deleting expired URLs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RestoreURL clears the deleted flag and the deletion time of the short URL owned by the user from the context
+// in the database and returns the URL. The row of the short URL is locked while it is checked and changed.
+// A short URL that isn't deleted is returned unchanged.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user
+// and models.ErrRestorePeriodOver if it was deleted at or before deletedAfter.
+func (d *URLInDBRepo) RestoreURL(ctx context.Context, shortURL string, deletedAfter time.Time) (models.URL, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URL{}, fmt.Errorf("invalid user context")
+	}
+	tx, err := d.DB.Begin(ctx)
+	if err != nil {
+		logrus.Error(err)
+		return models.URL{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	const selectQuery = `SELECT original_url, expires_at, created_at, domain, redirect_code, deleted_flag, deleted_at
+						 FROM shorted_URL WHERE short_url = $1 AND user_id = $2 FOR UPDATE`
+	url := models.URL{ShortURL: shortURL}
+	var deletedFlag bool
+	var deletedAt *time.Time
+	err = tx.QueryRow(ctx, selectQuery, shortURL, userID).Scan(&url.OriginalURL, &url.ExpiresAt, &url.CreatedAt, &url.Domain,
+		&url.RedirectCode, &deletedFlag, &deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.URL{}, models.ErrURLNotFound
+		}
+		logrus.Error("error querying for short URL: ", err)
+		return models.URL{}, fmt.Errorf("error querying for short URL: %w", err)
+	}
+	if !deletedFlag {
+		return url, nil
+	}
+	if deletedAt == nil || !deletedAt.After(deletedAfter) {
+		return models.URL{}, models.ErrRestorePeriodOver
+	}
+	const updateQuery = `UPDATE shorted_URL SET deleted_flag = false, deleted_at = NULL WHERE short_url = $1`
+	if _, err = tx.Exec(ctx, updateQuery, shortURL); err != nil {
+		logrus.Error("error restoring short URL: ", err)
+		return models.URL{}, fmt.Errorf("error restoring short URL: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logrus.Error(err)
+		return models.URL{}, err
+	}
+	return url, nil
+}
+
+// PurgeDeletedURLs removes short URLs deleted before the given time from the database
+// and returns how many were removed. Their clicks and change history are removed by the foreign keys.
+func (d *URLInDBRepo) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	const deleteQuery = `DELETE FROM shorted_URL WHERE deleted_flag AND (deleted_at IS NULL OR deleted_at < $1)`
+	tag, err := d.DB.Exec(ctx, deleteQuery, before)
+	if err != nil {
+		logrus.Error("error purging deleted URLs: ", err)
+		return 0, fmt.Errorf("error purging deleted URLs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// StoreClicks saves click events in the database with a single query.
+// Clicks of short URLs removed in the meantime are skipped.
+func (d *URLInDBRepo) StoreClicks(ctx context.Context, clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	shortURLs := make([]string, len(clicks))
+	clickedAt := make([]time.Time, len(clicks))
+	referrers := make([]string, len(clicks))
+	userAgents := make([]string, len(clicks))
+	clientIPs := make([]string, len(clicks))
+	for i, click := range clicks {
+		shortURLs[i] = click.ShortURL
+		clickedAt[i] = click.Timestamp
+		referrers[i] = click.Referrer
+		userAgents[i] = click.UserAgent
+		clientIPs[i] = click.ClientIP
+	}
+	const insertQuery = `INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, client_ip)
+						 SELECT c.short_url, c.clicked_at, c.referrer, c.user_agent, c.client_ip
+						 FROM unnest($1::varchar[], $2::timestamptz[], $3::text[], $4::text[], $5::varchar[])
+						 AS c(short_url, clicked_at, referrer, user_agent, client_ip)
+						 JOIN shorted_URL s ON s.short_url = c.short_url`
+	_, err := d.DB.Exec(ctx, insertQuery, shortURLs, clickedAt, referrers, userAgents, clientIPs)
+	if err != nil {
+		logrus.Error("clicks don't save in database ", err)
+		return fmt.Errorf("error saving clicks: %w", err)
+	}
+	return nil
+}
+
+// GetURLStats returns the total and daily clicks of the short URL owned by the user from the context.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (d *URLInDBRepo) GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URLStats{}, fmt.Errorf("invalid user context")
+	}
+	const ownerQuery = `SELECT domain FROM shorted_URL WHERE short_url = $1 AND user_id = $2`
+	var domain string
+	if err := d.DB.QueryRow(ctx, ownerQuery, shortURL, userID).Scan(&domain); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.URLStats{}, models.ErrURLNotFound
+		}
+		logrus.Error("error querying for short URL owner: ", err)
+		return models.URLStats{}, fmt.Errorf("error querying for short URL owner: %w", err)
+	}
+
+	const selectQuery = `SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(*)
+						 FROM clicks WHERE short_url = $1 GROUP BY day ORDER BY day`
+	rows, err := d.DB.Query(ctx, selectQuery, shortURL)
+	if err != nil {
+		logrus.Error("error querying for clicks: ", err)
+		return models.URLStats{}, fmt.Errorf("error querying for clicks: %w", err)
+	}
+	defer rows.Close()
+
+	stats := models.URLStats{Domain: domain, Daily: []models.DailyClicks{}}
+	for rows.Next() {
+		var day models.DailyClicks
+		if err = rows.Scan(&day.Date, &day.Clicks); err != nil {
+			logrus.Error(err)
+			return models.URLStats{}, err
+		}
+		stats.Daily = append(stats.Daily, day)
+		stats.TotalClicks += day.Clicks
+	}
+	if err = rows.Err(); err != nil {
+		logrus.Error(err)
+		return models.URLStats{}, err
+	}
+	return stats, nil
+}
+
+// UpdateURL changes the original URL of the short URL owned by the user from the context
+// and saves the replaced original URL in the url_history table in a single transaction.
+// The row of the short URL is locked, so concurrent changes are applied one by one.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user,
+// models.ErrURLDeleted or models.ErrURLExpired if it can't be followed anymore
+// and models.ErrOriginalURLTaken if another link on the domain leads to the original URL.
+func (d *URLInDBRepo) UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URL{}, fmt.Errorf("invalid user context")
+	}
+	tx, err := d.DB.Begin(ctx)
+	if err != nil {
+		logrus.Error(err)
+		return models.URL{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	const selectQuery = `SELECT original_url, expires_at, created_at, domain, redirect_code, deleted_flag, expires_at <= now()
+						 FROM shorted_URL WHERE short_url = $1 AND user_id = $2 FOR UPDATE`
+	url := models.URL{ShortURL: shortURL}
+	var current string
+	var deletedFlag bool
+	var expired *bool
+	err = tx.QueryRow(ctx, selectQuery, shortURL, userID).Scan(&current, &url.ExpiresAt, &url.CreatedAt, &url.Domain,
+		&url.RedirectCode, &deletedFlag, &expired)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.URL{}, models.ErrURLNotFound
+		}
+		logrus.Error("error querying for short URL: ", err)
+		return models.URL{}, fmt.Errorf("error querying for short URL: %w", err)
+	}
+	if deletedFlag {
+		return models.URL{}, models.ErrURLDeleted
+	}
+	if expired != nil && *expired {
+		return models.URL{}, models.ErrURLExpired
+	}
+	url.OriginalURL = originalURL
+	if current == originalURL {
+		return url, nil
+	}
+
+	const historyQuery = `INSERT INTO url_history (short_url, original_url, changed_at) VALUES ($1, $2, now())`
+	if _, err = tx.Exec(ctx, historyQuery, shortURL, current); err != nil {
+		logrus.Error("error saving URL history: ", err)
+		return models.URL{}, fmt.Errorf("error saving URL history: %w", err)
+	}
+	const updateQuery = `UPDATE shorted_URL SET original_url = $1 WHERE short_url = $2`
+	if _, err = tx.Exec(ctx, updateQuery, originalURL, shortURL); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return models.URL{}, models.ErrOriginalURLTaken
+		}
+		logrus.Error("error updating short URL: ", err)
+		return models.URL{}, fmt.Errorf("error updating short URL: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logrus.Error(err)
+		return models.URL{}, err
+	}
+	return url, nil
+}
+
+// GetURLHistory returns the former original URLs of the short URL owned by the user from the context,
+// the most recently replaced first.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (d *URLInDBRepo) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return nil, fmt.Errorf("invalid user context")
+	}
+	const ownerQuery = `SELECT EXISTS (SELECT 1 FROM shorted_URL WHERE short_url = $1 AND user_id = $2)`
+	var owned bool
+	if err := d.DB.QueryRow(ctx, ownerQuery, shortURL, userID).Scan(&owned); err != nil {
+		logrus.Error("error querying for short URL owner: ", err)
+		return nil, fmt.Errorf("error querying for short URL owner: %w", err)
+	}
+	if !owned {
+		return nil, models.ErrURLNotFound
+	}
+
+	const selectQuery = `SELECT original_url, changed_at FROM url_history WHERE short_url = $1 ORDER BY changed_at DESC, id DESC`
+	rows, err := d.DB.Query(ctx, selectQuery, shortURL)
+	if err != nil {
+		logrus.Error("error querying for URL history: ", err)
+		return nil, fmt.Errorf("error querying for URL history: %w", err)
+	}
+	defer rows.Close()
+	history := []models.URLChange{}
+	for rows.Next() {
+		var change models.URLChange
+		if err = rows.Scan(&change.OriginalURL, &change.ChangedAt); err != nil {
+			logrus.Error(err)
+			return nil, err
+		}
+		change.ChangedAt = change.ChangedAt.UTC()
+		history = append(history, change)
+	}
+	if err = rows.Err(); err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+	return history, nil
+}
+
+// ExportURLs calls fn for every stored short URL with its owner, deleted flag and change history until fn returns an error.
+// The rows are streamed from a single query ordered by short URL, the history of each is aggregated into JSON.
+func (d *URLInDBRepo) ExportURLs(ctx context.Context, fn func(record models.URLRecord) error) error {
+	const selectQuery = `SELECT s.short_url, s.original_url, s.user_id, s.expires_at, s.deleted_flag, s.created_at, s.domain,
+						 s.deleted_at, s.redirect_code,
+						 (SELECT json_agg(json_build_object('original_url', h.original_url, 'changed_at', h.changed_at)
+						                  ORDER BY h.changed_at, h.id)
+						  FROM url_history h WHERE h.short_url = s.short_url)
+						 FROM shorted_URL s ORDER BY s.short_url`
+	rows, err := d.DB.Query(ctx, selectQuery)
+	if err != nil {
+		logrus.Error("error querying for URLs: ", err)
+		return fmt.Errorf("error querying for URLs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var record models.URLRecord
+		var history []byte
+		if err = rows.Scan(&record.ShortURL, &record.OriginalURL, &record.UserID, &record.ExpiresAt, &record.DeletedFlag,
+			&record.CreatedAt, &record.Domain, &record.DeletedAt, &record.RedirectCode, &history); err != nil {
+			logrus.Error(err)
+			return err
+		}
+		// the history is NULL if the original URL has never been changed
+		if history != nil {
+			if err = json.Unmarshal(history, &record.History); err != nil {
+				return fmt.Errorf("error decoding history of short URL %s: %w", record.ShortURL, err)
+			}
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportURLs saves the records keeping their short URLs, owners, deleted flags and change histories.
+// The stored links with the same short URLs or original URLs are read with one query,
+// the records of already stored links and the records contradicting stored links are skipped and counted in the report,
+// the rest are inserted with one query, which also inserts the change histories of the inserted links.
+// In a dry run the records are only checked.
+func (d *URLInDBRepo) ImportURLs(ctx context.Context, records []models.URLRecord, dryRun bool) (models.ImportReport, error) {
+	var report models.ImportReport
+	if len(records) == 0 {
+		return report, nil
+	}
+	shortURLs := make([]string, len(records))
+	originalURLs := make([]string, len(records))
+	domains := make([]string, len(records))
+	for i, record := range records {
+		shortURLs[i] = record.ShortURL
+		originalURLs[i] = record.OriginalURL
+		domains[i] = record.Domain
+	}
+	const selectQuery = `SELECT short_url, original_url, user_id, domain FROM shorted_URL
+						 WHERE short_url = ANY($1)
+						 OR (domain, original_url) IN (SELECT * FROM unnest($2::varchar[], $3::varchar[]))`
+	rows, err := d.DB.Query(ctx, selectQuery, shortURLs, domains, originalURLs)
+	if err != nil {
+		logrus.Error("error querying for stored URLs: ", err)
+		return report, fmt.Errorf("error querying for stored URLs: %w", err)
+	}
+	byShort := make(map[string]storedLink)
+	shortOfOriginal := make(map[string]string)
+	for rows.Next() {
+		var shortURL string
+		var link storedLink
+		if err = rows.Scan(&shortURL, &link.OriginalURL, &link.UserID, &link.Domain); err != nil {
+			rows.Close()
+			logrus.Error(err)
+			return report, err
+		}
+		byShort[shortURL] = link
+		shortOfOriginal[originalKey(link.Domain, link.OriginalURL)] = shortURL
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		logrus.Error(err)
+		return report, err
+	}
+
+	var userIDs []string
+	var expiresAt, createdAt, deletedAt []*time.Time
+	var deleted []bool
+	var redirectCodes []int
+	var historyShortURLs, historyOriginalURLs []string
+	var historyChangedAt []time.Time
+	shortURLs, originalURLs, domains = shortURLs[:0], originalURLs[:0], domains[:0]
+	for _, record := range records {
+		var stored *storedLink
+		if link, ok := byShort[record.ShortURL]; ok {
+			stored = &link
+		}
+		exists, reason := checkImport(record, stored, shortOfOriginal[originalKey(record.Domain, record.OriginalURL)])
+		if !addChecked(&report, record, exists, reason) {
+			continue
+		}
+		userIDs = append(userIDs, record.UserID.String())
+		shortURLs = append(shortURLs, record.ShortURL)
+		originalURLs = append(originalURLs, record.OriginalURL)
+		expiresAt = append(expiresAt, record.ExpiresAt)
+		deleted = append(deleted, record.DeletedFlag)
+		createdAt = append(createdAt, record.CreatedAt)
+		domains = append(domains, record.Domain)
+		deletedAt = append(deletedAt, record.DeletedAt)
+		redirectCodes = append(redirectCodes, record.RedirectCode)
+		for _, change := range record.History {
+			historyShortURLs = append(historyShortURLs, record.ShortURL)
+			historyOriginalURLs = append(historyOriginalURLs, change.OriginalURL)
+			historyChangedAt = append(historyChangedAt, change.ChangedAt)
+		}
+	}
+	if dryRun || len(shortURLs) == 0 {
+		return report, nil
+	}
+
+	// the history rows are inserted only for the links the first insert hasn't skipped
+	const insertQuery = `WITH inserted AS (
+						 INSERT INTO shorted_URL (user_id, short_url, original_url, expires_at, deleted_flag, created_at, domain, deleted_at,
+						 redirect_code)
+						 SELECT r.user_id, r.short_url, r.original_url, r.expires_at, r.deleted_flag, COALESCE(r.created_at, now()), r.domain,
+						        CASE WHEN r.deleted_flag THEN r.deleted_at END, r.redirect_code
+						 FROM unnest($1::uuid[], $2::varchar[], $3::varchar[], $4::timestamptz[], $5::bool[], $6::timestamptz[], $7::varchar[],
+						             $8::timestamptz[], $9::smallint[])
+						 AS r(user_id, short_url, original_url, expires_at, deleted_flag, created_at, domain, deleted_at, redirect_code)
+						 ON CONFLICT DO NOTHING
+						 RETURNING short_url
+						 ), history AS (
+						 INSERT INTO url_history (short_url, original_url, changed_at)
+						 SELECT h.short_url, h.original_url, h.changed_at
+						 FROM unnest($10::varchar[], $11::varchar[], $12::timestamptz[]) WITH ORDINALITY AS h(short_url, original_url, changed_at, n)
+						 WHERE h.short_url IN (SELECT short_url FROM inserted)
+						 ORDER BY h.n
+						 )
+						 SELECT count(*) FROM inserted`
+	var inserted int
+	err = d.DB.QueryRow(ctx, insertQuery, userIDs, shortURLs, originalURLs, expiresAt, deleted, createdAt, domains, deletedAt,
+		redirectCodes, historyShortURLs, historyOriginalURLs, historyChangedAt).Scan(&inserted)
+	if err != nil {
+		logrus.Error("urls aren't imported to database ", err)
+		return models.ImportReport{}, fmt.Errorf("error importing URLs: %w", err)
+	}
+	// links stored by concurrent requests since the check are skipped by the insert
+	if skipped := len(shortURLs) - inserted; skipped > 0 {
+		logrus.Warnf("%d imported URLs have been stored concurrently and are skipped", skipped)
+		report.Imported -= skipped
+		report.Existing += skipped
+	}
+	return report, nil
+}
+
+// StoreAPIKey saves the API key in the api_keys table.
+func (d *URLInDBRepo) StoreAPIKey(ctx context.Context, key models.APIKey) error {
+	const insertQuery = `INSERT INTO api_keys (id, user_id, name, scopes, key_hash, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := d.DB.Exec(ctx, insertQuery, key.ID, key.UserID, key.Name, key.Scopes, key.Hash, key.CreatedAt); err != nil {
+		logrus.Error("error saving API key: ", err)
+		return fmt.Errorf("error saving API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns the API key by its ID from the api_keys table.
+// It returns models.ErrAPIKeyNotFound if the key doesn't exist.
+func (d *URLInDBRepo) GetAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	const selectQuery = `SELECT user_id, name, scopes, key_hash, created_at FROM api_keys WHERE id = $1`
+	key := models.APIKey{ID: id}
+	err := d.DB.QueryRow(ctx, selectQuery, id).Scan(&key.UserID, &key.Name, &key.Scopes, &key.Hash, &key.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, models.ErrAPIKeyNotFound
+		}
+		logrus.Error("error querying for API key: ", err)
+		return models.APIKey{}, fmt.Errorf("error querying for API key: %w", err)
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+	return key, nil
+}
+
+// GetUserAPIKeys returns the API keys of the user from the context ordered by creation time.
+func (d *URLInDBRepo) GetUserAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return nil, fmt.Errorf("invalid user context")
+	}
+	const selectQuery = `SELECT id, name, scopes, key_hash, created_at FROM api_keys WHERE user_id = $1 ORDER BY created_at, id`
+	rows, err := d.DB.Query(ctx, selectQuery, userID)
+	if err != nil {
+		logrus.Error("error querying for API keys: ", err)
+		return nil, fmt.Errorf("error querying for API keys: %w", err)
+	}
+	defer rows.Close()
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key := models.APIKey{UserID: userID}
+		if err = rows.Scan(&key.ID, &key.Name, &key.Scopes, &key.Hash, &key.CreatedAt); err != nil {
+			logrus.Error(err)
+			return nil, err
+		}
+		key.CreatedAt = key.CreatedAt.UTC()
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey removes the API key of the user from the context from the api_keys table.
+// It returns models.ErrAPIKeyNotFound if the key doesn't exist or belongs to another user.
+func (d *URLInDBRepo) DeleteAPIKey(ctx context.Context, id string) error {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return fmt.Errorf("invalid user context")
+	}
+	const deleteQuery = `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+	tag, err := d.DB.Exec(ctx, deleteQuery, id, userID)
+	if err != nil {
+		logrus.Error("error deleting API key: ", err)
+		return fmt.Errorf("error deleting API key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// ExportAPIKeys calls fn for every API key with its user and hash until fn returns an error.
+// The rows are streamed from a single query ordered by ID.
+func (d *URLInDBRepo) ExportAPIKeys(ctx context.Context, fn func(key models.APIKey) error) error {
+	const selectQuery = `SELECT id, user_id, name, scopes, key_hash, created_at FROM api_keys ORDER BY id`
+	rows, err := d.DB.Query(ctx, selectQuery)
+	if err != nil {
+		logrus.Error("error querying for API keys: ", err)
+		return fmt.Errorf("error querying for API keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key models.APIKey
+		if err = rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Scopes, &key.Hash, &key.CreatedAt); err != nil {
+			logrus.Error(err)
+			return err
+		}
+		key.CreatedAt = key.CreatedAt.UTC()
+		if err = fn(key); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportAPIKeys saves the API keys keeping their IDs, users and hashes.
+// The stored keys with the same IDs are read with one query, the keys already stored and the keys
+// whose IDs are taken by other keys are skipped and counted in the report, the rest are inserted with one query.
+// In a dry run the keys are only checked.
+func (d *URLInDBRepo) ImportAPIKeys(ctx context.Context, keys []models.APIKey, dryRun bool) (models.ImportItemsReport, error) {
+	var report models.ImportItemsReport
+	if len(keys) == 0 {
+		return report, nil
+	}
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.ID
+	}
+	const selectQuery = `SELECT id, user_id, key_hash FROM api_keys WHERE id = ANY($1)`
+	rows, err := d.DB.Query(ctx, selectQuery, ids)
+	if err != nil {
+		logrus.Error("error querying for stored API keys: ", err)
+		return report, fmt.Errorf("error querying for stored API keys: %w", err)
+	}
+	storedKeys := make(map[string]models.APIKey)
+	for rows.Next() {
+		var key models.APIKey
+		if err = rows.Scan(&key.ID, &key.UserID, &key.Hash); err != nil {
+			rows.Close()
+			logrus.Error(err)
+			return report, err
+		}
+		storedKeys[key.ID] = key
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		logrus.Error(err)
+		return report, err
+	}
+
+	var userIDs, names, scopes, hashes []string
+	var createdAt []time.Time
+	ids = ids[:0]
+	for _, key := range keys {
+		var stored *models.APIKey
+		if storedKey, ok := storedKeys[key.ID]; ok {
+			stored = &storedKey
+		}
+		exists, reason := checkAPIKeyImport(key, stored)
+		if !addCheckedItem(&report, key.ID, key.UserID, exists, reason) {
+			continue
+		}
+		ids = append(ids, key.ID)
+		userIDs = append(userIDs, key.UserID.String())
+		names = append(names, key.Name)
+		// scopes are passed joined, unnest can't take an array of arrays of different lengths
+		scopes = append(scopes, strings.Join(key.Scopes, " "))
+		hashes = append(hashes, key.Hash)
+		createdAt = append(createdAt, key.CreatedAt)
+	}
+	if dryRun || len(ids) == 0 {
+		return report, nil
+	}
+
+	const insertQuery = `INSERT INTO api_keys (id, user_id, name, scopes, key_hash, created_at)
+						 SELECT k.id, k.user_id, k.name, string_to_array(k.scopes, ' '), k.key_hash, k.created_at
+						 FROM unnest($1::varchar[], $2::uuid[], $3::varchar[], $4::text[], $5::varchar[], $6::timestamptz[])
+						 AS k(id, user_id, name, scopes, key_hash, created_at)
+						 ON CONFLICT DO NOTHING`
+	tag, err := d.DB.Exec(ctx, insertQuery, ids, userIDs, names, scopes, hashes, createdAt)
+	if err != nil {
+		logrus.Error("API keys aren't imported to database ", err)
+		return models.ImportItemsReport{}, fmt.Errorf("error importing API keys: %w", err)
+	}
+	// keys stored by concurrent requests since the check are skipped by the insert
+	if skipped := len(ids) - int(tag.RowsAffected()); skipped > 0 {
+		logrus.Warnf("%d imported API keys have been stored concurrently and are skipped", skipped)
+		report.Imported -= skipped
+		report.Existing += skipped
+	}
+	return report, nil
+}
+
+// StoreAccount saves the account in the accounts table.
+// It returns models.ErrLoginTaken if another account has the login.
+func (d *URLInDBRepo) StoreAccount(ctx context.Context, account models.Account) error {
+	const insertQuery = `INSERT INTO accounts (user_id, login, password_hash, created_at) VALUES ($1, $2, $3, $4)`
+	_, err := d.DB.Exec(ctx, insertQuery, account.UserID, account.Login, account.PasswordHash, account.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == loginConstraint {
+			return models.ErrLoginTaken
+		}
+		logrus.Error("error saving account: ", err)
+		return fmt.Errorf("error saving account: %w", err)
+	}
+	return nil
+}
+
+// ExportAccounts calls fn for every account with its password hash until fn returns an error.
+// The rows are streamed from a single query ordered by login.
+func (d *URLInDBRepo) ExportAccounts(ctx context.Context, fn func(account models.Account) error) error {
+	const selectQuery = `SELECT user_id, login, password_hash, created_at FROM accounts ORDER BY login`
+	rows, err := d.DB.Query(ctx, selectQuery)
+	if err != nil {
+		logrus.Error("error querying for accounts: ", err)
+		return fmt.Errorf("error querying for accounts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var account models.Account
+		if err = rows.Scan(&account.UserID, &account.Login, &account.PasswordHash, &account.CreatedAt); err != nil {
+			logrus.Error(err)
+			return err
+		}
+		account.CreatedAt = account.CreatedAt.UTC()
+		if err = fn(account); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportAccounts saves the accounts keeping their logins, users and password hashes.
+// The stored accounts with the same logins or users are read with one query, the accounts already stored
+// and the accounts whose logins are taken or whose users have other accounts are skipped and counted in the report,
+// the rest are inserted with one query. In a dry run the accounts are only checked.
+func (d *URLInDBRepo) ImportAccounts(ctx context.Context, accounts []models.Account, dryRun bool) (models.ImportItemsReport, error) {
+	var report models.ImportItemsReport
+	if len(accounts) == 0 {
+		return report, nil
+	}
+	logins := make([]string, len(accounts))
+	userIDs := make([]string, len(accounts))
+	for i, account := range accounts {
+		logins[i] = account.Login
+		userIDs[i] = account.UserID.String()
+	}
+	const selectQuery = `SELECT user_id, login, password_hash FROM accounts WHERE login = ANY($1) OR user_id = ANY($2::uuid[])`
+	rows, err := d.DB.Query(ctx, selectQuery, logins, userIDs)
+	if err != nil {
+		logrus.Error("error querying for stored accounts: ", err)
+		return report, fmt.Errorf("error querying for stored accounts: %w", err)
+	}
+	byLogin := make(map[string]models.Account)
+	loginOfUser := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var account models.Account
+		if err = rows.Scan(&account.UserID, &account.Login, &account.PasswordHash); err != nil {
+			rows.Close()
+			logrus.Error(err)
+			return report, err
+		}
+		byLogin[account.Login] = account
+		loginOfUser[account.UserID] = account.Login
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		logrus.Error(err)
+		return report, err
+	}
+
+	var hashes []string
+	var createdAt []time.Time
+	logins, userIDs = logins[:0], userIDs[:0]
+	for _, account := range accounts {
+		var stored *models.Account
+		if storedAccount, ok := byLogin[account.Login]; ok {
+			stored = &storedAccount
+		}
+		exists, reason := checkAccountImport(account, stored, loginOfUser[account.UserID])
+		if !addCheckedItem(&report, account.Login, account.UserID, exists, reason) {
+			continue
+		}
+		logins = append(logins, account.Login)
+		userIDs = append(userIDs, account.UserID.String())
+		hashes = append(hashes, account.PasswordHash)
+		createdAt = append(createdAt, account.CreatedAt)
+	}
+	if dryRun || len(logins) == 0 {
+		return report, nil
+	}
+
+	const insertQuery = `INSERT INTO accounts (user_id, login, password_hash, created_at)
+						 SELECT a.user_id, a.login, a.password_hash, a.created_at
+						 FROM unnest($1::uuid[], $2::varchar[], $3::varchar[], $4::timestamptz[])
+						 AS a(user_id, login, password_hash, created_at)
+						 ON CONFLICT DO NOTHING`
+	tag, err := d.DB.Exec(ctx, insertQuery, userIDs, logins, hashes, createdAt)
+	if err != nil {
+		logrus.Error("accounts aren't imported to database ", err)
+		return models.ImportItemsReport{}, fmt.Errorf("error importing accounts: %w", err)
+	}
+	// accounts registered by concurrent requests since the check are skipped by the insert
+	if skipped := len(logins) - int(tag.RowsAffected()); skipped > 0 {
+		logrus.Warnf("%d imported accounts have been registered concurrently and are skipped", skipped)
+		report.Imported -= skipped
+		report.Existing += skipped
+	}
+	return report, nil
+}
+
+// GetAccount returns the account with the login from the accounts table.
+// It returns models.ErrAccountNotFound if the account doesn't exist.
+func (d *URLInDBRepo) GetAccount(ctx context.Context, login string) (models.Account, error) {
+	const selectQuery = `SELECT user_id, password_hash, created_at FROM accounts WHERE login = $1`
+	account := models.Account{Login: login}
+	err := d.DB.QueryRow(ctx, selectQuery, login).Scan(&account.UserID, &account.PasswordHash, &account.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Account{}, models.ErrAccountNotFound
+		}
+		logrus.Error("error querying for account: ", err)
+		return models.Account{}, fmt.Errorf("error querying for account: %w", err)
+	}
+	account.CreatedAt = account.CreatedAt.UTC()
+	return account, nil
+}
+
+// GetUserAccount returns the account of the user from the context from the accounts table.
+// It returns models.ErrAccountNotFound if the user hasn't registered.
+func (d *URLInDBRepo) GetUserAccount(ctx context.Context) (models.Account, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.Account{}, fmt.Errorf("invalid user context")
+	}
+	const selectQuery = `SELECT login, password_hash, created_at FROM accounts WHERE user_id = $1`
+	account := models.Account{UserID: userID}
+	err := d.DB.QueryRow(ctx, selectQuery, userID).Scan(&account.Login, &account.PasswordHash, &account.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Account{}, models.ErrAccountNotFound
+		}
+		logrus.Error("error querying for account: ", err)
+		return models.Account{}, fmt.Errorf("error querying for account: %w", err)
+	}
+	account.CreatedAt = account.CreatedAt.UTC()
+	return account, nil
+}
+
+// ClaimURLs moves the short URLs of the user fromUserID to the user from the context in the shorted_URL table
+// and returns how many were moved.
+func (d *URLInDBRepo) ClaimURLs(ctx context.Context, fromUserID uuid.UUID) (int64, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return 0, fmt.Errorf("invalid user context")
+	}
+	if userID == fromUserID {
+		return 0, nil
+	}
+	const updateQuery = `UPDATE shorted_URL SET user_id = $1 WHERE user_id = $2`
+	tag, err := d.DB.Exec(ctx, updateQuery, userID, fromUserID)
+	if err != nil {
+		logrus.Error("error claiming URLs: ", err)
+		return 0, fmt.Errorf("error claiming URLs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,9 +2,15 @@ package url
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"testing"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewURLInDBRepo(t *testing.T) {
@@ -28,3 +34,105 @@ func TestNewURLInDBRepo(t *testing.T) {
 		t.Error("Expected DB to be initialized, got nil")
 	}
 }
+
+// storeBatchURLPerRow is the former implementation of URLInDBRepo.StoreBatchURL
+// executing one INSERT per URL, kept to compare with in benchmarks.
+func storeBatchURLPerRow(ctx context.Context, d *URLInDBRepo, batchURLtoStores map[string]string) error {
+	userID, _ := ctx.Value(models.UserIDKey).(uuid.UUID)
+	tx, err := d.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	const sqlQuery = `INSERT INTO shorted_URL (user_id, original_url, short_url, expires_at) VALUES ($1, $2, $3, $4) ON CONFLICT (domain, original_url) DO NOTHING`
+	if _, err = tx.Prepare(ctx, "store_batch_url", sqlQuery); err != nil {
+		return err
+	}
+	for shortURL, originalURL := range batchURLtoStores {
+		if _, err = tx.Exec(ctx, "store_batch_url", userID, originalURL, shortURL, nil); err != nil {
+			return conflictError(err, shortURL)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// getShortBatchURLPerRow is the former implementation of URLInDBRepo.GetShortBatchURL
+// executing one SELECT per URL, kept to compare with in benchmarks.
+func getShortBatchURLPerRow(ctx context.Context, d *URLInDBRepo, batchURLRequests []models.URLRequest) (map[string]string, error) {
+	var shortsURL = make(map[string]string, len(batchURLRequests))
+	tx, err := d.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	const selectQuery = `SELECT short_url FROM shorted_URL WHERE domain = '' AND original_url = $1`
+	for _, request := range batchURLRequests {
+		var shortURL string
+		if err = tx.QueryRow(ctx, selectQuery, request.OriginalURL).Scan(&shortURL); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, err
+		}
+		shortsURL[request.OriginalURL] = shortURL
+	}
+	return shortsURL, tx.Commit(ctx)
+}
+
+// BenchmarkURLInDBRepo_Batch compares the set-based batch queries with the per-row ones.
+// It runs only with a test database set by the TEST_DATABASE_DSN env, all data of the database is removed.
+func BenchmarkURLInDBRepo_Batch(b *testing.B) {
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	db := openTestDB(b)
+	repo, err := NewURLInDBRepo(db)
+	require.NoError(b, err)
+
+	// newBatch returns a batch of new short URLs and the requests of the same URLs, half of them stored
+	newBatch := func(b *testing.B, size int) (map[string]string, []models.URLRequest) {
+		b.StopTimer()
+		defer b.StartTimer()
+		_, err := db.Exec(ctx, `TRUNCATE shorted_URL CASCADE`)
+		require.NoError(b, err)
+		batch := make(map[string]string, size)
+		stored := make(map[string]string, size/2)
+		requests := make([]models.URLRequest, 0, size)
+		for i := 0; i < size; i++ {
+			shortURL, originalURL := fmt.Sprintf("short%d", i), fmt.Sprintf("http://example.com/%d", i)
+			batch[shortURL] = originalURL
+			if i%2 == 0 {
+				stored[shortURL] = originalURL
+			}
+			requests = append(requests, models.URLRequest{OriginalURL: originalURL})
+		}
+		require.NoError(b, repo.StoreBatchURL(ctx, stored, nil))
+		return batch, requests
+	}
+
+	for _, size := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("store per row/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				batch, _ := newBatch(b, size)
+				require.NoError(b, storeBatchURLPerRow(ctx, repo, batch))
+			}
+		})
+		b.Run(fmt.Sprintf("store set-based/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				batch, _ := newBatch(b, size)
+				require.NoError(b, repo.StoreBatchURL(ctx, batch, nil))
+			}
+		})
+		_, requests := newBatch(b, size)
+		b.Run(fmt.Sprintf("get per row/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := getShortBatchURLPerRow(ctx, repo, requests)
+				require.NoError(b, err)
+			}
+		})
+		b.Run(fmt.Sprintf("get set-based/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := repo.GetShortBatchURL(ctx, "", requests)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
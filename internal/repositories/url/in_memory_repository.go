@@ -5,82 +5,291 @@ package url
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"io"
 	"os"
+	"sort"
+	"sync"
 	"time"
 )
 
-// URLInFileRepo auxiliary structure for serialization in jSON for save to file
+// URLInFileRepo auxiliary structure for serialization in jSON for save to file.
+// A record with DeletedFlag set is a tombstone: it marks an earlier saved short URL as deleted at DeletedAt.
+// A record with RestoredFlag set clears the deleted flag of an earlier saved short URL.
+// A record with PurgedFlag set removes an earlier saved expired or deleted short URL completely.
+// A record with UpdatedFlag set replaces the state of an earlier saved short URL after its original URL is changed.
+// A record with APIKey set saves the API key of the user with the hash APIKeyHash,
+// together with DeletedFlag it removes an earlier saved API key.
+// A record with Account set saves the account of the user with the password hash PasswordHash.
 type URLInFileRepo struct {
-	UserID      uuid.UUID `json:"user_id"`
-	ShortURL    string    `json:"short_url"`
-	OriginalURL string    `json:"original_url"`
+	UserID       uuid.UUID          `json:"user_id"`
+	ShortURL     string             `json:"short_url"`
+	OriginalURL  string             `json:"original_url,omitempty"`
+	ExpiresAt    *time.Time         `json:"expires_at,omitempty"`
+	DeletedFlag  bool               `json:"is_deleted,omitempty"`
+	PurgedFlag   bool               `json:"is_purged,omitempty"`
+	CreatedAt    *time.Time         `json:"created_at,omitempty"`
+	Domain       string             `json:"domain,omitempty"`
+	UpdatedFlag  bool               `json:"is_updated,omitempty"`
+	History      []models.URLChange `json:"history,omitempty"`
+	DeletedAt    *time.Time         `json:"deleted_at,omitempty"`
+	RestoredFlag bool               `json:"is_restored,omitempty"`
+	RedirectCode int                `json:"redirect_code,omitempty"`
+	APIKey       *models.APIKey     `json:"api_key,omitempty"`
+	APIKeyHash   string             `json:"api_key_hash,omitempty"`
+	Account      *models.Account    `json:"account,omitempty"`
+	PasswordHash string             `json:"password_hash,omitempty"`
 }
 
+// memURL is the state of a short URL kept in memory.
+type memURL struct {
+	OriginalURL string
+	UserID      uuid.UUID
+	ExpiresAt   time.Time // zero if the short URL never expires
+	DeletedFlag bool
+	DeletedAt   time.Time          // zero if the short URL isn't deleted or has been deleted before deletion times were recorded
+	CreatedAt   time.Time          // zero if the short URL has been saved before creation times were recorded
+	Domain      string             // empty for the default domain
+	History     []models.URLChange // History keeps the former original URLs, the most recently replaced last
+	// RedirectCode is the HTTP status code of the redirect, 0 if the short URL has been saved before the codes were recorded
+	RedirectCode int
+}
+
+// originalKey returns the key of the original URL in the index of shortened original URLs.
+func (u memURL) originalKey() string {
+	return originalKey(u.Domain, u.OriginalURL)
+}
+
+// userURL returns the short URL as it is listed among the user URLs.
+func (u memURL) userURL(shortURL string) models.URL {
+	return models.URL{
+		ShortURL:     shortURL,
+		OriginalURL:  u.OriginalURL,
+		ExpiresAt:    timePtr(u.ExpiresAt),
+		CreatedAt:    timePtr(u.CreatedAt),
+		Domain:       u.Domain,
+		RedirectCode: u.RedirectCode,
+	}
+}
+
+// fileRecord returns the record of the storage file saving the short URL.
+func (u memURL) fileRecord(shortURL string) URLInFileRepo {
+	return URLInFileRepo{
+		UserID:       u.UserID,
+		ShortURL:     shortURL,
+		OriginalURL:  u.OriginalURL,
+		ExpiresAt:    timePtr(u.ExpiresAt),
+		CreatedAt:    timePtr(u.CreatedAt),
+		Domain:       u.Domain,
+		History:      u.History,
+		RedirectCode: u.RedirectCode,
+	}
+}
+
+// expiredBefore reports whether the short URL has a limited lifetime that ended before t.
+func (u memURL) expiredBefore(t time.Time) bool {
+	return !u.ExpiresAt.IsZero() && !t.Before(u.ExpiresAt)
+}
+
+// deletedBefore reports whether the short URL is deleted and its deletion time is before t.
+// A short URL deleted before deletion times were recorded is deleted before any time.
+func (u memURL) deletedBefore(t time.Time) bool {
+	return u.DeletedFlag && u.DeletedAt.Before(t)
+}
+
+// tombstone returns the record of the storage file marking the deleted short URL as deleted.
+func (u memURL) tombstone(shortURL string) URLInFileRepo {
+	return URLInFileRepo{UserID: u.UserID, ShortURL: shortURL, DeletedFlag: true, DeletedAt: timePtr(u.DeletedAt)}
+}
+
+// apiKeyRecord returns the record of the storage file saving the API key, or removing it if deleted is set.
+func apiKeyRecord(key models.APIKey, deleted bool) URLInFileRepo {
+	return URLInFileRepo{UserID: key.UserID, APIKey: &key, APIKeyHash: key.Hash, DeletedFlag: deleted}
+}
+
+// accountRecord returns the record of the storage file saving the account.
+func accountRecord(account models.Account) URLInFileRepo {
+	return URLInFileRepo{UserID: account.UserID, Account: &account, PasswordHash: account.PasswordHash}
+}
+
+// timePtr returns a pointer to t, or nil if t is zero.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// originalKey returns the key identifying the original URL shortened on the domain.
+// Original URLs of the default domain are keyed by themselves, so the keys of the links
+// saved before domains were introduced stay the same.
+func originalKey(domain, originalURL string) string {
+	if domain == "" {
+		return originalURL
+	}
+	return domain + "\x00" + originalURL
+}
+
+// FlushPolicy defines when the records buffered by URLInMemoryRepo are written to the storage file.
+// A store is acknowledged before its record is written, unless Records is 1.
+type FlushPolicy struct {
+	Records  int           // Records is the number of buffered records that are written at once, 1 writes every change
+	Interval time.Duration // Interval is the max time a record waits in the buffer, 0 disables writing by time
+}
+
+// DefaultFlushPolicy writes the buffered records every 100 records and at least once a second.
+var DefaultFlushPolicy = FlushPolicy{Records: 100, Interval: time.Second}
+
 // URLInMemoryRepo represents an in-memory repository for managing shortened URLs.
+// It is safe for concurrent use: the lookup maps are sharded and each shard has its own lock,
+// while the batch buffer and the storage file are guarded by separate mutexes.
+// Clicks are kept only in memory and only the latest clickRingSize of them.
+// API keys and accounts are saved in the storage file together with the URLs.
 type URLInMemoryRepo struct {
-	shortToOrigURL  map[string]string
-	origToShortURL  map[string]string
-	usersURLS       map[uuid.UUID][]models.URL
+	updateMu        sync.Mutex // serializes changes of original URLs
+	shortToOrigURL  *shardedMap[string, memURL]
+	origToShortURL  *shardedMap[string, string] // keyed by originalKey of the domain and the original URL
+	usersURLS       *shardedMap[uuid.UUID, []models.URL]
+	clicks          *clickRing
+	batchMu         sync.Mutex // guards batchBuffer
 	batchBuffer     []URLInFileRepo
-	batchCounter    uint8
-	batchSize       uint8
+	flushPolicy     FlushPolicy
+	fileMu          sync.Mutex // serializes writes to the storage file
 	storageFilePath string
+	apiKeysMu       sync.RWMutex // guards apiKeys
+	apiKeys         map[string]models.APIKey
+	accountsMu      sync.RWMutex // guards accounts and userAccounts
+	accounts        map[string]models.Account
+	userAccounts    map[uuid.UUID]string // login of the account of the user
 }
 
 // NewURLInMemoryRepo creates a new instance of URLInMemoryRepo.
-// It takes a file path for storing data.
-func NewURLInMemoryRepo(storageFilePath string) *URLInMemoryRepo {
-	storage := URLInMemoryRepo{
-		shortToOrigURL:  make(map[string]string),
-		origToShortURL:  make(map[string]string),
-		usersURLS:       make(map[uuid.UUID][]models.URL),
+// It takes a file path for storing data and the policy of writing changes to the file.
+func NewURLInMemoryRepo(storageFilePath string, flushPolicy FlushPolicy) *URLInMemoryRepo {
+	storage := &URLInMemoryRepo{
+		shortToOrigURL:  newStringMap[memURL](),
+		origToShortURL:  newStringMap[string](),
+		usersURLS:       newUUIDMap[[]models.URL](),
+		clicks:          newClickRing(clickRingSize),
+		apiKeys:         make(map[string]models.APIKey),
+		accounts:        make(map[string]models.Account),
+		userAccounts:    make(map[uuid.UUID]string),
 		batchBuffer:     []URLInFileRepo{},
-		batchCounter:    0,
-		batchSize:       100,
+		flushPolicy:     flushPolicy,
 		storageFilePath: storageFilePath,
 	}
 	err := storage.readFileToMemoryURL()
 	if err != nil {
 		logrus.Error(err)
 	}
-	return &storage
+	return storage
 }
 
-// readFileToMemoryURL read data from file and write it to memory (to URLInMemoryRepo)
+// readFileToMemoryURL read data from file and write it to memory (to URLInMemoryRepo).
+// Records with a wrong checksum or broken JSON are skipped. If they are at the end of the file,
+// they are a torn tail of an interrupted write, so the file is truncated after the last valid record.
 func (m *URLInMemoryRepo) readFileToMemoryURL() error {
-	file, err := os.Open(m.storageFilePath)
+	file, err := os.OpenFile(m.storageFilePath, os.O_RDWR, 0)
 	if err != nil {
 		logrus.Error(err)
 		return err
 	}
 	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	var buffer []byte
-	var bufferJSON URLInFileRepo
-	for scanner.Scan() {
-		buffer = scanner.Bytes()
-		err = json.Unmarshal(buffer, &bufferJSON)
-		if err != nil {
+	reader := bufio.NewReader(file)
+	var offset, validEnd int64
+	terminated := true
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			record, err := decodeRecord(line)
+			if err != nil {
+				logrus.Warnf("storage record at offset %d is skipped: %v", offset, err)
+			} else {
+				m.applyRecord(record)
+				validEnd = offset + int64(len(line))
+				terminated = line[len(line)-1] == '\n'
+			}
+			offset += int64(len(line))
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			logrus.Error(readErr)
+			return readErr
+		}
+	}
+	if validEnd < offset {
+		logrus.Warnf("storage file %s is truncated from %d to %d bytes", m.storageFilePath, offset, validEnd)
+		if err = file.Truncate(validEnd); err != nil {
 			logrus.Error(err)
 			return err
 		}
-		m.shortToOrigURL[bufferJSON.ShortURL] = bufferJSON.OriginalURL
-		m.origToShortURL[bufferJSON.OriginalURL] = bufferJSON.ShortURL
-		m.usersURLS[bufferJSON.UserID] = append(m.usersURLS[bufferJSON.UserID], models.URL{ShortURL: bufferJSON.ShortURL, OriginalURL: bufferJSON.OriginalURL})
 	}
-	if err = scanner.Err(); err != nil {
-		logrus.Error(err)
-		return err
+	// the next record mustn't be appended to the unterminated last line
+	if !terminated {
+		if _, err = file.WriteAt([]byte{'\n'}, validEnd); err != nil {
+			logrus.Error(err)
+			return err
+		}
 	}
 	return nil
 }
 
+// applyRecord replays the record of the storage file in memory.
+func (m *URLInMemoryRepo) applyRecord(record URLInFileRepo) {
+	if record.Account != nil {
+		account := *record.Account
+		account.UserID, account.PasswordHash = record.UserID, record.PasswordHash
+		m.putAccount(account)
+		return
+	}
+	if record.APIKey != nil {
+		key := *record.APIKey
+		key.UserID, key.Hash = record.UserID, record.APIKeyHash
+		m.apiKeysMu.Lock()
+		if record.DeletedFlag {
+			delete(m.apiKeys, key.ID)
+		} else {
+			m.apiKeys[key.ID] = key
+		}
+		m.apiKeysMu.Unlock()
+		return
+	}
+	if record.DeletedFlag {
+		var deletedAt time.Time
+		if record.DeletedAt != nil {
+			deletedAt = *record.DeletedAt
+		}
+		m.markDeleted(record.UserID, record.ShortURL, deletedAt)
+		return
+	}
+	if record.RestoredFlag {
+		m.restore(record.UserID, record.ShortURL, func(memURL) error { return nil })
+		return
+	}
+	if record.PurgedFlag {
+		m.removeURL(record.ShortURL, func(memURL) bool { return true })
+		return
+	}
+	url := memURL{OriginalURL: record.OriginalURL, UserID: record.UserID, Domain: record.Domain, History: record.History,
+		RedirectCode: record.RedirectCode}
+	if record.ExpiresAt != nil {
+		url.ExpiresAt = *record.ExpiresAt
+	}
+	if record.CreatedAt != nil {
+		url.CreatedAt = *record.CreatedAt
+	}
+	if record.UpdatedFlag {
+		m.removeURL(record.ShortURL, func(memURL) bool { return true })
+	}
+	m.putURL(record.ShortURL, url)
+}
+
 func (m *URLInMemoryRepo) Ping(_ context.Context) error {
 	if m.shortToOrigURL == nil || m.origToShortURL == nil {
 		return errors.New("storage in memory isn't initialised")
@@ -88,111 +297,288 @@ func (m *URLInMemoryRepo) Ping(_ context.Context) error {
 	return nil
 }
 
-// SaveBatchToFile read data from the memory (URLInMemoryRepo batchBuffer) and write to the file in a batch operation
+// SaveBatchToFile read data from the memory (URLInMemoryRepo batchBuffer) and write to the file in a batch operation.
+// The buffer is detached under its lock, so new URLs can be stored while the file is being written.
+// If writing fails, the detached records are returned to the buffer to be saved by the next call.
 func (m *URLInMemoryRepo) SaveBatchToFile() error {
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+
+	batch := m.detachBatch()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := m.writeToFile(batch); err != nil {
+		logrus.Error(err)
+		m.returnToBatch(batch)
+		return err
+	}
+	return nil
+}
+
+// detachBatch takes the records of the batch buffer, leaving it empty.
+func (m *URLInMemoryRepo) detachBatch() []URLInFileRepo {
+	m.batchMu.Lock()
+	defer m.batchMu.Unlock()
+	batch := m.batchBuffer
+	m.batchBuffer = make([]URLInFileRepo, 0, len(batch))
+	return batch
+}
+
+// returnToBatch puts the detached records back before the records buffered since then.
+func (m *URLInMemoryRepo) returnToBatch(batch []URLInFileRepo) {
+	m.batchMu.Lock()
+	defer m.batchMu.Unlock()
+	m.batchBuffer = append(batch, m.batchBuffer...)
+}
+
+// writeToFile appends records to the storage file and syncs it to disk. The caller must hold fileMu.
+func (m *URLInMemoryRepo) writeToFile(batch []URLInFileRepo) error {
 	startTime := time.Now() // Засекаем время начала операции
 	file, err := os.OpenFile(m.storageFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
-		logrus.Error(err)
 		return err
 	}
 	defer file.Close()
 	writer := bufio.NewWriter(file)
-	encoder := json.NewEncoder(writer)
-	for _, v := range m.batchBuffer {
-		err = encoder.Encode(v)
-		if err != nil {
-			return err
-		}
+	if err = writeRecords(writer, batch); err != nil {
+		return err
 	}
 	err = writer.Flush() // Запись оставшихся данных из буфера в файл
 	if err != nil {
 		return err
 	}
+	// данные должны оказаться на диске до того, как запись будет считаться сохраненной
+	if err = file.Sync(); err != nil {
+		return err
+	}
 
 	elapsedTime := time.Since(startTime) // Вычисляем затраченное время
-	logrus.Infof("%d URL saved in %v", m.batchCounter, elapsedTime)
-	m.batchBuffer = make([]URLInFileRepo, 0, m.batchSize)
+	logrus.Infof("%d URL saved in %v", len(batch), elapsedTime)
 	return nil
 }
 
-// StoreURL saves a mapping between an original URL and its shortened version in the database.
-// It returns an error if the saving process fails.
-func (m *URLInMemoryRepo) StoreURL(ctx context.Context, originalURL, shortURL string) error {
-	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+// putURL adds the URL mapping to all in-memory indexes. An already stored short URL is skipped,
+// so a record replayed twice doesn't duplicate the user URLs.
+func (m *URLInMemoryRepo) putURL(shortURL string, url memURL) {
+	if _, exists := m.shortToOrigURL.LoadOrStore(shortURL, url); exists {
+		return
+	}
+	m.origToShortURL.Store(url.originalKey(), shortURL)
+	m.addUserURL(url.UserID, url.userURL(shortURL))
+}
+
+// addUserURL adds the URL to the URLs of the user ordered by creation time.
+func (m *URLInMemoryRepo) addUserURL(userID uuid.UUID, url models.URL) {
+	m.usersURLS.Update(userID, func(urls []models.URL, _ bool) ([]models.URL, bool) {
+		return insertSorted(urls, url), true
+	})
+}
+
+// removeURL deletes the short URL from all in-memory indexes if shouldRemove approves its current state.
+// It returns the removed state and whether the short URL has been removed.
+func (m *URLInMemoryRepo) removeURL(shortURL string, shouldRemove func(url memURL) bool) (memURL, bool) {
+	var removed memURL
+	var ok bool
+	m.shortToOrigURL.Update(shortURL, func(url memURL, exists bool) (memURL, bool) {
+		if exists && shouldRemove(url) {
+			removed, ok = url, true
+			return url, false
+		}
+		return url, exists
+	})
 	if !ok {
-		logrus.Errorf("context value is not userID: %v", userID)
+		return removed, false
 	}
-	m.origToShortURL[originalURL] = shortURL
-	m.shortToOrigURL[shortURL] = originalURL
-	m.usersURLS[userID] = append(m.usersURLS[userID], models.URL{ShortURL: shortURL, OriginalURL: originalURL})
+	m.origToShortURL.Update(removed.originalKey(), func(short string, exists bool) (string, bool) {
+		return short, exists && short != shortURL
+	})
+	m.usersURLS.Update(removed.UserID, func(urls []models.URL, exists bool) ([]models.URL, bool) {
+		kept := make([]models.URL, 0, len(urls))
+		for _, url := range urls {
+			if url.ShortURL != shortURL {
+				kept = append(kept, url)
+			}
+		}
+		return kept, len(kept) > 0
+	})
+	return removed, true
+}
 
-	record := URLInFileRepo{
-		UserID:      userID,
-		ShortURL:    shortURL,
-		OriginalURL: originalURL,
+// appendToBatch adds records to the batch buffer and saves the buffer to the file
+// once it has as many records as the flush policy allows.
+func (m *URLInMemoryRepo) appendToBatch(records ...URLInFileRepo) error {
+	m.batchMu.Lock()
+	m.batchBuffer = append(m.batchBuffer, records...)
+	needFlush := len(m.batchBuffer) >= max(m.flushPolicy.Records, 1)
+	m.batchMu.Unlock()
+	if needFlush {
+		return m.SaveBatchToFile()
 	}
-	m.batchBuffer = append(m.batchBuffer, record)
-	m.batchCounter++
-	if m.batchCounter >= m.batchSize {
-		err := m.SaveBatchToFile()
-		if err != nil {
-			return err
+	return nil
+}
+
+// markDeleted sets the deleted flag and the deletion time of the short URL if it belongs to the user.
+// It reports whether the flag has been changed.
+func (m *URLInMemoryRepo) markDeleted(userID uuid.UUID, shortURL string, deletedAt time.Time) bool {
+	var marked bool
+	m.shortToOrigURL.Update(shortURL, func(url memURL, exists bool) (memURL, bool) {
+		if exists && url.UserID == userID && !url.DeletedFlag {
+			url.DeletedFlag = true
+			url.DeletedAt = deletedAt
+			marked = true
 		}
-		logrus.Infof("%d URL saved to file", m.batchCounter)
-		m.batchCounter = 0
+		return url, exists
+	})
+	return marked
+}
+
+// restore clears the deleted flag of the short URL if it belongs to the user and canRestore approves its state.
+// It returns the state of the short URL, whether the flag has been changed and the error of canRestore.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (m *URLInMemoryRepo) restore(userID uuid.UUID, shortURL string, canRestore func(url memURL) error) (memURL, bool, error) {
+	var current memURL
+	var restored bool
+	err := models.ErrURLNotFound
+	m.shortToOrigURL.Update(shortURL, func(url memURL, exists bool) (memURL, bool) {
+		if !exists || url.UserID != userID {
+			return url, exists
+		}
+		current, err = url, nil
+		if !url.DeletedFlag {
+			return url, true
+		}
+		if err = canRestore(url); err != nil {
+			return url, true
+		}
+		url.DeletedFlag, url.DeletedAt = false, time.Time{}
+		current, restored = url, true
+		return url, true
+	})
+	return current, restored, err
+}
+
+// reserveShortURL takes the short URL for the original URL and reports whether it has been reserved.
+// If the same mapping is already stored, nothing is reserved and no error is returned.
+// It returns models.ShortURLConflictError if the short URL is taken by another original URL.
+func (m *URLInMemoryRepo) reserveShortURL(shortURL string, url memURL) (bool, error) {
+	existing, taken := m.shortToOrigURL.LoadOrStore(shortURL, url)
+	if !taken {
+		return true, nil
+	}
+	if existing.OriginalURL == url.OriginalURL && existing.Domain == url.Domain {
+		return false, nil
 	}
+	return false, &models.ShortURLConflictError{ShortURL: shortURL}
+}
 
-	if m.origToShortURL[originalURL] == "" || m.shortToOrigURL[shortURL] == "" {
-		err := errors.New("error saving shortUrl")
-		logrus.Error(err)
+// commitURL completes saving of a reserved short URL by indexing its original URL and owner.
+// If the original URL has already been shortened, the reservation is released and false is returned
+// with the short URL the original URL is stored under, the same way the database ignores such an insert.
+func (m *URLInMemoryRepo) commitURL(shortURL string, url memURL) (string, bool) {
+	if stored, exists := m.origToShortURL.LoadOrStore(url.originalKey(), shortURL); exists {
+		m.shortToOrigURL.Delete(shortURL)
+		return stored, false
+	}
+	m.addUserURL(url.UserID, url.userURL(shortURL))
+	return shortURL, true
+}
+
+// StoreURL saves a mapping between an original URL and its shortened version with its options in memory.
+// It returns models.ShortURLConflictError if the short URL is already taken,
+// models.OriginalURLConflictError if the original URL has been shortened to another short URL
+// or another error if the saving process fails.
+func (m *URLInMemoryRepo) StoreURL(ctx context.Context, originalURL, shortURL string, options models.URLOptions) error {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+	}
+	url := memURL{OriginalURL: originalURL, UserID: userID, ExpiresAt: options.ExpiresAt, CreatedAt: time.Now(), Domain: options.Domain,
+		RedirectCode: options.RedirectCode}
+	reserved, err := m.reserveShortURL(shortURL, url)
+	if err != nil || !reserved {
 		return err
 	}
-	return nil
+	if stored, ok := m.commitURL(shortURL, url); !ok {
+		// the original URL has been shortened by a concurrent request since the service looked it up
+		return &models.OriginalURLConflictError{ShortURL: stored}
+	}
+	return m.appendToBatch(url.fileRecord(shortURL))
 }
 
-// GetOriginalURL retrieves the original URL corresponding to a given shortened URL from the database.
-// It returns the original URL and any error encountered during the retrieval.
-func (m *URLInMemoryRepo) GetOriginalURL(_ context.Context, shortURL string) (string, error) {
-	originalURL, exists := m.shortToOrigURL[shortURL]
+// GetRedirect retrieves the original URL and the domain of a given shortened URL from memory.
+// It returns models.ErrOriginalURLNotFound if the short URL doesn't exist, models.ErrURLDeleted if it is deleted
+// and models.ErrURLExpired if the short URL has expired but hasn't been removed yet.
+func (m *URLInMemoryRepo) GetRedirect(_ context.Context, shortURL string) (models.Redirect, error) {
+	url, exists := m.shortToOrigURL.Load(shortURL)
 	if !exists {
-		return "", errors.New("original URL not found")
+		return models.Redirect{}, models.ErrOriginalURLNotFound
+	}
+	if url.DeletedFlag {
+		return models.Redirect{}, models.ErrURLDeleted
 	}
-	return originalURL, nil
+	if url.expiredBefore(time.Now()) {
+		return models.Redirect{}, models.ErrURLExpired
+	}
+	return models.Redirect{OriginalURL: url.OriginalURL, Domain: url.Domain, Code: url.RedirectCode, ExpiresAt: url.ExpiresAt}, nil
 }
 
-// GetShortURL retrieves the shortened version of a given original URL from the database.
+// GetShortURL retrieves the shortened version of a given original URL on the domain from the database.
 // It returns the shortened URL and any error encountered during the retrieval.
-func (m *URLInMemoryRepo) GetShortURL(_ context.Context, originalURL string) (string, error) {
-	shortURL, exists := m.origToShortURL[originalURL]
+func (m *URLInMemoryRepo) GetShortURL(_ context.Context, domain, originalURL string) (string, error) {
+	shortURL, exists := m.origToShortURL.Load(originalKey(domain, originalURL))
 	if !exists {
 		return "", errors.New("short URL not found")
 	}
 	return shortURL, nil
 }
 
-// StoreBatchURL saves multiple URL mappings in the database in a batch operation.
-// The input is a map where keys are shortened URLs and values are the corresponding original URLs.
-// It returns an error if the batch saving process fails.
-func (m *URLInMemoryRepo) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string) error {
+// StoreBatchURL saves multiple URL mappings in memory in a batch operation.
+// The input is a map where keys are shortened URLs and values are the corresponding original URLs,
+// and a map of original URLs to their options.
+// All short URLs are reserved first: if any of them is already taken, nothing is saved
+// and models.ShortURLConflictError with this short URL is returned.
+func (m *URLInMemoryRepo) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string,
+	options map[string]models.URLOptions) error {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+	}
+	createdAt := time.Now()
+	reserved := make(map[string]memURL, len(batchURLtoStores))
 	for shortURL, originalURL := range batchURLtoStores {
-		if err := m.StoreURL(ctx, originalURL, shortURL); err != nil {
-			fmt.Println("Error saving to memory")
+		url := memURL{OriginalURL: originalURL, UserID: userID, ExpiresAt: options[originalURL].ExpiresAt, CreatedAt: createdAt,
+			Domain: options[originalURL].Domain, RedirectCode: options[originalURL].RedirectCode}
+		ok, err := m.reserveShortURL(shortURL, url)
+		if err != nil {
+			for r := range reserved {
+				m.shortToOrigURL.Delete(r)
+			}
 			return err
 		}
+		if ok {
+			reserved[shortURL] = url
+		}
 	}
-	return nil
+	records := make([]URLInFileRepo, 0, len(reserved))
+	for shortURL, url := range reserved {
+		if _, ok := m.commitURL(shortURL, url); ok {
+			records = append(records, url.fileRecord(shortURL))
+		}
+	}
+	return m.appendToBatch(records...)
 }
 
-// GetShortBatchURL retrieves multiple shortened URLs corresponding to a batch of original URLs from the database.
+// GetShortBatchURL retrieves multiple shortened URLs on the domain corresponding to a batch of original URLs from the database.
 // The input is a slice of URLRequest objects containing original URLs.
 //
 //	It returns found in database a map of original URLs to their shortened counterparts and any error encountered during the retrieval.
-func (m *URLInMemoryRepo) GetShortBatchURL(_ context.Context, batchURLRequests []models.URLRequest) (map[string]string, error) {
+func (m *URLInMemoryRepo) GetShortBatchURL(_ context.Context, domain string, batchURLRequests []models.URLRequest) (map[string]string, error) {
 	var shortsURL = make(map[string]string, len(batchURLRequests))
 
 	for _, request := range batchURLRequests {
-		if shortURL, ok := m.origToShortURL[request.OriginalURL]; ok {
+		if shortURL, ok := m.origToShortURL.Load(originalKey(domain, request.OriginalURL)); ok {
 			shortsURL[request.OriginalURL] = shortURL
 		}
 	}
@@ -200,32 +586,666 @@ func (m *URLInMemoryRepo) GetShortBatchURL(_ context.Context, batchURLRequests [
 	return shortsURL, nil
 }
 
-// GetUserURLs takes a slice of models.URL objects for a specific user from DB
-func (m *URLInMemoryRepo) GetUserURLs(ctx context.Context) ([]models.URL, error) {
+// GetUserURLs returns the URLs of the user from the context selected by the query.
+// The URLs of a user are kept ordered by creation time, so the page is found by a binary search.
+func (m *URLInMemoryRepo) GetUserURLs(ctx context.Context, query models.UserURLsQuery) ([]models.URL, error) {
 	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
 	if !ok {
 		logrus.Errorf("context value is not userID: %v", userID)
 	}
-	allUserShortURLs, exists := m.usersURLS[userID]
+	userURLs, exists := m.usersURLS.Load(userID)
 	if !exists {
 		return nil, errors.New("userID not found")
 	}
-	return allUserShortURLs, nil
+	// the page is copied, so the slice isn't changed by other goroutines after the lock is released
+	return pageURLs(userURLs, query), nil
 }
 
-//TODO подумать над логикой метода в in memory хранилище
+// MarkURLsAsDeleted marks the short URLs of the deletions of many users as deleted in memory.
+// Only URLs owned by the user of the deletion are marked; for each of them
+// a tombstone record is written to the storage file, so the flag survives a restart.
+func (m *URLInMemoryRepo) MarkURLsAsDeleted(_ context.Context, deletions []models.URLDeletion) error {
+	deletedAt := time.Now().UTC()
+	var requested int
+	var tombstones []URLInFileRepo
+	for _, deletion := range deletions {
+		requested += len(deletion.ShortURLs)
+		for _, shortURL := range deletion.ShortURLs {
+			if m.markDeleted(deletion.UserID, shortURL, deletedAt) {
+				tombstones = append(tombstones, URLInFileRepo{UserID: deletion.UserID, ShortURL: shortURL, DeletedFlag: true, DeletedAt: &deletedAt})
+			}
+		}
+	}
+	if len(tombstones) == 0 {
+		return nil
+	}
+	logrus.Infof("Complete mark URLs as deleted: %d of %d", len(tombstones), requested)
+	return m.appendToBatch(tombstones...)
+}
 
-// MarkURLsAsDeleted marks user URLs as deleted in DB
-func (m *URLInMemoryRepo) MarkURLsAsDeleted(_ context.Context, _ []string) error {
-	return nil
+// RestoreURL clears the deleted flag of the short URL owned by the user from the context in memory
+// and returns the URL. A restore record is written to the storage file, so the URL isn't deleted again after a restart.
+// A short URL that isn't deleted is returned unchanged.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user
+// and models.ErrRestorePeriodOver if it was deleted at or before deletedAfter.
+func (m *URLInMemoryRepo) RestoreURL(ctx context.Context, shortURL string, deletedAfter time.Time) (models.URL, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URL{}, fmt.Errorf("invalid user context")
+	}
+	url, restored, err := m.restore(userID, shortURL, func(url memURL) error {
+		if !url.DeletedAt.After(deletedAfter) {
+			return models.ErrRestorePeriodOver
+		}
+		return nil
+	})
+	if err != nil {
+		return models.URL{}, err
+	}
+	if !restored {
+		return url.userURL(shortURL), nil
+	}
+	return url.userURL(shortURL), m.appendToBatch(URLInFileRepo{UserID: userID, ShortURL: shortURL, RestoredFlag: true})
 }
 
 // GetStats returns the statistics of URLs and users stored in the in-memory repository.
 // This method retrieves the count of shortened URLs and unique users from the in-memory repository.
 // It then constructs a Stats struct containing the counts and returns it along with any error encountered.
 func (m *URLInMemoryRepo) GetStats(ctx context.Context) (models.Stats, error) {
-	countURLs := uint32(len(m.shortToOrigURL))
-	countUsers := uint32(len(m.usersURLS))
+	countURLs := uint32(m.shortToOrigURL.Len())
+	countUsers := uint32(m.usersURLS.Len())
 	stats := models.Stats{CountURLs: countURLs, CountUsers: countUsers}
 	return stats, nil
 }
+
+// DeleteExpiredURLs removes short URLs expired before the given time from memory
+// and returns how many were removed. For each of them a purge record is written
+// to the storage file, so they are not restored after a restart.
+func (m *URLInMemoryRepo) DeleteExpiredURLs(_ context.Context, before time.Time) (int64, error) {
+	var expired []string
+	m.shortToOrigURL.Range(func(shortURL string, url memURL) bool {
+		if url.expiredBefore(before) {
+			expired = append(expired, shortURL)
+		}
+		return true
+	})
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	tombstones := make([]URLInFileRepo, 0, len(expired))
+	for _, shortURL := range expired {
+		url, ok := m.removeURL(shortURL, func(url memURL) bool { return url.expiredBefore(before) })
+		if ok {
+			tombstones = append(tombstones, URLInFileRepo{UserID: url.UserID, ShortURL: shortURL, PurgedFlag: true})
+		}
+	}
+	return int64(len(tombstones)), m.appendToBatch(tombstones...)
+}
+
+// PurgeDeletedURLs removes short URLs deleted before the given time from memory
+// and returns how many were removed. For each of them a purge record is written
+// to the storage file, so they are not restored after a restart.
+func (m *URLInMemoryRepo) PurgeDeletedURLs(_ context.Context, before time.Time) (int64, error) {
+	var deleted []string
+	m.shortToOrigURL.Range(func(shortURL string, url memURL) bool {
+		if url.deletedBefore(before) {
+			deleted = append(deleted, shortURL)
+		}
+		return true
+	})
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	tombstones := make([]URLInFileRepo, 0, len(deleted))
+	for _, shortURL := range deleted {
+		// the short URL may have been restored since it was found
+		url, ok := m.removeURL(shortURL, func(url memURL) bool { return url.deletedBefore(before) })
+		if ok {
+			tombstones = append(tombstones, URLInFileRepo{UserID: url.UserID, ShortURL: shortURL, PurgedFlag: true})
+		}
+	}
+	return int64(len(tombstones)), m.appendToBatch(tombstones...)
+}
+
+// UpdateURL changes the original URL of the short URL owned by the user from the context in memory
+// and saves the replaced original URL in its history. The new state of the short URL is written
+// to the storage file, so the change survives a restart.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user,
+// models.ErrURLDeleted or models.ErrURLExpired if it can't be followed anymore
+// and models.ErrOriginalURLTaken if another link on the domain leads to the original URL.
+func (m *URLInMemoryRepo) UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URL{}, fmt.Errorf("invalid user context")
+	}
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+	current, exists := m.shortToOrigURL.Load(shortURL)
+	if !exists || current.UserID != userID {
+		return models.URL{}, models.ErrURLNotFound
+	}
+	if current.DeletedFlag {
+		return models.URL{}, models.ErrURLDeleted
+	}
+	if current.expiredBefore(time.Now()) {
+		return models.URL{}, models.ErrURLExpired
+	}
+	if current.OriginalURL == originalURL {
+		return current.userURL(shortURL), nil
+	}
+	updated := current
+	updated.OriginalURL = originalURL
+	// the history is copied, so the slice of the current state isn't shared
+	updated.History = append(current.History[:len(current.History):len(current.History)],
+		models.URLChange{OriginalURL: current.OriginalURL, ChangedAt: time.Now().UTC()})
+
+	// the new original URL is indexed first, so no other link can take it while the short URL is changed
+	if short, taken := m.origToShortURL.LoadOrStore(updated.originalKey(), shortURL); taken && short != shortURL {
+		return models.URL{}, models.ErrOriginalURLTaken
+	}
+	var deleted bool
+	m.shortToOrigURL.Update(shortURL, func(url memURL, exists bool) (memURL, bool) {
+		// the short URL may have been deleted or purged since it was loaded
+		if !exists || url.DeletedFlag {
+			deleted = true
+			return url, exists
+		}
+		updated.DeletedFlag = url.DeletedFlag
+		return updated, true
+	})
+	oldKey := current.originalKey()
+	if deleted {
+		oldKey = updated.originalKey()
+	}
+	m.origToShortURL.Update(oldKey, func(short string, exists bool) (string, bool) {
+		return short, exists && short != shortURL
+	})
+	if deleted {
+		return models.URL{}, models.ErrURLDeleted
+	}
+	m.usersURLS.Update(userID, func(urls []models.URL, exists bool) ([]models.URL, bool) {
+		// the URLs are copied, so the pages being read by other goroutines aren't changed
+		changed := make([]models.URL, len(urls))
+		for i, url := range urls {
+			if url.ShortURL == shortURL {
+				url.OriginalURL = originalURL
+			}
+			changed[i] = url
+		}
+		return changed, exists
+	})
+	record := updated.fileRecord(shortURL)
+	record.UpdatedFlag = true
+	return updated.userURL(shortURL), m.appendToBatch(record)
+}
+
+// GetURLHistory returns the former original URLs of the short URL owned by the user from the context,
+// the most recently replaced first.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (m *URLInMemoryRepo) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return nil, fmt.Errorf("invalid user context")
+	}
+	url, exists := m.shortToOrigURL.Load(shortURL)
+	if !exists || url.UserID != userID {
+		return nil, models.ErrURLNotFound
+	}
+	return reverseHistory(url.History), nil
+}
+
+// StoreAPIKey saves the API key in memory and writes it to the storage file at once,
+// so a key returned to the client isn't lost by a crash.
+func (m *URLInMemoryRepo) StoreAPIKey(_ context.Context, key models.APIKey) error {
+	m.apiKeysMu.Lock()
+	m.apiKeys[key.ID] = key
+	m.apiKeysMu.Unlock()
+	if err := m.appendToBatch(apiKeyRecord(key, false)); err != nil {
+		return err
+	}
+	return m.SaveBatchToFile()
+}
+
+// GetAPIKey returns the API key by its ID from memory.
+// It returns models.ErrAPIKeyNotFound if the key doesn't exist.
+func (m *URLInMemoryRepo) GetAPIKey(_ context.Context, id string) (models.APIKey, error) {
+	m.apiKeysMu.RLock()
+	defer m.apiKeysMu.RUnlock()
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return models.APIKey{}, models.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// GetUserAPIKeys returns the API keys of the user from the context ordered by creation time.
+func (m *URLInMemoryRepo) GetUserAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return nil, fmt.Errorf("invalid user context")
+	}
+	m.apiKeysMu.RLock()
+	keys := []models.APIKey{}
+	for _, key := range m.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	m.apiKeysMu.RUnlock()
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+// DeleteAPIKey removes the API key of the user from the context from memory and writes the removal
+// to the storage file at once, so a revoked key doesn't come back after a crash.
+// It returns models.ErrAPIKeyNotFound if the key doesn't exist or belongs to another user.
+func (m *URLInMemoryRepo) DeleteAPIKey(ctx context.Context, id string) error {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return fmt.Errorf("invalid user context")
+	}
+	m.apiKeysMu.Lock()
+	key, exists := m.apiKeys[id]
+	if !exists || key.UserID != userID {
+		m.apiKeysMu.Unlock()
+		return models.ErrAPIKeyNotFound
+	}
+	delete(m.apiKeys, id)
+	m.apiKeysMu.Unlock()
+	if err := m.appendToBatch(apiKeyRecord(key, true)); err != nil {
+		return err
+	}
+	return m.SaveBatchToFile()
+}
+
+// ExportAPIKeys calls fn for every API key in memory with its user and hash until fn returns an error.
+// The keys are copied first, so fn may use the repository.
+func (m *URLInMemoryRepo) ExportAPIKeys(_ context.Context, fn func(key models.APIKey) error) error {
+	m.apiKeysMu.RLock()
+	keys := make([]models.APIKey, 0, len(m.apiKeys))
+	for _, key := range m.apiKeys {
+		keys = append(keys, key)
+	}
+	m.apiKeysMu.RUnlock()
+	sortAPIKeys(keys)
+	for _, key := range keys {
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportAPIKeys saves the API keys keeping their IDs, users and hashes.
+// Keys already stored and keys whose IDs are taken by other keys are skipped and counted in the report.
+// In a dry run the keys are only checked. The saved keys are written to the storage file by the flush policy.
+func (m *URLInMemoryRepo) ImportAPIKeys(_ context.Context, keys []models.APIKey, dryRun bool) (models.ImportItemsReport, error) {
+	var report models.ImportItemsReport
+	var batch []URLInFileRepo
+	m.apiKeysMu.Lock()
+	for _, key := range keys {
+		var stored *models.APIKey
+		if storedKey, exists := m.apiKeys[key.ID]; exists {
+			stored = &storedKey
+		}
+		exists, reason := checkAPIKeyImport(key, stored)
+		if !addCheckedItem(&report, key.ID, key.UserID, exists, reason) || dryRun {
+			continue
+		}
+		m.apiKeys[key.ID] = key
+		batch = append(batch, apiKeyRecord(key, false))
+	}
+	m.apiKeysMu.Unlock()
+	if len(batch) == 0 {
+		return report, nil
+	}
+	return report, m.appendToBatch(batch...)
+}
+
+// putAccount adds the account to memory unless its login or its user has an account already.
+// It reports whether the account has been added.
+func (m *URLInMemoryRepo) putAccount(account models.Account) bool {
+	m.accountsMu.Lock()
+	defer m.accountsMu.Unlock()
+	if _, exists := m.accounts[account.Login]; exists {
+		return false
+	}
+	if _, exists := m.userAccounts[account.UserID]; exists {
+		return false
+	}
+	m.accounts[account.Login] = account
+	m.userAccounts[account.UserID] = account.Login
+	return true
+}
+
+// StoreAccount saves the account in memory and writes it to the storage file at once,
+// so a registered account isn't lost by a crash.
+// It returns models.ErrLoginTaken if another account has the login.
+func (m *URLInMemoryRepo) StoreAccount(_ context.Context, account models.Account) error {
+	if !m.putAccount(account) {
+		m.accountsMu.RLock()
+		_, taken := m.accounts[account.Login]
+		m.accountsMu.RUnlock()
+		if taken {
+			return models.ErrLoginTaken
+		}
+		return fmt.Errorf("user %s already has an account", account.UserID)
+	}
+	if err := m.appendToBatch(accountRecord(account)); err != nil {
+		return err
+	}
+	return m.SaveBatchToFile()
+}
+
+// ExportAccounts calls fn for every account in memory with its password hash until fn returns an error.
+// The accounts are copied first, so fn may use the repository.
+func (m *URLInMemoryRepo) ExportAccounts(_ context.Context, fn func(account models.Account) error) error {
+	m.accountsMu.RLock()
+	accounts := make([]models.Account, 0, len(m.accounts))
+	for _, account := range m.accounts {
+		accounts = append(accounts, account)
+	}
+	m.accountsMu.RUnlock()
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Login < accounts[j].Login })
+	for _, account := range accounts {
+		if err := fn(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportAccounts saves the accounts keeping their logins, users and password hashes.
+// Accounts already stored and accounts whose logins are taken or whose users have other accounts
+// are skipped and counted in the report. In a dry run the accounts are only checked.
+// The saved accounts are written to the storage file by the flush policy.
+func (m *URLInMemoryRepo) ImportAccounts(_ context.Context, accounts []models.Account, dryRun bool) (models.ImportItemsReport, error) {
+	var report models.ImportItemsReport
+	var batch []URLInFileRepo
+	m.accountsMu.Lock()
+	for _, account := range accounts {
+		var byLogin *models.Account
+		if stored, exists := m.accounts[account.Login]; exists {
+			byLogin = &stored
+		}
+		exists, reason := checkAccountImport(account, byLogin, m.userAccounts[account.UserID])
+		if !addCheckedItem(&report, account.Login, account.UserID, exists, reason) || dryRun {
+			continue
+		}
+		m.accounts[account.Login] = account
+		m.userAccounts[account.UserID] = account.Login
+		batch = append(batch, accountRecord(account))
+	}
+	m.accountsMu.Unlock()
+	if len(batch) == 0 {
+		return report, nil
+	}
+	return report, m.appendToBatch(batch...)
+}
+
+// GetAccount returns the account with the login from memory.
+// It returns models.ErrAccountNotFound if the account doesn't exist.
+func (m *URLInMemoryRepo) GetAccount(_ context.Context, login string) (models.Account, error) {
+	m.accountsMu.RLock()
+	defer m.accountsMu.RUnlock()
+	account, ok := m.accounts[login]
+	if !ok {
+		return models.Account{}, models.ErrAccountNotFound
+	}
+	return account, nil
+}
+
+// GetUserAccount returns the account of the user from the context from memory.
+// It returns models.ErrAccountNotFound if the user hasn't registered.
+func (m *URLInMemoryRepo) GetUserAccount(ctx context.Context) (models.Account, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.Account{}, fmt.Errorf("invalid user context")
+	}
+	m.accountsMu.RLock()
+	defer m.accountsMu.RUnlock()
+	login, ok := m.userAccounts[userID]
+	if !ok {
+		return models.Account{}, models.ErrAccountNotFound
+	}
+	return m.accounts[login], nil
+}
+
+// ClaimURLs moves the short URLs of the user fromUserID to the user from the context in memory
+// and returns how many were moved. The new state of every moved short URL is written to the storage file,
+// followed by a tombstone if it is deleted, so the move survives a restart.
+func (m *URLInMemoryRepo) ClaimURLs(ctx context.Context, fromUserID uuid.UUID) (int64, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return 0, fmt.Errorf("invalid user context")
+	}
+	if userID == fromUserID {
+		return 0, nil
+	}
+	// changes of original URLs are serialized with the move, so no update is written with the former owner
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+	var urls []models.URL
+	m.usersURLS.Update(fromUserID, func(current []models.URL, _ bool) ([]models.URL, bool) {
+		urls = current
+		return nil, false
+	})
+	records := make([]URLInFileRepo, 0, len(urls))
+	var claimed int64
+	for _, userURL := range urls {
+		var url memURL
+		var moved bool
+		m.shortToOrigURL.Update(userURL.ShortURL, func(current memURL, exists bool) (memURL, bool) {
+			if exists && current.UserID == fromUserID {
+				current.UserID = userID
+				url, moved = current, true
+			}
+			return current, exists
+		})
+		if !moved {
+			continue
+		}
+		m.addUserURL(userID, url.userURL(userURL.ShortURL))
+		record := url.fileRecord(userURL.ShortURL)
+		record.UpdatedFlag = true
+		records = append(records, record)
+		if url.DeletedFlag {
+			records = append(records, url.tombstone(userURL.ShortURL))
+		}
+		claimed++
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return claimed, m.appendToBatch(records...)
+}
+
+// StoreClicks saves click events in the in-memory ring.
+func (m *URLInMemoryRepo) StoreClicks(_ context.Context, clicks []models.Click) error {
+	m.clicks.add(clicks)
+	return nil
+}
+
+// GetURLStats returns the total and daily clicks of the short URL owned by the user from the context.
+// Only the clicks kept in the in-memory ring are counted.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (m *URLInMemoryRepo) GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.URLStats{}, fmt.Errorf("invalid user context")
+	}
+	url, exists := m.shortToOrigURL.Load(shortURL)
+	if !exists || url.UserID != userID {
+		return models.URLStats{}, models.ErrURLNotFound
+	}
+	stats := m.clicks.stats(shortURL)
+	stats.Domain = url.Domain
+	return stats, nil
+}
+
+// Compact rewrites the storage file with one record per stored short URL, followed by a tombstone
+// if the URL is deleted, dropping outdated, restored, purged and corrupted records.
+// Deleted URLs are kept until PurgeDeletedURLs removes them, so they can be restored in the meantime.
+// The new file replaces the old one atomically, so a crash leaves one of them complete.
+// Records buffered during compaction are appended to the new file by the next SaveBatchToFile.
+func (m *URLInMemoryRepo) Compact() error {
+	m.fileMu.Lock()
+	defer m.fileMu.Unlock()
+	startTime := time.Now()
+
+	// the buffered records are already in memory, so they are saved as a part of the snapshot
+	batch := m.detachBatch()
+	var deleted int
+	records := make([]URLInFileRepo, 0, m.shortToOrigURL.Len())
+	m.shortToOrigURL.Range(func(shortURL string, url memURL) bool {
+		// a short URL reserved by a concurrent store isn't saved until its original URL is indexed
+		if committed, ok := m.origToShortURL.Load(url.originalKey()); !ok || committed != shortURL {
+			return true
+		}
+		records = append(records, url.fileRecord(shortURL))
+		if url.DeletedFlag {
+			records = append(records, url.tombstone(shortURL))
+			deleted++
+		}
+		return true
+	})
+	urls := len(records) - deleted
+	m.apiKeysMu.RLock()
+	for _, key := range m.apiKeys {
+		records = append(records, apiKeyRecord(key, false))
+	}
+	m.apiKeysMu.RUnlock()
+	keys := len(records) - urls - deleted
+	m.accountsMu.RLock()
+	for _, account := range m.accounts {
+		records = append(records, accountRecord(account))
+	}
+	m.accountsMu.RUnlock()
+	if err := replaceFile(m.storageFilePath, records); err != nil {
+		logrus.Error(err)
+		m.returnToBatch(batch)
+		return err
+	}
+	logrus.Infof("Storage file compacted to %d URLs in %v, %d of them deleted, %d API keys and %d accounts",
+		urls, time.Since(startTime), deleted, keys, len(records)-urls-deleted-keys)
+	return nil
+}
+
+// RunCompaction compacts the storage file every interval until ctx is done.
+// A non-positive interval disables compaction.
+func (m *URLInMemoryRepo) RunCompaction(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Compact(); err != nil {
+				logrus.WithError(err).Error("Error compacting storage file")
+			}
+		}
+	}
+}
+
+// RunFlusher saves the buffered records to the storage file every flush policy interval until ctx is done,
+// then saves the rest of them. It does nothing if the interval isn't positive.
+func (m *URLInMemoryRepo) RunFlusher(ctx context.Context) {
+	if m.flushPolicy.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.flushPolicy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.SaveBatchToFile(); err != nil {
+				logrus.WithError(err).Error("Error save memory in file")
+			}
+			return
+		case <-ticker.C:
+			if err := m.SaveBatchToFile(); err != nil {
+				logrus.WithError(err).Error("Error save memory in file")
+			}
+		}
+	}
+}
+
+// ExportURLs calls fn for every stored short URL with its owner, deleted flag and change history until fn returns an error.
+// The map shard being iterated is locked, so fn mustn't use the repository.
+func (m *URLInMemoryRepo) ExportURLs(_ context.Context, fn func(record models.URLRecord) error) error {
+	var err error
+	m.shortToOrigURL.Range(func(shortURL string, url memURL) bool {
+		err = fn(models.URLRecord{
+			ShortURL:     shortURL,
+			OriginalURL:  url.OriginalURL,
+			UserID:       url.UserID,
+			ExpiresAt:    timePtr(url.ExpiresAt),
+			DeletedFlag:  url.DeletedFlag,
+			CreatedAt:    timePtr(url.CreatedAt),
+			Domain:       url.Domain,
+			DeletedAt:    timePtr(url.DeletedAt),
+			RedirectCode: url.RedirectCode,
+			History:      url.History,
+		})
+		return err == nil
+	})
+	return err
+}
+
+// ImportURLs saves the records keeping their short URLs, owners, deleted flags and change histories.
+// Records of already stored links and records contradicting stored links are skipped and counted in the report.
+// In a dry run the records are only checked. The saved records are written to the storage file by the flush policy.
+func (m *URLInMemoryRepo) ImportURLs(_ context.Context, records []models.URLRecord, dryRun bool) (models.ImportReport, error) {
+	var report models.ImportReport
+	var batch []URLInFileRepo
+	for _, record := range records {
+		var byShort *storedLink
+		if url, exists := m.shortToOrigURL.Load(record.ShortURL); exists {
+			byShort = &storedLink{OriginalURL: url.OriginalURL, UserID: url.UserID, Domain: url.Domain}
+		}
+		shortOfOriginal, _ := m.origToShortURL.Load(originalKey(record.Domain, record.OriginalURL))
+		exists, reason := checkImport(record, byShort, shortOfOriginal)
+		if !addChecked(&report, record, exists, reason) || dryRun {
+			continue
+		}
+		options := recordOptions(record)
+		url := memURL{OriginalURL: record.OriginalURL, UserID: record.UserID, ExpiresAt: options.ExpiresAt,
+			CreatedAt: recordCreatedAt(record, time.Now()), Domain: options.Domain, RedirectCode: options.RedirectCode,
+			History: record.History}
+		reserved, err := m.reserveShortURL(record.ShortURL, url)
+		if err == nil && reserved {
+			_, reserved = m.commitURL(record.ShortURL, url)
+		}
+		if err != nil || !reserved {
+			// the link has been stored by a concurrent request since the check
+			report.Imported--
+			report.Conflicts = append(report.Conflicts, models.ImportConflict{URLRecord: record, Reason: models.ConflictShortURLTaken})
+			continue
+		}
+		batch = append(batch, url.fileRecord(record.ShortURL))
+		if record.DeletedFlag {
+			url.DeletedFlag = true
+			if record.DeletedAt != nil {
+				url.DeletedAt = *record.DeletedAt
+			}
+			m.markDeleted(record.UserID, record.ShortURL, url.DeletedAt)
+			batch = append(batch, url.tombstone(record.ShortURL))
+		}
+	}
+	if len(batch) == 0 {
+		return report, nil
+	}
+	return report, m.appendToBatch(batch...)
+}
@@ -1,13 +1,19 @@
 package url
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
 var UserID, _ = uuid.Parse("e774844b-5895-4b08-b867-50480263f75b")
@@ -15,33 +21,36 @@ var UserID, _ = uuid.Parse("e774844b-5895-4b08-b867-50480263f75b")
 func TestNewRepository(t *testing.T) {
 
 	tests := []struct {
-		name string
-		want *URLInMemoryRepo
+		name           string
+		storagePath    string
+		shortToOrigURL map[string]memURL
+		origToShortURL map[string]string
+		usersURLS      map[uuid.UUID][]models.URL
 	}{
 		{
-			name: "Valid args",
-			want: &URLInMemoryRepo{
-				shortToOrigURL: map[string]string{"short1": "original1"},
-				origToShortURL: map[string]string{"original1": "short1"},
-				usersURLS: map[uuid.UUID][]models.URL{
-					UserID: {{ShortURL: "short1", OriginalURL: "original1"}},
-				},
-				batchBuffer:     make([]URLInFileRepo, 0),
-				batchCounter:    0,
-				batchSize:       100,
-				storageFilePath: createTempFilePath(t),
+			name:           "Valid args",
+			storagePath:    createTempFilePath(t),
+			shortToOrigURL: map[string]memURL{"short1": {OriginalURL: "original1", UserID: UserID}},
+			origToShortURL: map[string]string{"original1": "short1"},
+			usersURLS: map[uuid.UUID][]models.URL{
+				UserID: {{ShortURL: "short1", OriginalURL: "original1"}},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equalf(t, tt.want, NewURLInMemoryRepo(tt.want.storageFilePath), "NewURLInMemoryRepo()")
-			defer os.Remove(tt.want.storageFilePath)
+			defer os.Remove(tt.storagePath)
+			got := NewURLInMemoryRepo(tt.storagePath, DefaultFlushPolicy)
+			assert.Equal(t, tt.shortToOrigURL, mapOf(got.shortToOrigURL))
+			assert.Equal(t, tt.origToShortURL, mapOf(got.origToShortURL))
+			assert.Equal(t, tt.usersURLS, mapOf(got.usersURLS))
+			assert.Equal(t, DefaultFlushPolicy, got.flushPolicy)
+			assert.Empty(t, got.batchBuffer)
 		})
 	}
 }
 
-func TestRepositoryURL_GetOriginalURL(t *testing.T) {
+func TestRepositoryURL_GetRedirect(t *testing.T) {
 	type fields struct {
 		shortToOrigURL map[string]string
 		origToShortURL map[string]string
@@ -73,14 +82,14 @@ func TestRepositoryURL_GetOriginalURL(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			d := &URLInMemoryRepo{
-				shortToOrigURL: tt.fields.shortToOrigURL,
-				origToShortURL: tt.fields.origToShortURL,
+				shortToOrigURL: urlMapOf(tt.fields.shortToOrigURL),
+				origToShortURL: stringMapOf(tt.fields.origToShortURL),
 			}
-			got, err := d.GetOriginalURL(tt.args.ctx, tt.args.shortURL)
-			if !tt.wantErr(t, err, fmt.Sprintf("GetOriginalURL(%v)", tt.args.shortURL)) {
+			got, err := d.GetRedirect(tt.args.ctx, tt.args.shortURL)
+			if !tt.wantErr(t, err, fmt.Sprintf("GetRedirect(%v)", tt.args.shortURL)) {
 				return
 			}
-			assert.Equalf(t, tt.want, got, "GetOriginalURL(%v)", tt.args.shortURL)
+			assert.Equalf(t, tt.want, got.OriginalURL, "GetRedirect(%v)", tt.args.shortURL)
 		})
 	}
 }
@@ -117,10 +126,10 @@ func TestRepositoryURL_GetShortURL(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			d := &URLInMemoryRepo{
-				shortToOrigURL: tt.fields.shortToOrigURL,
-				origToShortURL: tt.fields.origToShortURL,
+				shortToOrigURL: urlMapOf(tt.fields.shortToOrigURL),
+				origToShortURL: stringMapOf(tt.fields.origToShortURL),
 			}
-			got, err := d.GetShortURL(tt.args.ctx, tt.args.originalURL)
+			got, err := d.GetShortURL(tt.args.ctx, "", tt.args.originalURL)
 			if !tt.wantErr(t, err, fmt.Sprintf("GetShortURL(%v)", tt.args.originalURL)) {
 				return
 			}
@@ -135,8 +144,7 @@ func TestURLInMemoryRepo_ReadFileToMemoryURL(t *testing.T) {
 		origToShortURL  map[string]string
 		usersURLS       map[uuid.UUID][]models.URL
 		batchBuffer     []URLInFileRepo
-		batchCounter    uint8
-		batchSize       uint8
+		flushPolicy     FlushPolicy
 		storageFilePath string
 	}
 	tests := []struct {
@@ -153,8 +161,7 @@ func TestURLInMemoryRepo_ReadFileToMemoryURL(t *testing.T) {
 					UserID: {{ShortURL: "short1", OriginalURL: "original1"}},
 				},
 				batchBuffer:     []URLInFileRepo{},
-				batchCounter:    0,
-				batchSize:       100,
+				flushPolicy:     DefaultFlushPolicy,
 				storageFilePath: createTempFilePath(t),
 			},
 			wantErr: assert.NoError,
@@ -163,12 +170,11 @@ func TestURLInMemoryRepo_ReadFileToMemoryURL(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &URLInMemoryRepo{
-				shortToOrigURL:  tt.fields.shortToOrigURL,
-				origToShortURL:  tt.fields.origToShortURL,
-				usersURLS:       tt.fields.usersURLS,
+				shortToOrigURL:  urlMapOf(tt.fields.shortToOrigURL),
+				origToShortURL:  stringMapOf(tt.fields.origToShortURL),
+				usersURLS:       uuidMapOf(tt.fields.usersURLS),
 				batchBuffer:     tt.fields.batchBuffer,
-				batchCounter:    tt.fields.batchCounter,
-				batchSize:       tt.fields.batchSize,
+				flushPolicy:     tt.fields.flushPolicy,
 				storageFilePath: tt.fields.storageFilePath,
 			}
 			tt.wantErr(t, m.readFileToMemoryURL(), "ReadFileToMemoryURL()")
@@ -183,8 +189,7 @@ func TestURLInMemoryRepo_SaveBatchToFile(t *testing.T) {
 		origToShortURL  map[string]string
 		usersURLS       map[uuid.UUID][]models.URL
 		batchBuffer     []URLInFileRepo
-		batchCounter    uint8
-		batchSize       uint8
+		flushPolicy     FlushPolicy
 		storageFilePath string
 	}
 	tests := []struct {
@@ -207,8 +212,7 @@ func TestURLInMemoryRepo_SaveBatchToFile(t *testing.T) {
 						OriginalURL: "original1",
 					},
 				},
-				batchCounter:    1,
-				batchSize:       100,
+				flushPolicy:     DefaultFlushPolicy,
 				storageFilePath: createTempFilePath(t),
 			},
 			wantErr: assert.NoError,
@@ -217,12 +221,11 @@ func TestURLInMemoryRepo_SaveBatchToFile(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &URLInMemoryRepo{
-				shortToOrigURL:  tt.fields.shortToOrigURL,
-				origToShortURL:  tt.fields.origToShortURL,
-				usersURLS:       tt.fields.usersURLS,
+				shortToOrigURL:  urlMapOf(tt.fields.shortToOrigURL),
+				origToShortURL:  stringMapOf(tt.fields.origToShortURL),
+				usersURLS:       uuidMapOf(tt.fields.usersURLS),
 				batchBuffer:     tt.fields.batchBuffer,
-				batchCounter:    tt.fields.batchCounter,
-				batchSize:       tt.fields.batchSize,
+				flushPolicy:     tt.fields.flushPolicy,
 				storageFilePath: tt.fields.storageFilePath,
 			}
 			tt.wantErr(t, m.SaveBatchToFile(), "SaveBatchToFile()")
@@ -237,8 +240,7 @@ func TestURLInMemoryRepo_StoreURLS(t *testing.T) {
 		origToShortURL  map[string]string
 		usersURLS       map[uuid.UUID][]models.URL
 		batchBuffer     []URLInFileRepo
-		batchCounter    uint8
-		batchSize       uint8
+		flushPolicy     FlushPolicy
 		storageFilePath string
 	}
 	type args struct {
@@ -267,8 +269,7 @@ func TestURLInMemoryRepo_StoreURLS(t *testing.T) {
 						OriginalURL: "original2",
 					},
 				},
-				batchCounter:    1,
-				batchSize:       100,
+				flushPolicy:     DefaultFlushPolicy,
 				storageFilePath: createTempFilePath(t),
 			},
 			args:    args{ctx: context.Background(), originalURL: "original2", shortURL: "short2"},
@@ -278,20 +279,56 @@ func TestURLInMemoryRepo_StoreURLS(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &URLInMemoryRepo{
-				shortToOrigURL:  tt.fields.shortToOrigURL,
-				origToShortURL:  tt.fields.origToShortURL,
-				usersURLS:       tt.fields.usersURLS,
+				shortToOrigURL:  urlMapOf(tt.fields.shortToOrigURL),
+				origToShortURL:  stringMapOf(tt.fields.origToShortURL),
+				usersURLS:       uuidMapOf(tt.fields.usersURLS),
 				batchBuffer:     tt.fields.batchBuffer,
-				batchCounter:    tt.fields.batchCounter,
-				batchSize:       tt.fields.batchSize,
+				flushPolicy:     tt.fields.flushPolicy,
 				storageFilePath: tt.fields.storageFilePath,
 			}
-			tt.wantErr(t, m.StoreURL(tt.args.ctx, tt.args.originalURL, tt.args.shortURL), fmt.Sprintf("StoreURL(%v, %v)", tt.args.originalURL, tt.args.shortURL))
+			tt.wantErr(t, m.StoreURL(tt.args.ctx, tt.args.originalURL, tt.args.shortURL, models.URLOptions{}), fmt.Sprintf("StoreURL(%v, %v)", tt.args.originalURL, tt.args.shortURL))
 			defer os.Remove(tt.fields.storageFilePath)
 		})
 	}
 }
 
+// urlMapOf builds a shardedMap of memURL from a plain map of short to original URLs.
+func urlMapOf(items map[string]string) *shardedMap[string, memURL] {
+	m := newStringMap[memURL]()
+	for k, v := range items {
+		m.Store(k, memURL{OriginalURL: v, UserID: UserID})
+	}
+	return m
+}
+
+// stringMapOf builds a shardedMap with the contents of the plain map.
+func stringMapOf(items map[string]string) *shardedMap[string, string] {
+	m := newStringMap[string]()
+	for k, v := range items {
+		m.Store(k, v)
+	}
+	return m
+}
+
+// uuidMapOf builds a shardedMap with the contents of the plain map.
+func uuidMapOf(items map[uuid.UUID][]models.URL) *shardedMap[uuid.UUID, []models.URL] {
+	m := newUUIDMap[[]models.URL]()
+	for k, v := range items {
+		m.Store(k, v)
+	}
+	return m
+}
+
+// mapOf returns the contents of the shardedMap as a plain map.
+func mapOf[K comparable, V any](m *shardedMap[K, V]) map[K]V {
+	items := make(map[K]V)
+	m.Range(func(k K, v V) bool {
+		items[k] = v
+		return true
+	})
+	return items
+}
+
 func createTempFilePath(t *testing.T) string {
 	tempFile, err := os.CreateTemp("", "testfile")
 	if err != nil {
@@ -337,9 +374,9 @@ func TestURLInMemoryRepo_StoreBatchURL(t *testing.T) {
 			}()
 
 			// Создаем репозиторий
-			repo := NewURLInMemoryRepo(tempFile.Name())
+			repo := NewURLInMemoryRepo(tempFile.Name(), DefaultFlushPolicy)
 			// Call the method under test
-			err = repo.StoreBatchURL(ctx, tt.batchURLtoStores)
+			err = repo.StoreBatchURL(ctx, tt.batchURLtoStores, nil)
 
 			// Check the result
 			if (err != nil && tt.expectedError == nil) || (err == nil && tt.expectedError != nil) || (err != nil && tt.expectedError != nil && err.Error() != tt.expectedError.Error()) {
@@ -387,18 +424,18 @@ func TestURLInMemoryRepo_GetShortBatchURL(t *testing.T) {
 			}()
 
 			// Создаем репозиторий
-			repo := NewURLInMemoryRepo(tempFile.Name())
+			repo := NewURLInMemoryRepo(tempFile.Name(), DefaultFlushPolicy)
 			defer repo.SaveBatchToFile() // Сохраняем оставшиеся данные перед завершением теста
 
 			// Добавляем тестовые URL в репозиторий
 			for i, req := range tt.batchURLRequests {
 				shortURL := fmt.Sprintf("short%d", i+1)
-				err := repo.StoreURL(context.Background(), req.OriginalURL, shortURL)
+				err := repo.StoreURL(context.Background(), req.OriginalURL, shortURL, models.URLOptions{})
 				assert.NoError(t, err)
 			}
 
 			// Вызываем метод, который мы тестируем
-			shortURLs, err := repo.GetShortBatchURL(context.Background(), tt.batchURLRequests)
+			shortURLs, err := repo.GetShortBatchURL(context.Background(), "", tt.batchURLRequests)
 
 			// Проверяем ошибку
 			assert.Equal(t, tt.expectedErr, err != nil)
@@ -442,18 +479,18 @@ func TestURLInMemoryRepo_GetUserURLS(t *testing.T) {
 			}()
 
 			// Создаем репозиторий
-			repo := NewURLInMemoryRepo(tempFile.Name())
+			repo := NewURLInMemoryRepo(tempFile.Name(), DefaultFlushPolicy)
 			defer repo.SaveBatchToFile() // Сохраняем оставшиеся данные перед завершением теста
 
 			// Создаем контекст с указанным userID
 			ctx := context.WithValue(context.Background(), models.UserIDKey, tt.userID)
 
 			// Добавляем тестовый URL для пользователя
-			err = repo.StoreURL(ctx, "http://example.com", "http://short.com")
+			err = repo.StoreURL(ctx, "http://example.com", "http://short.com", models.URLOptions{})
 			assert.NoError(t, err)
 
 			// Вызываем метод, который мы тестируем
-			userURLs, err := repo.GetUserURLs(ctx)
+			userURLs, err := repo.GetUserURLs(ctx, models.UserURLsQuery{})
 
 			// Проверяем ошибку
 			assert.Equal(t, tt.expectedErr, err != nil)
@@ -467,3 +504,515 @@ func TestURLInMemoryRepo_GetUserURLS(t *testing.T) {
 		})
 	}
 }
+
+func TestURLInMemoryRepo_ConcurrentAccess(t *testing.T) {
+	const (
+		workers   = 16
+		perWorker = 50
+	)
+	storagePath := filepath.Join(t.TempDir(), "storage.json")
+	repo := NewURLInMemoryRepo(storagePath, DefaultFlushPolicy)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := uuid.New()
+			ctx := context.WithValue(context.Background(), models.UserIDKey, userID)
+			for i := 0; i < perWorker; i++ {
+				if i%2 == 0 {
+					originalURL := fmt.Sprintf("http://example.com/%d/%d", w, i)
+					assert.NoError(t, repo.StoreURL(ctx, originalURL, fmt.Sprintf("s%d_%d", w, i), models.URLOptions{}))
+				} else {
+					batch := map[string]string{
+						fmt.Sprintf("b%d_%d", w, i): fmt.Sprintf("http://example.com/batch/%d/%d", w, i),
+					}
+					assert.NoError(t, repo.StoreBatchURL(ctx, batch, nil))
+				}
+				_, err := repo.GetUserURLs(ctx, models.UserURLsQuery{})
+				assert.NoError(t, err)
+				_, err = repo.GetStats(ctx)
+				assert.NoError(t, err)
+				if i%10 == 0 {
+					assert.NoError(t, repo.SaveBatchToFile())
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	require.NoError(t, repo.SaveBatchToFile())
+
+	stats, err := repo.GetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint32(workers*perWorker), stats.CountURLs)
+	assert.Equal(t, uint32(workers), stats.CountUsers)
+
+	// every record must reach the file exactly once
+	restored := NewURLInMemoryRepo(storagePath, DefaultFlushPolicy)
+	assert.Equal(t, repo.shortToOrigURL.Len(), restored.shortToOrigURL.Len())
+	var lines int
+	file, err := os.Open(storagePath)
+	require.NoError(t, err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	assert.Equal(t, workers*perWorker, lines)
+}
+
+func TestURLInMemoryRepo_ConcurrentReadWriteSameUser(t *testing.T) {
+	repo := NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), DefaultFlushPolicy)
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				shortURL := fmt.Sprintf("%d_%d", w, i)
+				assert.NoError(t, repo.StoreURL(ctx, "http://example.com/"+shortURL, shortURL, models.URLOptions{}))
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if urls, err := repo.GetUserURLs(ctx, models.UserURLsQuery{}); err == nil {
+					for _, u := range urls {
+						assert.NotEmpty(t, u.ShortURL)
+					}
+				}
+				_, _ = repo.GetRedirect(ctx, "0_0")
+				_, _ = repo.GetShortBatchURL(ctx, "", []models.URLRequest{{OriginalURL: "http://example.com/0_0"}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	urls, err := repo.GetUserURLs(ctx, models.UserURLsQuery{})
+	require.NoError(t, err)
+	assert.Len(t, urls, 800)
+}
+
+func TestURLInMemoryRepo_MarkURLsAsDeleted(t *testing.T) {
+	otherUserID := uuid.New()
+	tests := []struct {
+		name        string
+		deletions   []models.URLDeletion
+		wantDeleted map[string]bool
+	}{
+		{
+			name:        "owner deletes own URLs",
+			deletions:   []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"short1", "short2"}}},
+			wantDeleted: map[string]bool{"short1": true, "short2": true, "short3": false},
+		},
+		{
+			name:        "foreign URLs are not deleted",
+			deletions:   []models.URLDeletion{{UserID: otherUserID, ShortURLs: []string{"short1", "short3", "unknown"}}},
+			wantDeleted: map[string]bool{"short1": false, "short2": false, "short3": true},
+		},
+		{
+			name: "deletions of many users",
+			deletions: []models.URLDeletion{
+				{UserID: UserID, ShortURLs: []string{"short2", "short3"}},
+				{UserID: otherUserID, ShortURLs: []string{"short3"}},
+			},
+			wantDeleted: map[string]bool{"short1": false, "short2": true, "short3": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storagePath := filepath.Join(t.TempDir(), "storage.json")
+			repo := NewURLInMemoryRepo(storagePath, DefaultFlushPolicy)
+			ownerCtx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+			otherCtx := context.WithValue(context.Background(), models.UserIDKey, otherUserID)
+			require.NoError(t, repo.StoreURL(ownerCtx, "http://example1.com", "short1", models.URLOptions{}))
+			require.NoError(t, repo.StoreURL(ownerCtx, "http://example2.com", "short2", models.URLOptions{}))
+			require.NoError(t, repo.StoreURL(otherCtx, "http://example3.com", "short3", models.URLOptions{}))
+
+			require.NoError(t, repo.MarkURLsAsDeleted(context.Background(), tt.deletions))
+			require.NoError(t, repo.SaveBatchToFile())
+
+			// the flags must be the same before and after replaying the storage file
+			restored := NewURLInMemoryRepo(storagePath, DefaultFlushPolicy)
+			for _, r := range []*URLInMemoryRepo{repo, restored} {
+				for shortURL, deleted := range tt.wantDeleted {
+					_, err := r.GetRedirect(context.Background(), shortURL)
+					if deleted {
+						assert.ErrorIs(t, err, models.ErrURLDeleted, shortURL)
+					} else {
+						assert.NoError(t, err, shortURL)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestURLInMemoryRepo_ShortURLConflict(t *testing.T) {
+	repo := NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), DefaultFlushPolicy)
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	require.NoError(t, repo.StoreURL(ctx, "http://example1.com", "short1", models.URLOptions{}))
+
+	t.Run("same mapping is stored again", func(t *testing.T) {
+		assert.NoError(t, repo.StoreURL(ctx, "http://example1.com", "short1", models.URLOptions{}))
+	})
+
+	t.Run("taken short URL", func(t *testing.T) {
+		err := repo.StoreURL(ctx, "http://example2.com", "short1", models.URLOptions{})
+		var conflict *models.ShortURLConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "short1", conflict.ShortURL)
+		assert.ErrorIs(t, err, models.ErrShortURLConflict)
+
+		redirect, err := repo.GetRedirect(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, "http://example1.com", redirect.OriginalURL)
+		_, err = repo.GetShortURL(ctx, "", "http://example2.com")
+		assert.Error(t, err)
+	})
+
+	t.Run("batch with taken short URL is not stored", func(t *testing.T) {
+		err := repo.StoreBatchURL(ctx, map[string]string{
+			"short2": "http://example2.com",
+			"short3": "http://example3.com",
+			"short1": "http://example4.com",
+		}, nil)
+		assert.ErrorIs(t, err, models.ErrShortURLConflict)
+		for _, shortURL := range []string{"short2", "short3"} {
+			_, err = repo.GetRedirect(ctx, shortURL)
+			assert.Error(t, err, shortURL)
+		}
+		urls, err := repo.GetUserURLs(ctx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+	})
+}
+
+func TestURLInMemoryRepo_DeleteExpiredURLs(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		before      time.Time
+		wantDeleted int64
+		wantErrs    map[string]error
+	}{
+		{
+			name:        "expired URLs are removed",
+			before:      now,
+			wantDeleted: 1,
+			wantErrs:    map[string]error{"expired": errors.New("original URL not found"), "alive": nil, "forever": nil},
+		},
+		{
+			name:        "recently expired URLs are kept",
+			before:      now.Add(-2 * time.Hour),
+			wantDeleted: 0,
+			wantErrs:    map[string]error{"expired": models.ErrURLExpired, "alive": nil, "forever": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storagePath := filepath.Join(t.TempDir(), "storage.json")
+			repo := NewURLInMemoryRepo(storagePath, DefaultFlushPolicy)
+			ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+			require.NoError(t, repo.StoreURL(ctx, "http://expired.com", "expired", models.URLOptions{ExpiresAt: now.Add(-time.Hour)}))
+			require.NoError(t, repo.StoreURL(ctx, "http://alive.com", "alive", models.URLOptions{ExpiresAt: now.Add(time.Hour)}))
+			require.NoError(t, repo.StoreBatchURL(ctx, map[string]string{"forever": "http://forever.com"}, nil))
+
+			deleted, err := repo.DeleteExpiredURLs(ctx, tt.before)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDeleted, deleted)
+			require.NoError(t, repo.SaveBatchToFile())
+
+			// the result must be the same before and after replaying the storage file
+			restored := NewURLInMemoryRepo(storagePath, DefaultFlushPolicy)
+			for _, r := range []*URLInMemoryRepo{repo, restored} {
+				for shortURL, wantErr := range tt.wantErrs {
+					_, err = r.GetRedirect(ctx, shortURL)
+					switch {
+					case wantErr == nil:
+						assert.NoError(t, err, shortURL)
+					case errors.Is(wantErr, models.ErrURLExpired):
+						assert.ErrorIs(t, err, models.ErrURLExpired, shortURL)
+					default:
+						assert.EqualError(t, err, wantErr.Error(), shortURL)
+					}
+				}
+				urls, err := r.GetUserURLs(ctx, models.UserURLsQuery{})
+				require.NoError(t, err)
+				assert.Len(t, urls, 3-int(tt.wantDeleted))
+				for _, url := range urls {
+					if url.ShortURL == "alive" {
+						require.NotNil(t, url.ExpiresAt)
+						assert.True(t, url.ExpiresAt.Equal(now.Add(time.Hour)))
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("removed original URL can be shortened again", func(t *testing.T) {
+		repo := NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), DefaultFlushPolicy)
+		ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+		require.NoError(t, repo.StoreURL(ctx, "http://expired.com", "expired", models.URLOptions{ExpiresAt: now.Add(-time.Hour)}))
+		_, err := repo.DeleteExpiredURLs(ctx, now)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.StoreURL(ctx, "http://expired.com", "renewed", models.URLOptions{}))
+		shortURL, err := repo.GetShortURL(ctx, "", "http://expired.com")
+		require.NoError(t, err)
+		assert.Equal(t, "renewed", shortURL)
+	})
+}
+
+func TestURLInMemoryRepo_GetURLStats(t *testing.T) {
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), DefaultFlushPolicy)
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	otherCtx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+	require.NoError(t, repo.StoreURL(ctx, "http://mine.com", "mine", models.URLOptions{}))
+	require.NoError(t, repo.StoreURL(otherCtx, "http://other.com", "other", models.URLOptions{}))
+	require.NoError(t, repo.StoreClicks(ctx, []models.Click{
+		{ShortURL: "mine", Timestamp: day},
+		{ShortURL: "mine", Timestamp: day.Add(24 * time.Hour)},
+		{ShortURL: "other", Timestamp: day},
+		{ShortURL: "mine", Timestamp: day.Add(25 * time.Hour)},
+	}))
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		shortURL  string
+		wantStats models.URLStats
+		wantErr   error
+	}{
+		{
+			name:     "own URL",
+			ctx:      ctx,
+			shortURL: "mine",
+			wantStats: models.URLStats{TotalClicks: 3, Daily: []models.DailyClicks{
+				{Date: "2024-01-01", Clicks: 1},
+				{Date: "2024-01-02", Clicks: 2},
+			}},
+		},
+		{
+			name:     "URL of another user",
+			ctx:      ctx,
+			shortURL: "other",
+			wantErr:  models.ErrURLNotFound,
+		},
+		{
+			name:     "unknown URL",
+			ctx:      ctx,
+			shortURL: "unknown",
+			wantErr:  models.ErrURLNotFound,
+		},
+		{
+			name:     "no user in context",
+			ctx:      context.Background(),
+			shortURL: "mine",
+			wantErr:  errors.New("invalid user context"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := repo.GetURLStats(tt.ctx, tt.shortURL)
+			switch {
+			case tt.wantErr == nil:
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantStats, stats)
+			case errors.Is(tt.wantErr, models.ErrURLNotFound):
+				assert.ErrorIs(t, err, models.ErrURLNotFound)
+			default:
+				assert.EqualError(t, err, tt.wantErr.Error())
+			}
+		})
+	}
+}
+
+func TestClickRing_Overwrite(t *testing.T) {
+	ring := newClickRing(3)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		ring.add([]models.Click{{ShortURL: "short", Timestamp: day.Add(time.Duration(i) * 24 * time.Hour)}})
+	}
+	stats := ring.stats("short")
+	assert.Equal(t, int64(3), stats.TotalClicks)
+	assert.Equal(t, []models.DailyClicks{
+		{Date: "2024-01-03", Clicks: 1},
+		{Date: "2024-01-04", Clicks: 1},
+		{Date: "2024-01-05", Clicks: 1},
+	}, stats.Daily)
+}
+
+// restoredShortURLs opens a new repository on the storage file, as after a crash of the process,
+// and returns the short URLs found there among the given ones.
+func restoredShortURLs(t *testing.T, path string, shortURLs ...string) []string {
+	repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	restored := make([]string, 0, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		if _, err := repo.GetRedirect(context.Background(), shortURL); err == nil {
+			restored = append(restored, shortURL)
+		}
+	}
+	return restored
+}
+
+func TestURLInMemoryRepo_FlushPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		flushPolicy FlushPolicy
+		stores      int
+		wantShort   []string
+	}{
+		{
+			name:        "every write is flushed",
+			flushPolicy: FlushPolicy{Records: 1},
+			stores:      3,
+			wantShort:   []string{"short1", "short2", "short3"},
+		},
+		{
+			name:        "records are flushed when the batch is full",
+			flushPolicy: FlushPolicy{Records: 2},
+			stores:      3,
+			wantShort:   []string{"short1", "short2"},
+		},
+		{
+			name:        "records are buffered until the batch is full",
+			flushPolicy: FlushPolicy{Records: 100},
+			stores:      3,
+			wantShort:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.json")
+			ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+			repo := NewURLInMemoryRepo(path, tt.flushPolicy)
+			stored := make([]string, 0, tt.stores)
+			for i := 1; i <= tt.stores; i++ {
+				shortURL := fmt.Sprintf("short%d", i)
+				require.NoError(t, repo.StoreURL(ctx, fmt.Sprintf("http://example.com/%d", i), shortURL, models.URLOptions{}))
+				stored = append(stored, shortURL)
+			}
+			// the repository isn't saved before the restart
+			assert.ElementsMatch(t, tt.wantShort, restoredShortURLs(t, path, stored...))
+		})
+	}
+}
+
+func TestURLInMemoryRepo_RunFlusher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	repo := NewURLInMemoryRepo(path, FlushPolicy{Records: 100, Interval: 10 * time.Millisecond})
+	flushCtx, stopFlusher := context.WithCancel(context.Background())
+	flushDone := make(chan struct{})
+	go func() {
+		repo.RunFlusher(flushCtx)
+		close(flushDone)
+	}()
+
+	// the buffered record is written by the interval
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com/1", "short1", models.URLOptions{}))
+	assert.Eventually(t, func() bool {
+		return len(restoredShortURLs(t, path, "short1")) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// the rest of the records is written when the flusher stops
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com/2", "short2", models.URLOptions{}))
+	stopFlusher()
+	<-flushDone
+	assert.ElementsMatch(t, []string{"short1", "short2"}, restoredShortURLs(t, path, "short1", "short2"))
+}
+
+func TestURLInMemoryRepo_UserURLsOrderRestored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	for _, shortURL := range []string{"b", "a", "c"} {
+		require.NoError(t, repo.StoreURL(ctx, "http://example.com/"+shortURL, shortURL, models.URLOptions{}))
+	}
+	urls, err := repo.GetUserURLs(ctx, models.UserURLsQuery{})
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveBatchToFile())
+
+	// the creation times are restored from the storage file
+	restored, err := NewURLInMemoryRepo(path, DefaultFlushPolicy).GetUserURLs(ctx, models.UserURLsQuery{})
+	require.NoError(t, err)
+	require.Len(t, restored, len(urls))
+	for i := range urls {
+		assert.Equal(t, urls[i].ShortURL, restored[i].ShortURL)
+		assert.True(t, urls[i].CreatedAt.Equal(*restored[i].CreatedAt))
+	}
+}
+
+func TestURLInMemoryRepo_APIKeysRestored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	kept := models.APIKey{ID: "k1", Name: "backend", Scopes: []string{models.ScopeRead},
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), UserID: UserID, Hash: "hash1"}
+	revoked := models.APIKey{ID: "k2", Name: "reports", Scopes: []string{models.ScopeRead},
+		CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), UserID: UserID, Hash: "hash2"}
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com", "short", models.URLOptions{}))
+	require.NoError(t, repo.StoreAPIKey(ctx, kept))
+	require.NoError(t, repo.StoreAPIKey(ctx, revoked))
+	require.NoError(t, repo.DeleteAPIKey(ctx, "k2"))
+
+	// the keys are restored from the storage file before and after compaction
+	for _, compact := range []bool{false, true} {
+		if compact {
+			require.NoError(t, repo.Compact())
+		}
+		restored := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+		keys, err := restored.GetUserAPIKeys(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []models.APIKey{kept}, keys)
+		stats, err := restored.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), stats.CountURLs)
+	}
+}
+
+func TestURLInMemoryRepo_AccountsRestored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	anonymousID := uuid.New()
+	anonymousCtx := context.WithValue(context.Background(), models.UserIDKey, anonymousID)
+	repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	account := models.Account{UserID: UserID, Login: "alice",
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), PasswordHash: "hash"}
+	require.NoError(t, repo.StoreAccount(ctx, account))
+	require.NoError(t, repo.StoreURL(anonymousCtx, "http://example1.com", "short1", models.URLOptions{}))
+	require.NoError(t, repo.StoreURL(anonymousCtx, "http://example2.com", "short2", models.URLOptions{}))
+	require.NoError(t, repo.MarkURLsAsDeleted(ctx, []models.URLDeletion{{UserID: anonymousID, ShortURLs: []string{"short2"}}}))
+	claimed, err := repo.ClaimURLs(ctx, anonymousID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), claimed)
+	require.NoError(t, repo.SaveBatchToFile())
+
+	// the account and the claimed URLs are restored from the storage file before and after compaction
+	for _, compact := range []bool{false, true} {
+		if compact {
+			require.NoError(t, repo.Compact())
+		}
+		restored := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+		stored, err := restored.GetAccount(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, account, stored)
+		stored, err = restored.GetUserAccount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, account, stored)
+
+		urls, err := restored.GetUserURLs(ctx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"short1", "short2"}, shortURLs(urls))
+		_, err = restored.GetRedirect(ctx, "short2")
+		assert.ErrorIs(t, err, models.ErrURLDeleted)
+		stats, err := restored.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, models.Stats{CountURLs: 2, CountUsers: 1}, stats)
+	}
+}
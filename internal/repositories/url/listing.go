@@ -0,0 +1,90 @@
+package url
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// createdAt returns the creation time of the URL, zero if it isn't known.
+func createdAt(url models.URL) time.Time {
+	if url.CreatedAt == nil {
+		return time.Time{}
+	}
+	return *url.CreatedAt
+}
+
+// compareToCursor returns -1, 0 or +1 depending on whether the URL is listed before, at or after
+// the cursor position in the order of creation time.
+func compareToCursor(url models.URL, cursor models.URLCursor) int {
+	if c := createdAt(url).Compare(cursor.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(url.ShortURL, cursor.ShortURL)
+}
+
+// cursorOf returns the cursor position of the URL.
+func cursorOf(url models.URL) models.URLCursor {
+	return models.URLCursor{CreatedAt: createdAt(url), ShortURL: url.ShortURL}
+}
+
+// insertSorted inserts the URL into the URLs ordered by creation time keeping the order.
+// URLs are usually created in order, so the place is searched from the end and the URL is appended.
+// Otherwise a new slice is made, because the old one may be read without the lock.
+func insertSorted(urls []models.URL, url models.URL) []models.URL {
+	cursor := cursorOf(url)
+	i := len(urls)
+	for i > 0 && compareToCursor(urls[i-1], cursor) > 0 {
+		i--
+	}
+	if i == len(urls) {
+		return append(urls, url)
+	}
+	result := make([]models.URL, 0, len(urls)+1)
+	result = append(result, urls[:i]...)
+	result = append(result, url)
+	return append(result, urls[i:]...)
+}
+
+// sortURLs orders the URLs by creation time.
+func sortURLs(urls []models.URL) {
+	sort.Slice(urls, func(i, j int) bool {
+		return compareToCursor(urls[i], cursorOf(urls[j])) < 0
+	})
+}
+
+// pageURLs returns a copy of the page of the URLs ordered by creation time selected by the query.
+func pageURLs(sorted []models.URL, query models.UserURLsQuery) []models.URL {
+	search := strings.ToLower(query.Search)
+	matches := func(url models.URL) bool {
+		return search == "" || strings.Contains(strings.ToLower(url.OriginalURL), search)
+	}
+	full := func(page []models.URL) bool {
+		return query.Limit > 0 && len(page) >= query.Limit
+	}
+	page := make([]models.URL, 0, min(len(sorted), max(query.Limit, 0)))
+	if !query.Desc {
+		start := 0
+		if query.After != nil {
+			start = sort.Search(len(sorted), func(i int) bool { return compareToCursor(sorted[i], *query.After) > 0 })
+		}
+		for i := start; i < len(sorted) && !full(page); i++ {
+			if matches(sorted[i]) {
+				page = append(page, sorted[i])
+			}
+		}
+		return page
+	}
+	end := len(sorted)
+	if query.After != nil {
+		end = sort.Search(len(sorted), func(i int) bool { return compareToCursor(sorted[i], *query.After) >= 0 })
+	}
+	for i := end - 1; i >= 0 && !full(page); i-- {
+		if matches(sorted[i]) {
+			page = append(page, sorted[i])
+		}
+	}
+	return page
+}
@@ -0,0 +1,31 @@
+package url
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertSorted(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(shortURL string, hours int) models.URL {
+		createdAt := base.Add(time.Duration(hours) * time.Hour)
+		return models.URL{ShortURL: shortURL, CreatedAt: &createdAt}
+	}
+
+	urls := insertSorted(nil, at("b", 1))
+	urls = insertSorted(urls, at("c", 2))
+	urls = insertSorted(urls, models.URL{ShortURL: "legacy"})
+	before := urls
+	urls = insertSorted(urls, at("a", 1))
+	assert.Equal(t, []string{"legacy", "a", "b", "c"}, shortURLs(urls))
+	// the slice read before the insertion isn't changed
+	assert.Equal(t, []string{"legacy", "b", "c"}, shortURLs(before))
+
+	assert.Equal(t, []string{"c", "b"}, shortURLs(pageURLs(urls, models.UserURLsQuery{
+		Limit: 2, Desc: true, After: &models.URLCursor{CreatedAt: base.Add(3 * time.Hour)}})))
+	assert.Equal(t, []string{"b", "c"}, shortURLs(pageURLs(urls, models.UserURLsQuery{
+		After: &models.URLCursor{CreatedAt: base.Add(time.Hour), ShortURL: "a"}})))
+}
@@ -0,0 +1,802 @@
+package url
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/migrations"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contractRepository is the part of the repository interface shared by all storage backends.
+type contractRepository interface {
+	StoreURL(ctx context.Context, originalURL, shortURL string, options models.URLOptions) error
+	GetShortURL(ctx context.Context, domain, originalURL string) (string, error)
+	GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error)
+	StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string, options map[string]models.URLOptions) error
+	GetShortBatchURL(ctx context.Context, domain string, batchURLRequests []models.URLRequest) (map[string]string, error)
+	GetUserURLs(ctx context.Context, query models.UserURLsQuery) ([]models.URL, error)
+	MarkURLsAsDeleted(ctx context.Context, deletions []models.URLDeletion) error
+	RestoreURL(ctx context.Context, shortURL string, deletedAfter time.Time) (models.URL, error)
+	PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error)
+	GetStats(ctx context.Context) (models.Stats, error)
+	DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error)
+	StoreClicks(ctx context.Context, clicks []models.Click) error
+	GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error)
+	UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error)
+	GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error)
+	ExportURLs(ctx context.Context, fn func(record models.URLRecord) error) error
+	ImportURLs(ctx context.Context, records []models.URLRecord, dryRun bool) (models.ImportReport, error)
+	ExportAPIKeys(ctx context.Context, fn func(key models.APIKey) error) error
+	ImportAPIKeys(ctx context.Context, keys []models.APIKey, dryRun bool) (models.ImportItemsReport, error)
+	ExportAccounts(ctx context.Context, fn func(account models.Account) error) error
+	ImportAccounts(ctx context.Context, accounts []models.Account, dryRun bool) (models.ImportItemsReport, error)
+	StoreAPIKey(ctx context.Context, key models.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (models.APIKey, error)
+	GetUserAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	StoreAccount(ctx context.Context, account models.Account) error
+	GetAccount(ctx context.Context, login string) (models.Account, error)
+	GetUserAccount(ctx context.Context) (models.Account, error)
+	ClaimURLs(ctx context.Context, fromUserID uuid.UUID) (int64, error)
+}
+
+// shortURLs returns the short URLs of the user URLs.
+func shortURLs(urls []models.URL) []string {
+	result := make([]string, 0, len(urls))
+	for _, url := range urls {
+		result = append(result, url.ShortURL)
+	}
+	return result
+}
+
+// testRepositoryContract checks the behaviour every storage backend must have.
+// newRepo must return an empty repository.
+func testRepositoryContract(t *testing.T, newRepo func(t *testing.T) contractRepository) {
+	userCtx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	otherCtx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+
+	t.Run("store and get URL", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+		// the same mapping may be saved again
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+
+		redirect, err := repo.GetRedirect(userCtx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com", redirect.OriginalURL)
+		shortURL, err := repo.GetShortURL(userCtx, "", "http://example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "short", shortURL)
+
+		_, err = repo.GetRedirect(userCtx, "unknown")
+		assert.Error(t, err)
+		_, err = repo.GetShortURL(userCtx, "", "http://unknown.com")
+		assert.Error(t, err)
+	})
+
+	t.Run("short URL conflict", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+		err := repo.StoreURL(userCtx, "http://other.com", "short", models.URLOptions{})
+		assert.ErrorIs(t, err, models.ErrShortURLConflict)
+		_, err = repo.GetShortURL(userCtx, "", "http://other.com")
+		assert.Error(t, err)
+	})
+
+	t.Run("original URL is shortened once", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "first", models.URLOptions{}))
+		// the short URL the original URL is stored under is returned, so it can be answered instead of the new one
+		err := repo.StoreURL(userCtx, "http://example.com", "second", models.URLOptions{})
+		var conflict *models.OriginalURLConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "first", conflict.ShortURL)
+		assert.ErrorIs(t, err, models.ErrOriginalURLTaken)
+		shortURL, err := repo.GetShortURL(userCtx, "", "http://example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "first", shortURL)
+		_, err = repo.GetRedirect(userCtx, "second")
+		assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+	})
+
+	t.Run("short domains", func(t *testing.T) {
+		repo := newRepo(t)
+		onDomain := models.URLOptions{Domain: "go.example.com"}
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+		// the same original URL gets its own short URL on every domain
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "domain", onDomain))
+		shortURL, err := repo.GetShortURL(userCtx, "", "http://example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "short", shortURL)
+		shortURL, err = repo.GetShortURL(userCtx, "go.example.com", "http://example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "domain", shortURL)
+
+		redirect, err := repo.GetRedirect(userCtx, "domain")
+		require.NoError(t, err)
+		assert.Equal(t, models.Redirect{OriginalURL: "http://example.com", Domain: "go.example.com"}, redirect)
+		// short URLs are unique across the domains
+		err = repo.StoreURL(userCtx, "http://example.com", "domain", models.URLOptions{})
+		assert.ErrorIs(t, err, models.ErrShortURLConflict)
+
+		require.NoError(t, repo.StoreBatchURL(userCtx, map[string]string{"batch": "http://example1.com"},
+			map[string]models.URLOptions{"http://example1.com": onDomain}))
+		found, err := repo.GetShortBatchURL(userCtx, "go.example.com", []models.URLRequest{{OriginalURL: "http://example1.com"}})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"http://example1.com": "batch"}, found)
+		found, err = repo.GetShortBatchURL(userCtx, "", []models.URLRequest{{OriginalURL: "http://example1.com"}})
+		require.NoError(t, err)
+		assert.Empty(t, found)
+
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{Search: "example1"})
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Equal(t, "go.example.com", urls[0].Domain)
+		stats, err := repo.GetURLStats(userCtx, "batch")
+		require.NoError(t, err)
+		assert.Equal(t, "go.example.com", stats.Domain)
+	})
+
+	t.Run("redirect codes", func(t *testing.T) {
+		repo := newRepo(t)
+		permanent := models.URLOptions{RedirectCode: http.StatusPermanentRedirect}
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", permanent))
+		require.NoError(t, repo.StoreURL(userCtx, "http://legacy.com", "legacy", models.URLOptions{}))
+		require.NoError(t, repo.StoreBatchURL(userCtx, map[string]string{"batch": "http://example1.com"},
+			map[string]models.URLOptions{"http://example1.com": {RedirectCode: http.StatusFound}}))
+
+		for shortURL, want := range map[string]int{"short": http.StatusPermanentRedirect, "legacy": 0, "batch": http.StatusFound} {
+			redirect, err := repo.GetRedirect(userCtx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, want, redirect.Code, shortURL)
+		}
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{Search: "example.com"})
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Equal(t, http.StatusPermanentRedirect, urls[0].RedirectCode)
+
+		// the code is kept when the original URL is changed
+		_, err = repo.UpdateURL(userCtx, "short", "http://new.com")
+		require.NoError(t, err)
+		redirect, err := repo.GetRedirect(userCtx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, models.Redirect{OriginalURL: "http://new.com", Code: http.StatusPermanentRedirect}, redirect)
+	})
+
+	t.Run("update URL", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+		require.NoError(t, repo.StoreURL(userCtx, "http://taken.com", "taken", models.URLOptions{}))
+		history, err := repo.GetURLHistory(userCtx, "short")
+		require.NoError(t, err)
+		assert.Empty(t, history)
+
+		before := time.Now().Add(-time.Second)
+		url, err := repo.UpdateURL(userCtx, "short", "http://example2.com")
+		require.NoError(t, err)
+		assert.Equal(t, "short", url.ShortURL)
+		assert.Equal(t, "http://example2.com", url.OriginalURL)
+		_, err = repo.UpdateURL(userCtx, "short", "http://example3.com")
+		require.NoError(t, err)
+		// the same original URL isn't recorded as a change
+		_, err = repo.UpdateURL(userCtx, "short", "http://example3.com")
+		require.NoError(t, err)
+
+		redirect, err := repo.GetRedirect(userCtx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "http://example3.com", redirect.OriginalURL)
+		shortURL, err := repo.GetShortURL(userCtx, "", "http://example3.com")
+		require.NoError(t, err)
+		assert.Equal(t, "short", shortURL)
+		// the replaced original URL may be shortened by another link
+		_, err = repo.GetShortURL(userCtx, "", "http://example.com")
+		assert.Error(t, err)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "fresh", models.URLOptions{}))
+		shortURL, err = repo.GetShortURL(userCtx, "", "http://example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "fresh", shortURL)
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{Search: "example3"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"short"}, shortURLs(urls))
+
+		history, err = repo.GetURLHistory(userCtx, "short")
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, "http://example2.com", history[0].OriginalURL)
+		assert.Equal(t, "http://example.com", history[1].OriginalURL)
+		assert.True(t, history[1].ChangedAt.After(before))
+		assert.False(t, history[0].ChangedAt.Before(history[1].ChangedAt))
+
+		_, err = repo.UpdateURL(userCtx, "short", "http://taken.com")
+		assert.ErrorIs(t, err, models.ErrOriginalURLTaken)
+		_, err = repo.UpdateURL(otherCtx, "short", "http://other.com")
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+		_, err = repo.GetURLHistory(otherCtx, "short")
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+		_, err = repo.UpdateURL(userCtx, "unknown", "http://other.com")
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+
+		require.NoError(t, repo.MarkURLsAsDeleted(userCtx, []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"taken"}}}))
+		_, err = repo.UpdateURL(userCtx, "taken", "http://other.com")
+		assert.ErrorIs(t, err, models.ErrURLDeleted)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreBatchURL(userCtx, map[string]string{
+			"short1": "http://example1.com",
+			"short2": "http://example2.com",
+		}, nil))
+		found, err := repo.GetShortBatchURL(userCtx, "", []models.URLRequest{
+			{OriginalURL: "http://example1.com"},
+			{OriginalURL: "http://example2.com"},
+			{OriginalURL: "http://unknown.com"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"http://example1.com": "short1", "http://example2.com": "short2"}, found)
+
+		// the batch with a taken short URL is not saved at all
+		err = repo.StoreBatchURL(userCtx, map[string]string{
+			"short3": "http://example3.com",
+			"short1": "http://other.com",
+		}, nil)
+		var conflict *models.ShortURLConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "short1", conflict.ShortURL)
+		_, err = repo.GetRedirect(userCtx, "short3")
+		assert.Error(t, err)
+
+		// the stored links of the batch are skipped
+		require.NoError(t, repo.StoreBatchURL(userCtx, map[string]string{
+			"short1": "http://example1.com",
+			"other2": "http://example2.com",
+			"short3": "http://example3.com",
+		}, nil))
+		found, err = repo.GetShortBatchURL(userCtx, "", []models.URLRequest{
+			{OriginalURL: "http://example2.com"},
+			{OriginalURL: "http://example3.com"},
+			{OriginalURL: "http://example3.com"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"http://example2.com": "short2", "http://example3.com": "short3"}, found)
+	})
+
+	t.Run("user URLs", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example1.com", "short1", models.URLOptions{}))
+		require.NoError(t, repo.StoreURL(userCtx, "http://example2.com", "short2", models.URLOptions{}))
+		require.NoError(t, repo.StoreURL(otherCtx, "http://example3.com", "short3", models.URLOptions{}))
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"short1", "short2"}, shortURLs(urls))
+
+		stats, err := repo.GetStats(userCtx)
+		require.NoError(t, err)
+		assert.Equal(t, models.Stats{CountURLs: 3, CountUsers: 2}, stats)
+	})
+
+	t.Run("mark URLs as deleted", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+		// URLs of another user are not marked
+		otherUserID := otherCtx.Value(models.UserIDKey).(uuid.UUID)
+		require.NoError(t, repo.StoreURL(otherCtx, "http://other.com", "other", models.URLOptions{}))
+		require.NoError(t, repo.MarkURLsAsDeleted(userCtx, []models.URLDeletion{{UserID: otherUserID, ShortURLs: []string{"short"}}}))
+		_, err := repo.GetRedirect(userCtx, "short")
+		require.NoError(t, err)
+
+		// deletions of many users are marked at once
+		require.NoError(t, repo.MarkURLsAsDeleted(userCtx, []models.URLDeletion{
+			{UserID: UserID, ShortURLs: []string{"short", "unknown"}},
+			{UserID: otherUserID, ShortURLs: []string{"other"}},
+		}))
+		_, err = repo.GetRedirect(userCtx, "short")
+		assert.ErrorIs(t, err, models.ErrURLDeleted)
+		_, err = repo.GetRedirect(userCtx, "other")
+		assert.ErrorIs(t, err, models.ErrURLDeleted)
+		require.NoError(t, repo.MarkURLsAsDeleted(userCtx, nil))
+	})
+
+	t.Run("restore and purge deleted URLs", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+		require.NoError(t, repo.StoreURL(userCtx, "http://alive.com", "alive", models.URLOptions{}))
+		// a link deleted before deletion times were recorded can't be restored
+		_, err := repo.ImportURLs(userCtx, []models.URLRecord{
+			{ShortURL: "legacy", OriginalURL: "http://legacy.com", UserID: UserID, DeletedFlag: true},
+		}, false)
+		require.NoError(t, err)
+		_, err = repo.RestoreURL(userCtx, "legacy", time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, models.ErrRestorePeriodOver)
+
+		// a link that isn't deleted is returned as is
+		url, err := repo.RestoreURL(userCtx, "alive", time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, "http://alive.com", url.OriginalURL)
+
+		require.NoError(t, repo.MarkURLsAsDeleted(userCtx, []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"short"}}}))
+		_, err = repo.RestoreURL(otherCtx, "short", time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+		_, err = repo.RestoreURL(userCtx, "unknown", time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+		_, err = repo.RestoreURL(userCtx, "short", time.Now().Add(time.Hour))
+		assert.ErrorIs(t, err, models.ErrRestorePeriodOver)
+		url, err = repo.RestoreURL(userCtx, "short", time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, models.URL{ShortURL: "short", OriginalURL: "http://example.com", CreatedAt: url.CreatedAt}, url)
+		redirect, err := repo.GetRedirect(userCtx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com", redirect.OriginalURL)
+
+		// deleted links are kept until their restore period is over
+		require.NoError(t, repo.MarkURLsAsDeleted(userCtx, []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"short"}}}))
+		purged, err := repo.PurgeDeletedURLs(userCtx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		_, err = repo.RestoreURL(userCtx, "legacy", time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+		_, err = repo.GetRedirect(userCtx, "short")
+		assert.ErrorIs(t, err, models.ErrURLDeleted)
+
+		purged, err = repo.PurgeDeletedURLs(userCtx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		_, err = repo.GetRedirect(userCtx, "short")
+		assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+		_, err = repo.GetShortURL(userCtx, "", "http://example.com")
+		assert.Error(t, err)
+		_, err = repo.RestoreURL(userCtx, "short", time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alive"}, shortURLs(urls))
+	})
+
+	t.Run("expired URLs", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+		require.NoError(t, repo.StoreURL(userCtx, "http://expired.com", "expired", models.URLOptions{ExpiresAt: now.Add(-time.Hour)}))
+		require.NoError(t, repo.StoreURL(userCtx, "http://alive.com", "alive", models.URLOptions{ExpiresAt: now.Add(time.Hour)}))
+		require.NoError(t, repo.StoreURL(userCtx, "http://forever.com", "forever", models.URLOptions{}))
+		_, err := repo.GetRedirect(userCtx, "expired")
+		assert.ErrorIs(t, err, models.ErrURLExpired)
+		// the expiration time is returned, so the redirect isn't cached past it
+		redirect, err := repo.GetRedirect(userCtx, "alive")
+		require.NoError(t, err)
+		assert.WithinDuration(t, now.Add(time.Hour), redirect.ExpiresAt, time.Millisecond)
+		redirect, err = repo.GetRedirect(userCtx, "forever")
+		require.NoError(t, err)
+		assert.True(t, redirect.ExpiresAt.IsZero())
+
+		deleted, err := repo.DeleteExpiredURLs(userCtx, now.Add(-2*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), deleted)
+		deleted, err = repo.DeleteExpiredURLs(userCtx, now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		_, err = repo.GetRedirect(userCtx, "expired")
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, models.ErrURLExpired))
+		_, err = repo.GetShortURL(userCtx, "", "http://expired.com")
+		assert.Error(t, err)
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"alive", "forever"}, shortURLs(urls))
+	})
+
+	t.Run("clicks", func(t *testing.T) {
+		repo := newRepo(t)
+		day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "short", models.URLOptions{}))
+		require.NoError(t, repo.StoreURL(otherCtx, "http://other.com", "other", models.URLOptions{}))
+		require.NoError(t, repo.StoreClicks(userCtx, []models.Click{
+			{ShortURL: "short", Timestamp: day},
+			{ShortURL: "short", Timestamp: day.Add(24 * time.Hour)},
+			{ShortURL: "short", Timestamp: day.Add(25 * time.Hour)},
+			{ShortURL: "other", Timestamp: day},
+		}))
+
+		stats, err := repo.GetURLStats(userCtx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stats.TotalClicks)
+		assert.Equal(t, []models.DailyClicks{{Date: "2024-01-01", Clicks: 1}, {Date: "2024-01-02", Clicks: 2}}, stats.Daily)
+
+		_, err = repo.GetURLStats(userCtx, "other")
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+		_, err = repo.GetURLStats(userCtx, "unknown")
+		assert.ErrorIs(t, err, models.ErrURLNotFound)
+	})
+
+	t.Run("user URLs pages", func(t *testing.T) {
+		repo := newRepo(t)
+		base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		records := make([]models.URLRecord, 0, 5)
+		for i, shortURL := range []string{"e", "d", "c", "b", "a"} {
+			createdAt := base.Add(time.Duration(i/2) * time.Hour) // two URLs are created at the same time
+			records = append(records, models.URLRecord{ShortURL: shortURL, OriginalURL: "http://Example.com/" + shortURL,
+				UserID: UserID, CreatedAt: &createdAt})
+		}
+		records[2].OriginalURL = "http://other.com/c"
+		_, err := repo.ImportURLs(userCtx, records, false)
+		require.NoError(t, err)
+		require.NoError(t, repo.StoreURL(otherCtx, "http://example.com/other", "other", models.URLOptions{}))
+
+		// all pages are read following the cursor of the last URL
+		readAll := func(query models.UserURLsQuery) []string {
+			var result []string
+			for {
+				urls, err := repo.GetUserURLs(userCtx, query)
+				require.NoError(t, err)
+				result = append(result, shortURLs(urls)...)
+				if len(urls) < query.Limit {
+					return result
+				}
+				last := urls[len(urls)-1]
+				require.NotNil(t, last.CreatedAt)
+				query.After = &models.URLCursor{CreatedAt: *last.CreatedAt, ShortURL: last.ShortURL}
+			}
+		}
+		assert.Equal(t, []string{"d", "e", "b", "c", "a"}, readAll(models.UserURLsQuery{Limit: 2}))
+		assert.Equal(t, []string{"a", "c", "b", "e", "d"}, readAll(models.UserURLsQuery{Limit: 2, Desc: true}))
+		assert.Equal(t, []string{"d", "e", "b", "a"}, readAll(models.UserURLsQuery{Limit: 3, Search: "EXAMPLE.com"}))
+		assert.Equal(t, []string{"a", "b", "e", "d"}, readAll(models.UserURLsQuery{Limit: 1, Desc: true, Search: "example"}))
+
+		// wildcards of the search string are matched literally
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{Search: "%"})
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+
+		urls, err = repo.GetUserURLs(userCtx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		assert.Len(t, urls, 5)
+		assert.True(t, base.Equal(*urls[0].CreatedAt))
+	})
+
+	t.Run("export and import", func(t *testing.T) {
+		repo := newRepo(t)
+		otherUserID := otherCtx.Value(models.UserIDKey).(uuid.UUID)
+		expiresAt := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, repo.StoreURL(userCtx, "http://stored.com", "stored", models.URLOptions{}))
+		records := []models.URLRecord{
+			{ShortURL: "alive", OriginalURL: "http://alive.com", UserID: UserID, ExpiresAt: &expiresAt,
+				RedirectCode: http.StatusMovedPermanently},
+			{ShortURL: "deleted", OriginalURL: "http://deleted.com", UserID: otherUserID, DeletedFlag: true},
+			{ShortURL: "stored", OriginalURL: "http://stored.com", UserID: UserID},
+			{ShortURL: "stored", OriginalURL: "http://other.com", UserID: UserID},
+			{ShortURL: "other", OriginalURL: "http://stored.com", UserID: UserID},
+		}
+		wantConflicts := []models.ImportConflict{
+			{URLRecord: records[3], Reason: models.ConflictShortURLTaken},
+			{URLRecord: records[4], Reason: models.ConflictOriginalShortened},
+		}
+
+		// nothing is saved in a dry run
+		report, err := repo.ImportURLs(userCtx, records, true)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportReport{Imported: 2, Existing: 1, Conflicts: wantConflicts}, report)
+		_, err = repo.GetRedirect(userCtx, "alive")
+		assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+
+		report, err = repo.ImportURLs(userCtx, records, false)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportReport{Imported: 2, Existing: 1, Conflicts: wantConflicts}, report)
+		redirect, err := repo.GetRedirect(userCtx, "alive")
+		require.NoError(t, err)
+		assert.Equal(t, "http://alive.com", redirect.OriginalURL)
+		_, err = repo.GetRedirect(userCtx, "deleted")
+		assert.ErrorIs(t, err, models.ErrURLDeleted)
+
+		// the link of another user isn't taken over
+		report, err = repo.ImportURLs(userCtx, []models.URLRecord{{ShortURL: "deleted", OriginalURL: "http://deleted.com", UserID: UserID}}, false)
+		require.NoError(t, err)
+		assert.Equal(t, models.ConflictOtherOwner, report.Conflicts[0].Reason)
+
+		var exported []models.URLRecord
+		require.NoError(t, repo.ExportURLs(userCtx, func(record models.URLRecord) error {
+			exported = append(exported, record)
+			return nil
+		}))
+		for i := range exported {
+			// the records imported without creation times are created at the time of import
+			require.NotNil(t, exported[i].CreatedAt)
+			exported[i].CreatedAt = nil
+			if exported[i].ExpiresAt != nil {
+				assert.True(t, expiresAt.Equal(*exported[i].ExpiresAt))
+				exported[i].ExpiresAt = &expiresAt
+			}
+		}
+		assert.ElementsMatch(t, []models.URLRecord{records[0], records[1], records[2]}, exported)
+
+		// export stops on the error of fn
+		errStop := errors.New("stop")
+		assert.ErrorIs(t, repo.ExportURLs(userCtx, func(models.URLRecord) error { return errStop }), errStop)
+	})
+
+	t.Run("export and import history", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.StoreURL(userCtx, "http://example.com", "changed", models.URLOptions{}))
+		_, err := repo.UpdateURL(userCtx, "changed", "http://example2.com")
+		require.NoError(t, err)
+		_, err = repo.UpdateURL(userCtx, "changed", "http://example3.com")
+		require.NoError(t, err)
+		wantHistory, err := repo.GetURLHistory(userCtx, "changed")
+		require.NoError(t, err)
+		require.Len(t, wantHistory, 2)
+
+		var exported []models.URLRecord
+		require.NoError(t, repo.ExportURLs(userCtx, func(record models.URLRecord) error {
+			exported = append(exported, record)
+			return nil
+		}))
+		require.Len(t, exported, 1)
+		// the exported history is ordered the same as it is kept, the most recently replaced last
+		require.Len(t, exported[0].History, 2)
+		assert.Equal(t, "http://example.com", exported[0].History[0].OriginalURL)
+		assert.Equal(t, "http://example2.com", exported[0].History[1].OriginalURL)
+
+		changedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		deletedAt := time.Now().Add(-time.Minute)
+		imported := models.URLRecord{ShortURL: "imported", OriginalURL: "http://imported3.com", UserID: UserID, DeletedFlag: true,
+			DeletedAt: &deletedAt, History: []models.URLChange{
+				{OriginalURL: "http://imported1.com", ChangedAt: changedAt},
+				{OriginalURL: "http://imported2.com", ChangedAt: changedAt.Add(time.Hour)},
+			}}
+		target := newRepo(t)
+		report, err := target.ImportURLs(userCtx, append(exported, imported), false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Imported)
+
+		history, err := target.GetURLHistory(userCtx, "changed")
+		require.NoError(t, err)
+		require.Len(t, history, len(wantHistory))
+		for i := range wantHistory {
+			assert.Equal(t, wantHistory[i].OriginalURL, history[i].OriginalURL)
+			assert.True(t, wantHistory[i].ChangedAt.Equal(history[i].ChangedAt))
+		}
+		// the history of a deleted link is kept, it is read back after the link is restored
+		_, err = target.RestoreURL(userCtx, "imported", deletedAt.Add(-time.Hour))
+		require.NoError(t, err)
+		history, err = target.GetURLHistory(userCtx, "imported")
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, "http://imported2.com", history[0].OriginalURL)
+		assert.True(t, changedAt.Add(time.Hour).Equal(history[0].ChangedAt))
+		assert.Equal(t, "http://imported1.com", history[1].OriginalURL)
+
+		// the history of a record of an already stored link isn't imported
+		report, err = target.ImportURLs(userCtx, []models.URLRecord{{ShortURL: "changed", OriginalURL: "http://example3.com",
+			UserID: UserID, History: imported.History}}, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Existing)
+		history, err = target.GetURLHistory(userCtx, "changed")
+		require.NoError(t, err)
+		assert.Len(t, history, len(wantHistory))
+	})
+
+	t.Run("API keys", func(t *testing.T) {
+		repo := newRepo(t)
+		createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		first := models.APIKey{ID: "k1", Name: "backend", Scopes: []string{models.ScopeCreate, models.ScopeRead},
+			CreatedAt: createdAt.Add(time.Minute), UserID: UserID, Hash: "hash1"}
+		second := models.APIKey{ID: "k2", Name: "reports", Scopes: []string{models.ScopeRead},
+			CreatedAt: createdAt, UserID: UserID, Hash: "hash2"}
+		other := models.APIKey{ID: "k3", Name: "other", Scopes: []string{models.ScopeDelete},
+			CreatedAt: createdAt, UserID: otherCtx.Value(models.UserIDKey).(uuid.UUID), Hash: "hash3"}
+		for _, key := range []models.APIKey{first, second, other} {
+			require.NoError(t, repo.StoreAPIKey(userCtx, key))
+		}
+
+		key, err := repo.GetAPIKey(context.Background(), "k1")
+		require.NoError(t, err)
+		assert.Equal(t, first, key)
+		_, err = repo.GetAPIKey(context.Background(), "missing")
+		assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+
+		keys, err := repo.GetUserAPIKeys(userCtx)
+		require.NoError(t, err)
+		assert.Equal(t, []models.APIKey{second, first}, keys)
+
+		// keys of another user can't be deleted
+		assert.ErrorIs(t, repo.DeleteAPIKey(userCtx, "k3"), models.ErrAPIKeyNotFound)
+		require.NoError(t, repo.DeleteAPIKey(userCtx, "k2"))
+		assert.ErrorIs(t, repo.DeleteAPIKey(userCtx, "k2"), models.ErrAPIKeyNotFound)
+		_, err = repo.GetAPIKey(context.Background(), "k2")
+		assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+
+		keys, err = repo.GetUserAPIKeys(userCtx)
+		require.NoError(t, err)
+		assert.Equal(t, []models.APIKey{first}, keys)
+		keys, err = repo.GetUserAPIKeys(context.WithValue(context.Background(), models.UserIDKey, uuid.New()))
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("export and import API keys", func(t *testing.T) {
+		repo := newRepo(t)
+		createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		stored := models.APIKey{ID: "k1", Name: "backend", Scopes: []string{models.ScopeCreate, models.ScopeRead},
+			CreatedAt: createdAt, UserID: UserID, Hash: "hash1"}
+		require.NoError(t, repo.StoreAPIKey(userCtx, stored))
+		keys := []models.APIKey{
+			stored,
+			{ID: "k1", Name: "backend", Scopes: []string{models.ScopeRead}, CreatedAt: createdAt, UserID: UserID, Hash: "other"},
+			{ID: "k2", Name: "reports", Scopes: []string{models.ScopeRead}, CreatedAt: createdAt.Add(time.Minute),
+				UserID: otherCtx.Value(models.UserIDKey).(uuid.UUID), Hash: "hash2"},
+			{ID: "k3", Name: "no scopes", Scopes: []string{}, CreatedAt: createdAt, UserID: UserID, Hash: "hash3"},
+		}
+		wantReport := models.ImportItemsReport{Imported: 2, Existing: 1, Conflicts: []models.ImportItemConflict{
+			{ID: "k1", UserID: UserID, Reason: models.ConflictAPIKeyTaken},
+		}}
+
+		// nothing is saved in a dry run
+		report, err := repo.ImportAPIKeys(userCtx, keys, true)
+		require.NoError(t, err)
+		assert.Equal(t, wantReport, report)
+		_, err = repo.GetAPIKey(userCtx, "k2")
+		assert.ErrorIs(t, err, models.ErrAPIKeyNotFound)
+
+		report, err = repo.ImportAPIKeys(userCtx, keys, false)
+		require.NoError(t, err)
+		assert.Equal(t, wantReport, report)
+		key, err := repo.GetAPIKey(userCtx, "k2")
+		require.NoError(t, err)
+		assert.Equal(t, keys[2], key)
+
+		var exported []models.APIKey
+		require.NoError(t, repo.ExportAPIKeys(userCtx, func(key models.APIKey) error {
+			exported = append(exported, key)
+			return nil
+		}))
+		assert.ElementsMatch(t, []models.APIKey{stored, keys[2], keys[3]}, exported)
+
+		errStop := errors.New("stop")
+		assert.ErrorIs(t, repo.ExportAPIKeys(userCtx, func(models.APIKey) error { return errStop }), errStop)
+	})
+
+	t.Run("export and import accounts", func(t *testing.T) {
+		repo := newRepo(t)
+		createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		stored := models.Account{UserID: UserID, Login: "alice", CreatedAt: createdAt, PasswordHash: "hash1"}
+		require.NoError(t, repo.StoreAccount(userCtx, stored))
+		otherUserID := otherCtx.Value(models.UserIDKey).(uuid.UUID)
+		accounts := []models.Account{
+			stored,
+			{UserID: otherUserID, Login: "alice", CreatedAt: createdAt, PasswordHash: "hash2"},
+			{UserID: UserID, Login: "alice2", CreatedAt: createdAt, PasswordHash: "hash1"},
+			{UserID: otherUserID, Login: "bob", CreatedAt: createdAt.Add(time.Minute), PasswordHash: "hash3"},
+		}
+		wantReport := models.ImportItemsReport{Imported: 1, Existing: 1, Conflicts: []models.ImportItemConflict{
+			{ID: "alice", UserID: otherUserID, Reason: models.ConflictLoginTaken},
+			{ID: "alice2", UserID: UserID, Reason: models.ConflictUserHasAccount},
+		}}
+
+		// nothing is saved in a dry run
+		report, err := repo.ImportAccounts(userCtx, accounts, true)
+		require.NoError(t, err)
+		assert.Equal(t, wantReport, report)
+		_, err = repo.GetAccount(userCtx, "bob")
+		assert.ErrorIs(t, err, models.ErrAccountNotFound)
+
+		report, err = repo.ImportAccounts(userCtx, accounts, false)
+		require.NoError(t, err)
+		assert.Equal(t, wantReport, report)
+		account, err := repo.GetUserAccount(otherCtx)
+		require.NoError(t, err)
+		assert.Equal(t, accounts[3], account)
+
+		var exported []models.Account
+		require.NoError(t, repo.ExportAccounts(userCtx, func(account models.Account) error {
+			exported = append(exported, account)
+			return nil
+		}))
+		assert.Equal(t, []models.Account{stored, accounts[3]}, exported)
+
+		errStop := errors.New("stop")
+		assert.ErrorIs(t, repo.ExportAccounts(userCtx, func(models.Account) error { return errStop }), errStop)
+	})
+
+	t.Run("accounts and claiming", func(t *testing.T) {
+		repo := newRepo(t)
+		account := models.Account{UserID: UserID, Login: "alice",
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), PasswordHash: "hash"}
+		require.NoError(t, repo.StoreAccount(userCtx, account))
+		taken := models.Account{UserID: uuid.New(), Login: "alice", CreatedAt: account.CreatedAt, PasswordHash: "hash2"}
+		assert.ErrorIs(t, repo.StoreAccount(otherCtx, taken), models.ErrLoginTaken)
+
+		stored, err := repo.GetAccount(context.Background(), "alice")
+		require.NoError(t, err)
+		assert.Equal(t, account, stored)
+		_, err = repo.GetAccount(context.Background(), "bob")
+		assert.ErrorIs(t, err, models.ErrAccountNotFound)
+		stored, err = repo.GetUserAccount(userCtx)
+		require.NoError(t, err)
+		assert.Equal(t, account, stored)
+		_, err = repo.GetUserAccount(otherCtx)
+		assert.ErrorIs(t, err, models.ErrAccountNotFound)
+
+		// the links of the anonymous user, deleted ones too, move to the account
+		otherUserID := otherCtx.Value(models.UserIDKey).(uuid.UUID)
+		require.NoError(t, repo.StoreURL(otherCtx, "http://example1.com", "short1", models.URLOptions{}))
+		require.NoError(t, repo.StoreURL(otherCtx, "http://example2.com", "short2", models.URLOptions{}))
+		require.NoError(t, repo.MarkURLsAsDeleted(otherCtx, []models.URLDeletion{{UserID: otherUserID, ShortURLs: []string{"short2"}}}))
+		claimed, err := repo.ClaimURLs(userCtx, otherUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), claimed)
+
+		urls, err := repo.GetUserURLs(userCtx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"short1", "short2"}, shortURLs(urls))
+		// the former user has no links left, some backends report it as an error
+		urls, _ = repo.GetUserURLs(otherCtx, models.UserURLsQuery{})
+		assert.Empty(t, urls)
+		_, err = repo.GetRedirect(userCtx, "short2")
+		assert.ErrorIs(t, err, models.ErrURLDeleted)
+		stats, err := repo.GetStats(userCtx)
+		require.NoError(t, err)
+		assert.Equal(t, models.Stats{CountURLs: 2, CountUsers: 1}, stats)
+
+		// nothing is left to claim
+		claimed, err = repo.ClaimURLs(userCtx, otherUserID)
+		require.NoError(t, err)
+		assert.Zero(t, claimed)
+	})
+}
+
+func TestURLInMemoryRepo_Contract(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) contractRepository {
+		return NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), DefaultFlushPolicy)
+	})
+}
+
+func TestURLInBoltRepo_Contract(t *testing.T) {
+	testRepositoryContract(t, func(t *testing.T) contractRepository {
+		repo, err := NewURLInBoltRepo(filepath.Join(t.TempDir(), "storage.bolt"))
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+// openTestDB connects to the test database set by the TEST_DATABASE_DSN env and applies the migrations.
+// The test is skipped if the env is not set.
+func openTestDB(tb testing.TB) *pgxpool.Pool {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, dsn)
+	require.NoError(tb, err)
+	tb.Cleanup(db.Close)
+	migrator, err := migrations.NewMigrator(db)
+	require.NoError(tb, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(tb, err)
+	return db
+}
+
+// TestURLInDBRepo_Contract runs only with a test database set by the TEST_DATABASE_DSN env,
+// all data of the database is removed.
+func TestURLInDBRepo_Contract(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	testRepositoryContract(t, func(t *testing.T) contractRepository {
+		_, err := db.Exec(ctx, `TRUNCATE shorted_URL, api_keys, accounts CASCADE`)
+		require.NoError(t, err)
+		repo, err := NewURLInDBRepo(db)
+		require.NoError(t, err)
+		return repo
+	})
+}
@@ -0,0 +1,143 @@
+// Package repositories provides implementations of data storage for managing shortened URLs.
+// It includes functionality to store, retrieve, and delete URLs using in-memory and file-based storage.
+package url
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// shardCount is the number of independent shards in a shardedMap. It must be a power of two.
+const shardCount = 32
+
+// mapShard is a single lock-protected part of a shardedMap.
+type mapShard[K comparable, V any] struct {
+	sync.RWMutex
+	items map[K]V
+}
+
+// shardedMap is a concurrency-safe map split into shards, each guarded by its own RWMutex,
+// so that goroutines working with different keys rarely contend for the same lock.
+type shardedMap[K comparable, V any] struct {
+	shards [shardCount]*mapShard[K, V]
+	hash   func(K) uint32
+}
+
+// newShardedMap creates an empty shardedMap that distributes keys with the given hash function.
+func newShardedMap[K comparable, V any](hash func(K) uint32) *shardedMap[K, V] {
+	m := &shardedMap[K, V]{hash: hash}
+	for i := range m.shards {
+		m.shards[i] = &mapShard[K, V]{items: make(map[K]V)}
+	}
+	return m
+}
+
+// newStringMap creates a shardedMap with string keys.
+func newStringMap[V any]() *shardedMap[string, V] {
+	return newShardedMap[string, V](hashString)
+}
+
+// newUUIDMap creates a shardedMap with uuid.UUID keys.
+func newUUIDMap[V any]() *shardedMap[uuid.UUID, V] {
+	return newShardedMap[uuid.UUID, V](hashUUID)
+}
+
+// hashString returns FNV-1a hash of the string key.
+func hashString(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// hashUUID returns FNV-1a hash of the UUID key.
+func hashUUID(key uuid.UUID) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write(key[:])
+	return h.Sum32()
+}
+
+// shard returns the shard responsible for the key.
+func (m *shardedMap[K, V]) shard(key K) *mapShard[K, V] {
+	return m.shards[m.hash(key)&(shardCount-1)]
+}
+
+// Load returns the value stored for the key and whether it was found.
+func (m *shardedMap[K, V]) Load(key K) (V, bool) {
+	s := m.shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.items[key]
+	return v, ok
+}
+
+// Store sets the value for the key.
+func (m *shardedMap[K, V]) Store(key K, value V) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+	s.items[key] = value
+}
+
+// LoadOrStore returns the existing value for the key if present and true.
+// Otherwise, it stores the given value and returns it and false.
+func (m *shardedMap[K, V]) LoadOrStore(key K, value V) (V, bool) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if v, ok := s.items[key]; ok {
+		return v, true
+	}
+	s.items[key] = value
+	return value, false
+}
+
+// Update atomically replaces the value for the key with the result of fn.
+// fn receives the current value and whether it exists; if fn returns false as the
+// second result, the key is deleted.
+func (m *shardedMap[K, V]) Update(key K, fn func(value V, exists bool) (V, bool)) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+	old, exists := s.items[key]
+	if v, keep := fn(old, exists); keep {
+		s.items[key] = v
+	} else {
+		delete(s.items, key)
+	}
+}
+
+// Delete removes the key from the map.
+func (m *shardedMap[K, V]) Delete(key K) {
+	s := m.shard(key)
+	s.Lock()
+	defer s.Unlock()
+	delete(s.items, key)
+}
+
+// Len returns the total number of keys in all shards.
+func (m *shardedMap[K, V]) Len() int {
+	var n int
+	for _, s := range m.shards {
+		s.RLock()
+		n += len(s.items)
+		s.RUnlock()
+	}
+	return n
+}
+
+// Range calls fn for every key and value until fn returns false.
+// Each shard is read-locked while it is being iterated, so fn must not modify the map.
+func (m *shardedMap[K, V]) Range(fn func(key K, value V) bool) {
+	for _, s := range m.shards {
+		s.RLock()
+		for k, v := range s.items {
+			if !fn(k, v) {
+				s.RUnlock()
+				return
+			}
+		}
+		s.RUnlock()
+	}
+}
@@ -0,0 +1,63 @@
+package url
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedMap(t *testing.T) {
+	m := newStringMap[int]()
+
+	m.Store("a", 1)
+	v, ok := m.Load("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	v, loaded := m.LoadOrStore("a", 2)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, v)
+
+	v, loaded = m.LoadOrStore("b", 2)
+	assert.False(t, loaded)
+	assert.Equal(t, 2, v)
+
+	m.Update("a", func(v int, exists bool) (int, bool) {
+		assert.True(t, exists)
+		return v + 10, true
+	})
+	v, _ = m.Load("a")
+	assert.Equal(t, 11, v)
+
+	m.Update("b", func(int, bool) (int, bool) { return 0, false })
+	_, ok = m.Load("b")
+	assert.False(t, ok)
+
+	m.Delete("a")
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestShardedMap_Concurrent(t *testing.T) {
+	m := newStringMap[int]()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				m.Store(key, i)
+				m.Update("counter", func(v int, _ bool) (int, bool) { return v + 1, true })
+				_, _ = m.Load(key)
+				m.Range(func(string, int) bool { return false })
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	counter, _ := m.Load("counter")
+	assert.Equal(t, 8000, counter)
+	assert.Equal(t, 8001, m.Len())
+}
@@ -0,0 +1,128 @@
+// Package repositories provides implementations of data storage for managing shortened URLs.
+// It includes functionality to store, retrieve, and delete URLs using in-memory and file-based storage.
+package url
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The storage file is a log of records, one per line. Every line is the CRC-32C checksum of the record
+// in 8 hex digits, a space and the record in JSON:
+//
+//	1a2b3c4d {"user_id":"...","short_url":"...","original_url":"..."}
+//
+// Lines of plain JSON without a checksum, written by the previous versions, are read as well.
+
+// checksumLen is the length of the hex encoded checksum at the start of a line.
+const checksumLen = 8
+
+// crcTable is the CRC-32C (Castagnoli) table used for the record checksums.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errRecordChecksum is returned for a line whose checksum doesn't match its record.
+var errRecordChecksum = errors.New("record checksum mismatch")
+
+// encodeRecord returns the line of the storage file for the record.
+func encodeRecord(record URLInFileRepo) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, checksumLen+len(data)+2)
+	line = fmt.Appendf(line, "%08x ", crc32.Checksum(data, crcTable))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+// decodeRecord parses the line of the storage file and verifies its checksum.
+func decodeRecord(line []byte) (URLInFileRepo, error) {
+	var record URLInFileRepo
+	line = bytes.TrimRight(line, "\r\n")
+	data := line
+	if len(line) > 0 && line[0] != '{' {
+		if len(line) <= checksumLen || line[checksumLen] != ' ' {
+			return record, errors.New("record has no checksum")
+		}
+		var checksum [4]byte
+		if _, err := hex.Decode(checksum[:], line[:checksumLen]); err != nil {
+			return record, fmt.Errorf("record checksum is invalid: %w", err)
+		}
+		data = line[checksumLen+1:]
+		if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(checksum[:]) {
+			return record, errRecordChecksum
+		}
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// writeRecords writes the records to w in the storage file format.
+func writeRecords(w io.Writer, records []URLInFileRepo) error {
+	for _, record := range records {
+		line, err := encodeRecord(record)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceFile atomically replaces the file at path with the records: they are written
+// to a temporary file in the same directory, synced to disk and renamed over the old file.
+func replaceFile(path string, records []URLInFileRepo) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".compact-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	writer := bufio.NewWriter(tmp)
+	if err = writeRecords(writer, records); err != nil {
+		return err
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(0666); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes the directory entry changes, such as a rename, to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
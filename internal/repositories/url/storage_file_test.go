@@ -0,0 +1,213 @@
+package url
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRecord(t *testing.T) {
+	record := URLInFileRepo{UserID: UserID, ShortURL: "short1", OriginalURL: "original1"}
+	line, err := encodeRecord(record)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		line    string
+		wantErr bool
+	}{
+		{name: "record with checksum", line: string(line)},
+		{name: "record without trailing newline", line: strings.TrimSuffix(string(line), "\n")},
+		{name: "legacy record without checksum", line: `{"user_id":"e774844b-5895-4b08-b867-50480263f75b","short_url":"short1","original_url":"original1"}` + "\n"},
+		{name: "checksum mismatch", line: strings.Replace(string(line), "short1", "short2", 1), wantErr: true},
+		{name: "invalid checksum", line: "zzzzzzzz " + string(line[checksumLen+1:]), wantErr: true},
+		{name: "torn record", line: string(line[:len(line)/2]), wantErr: true},
+		{name: "torn legacy record", line: `{"user_id":"e774844b-5895`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeRecord([]byte(tt.line))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, record, got)
+		})
+	}
+}
+
+// writeStorageFile writes the records followed by the raw tail to the storage file.
+func writeStorageFile(t *testing.T, path string, records []URLInFileRepo, tail string) {
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, writeRecords(file, records))
+	_, err = file.WriteString(tail)
+	require.NoError(t, err)
+}
+
+func TestURLInMemoryRepo_Recovery(t *testing.T) {
+	records := []URLInFileRepo{
+		{UserID: UserID, ShortURL: "short1", OriginalURL: "http://example1.com"},
+		{UserID: UserID, ShortURL: "short2", OriginalURL: "http://example2.com"},
+	}
+	valid, err := encodeRecord(URLInFileRepo{UserID: UserID, ShortURL: "short3", OriginalURL: "http://example3.com"})
+	require.NoError(t, err)
+	corrupted := strings.Replace(string(valid), "short3", "shortX", 1)
+
+	tests := []struct {
+		name      string
+		tail      string
+		wantShort []string
+		wantTail  string
+	}{
+		{
+			name:      "torn tail is truncated",
+			tail:      string(valid[:len(valid)/2]),
+			wantShort: []string{"short1", "short2"},
+		},
+		{
+			name:      "corrupted tail is truncated",
+			tail:      corrupted,
+			wantShort: []string{"short1", "short2"},
+		},
+		{
+			name:      "corrupted record before valid ones is skipped",
+			tail:      corrupted + string(valid),
+			wantShort: []string{"short1", "short2", "short3"},
+			wantTail:  corrupted + string(valid),
+		},
+		{
+			name:      "unterminated last record is kept",
+			tail:      strings.TrimSuffix(string(valid), "\n"),
+			wantShort: []string{"short1", "short2", "short3"},
+			wantTail:  string(valid),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.json")
+			writeStorageFile(t, path, records, tt.tail)
+			ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+
+			repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+			urls, err := repo.GetUserURLs(ctx, models.UserURLsQuery{})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.wantShort, shortURLs(urls))
+
+			var head strings.Builder
+			require.NoError(t, writeRecords(&head, records))
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, head.String()+tt.wantTail, string(data))
+
+			// records written after the recovery are read back
+			require.NoError(t, repo.StoreURL(ctx, "http://example4.com", "short4", models.URLOptions{}))
+			require.NoError(t, repo.SaveBatchToFile())
+			urls, err = NewURLInMemoryRepo(path, DefaultFlushPolicy).GetUserURLs(ctx, models.UserURLsQuery{})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, append(tt.wantShort, "short4"), shortURLs(urls))
+		})
+	}
+}
+
+func TestURLInMemoryRepo_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	for i, shortURL := range []string{"short1", "short2", "short3"} {
+		require.NoError(t, repo.StoreURL(ctx, fmt.Sprintf("http://example.com/%d", i+1), shortURL, models.URLOptions{}))
+	}
+	require.NoError(t, repo.SaveBatchToFile())
+	require.NoError(t, repo.MarkURLsAsDeleted(ctx, []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"short2", "short3"}}}))
+	_, err := repo.RestoreURL(ctx, "short3", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	// the deleted URL is kept with its tombstone, so it can be restored until it is purged
+	require.NoError(t, repo.Compact())
+	_, err = repo.GetRedirect(ctx, "short2")
+	assert.ErrorIs(t, err, models.ErrURLDeleted)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(data), "\n"))
+	matches, err := filepath.Glob(path + ".compact-*")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+
+	// records buffered after compaction are appended to the new file
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com/4", "short4", models.URLOptions{}))
+	require.NoError(t, repo.SaveBatchToFile())
+	restored := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	urls, err := restored.GetUserURLs(ctx, models.UserURLsQuery{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"short1", "short2", "short3", "short4"}, shortURLs(urls))
+	redirect, err := restored.GetRedirect(ctx, "short3")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/3", redirect.OriginalURL)
+	// the deletion time survives the compaction
+	_, err = restored.RestoreURL(ctx, "short2", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, models.ErrRestorePeriodOver)
+	_, err = restored.RestoreURL(ctx, "short2", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+}
+
+func TestURLInMemoryRepo_PurgeRestored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com/1", "short1", models.URLOptions{}))
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com/2", "short2", models.URLOptions{}))
+	require.NoError(t, repo.MarkURLsAsDeleted(ctx, []models.URLDeletion{{UserID: UserID, ShortURLs: []string{"short2"}}}))
+	purged, err := repo.PurgeDeletedURLs(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	require.NoError(t, repo.SaveBatchToFile())
+
+	_, err = NewURLInMemoryRepo(path, DefaultFlushPolicy).GetRedirect(ctx, "short2")
+	assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+	// the compacted file keeps only the URL that isn't purged
+	require.NoError(t, repo.Compact())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+}
+
+func TestURLInMemoryRepo_UpdateRestored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.WithValue(context.Background(), models.UserIDKey, UserID)
+	repo := NewURLInMemoryRepo(path, DefaultFlushPolicy)
+	require.NoError(t, repo.StoreURL(ctx, "http://example.com/1", "short", models.URLOptions{}))
+	_, err := repo.UpdateURL(ctx, "short", "http://example.com/2")
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveBatchToFile())
+
+	check := func(t *testing.T, restored *URLInMemoryRepo) {
+		redirect, err := restored.GetRedirect(ctx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com/2", redirect.OriginalURL)
+		shortURL, err := restored.GetShortURL(ctx, "", "http://example.com/2")
+		require.NoError(t, err)
+		assert.Equal(t, "short", shortURL)
+		urls, err := restored.GetUserURLs(ctx, models.UserURLsQuery{})
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Equal(t, "http://example.com/2", urls[0].OriginalURL)
+		history, err := restored.GetURLHistory(ctx, "short")
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "http://example.com/1", history[0].OriginalURL)
+	}
+	// the update record replaces the stored short URL, and the compacted file keeps the history
+	check(t, NewURLInMemoryRepo(path, DefaultFlushPolicy))
+	require.NoError(t, repo.Compact())
+	check(t, NewURLInMemoryRepo(path, DefaultFlushPolicy))
+}
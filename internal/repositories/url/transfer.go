@@ -0,0 +1,109 @@
+package url
+
+import (
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+)
+
+// storedLink is the part of a stored short URL that decides whether a record can be imported next to it.
+type storedLink struct {
+	OriginalURL string
+	UserID      uuid.UUID
+	Domain      string
+}
+
+// checkImport compares the record with the link stored under its short URL, if byShort is not nil,
+// and with the short URL its original URL is stored under, if shortOfOriginal is not empty.
+// It reports whether the same link is already stored, or returns the reason of the conflict.
+// The record can be imported if it is neither stored nor conflicting.
+func checkImport(record models.URLRecord, byShort *storedLink, shortOfOriginal string) (bool, string) {
+	if byShort != nil {
+		switch {
+		case byShort.OriginalURL != record.OriginalURL || byShort.Domain != record.Domain:
+			return false, models.ConflictShortURLTaken
+		case byShort.UserID != record.UserID:
+			return false, models.ConflictOtherOwner
+		default:
+			return true, ""
+		}
+	}
+	if shortOfOriginal != "" && shortOfOriginal != record.ShortURL {
+		return false, models.ConflictOriginalShortened
+	}
+	return false, ""
+}
+
+// addChecked counts the checked record in the report and reports whether it should be imported.
+func addChecked(report *models.ImportReport, record models.URLRecord, exists bool, reason string) bool {
+	switch {
+	case exists:
+		report.Existing++
+	case reason != "":
+		report.Conflicts = append(report.Conflicts, models.ImportConflict{URLRecord: record, Reason: reason})
+	default:
+		report.Imported++
+		return true
+	}
+	return false
+}
+
+// checkAPIKeyImport compares the API key with the key stored under its ID, if stored is not nil.
+// It reports whether the same key is already stored, or returns the reason of the conflict.
+func checkAPIKeyImport(key models.APIKey, stored *models.APIKey) (bool, string) {
+	if stored == nil {
+		return false, ""
+	}
+	if stored.UserID != key.UserID || stored.Hash != key.Hash {
+		return false, models.ConflictAPIKeyTaken
+	}
+	return true, ""
+}
+
+// checkAccountImport compares the account with the account stored under its login, if byLogin is not nil,
+// and with the login of the account of its user, if loginOfUser is not empty.
+// It reports whether the same account is already stored, or returns the reason of the conflict.
+func checkAccountImport(account models.Account, byLogin *models.Account, loginOfUser string) (bool, string) {
+	if byLogin != nil {
+		if byLogin.UserID != account.UserID || byLogin.PasswordHash != account.PasswordHash {
+			return false, models.ConflictLoginTaken
+		}
+		return true, ""
+	}
+	if loginOfUser != "" {
+		return false, models.ConflictUserHasAccount
+	}
+	return false, ""
+}
+
+// addCheckedItem counts the checked API key or account in the report and reports whether it should be imported.
+func addCheckedItem(report *models.ImportItemsReport, id string, userID uuid.UUID, exists bool, reason string) bool {
+	switch {
+	case exists:
+		report.Existing++
+	case reason != "":
+		report.Conflicts = append(report.Conflicts, models.ImportItemConflict{ID: id, UserID: userID, Reason: reason})
+	default:
+		report.Imported++
+		return true
+	}
+	return false
+}
+
+// recordOptions returns the options of the short URL of the record.
+func recordOptions(record models.URLRecord) models.URLOptions {
+	options := models.URLOptions{Domain: record.Domain, RedirectCode: record.RedirectCode}
+	if record.ExpiresAt != nil {
+		options.ExpiresAt = *record.ExpiresAt
+	}
+	return options
+}
+
+// recordCreatedAt returns the creation time of the record, the import time if the record has none.
+func recordCreatedAt(record models.URLRecord, importedAt time.Time) time.Time {
+	if record.CreatedAt != nil {
+		return *record.CreatedAt
+	}
+	return importedAt
+}
@@ -0,0 +1,59 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limits of the credentials of accounts.
+const (
+	minLoginLength    = 3
+	maxLoginLength    = 64
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores the bytes of a password after the 72nd
+)
+
+// passwordCost is the bcrypt cost of hashing passwords, lowered by the tests.
+var passwordCost = bcrypt.DefaultCost
+
+// dummyPasswordHash returns the hash the password of a login without an account is compared with,
+// so a missing login takes as long to reject as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password of a missing account"), passwordCost)
+	return hash
+})
+
+// normalizeLogin returns the login the account is stored with: trimmed and in lower case,
+// so logins differing only in case belong to one account.
+func normalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// credentialsOf validates the credentials of a new account and returns them with the login normalized.
+// It returns models.ErrCredentialsInvalid if the login isn't 3 to 64 letters, digits, '.', '_', '-' and '@',
+// or the password isn't 8 to 72 bytes long.
+func credentialsOf(credentials models.Credentials) (models.Credentials, error) {
+	login := normalizeLogin(credentials.Login)
+	if length := utf8.RuneCountInString(login); length < minLoginLength || length > maxLoginLength {
+		return models.Credentials{}, fmt.Errorf("%w: login must be %d to %d characters long",
+			models.ErrCredentialsInvalid, minLoginLength, maxLoginLength)
+	}
+	for _, r := range login {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("._-@", r) {
+			return models.Credentials{}, fmt.Errorf("%w: login contains %q", models.ErrCredentialsInvalid, r)
+		}
+	}
+	if len(credentials.Password) < minPasswordLength || len(credentials.Password) > maxPasswordLength {
+		return models.Credentials{}, fmt.Errorf("%w: password must be %d to %d bytes long",
+			models.ErrCredentialsInvalid, minPasswordLength, maxPasswordLength)
+	}
+	return models.Credentials{Login: login, Password: credentials.Password}, nil
+}
@@ -0,0 +1,51 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// AliasPolicy defines which custom aliases can be used as short URLs.
+type AliasPolicy struct {
+	Charset  string   // Charset lists all characters allowed in aliases
+	MinLen   int      // MinLen is the minimal alias length in characters
+	MaxLen   int      // MaxLen is the maximal alias length in characters
+	Reserved []string // Reserved are aliases that collide with the service routes, compared case-insensitively
+}
+
+// NewAliasPolicy creates an AliasPolicy from the configuration values.
+// reserved is a comma separated list of reserved aliases.
+func NewAliasPolicy(charset string, minLen, maxLen int, reserved string) AliasPolicy {
+	policy := AliasPolicy{Charset: charset, MinLen: minLen, MaxLen: maxLen}
+	for _, word := range strings.Split(reserved, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			policy.Reserved = append(policy.Reserved, word)
+		}
+	}
+	return policy
+}
+
+// Validate checks the alias against the policy.
+// It returns an error wrapping models.ErrAliasInvalid if the alias can't be used.
+func (p AliasPolicy) Validate(alias string) error {
+	length := utf8.RuneCountInString(alias)
+	if length < p.MinLen || (p.MaxLen > 0 && length > p.MaxLen) {
+		return fmt.Errorf("%w: length must be from %d to %d characters", models.ErrAliasInvalid, p.MinLen, p.MaxLen)
+	}
+	for _, r := range alias {
+		if !strings.ContainsRune(p.Charset, r) {
+			return fmt.Errorf("%w: character %q is not allowed", models.ErrAliasInvalid, r)
+		}
+	}
+	for _, word := range p.Reserved {
+		if strings.EqualFold(alias, word) {
+			return fmt.Errorf("%w: %q is reserved", models.ErrAliasInvalid, alias)
+		}
+	}
+	return nil
+}
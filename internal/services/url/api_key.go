@@ -0,0 +1,80 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// API keys are formatted as models.APIKeyPrefix, the ID and the secret separated by an underscore,
+// the ID identifies the stored key, only the hash of the secret is stored.
+const (
+	apiKeyIDLength     = 8  // random bytes of the ID
+	apiKeySecretLength = 32 // random bytes of the secret
+	maxAPIKeyName      = 100
+)
+
+// generateAPIKey creates a random API key and returns its ID, the key itself and the hash of its secret.
+func generateAPIKey() (id, key, hash string, err error) {
+	random := make([]byte, apiKeyIDLength+apiKeySecretLength)
+	if _, err = rand.Read(random); err != nil {
+		return "", "", "", fmt.Errorf("generating API key: %w", err)
+	}
+	id = hex.EncodeToString(random[:apiKeyIDLength])
+	secret := hex.EncodeToString(random[apiKeyIDLength:])
+	return id, models.APIKeyPrefix + id + "_" + secret, hashAPIKeySecret(secret), nil
+}
+
+// parseAPIKey splits the API key into its ID and secret.
+// It returns models.ErrAPIKeyInvalid if the key isn't formatted as a generated key.
+func parseAPIKey(key string) (id, secret string, err error) {
+	key, ok := strings.CutPrefix(key, models.APIKeyPrefix)
+	parts := strings.Split(key, "_")
+	if !ok || len(parts) != 2 || len(parts[0]) != 2*apiKeyIDLength || len(parts[1]) != 2*apiKeySecretLength {
+		return "", "", models.ErrAPIKeyInvalid
+	}
+	return parts[0], parts[1], nil
+}
+
+// hashAPIKeySecret returns the hex SHA-256 hash of the secret of an API key.
+// The secret is random, so a fast hash without a salt is enough to keep stolen hashes useless.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyRequestOf validates the request of a new API key and returns it with the name trimmed
+// and the scopes without repeats in the order of the request.
+// It returns models.ErrAPIKeyRequestInvalid if the name is empty or too long, or the scopes are empty or unknown.
+func apiKeyRequestOf(request models.APIKeyRequest) (models.APIKeyRequest, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return models.APIKeyRequest{}, fmt.Errorf("%w: name is empty", models.ErrAPIKeyRequestInvalid)
+	}
+	if utf8.RuneCountInString(name) > maxAPIKeyName {
+		return models.APIKeyRequest{}, fmt.Errorf("%w: name is longer than %d characters", models.ErrAPIKeyRequestInvalid, maxAPIKeyName)
+	}
+	if len(request.Scopes) == 0 {
+		return models.APIKeyRequest{}, fmt.Errorf("%w: scopes are empty", models.ErrAPIKeyRequestInvalid)
+	}
+	scopes := make([]string, 0, len(request.Scopes))
+	seen := make(map[string]struct{}, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		if !models.IsScope(scope) {
+			return models.APIKeyRequest{}, fmt.Errorf("%w: unknown scope %q", models.ErrAPIKeyRequestInvalid, scope)
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		scopes = append(scopes, scope)
+	}
+	return models.APIKeyRequest{Name: name, Scopes: scopes}, nil
+}
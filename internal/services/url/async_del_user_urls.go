@@ -4,27 +4,29 @@ package url
 
 import (
 	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/sync/errgroup"
-	"time"
 )
 
-// AsyncDeleteUserURLs async runs requests to DB for mark user URLs as deleted
-func (s ShortURLServices) AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) error {
-	var g errgroup.Group
-
-	g.Go(func() error {
-		asyncCtx, cancel := context.WithTimeout(ctx, time.Minute)
-		defer cancel()
-		if err := s.repository.MarkURLsAsDeleted(asyncCtx, URLSToDel); err != nil {
-			logrus.Error(err)
-			return err
-		}
-		return nil
-	})
-	// Ожидание завершения работы всех горутин и проверка ошибок
-	if err := g.Wait(); err != nil {
-		return err
+// AsyncDeleteUserURLs queues the deletion of the URLs of the user from the context and returns its job
+// without waiting for the storage. The URLs are marked as deleted by RunDeleteWorkers,
+// the progress of the job is returned by GetDeleteJob.
+// It returns models.ErrDeleteQueueFull if the deletion queue is full.
+func (s ShortURLServices) AsyncDeleteUserURLs(ctx context.Context, URLSToDel []string) (models.DeleteJob, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.DeleteJob{}, errors.New("invalid user context")
+	}
+	if s.deletions == nil {
+		return models.DeleteJob{}, errors.New("deletion queue isn't initialised")
+	}
+	job, err := s.deletions.enqueue(models.URLDeletion{UserID: userID, ShortURLs: URLSToDel})
+	if err != nil {
+		logrus.Error(err)
+		return models.DeleteJob{}, err
 	}
-	return nil
+	return job, nil
 }
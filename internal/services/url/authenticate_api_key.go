@@ -0,0 +1,33 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// AuthenticateAPIKey returns the stored API key matching the key of a request,
+// its UserID is the user the request is made as.
+// It returns models.ErrAPIKeyInvalid if the key is malformed, revoked or its secret doesn't match.
+func (s ShortURLServices) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error) {
+	id, secret, err := parseAPIKey(key)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	apiKey, err := s.repository.GetAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return models.APIKey{}, models.ErrAPIKeyInvalid
+		}
+		logrus.Error(err)
+		return models.APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(apiKey.Hash)) != 1 {
+		return models.APIKey{}, models.ErrAPIKeyInvalid
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,130 @@
+// Package cache provides a read-through cache decorator for the storage of shortened URLs.
+// The decorator wraps any url.Repository, so it belongs to the service layer owning the interface
+// rather than to the repositories implementing it.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// entry is a cached result of the redirect lookup.
+type entry struct {
+	shortURL  string
+	redirect  models.Redirect
+	err       error     // err is set for cached negative results
+	expiresAt time.Time // expiresAt is the time the entry stops being valid
+}
+
+// lru is a bounded cache of lookup results, the least recently used entries are evicted first.
+// The short URLs being loaded are tracked, so a result loaded before the invalidation of its short URL
+// isn't cached, while the loads of other short URLs aren't affected.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List // order keeps entries from the most to the least recently used
+	// loads maps the short URLs being loaded to whether they have been invalidated since the load began
+	loads map[string]bool
+}
+
+// newLRU creates an empty lru holding up to size entries.
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+		loads: make(map[string]bool),
+	}
+}
+
+// get returns the entry of the short URL if it is cached and still valid at now.
+func (c *lru) get(shortURL string, now time.Time) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[shortURL]
+	if !ok {
+		return entry{}, false
+	}
+	e := element.Value.(entry)
+	if !now.Before(e.expiresAt) {
+		c.removeElement(element)
+		return entry{}, false
+	}
+	c.order.MoveToFront(element)
+	return e, true
+}
+
+// beginLoad starts tracking the load of the short URL, it must be ended by finishLoad.
+// Loads of the same short URL mustn't overlap.
+func (c *lru) beginLoad(shortURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loads[shortURL] = false
+}
+
+// finishLoad ends the load of the short URL of the entry and caches the loaded entry if store is set,
+// unless the short URL has been invalidated since the load began.
+func (c *lru) finishLoad(e entry, store bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	invalidated := c.loads[e.shortURL]
+	delete(c.loads, e.shortURL)
+	if store && !invalidated {
+		c.add(e)
+	}
+}
+
+// add caches the entry, evicting the least recently used entry if the cache is full. The caller must hold mu.
+func (c *lru) add(e entry) {
+	if element, ok := c.items[e.shortURL]; ok {
+		element.Value = e
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[e.shortURL] = c.order.PushFront(e)
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// invalidate removes the entries of the short URLs and the results of their loads in progress.
+func (c *lru) invalidate(shortURLs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, shortURL := range shortURLs {
+		if element, ok := c.items[shortURL]; ok {
+			c.removeElement(element)
+		}
+		if _, loading := c.loads[shortURL]; loading {
+			c.loads[shortURL] = true
+		}
+	}
+}
+
+// invalidateAll removes all entries and the results of all loads in progress.
+func (c *lru) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for shortURL := range c.loads {
+		c.loads[shortURL] = true
+	}
+	c.items = make(map[string]*list.Element, c.size)
+	c.order.Init()
+}
+
+// len returns the number of cached entries.
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// removeElement removes the element from the list and the index. The caller must hold mu.
+func (c *lru) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(entry).shortURL)
+}
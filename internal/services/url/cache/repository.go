@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/DenisKhanov/shorterURL/internal/services/url"
+	"golang.org/x/sync/singleflight"
+	"sync/atomic"
+	"time"
+)
+
+// CachedRepository is a url.Repository decorator caching the redirect lookups by short URLs.
+// Found URLs are cached for ttl, but not past their expiration time, missing, deleted and expired URLs
+// are cached for negativeTTL.
+// Concurrent lookups of the same short URL missing in the cache are collapsed into one storage query.
+// Changes of short URLs made through the decorator invalidate their entries immediately,
+// so deleted URLs stop redirecting at once. Other methods are passed to the wrapped repository.
+type CachedRepository struct {
+	url.Repository
+	entries     *lru
+	loads       singleflight.Group
+	ttl         time.Duration
+	negativeTTL time.Duration
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+}
+
+// checking interface compliance at the compiler level
+var _ url.Repository = (*CachedRepository)(nil)
+
+// NewCachedRepository wraps the repository with a cache of up to size lookups.
+func NewCachedRepository(repository url.Repository, size int, ttl, negativeTTL time.Duration) *CachedRepository {
+	return &CachedRepository{
+		Repository:  repository,
+		entries:     newLRU(size),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// isNegative reports whether the lookup error is a stable result worth caching,
+// unlike errors of the storage itself.
+func isNegative(err error) bool {
+	return errors.Is(err, models.ErrOriginalURLNotFound) ||
+		errors.Is(err, models.ErrURLDeleted) ||
+		errors.Is(err, models.ErrURLExpired)
+}
+
+// GetRedirect returns the original URL and the domain of the short URL from the cache,
+// or loads them from the wrapped repository and caches the result.
+func (c *CachedRepository) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	if e, ok := c.entries.get(shortURL, time.Now()); ok {
+		c.hits.Add(1)
+		return e.redirect, e.err
+	}
+	c.misses.Add(1)
+	result, err, _ := c.loads.Do(shortURL, func() (any, error) {
+		// singleflight runs one load of the short URL at a time, so the loads tracked by the cache don't overlap
+		c.entries.beginLoad(shortURL)
+		// the lookup is shared, so it mustn't be canceled together with the first request
+		redirect, err := c.Repository.GetRedirect(context.WithoutCancel(ctx), shortURL)
+		switch {
+		case err == nil:
+			// the link stops redirecting at its expiration time, not when the reaper removes it
+			expiresAt := time.Now().Add(c.ttl)
+			if !redirect.ExpiresAt.IsZero() && redirect.ExpiresAt.Before(expiresAt) {
+				expiresAt = redirect.ExpiresAt
+			}
+			c.entries.finishLoad(entry{shortURL: shortURL, redirect: redirect, expiresAt: expiresAt}, true)
+		case isNegative(err):
+			c.entries.finishLoad(entry{shortURL: shortURL, err: err, expiresAt: time.Now().Add(c.negativeTTL)}, true)
+		default:
+			c.entries.finishLoad(entry{shortURL: shortURL}, false)
+		}
+		return redirect, err
+	})
+	return result.(models.Redirect), err
+}
+
+// StoreURL saves the URL in the wrapped repository and drops the cached negative result of the short URL.
+func (c *CachedRepository) StoreURL(ctx context.Context, originalURL, shortURL string, options models.URLOptions) error {
+	err := c.Repository.StoreURL(ctx, originalURL, shortURL, options)
+	c.entries.invalidate(shortURL)
+	return err
+}
+
+// StoreBatchURL saves the URLs in the wrapped repository and drops the cached negative results of the short URLs.
+func (c *CachedRepository) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string,
+	options map[string]models.URLOptions) error {
+	err := c.Repository.StoreBatchURL(ctx, batchURLtoStores, options)
+	shortURLs := make([]string, 0, len(batchURLtoStores))
+	for shortURL := range batchURLtoStores {
+		shortURLs = append(shortURLs, shortURL)
+	}
+	c.entries.invalidate(shortURLs...)
+	return err
+}
+
+// MarkURLsAsDeleted marks the URLs as deleted in the wrapped repository and drops them from the cache,
+// so they stop redirecting immediately.
+func (c *CachedRepository) MarkURLsAsDeleted(ctx context.Context, deletions []models.URLDeletion) error {
+	err := c.Repository.MarkURLsAsDeleted(ctx, deletions)
+	for _, deletion := range deletions {
+		c.entries.invalidate(deletion.ShortURLs...)
+	}
+	return err
+}
+
+// RestoreURL restores the URL in the wrapped repository and drops the short URL from the cache,
+// so it redirects again immediately.
+func (c *CachedRepository) RestoreURL(ctx context.Context, shortURL string, deletedAfter time.Time) (models.URL, error) {
+	url, err := c.Repository.RestoreURL(ctx, shortURL, deletedAfter)
+	c.entries.invalidate(shortURL)
+	return url, err
+}
+
+// PurgeDeletedURLs removes the deleted URLs from the wrapped repository and clears the cache if any were removed.
+func (c *CachedRepository) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	purged, err := c.Repository.PurgeDeletedURLs(ctx, before)
+	if purged > 0 {
+		c.entries.invalidateAll()
+	}
+	return purged, err
+}
+
+// UpdateURL changes the original URL in the wrapped repository and drops the short URL from the cache,
+// so it redirects to the new original URL immediately.
+func (c *CachedRepository) UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error) {
+	url, err := c.Repository.UpdateURL(ctx, shortURL, originalURL)
+	c.entries.invalidate(shortURL)
+	return url, err
+}
+
+// DeleteExpiredURLs removes the expired URLs from the wrapped repository and clears the cache if any were removed.
+func (c *CachedRepository) DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := c.Repository.DeleteExpiredURLs(ctx, before)
+	if deleted > 0 {
+		c.entries.invalidateAll()
+	}
+	return deleted, err
+}
+
+// GetStats returns the statistics of the wrapped repository with the cache hit and miss counters.
+func (c *CachedRepository) GetStats(ctx context.Context) (models.Stats, error) {
+	stats, err := c.Repository.GetStats(ctx)
+	if err != nil {
+		return stats, err
+	}
+	stats.CacheHits = c.hits.Load()
+	stats.CacheMisses = c.misses.Load()
+	return stats, nil
+}
@@ -0,0 +1,301 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/DenisKhanov/shorterURL/internal/services/url/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	cache := newLRU(2)
+	load := func(e entry) {
+		cache.beginLoad(e.shortURL)
+		cache.finishLoad(e, true)
+	}
+	load(entry{shortURL: "a", redirect: models.Redirect{OriginalURL: "http://a.com"}, expiresAt: now.Add(time.Minute)})
+	load(entry{shortURL: "b", redirect: models.Redirect{OriginalURL: "http://b.com"}, expiresAt: now.Add(time.Minute)})
+	// "a" becomes the most recently used, so "b" is evicted
+	_, ok := cache.get("a", now)
+	require.True(t, ok)
+	load(entry{shortURL: "c", redirect: models.Redirect{OriginalURL: "http://c.com"}, expiresAt: now.Add(time.Minute)})
+	assert.Equal(t, 2, cache.len())
+	_, ok = cache.get("b", now)
+	assert.False(t, ok)
+
+	// the entry isn't returned after its TTL
+	_, ok = cache.get("c", now.Add(time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.len())
+
+	// the result loaded before the invalidation of its short URL isn't cached
+	cache.beginLoad("a")
+	cache.invalidate("a")
+	cache.finishLoad(entry{shortURL: "a", redirect: models.Redirect{OriginalURL: "http://stale.com"}, expiresAt: now.Add(time.Minute)}, true)
+	_, ok = cache.get("a", now)
+	assert.False(t, ok)
+
+	// invalidations of other short URLs don't affect the load
+	cache.beginLoad("d")
+	cache.invalidate("a", "e")
+	cache.finishLoad(entry{shortURL: "d", redirect: models.Redirect{OriginalURL: "http://d.com"}, expiresAt: now.Add(time.Minute)}, true)
+	_, ok = cache.get("d", now)
+	assert.True(t, ok)
+
+	// the result loaded before the whole cache is invalidated isn't cached
+	cache.beginLoad("e")
+	cache.invalidateAll()
+	cache.finishLoad(entry{shortURL: "e", redirect: models.Redirect{OriginalURL: "http://e.com"}, expiresAt: now.Add(time.Minute)}, true)
+	assert.Equal(t, 0, cache.len())
+	assert.Empty(t, cache.loads)
+}
+
+func TestCachedRepository_GetRedirect(t *testing.T) {
+	tests := []struct {
+		name       string
+		repoURL    models.Redirect
+		repoErr    error
+		wantCached bool
+	}{
+		{
+			name:       "found URL is cached",
+			repoURL:    models.Redirect{OriginalURL: "http://original.url", Domain: "go.example.com"},
+			wantCached: true,
+		},
+		{
+			name:       "missing URL is cached",
+			repoErr:    models.ErrOriginalURLNotFound,
+			wantCached: true,
+		},
+		{
+			name:       "deleted URL is cached",
+			repoErr:    models.ErrURLDeleted,
+			wantCached: true,
+		},
+		{
+			name:       "storage error isn't cached",
+			repoErr:    errors.New("connection refused"),
+			wantCached: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			calls := 1
+			if !tt.wantCached {
+				calls = 2
+			}
+			mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(tt.repoURL, tt.repoErr).Times(calls)
+			mockRepo.EXPECT().GetStats(gomock.Any()).Return(models.Stats{CountURLs: 1, CountUsers: 1}, nil)
+
+			repo := NewCachedRepository(mockRepo, 10, time.Minute, time.Minute)
+			for i := 0; i < 2; i++ {
+				redirect, err := repo.GetRedirect(context.Background(), "short")
+				assert.Equal(t, tt.repoURL, redirect)
+				assert.Equal(t, tt.repoErr, err)
+			}
+
+			stats, err := repo.GetStats(context.Background())
+			require.NoError(t, err)
+			wantStats := models.Stats{CountURLs: 1, CountUsers: 1, CacheHits: 1, CacheMisses: 1}
+			if !tt.wantCached {
+				wantStats.CacheHits, wantStats.CacheMisses = 0, 2
+			}
+			assert.Equal(t, wantStats, stats)
+		})
+	}
+}
+
+func TestCachedRepository_ExpiringURL(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	repo := NewCachedRepository(mockRepo, 10, time.Minute, time.Minute)
+
+	// the link expiring before the cache TTL is looked up again after its expiration time
+	expiring := models.Redirect{OriginalURL: "http://original.url", ExpiresAt: time.Now().Add(50 * time.Millisecond)}
+	gomock.InOrder(
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(expiring, nil),
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(models.Redirect{}, models.ErrURLExpired),
+	)
+	for i := 0; i < 2; i++ {
+		redirect, err := repo.GetRedirect(ctx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, expiring, redirect)
+	}
+	time.Sleep(time.Until(expiring.ExpiresAt))
+	for i := 0; i < 2; i++ {
+		_, err := repo.GetRedirect(ctx, "short")
+		assert.ErrorIs(t, err, models.ErrURLExpired)
+	}
+}
+
+func TestCachedRepository_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	repo := NewCachedRepository(mockRepo, 10, time.Minute, time.Minute)
+	deletions := []models.URLDeletion{{UserID: uuid.New(), ShortURLs: []string{"alias"}}}
+
+	// the cached missing alias is dropped when it is stored
+	gomock.InOrder(
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "alias").Return(models.Redirect{}, models.ErrOriginalURLNotFound),
+		mockRepo.EXPECT().StoreURL(gomock.Any(), "http://original.url", "alias", models.URLOptions{}).Return(nil),
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "alias").Return(models.Redirect{OriginalURL: "http://original.url"}, nil),
+		mockRepo.EXPECT().MarkURLsAsDeleted(gomock.Any(), deletions).Return(nil),
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "alias").Return(models.Redirect{}, models.ErrURLDeleted),
+	)
+	_, err := repo.GetRedirect(ctx, "alias")
+	assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+	require.NoError(t, repo.StoreURL(ctx, "http://original.url", "alias", models.URLOptions{}))
+	redirect, err := repo.GetRedirect(ctx, "alias")
+	require.NoError(t, err)
+	assert.Equal(t, "http://original.url", redirect.OriginalURL)
+
+	// the deleted URL stops redirecting immediately
+	require.NoError(t, repo.MarkURLsAsDeleted(ctx, deletions))
+	_, err = repo.GetRedirect(ctx, "alias")
+	assert.ErrorIs(t, err, models.ErrURLDeleted)
+	_, err = repo.GetRedirect(ctx, "alias")
+	assert.ErrorIs(t, err, models.ErrURLDeleted)
+}
+
+func TestCachedRepository_StoreDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	repo := NewCachedRepository(mockRepo, 10, time.Minute, time.Minute)
+
+	// links created while a lookup is in progress don't prevent caching of its result
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").DoAndReturn(
+		func(context.Context, string) (models.Redirect, error) {
+			close(loading)
+			<-release
+			return models.Redirect{OriginalURL: "http://original.url"}, nil
+		}).Times(1)
+	mockRepo.EXPECT().StoreURL(gomock.Any(), "http://other.url", "other", models.URLOptions{}).Return(nil)
+	mockRepo.EXPECT().StoreBatchURL(gomock.Any(), map[string]string{"batch": "http://batch.url"}, nil).Return(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := repo.GetRedirect(ctx, "short")
+		assert.NoError(t, err)
+	}()
+	<-loading
+	require.NoError(t, repo.StoreURL(ctx, "http://other.url", "other", models.URLOptions{}))
+	require.NoError(t, repo.StoreBatchURL(ctx, map[string]string{"batch": "http://batch.url"}, nil))
+	close(release)
+	<-done
+
+	redirect, err := repo.GetRedirect(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, "http://original.url", redirect.OriginalURL)
+}
+
+func TestCachedRepository_UpdateURL(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	repo := NewCachedRepository(mockRepo, 10, time.Minute, time.Minute)
+
+	// the changed short URL redirects to the new original URL immediately
+	gomock.InOrder(
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(models.Redirect{OriginalURL: "http://old.url"}, nil),
+		mockRepo.EXPECT().UpdateURL(gomock.Any(), "short", "http://new.url").
+			Return(models.URL{ShortURL: "short", OriginalURL: "http://new.url"}, nil),
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(models.Redirect{OriginalURL: "http://new.url"}, nil),
+	)
+	redirect, err := repo.GetRedirect(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, "http://old.url", redirect.OriginalURL)
+	_, err = repo.UpdateURL(ctx, "short", "http://new.url")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		redirect, err = repo.GetRedirect(ctx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "http://new.url", redirect.OriginalURL)
+	}
+}
+
+func TestCachedRepository_RestoreURL(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	repo := NewCachedRepository(mockRepo, 10, time.Minute, time.Minute)
+
+	// the restored short URL redirects immediately, the purged one is looked up again
+	gomock.InOrder(
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(models.Redirect{}, models.ErrURLDeleted),
+		mockRepo.EXPECT().RestoreURL(gomock.Any(), "short", gomock.Any()).
+			Return(models.URL{ShortURL: "short", OriginalURL: "http://original.url"}, nil),
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(models.Redirect{OriginalURL: "http://original.url"}, nil),
+		mockRepo.EXPECT().PurgeDeletedURLs(gomock.Any(), gomock.Any()).Return(int64(1), nil),
+		mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(models.Redirect{}, models.ErrOriginalURLNotFound),
+	)
+	_, err := repo.GetRedirect(ctx, "short")
+	assert.ErrorIs(t, err, models.ErrURLDeleted)
+	_, err = repo.RestoreURL(ctx, "short", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		redirect, err := repo.GetRedirect(ctx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "http://original.url", redirect.OriginalURL)
+	}
+	_, err = repo.PurgeDeletedURLs(ctx, time.Now())
+	require.NoError(t, err)
+	_, err = repo.GetRedirect(ctx, "short")
+	assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+}
+
+func TestCachedRepository_ConcurrentMisses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	repo := NewCachedRepository(mockRepo, 10, time.Minute, time.Minute)
+
+	const requests = 10
+	var started sync.WaitGroup
+	started.Add(requests)
+	release := make(chan struct{})
+	mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").DoAndReturn(
+		func(context.Context, string) (models.Redirect, error) {
+			<-release
+			return models.Redirect{OriginalURL: "http://original.url"}, nil
+		}).Times(1)
+
+	var done sync.WaitGroup
+	done.Add(requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			defer done.Done()
+			started.Done()
+			redirect, err := repo.GetRedirect(context.Background(), "short")
+			assert.NoError(t, err)
+			assert.Equal(t, "http://original.url", redirect.OriginalURL)
+		}()
+	}
+	started.Wait()
+	// the lookups are released after all of them have been started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	done.Wait()
+}
@@ -0,0 +1,46 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Settings of the background saving of click events.
+const (
+	clickQueueSize     = 10000           // clickQueueSize is the number of clicks waiting to be saved
+	clickBatchSize     = 500             // clickBatchSize is the max number of clicks saved at once
+	clickFlushInterval = time.Second     // clickFlushInterval is the max time a click waits in an incomplete batch
+	clickFlushTimeout  = 5 * time.Second // clickFlushTimeout limits saving of the last batch on shutdown
+)
+
+// clickWriter queues click events, so that redirects don't wait for the storage.
+// The queue is drained by ShortURLServices.RunClickWriter.
+type clickWriter struct {
+	queue   chan models.Click
+	dropped atomic.Int64
+}
+
+// newClickWriter creates a clickWriter with an empty queue.
+func newClickWriter() *clickWriter {
+	return &clickWriter{queue: make(chan models.Click, clickQueueSize)}
+}
+
+// record queues the click without blocking. If the queue is full, the click is dropped.
+// It does nothing for a nil clickWriter.
+func (w *clickWriter) record(click models.Click) {
+	if w == nil {
+		return
+	}
+	select {
+	case w.queue <- click:
+	default:
+		if dropped := w.dropped.Add(1); dropped%clickQueueSize == 1 {
+			logrus.Warnf("click queue is full, %d clicks dropped", dropped)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// CreateAPIKey creates an API key with the scopes of the request bound to the user from the context.
+// The returned key authenticates requests as the user, it is returned only once and can't be recovered.
+// It returns models.ErrAPIKeyRequestInvalid if the name or the scopes of the request are invalid.
+func (s ShortURLServices) CreateAPIKey(ctx context.Context, request models.APIKeyRequest) (models.NewAPIKey, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.NewAPIKey{}, errors.New("invalid user context")
+	}
+	request, err := apiKeyRequestOf(request)
+	if err != nil {
+		return models.NewAPIKey{}, err
+	}
+	id, key, hash, err := generateAPIKey()
+	if err != nil {
+		logrus.Error(err)
+		return models.NewAPIKey{}, err
+	}
+	apiKey := models.APIKey{
+		ID:        id,
+		Name:      request.Name,
+		Scopes:    request.Scopes,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		UserID:    userID,
+		Hash:      hash,
+	}
+	if err = s.repository.StoreAPIKey(ctx, apiKey); err != nil {
+		logrus.Error(err)
+		return models.NewAPIKey{}, err
+	}
+	return models.NewAPIKey{APIKey: apiKey, Key: key}, nil
+}
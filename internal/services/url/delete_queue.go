@@ -0,0 +1,129 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+)
+
+// Settings of the background deletion of short URLs.
+const (
+	deleteQueueSize    = 1000        // deleteQueueSize is the number of deletion requests waiting to be processed
+	deleteBatchSize    = 100         // deleteBatchSize is the max number of deletion requests marked at once
+	deleteTimeout      = time.Minute // deleteTimeout limits marking of one batch of deletion requests
+	deleteJobRetention = time.Hour   // deleteJobRetention is how long the status of a finished job is kept
+)
+
+// deleteTask is a deletion request waiting in the queue together with the ID of its job.
+type deleteTask struct {
+	jobID    string
+	deletion models.URLDeletion
+}
+
+// deleteJobState is a deletion job together with the user who requested it.
+type deleteJobState struct {
+	job    models.DeleteJob
+	userID uuid.UUID
+}
+
+// deleteQueue queues deletion requests, so that requests don't wait for the storage,
+// and keeps the statuses of their jobs. The queue is drained by ShortURLServices.RunDeleteWorkers.
+// Jobs are kept only in memory: they are lost on restart and forgotten deleteJobRetention after they finish.
+type deleteQueue struct {
+	tasks     chan deleteTask
+	mu        sync.Mutex // guards jobs and lastSweep
+	jobs      map[string]*deleteJobState
+	lastSweep time.Time
+}
+
+// newDeleteQueue creates a deleteQueue with an empty queue.
+func newDeleteQueue() *deleteQueue {
+	return &deleteQueue{
+		tasks: make(chan deleteTask, deleteQueueSize),
+		jobs:  make(map[string]*deleteJobState),
+	}
+}
+
+// enqueue creates a job deleting the short URLs of the user and queues it without blocking.
+// It returns models.ErrDeleteQueueFull if the queue is full.
+func (q *deleteQueue) enqueue(deletion models.URLDeletion) (models.DeleteJob, error) {
+	now := time.Now().UTC()
+	state := &deleteJobState{
+		job:    models.DeleteJob{ID: uuid.NewString(), Status: models.DeleteJobQueued, URLs: len(deletion.ShortURLs), CreatedAt: now},
+		userID: deletion.UserID,
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sweep(now)
+	select {
+	case q.tasks <- deleteTask{jobID: state.job.ID, deletion: deletion}:
+	default:
+		return models.DeleteJob{}, models.ErrDeleteQueueFull
+	}
+	q.jobs[state.job.ID] = state
+	return state.job, nil
+}
+
+// sweep forgets the jobs finished longer than deleteJobRetention ago, at most once a minute.
+// The caller must hold mu.
+func (q *deleteQueue) sweep(now time.Time) {
+	if now.Sub(q.lastSweep) < time.Minute {
+		return
+	}
+	q.lastSweep = now
+	for id, state := range q.jobs {
+		if finishedAt := state.job.FinishedAt; finishedAt != nil && now.Sub(*finishedAt) > deleteJobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// get returns the job of the user.
+// It returns models.ErrDeleteJobNotFound if the job doesn't exist, has been forgotten or belongs to another user.
+func (q *deleteQueue) get(userID uuid.UUID, jobID string) (models.DeleteJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state, ok := q.jobs[jobID]
+	if !ok || state.userID != userID {
+		return models.DeleteJob{}, models.ErrDeleteJobNotFound
+	}
+	return state.job, nil
+}
+
+// setStatus changes the status of the jobs of the tasks. The jobs finished with an error keep its text.
+func (q *deleteQueue) setStatus(tasks []deleteTask, status string, err error) {
+	now := time.Now().UTC()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, task := range tasks {
+		state, ok := q.jobs[task.jobID]
+		if !ok {
+			continue
+		}
+		state.job.Status = status
+		if status == models.DeleteJobDone || status == models.DeleteJobFailed {
+			state.job.FinishedAt = &now
+		}
+		if err != nil {
+			state.job.Error = err.Error()
+		}
+	}
+}
+
+// collect returns the task with the tasks waiting in the queue, up to deleteBatchSize tasks, without blocking.
+func (q *deleteQueue) collect(first deleteTask) []deleteTask {
+	tasks := []deleteTask{first}
+	for len(tasks) < deleteBatchSize {
+		select {
+		case task := <-q.tasks:
+			tasks = append(tasks, task)
+		default:
+			return tasks
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,101 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Domains defines the short domains served by the instance.
+// A domain is named by the host of its base URL. Links of the default domain of BASE_URL
+// are stored with an empty domain, so they keep working when the default domain is changed.
+type Domains struct {
+	defaultURL  string
+	defaultHost string
+	byHost      map[string]string // host of an additional domain -> base URL of its links
+}
+
+// NewDomains creates Domains from the base URL of the default domain
+// and the comma separated list of base URLs of the additional domains.
+// Base URLs that can't be parsed are logged and skipped.
+func NewDomains(baseURL, domains string) Domains {
+	parsedBaseURL, err := url.Parse(baseURL)
+	if err != nil {
+		logrus.Error(err)
+		return Domains{defaultURL: baseURL}
+	}
+	d := Domains{
+		defaultURL:  parsedBaseURL.String(),
+		defaultHost: strings.ToLower(parsedBaseURL.Host),
+		byHost:      make(map[string]string),
+	}
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.TrimSpace(domain); domain == "" {
+			continue
+		}
+		parsed, err := url.Parse(domain)
+		if err != nil || parsed.Host == "" {
+			logrus.Errorf("short domain %q is skipped: base URL must be like https://host", domain)
+			continue
+		}
+		if host := strings.ToLower(parsed.Host); host != d.defaultHost {
+			d.byHost[host] = parsed.String()
+		}
+	}
+	return d
+}
+
+// Select returns the domain links of the request are created on: the explicitly selected domain,
+// or the domain of the request Host if it isn't selected.
+// It returns an error wrapping models.ErrDomainInvalid if the selected domain isn't served.
+func (d Domains) Select(request models.RequestDomain) (string, error) {
+	if request.Domain == "" {
+		return d.ByHost(request.Host), nil
+	}
+	domain := strings.ToLower(request.Domain)
+	if domain == d.defaultHost {
+		return "", nil
+	}
+	if _, ok := d.byHost[domain]; !ok {
+		return "", fmt.Errorf("%w: %s", models.ErrDomainInvalid, request.Domain)
+	}
+	return domain, nil
+}
+
+// ByHost returns the domain of the request Host header. Hosts of no additional domain,
+// for example IP addresses, belong to the default domain.
+func (d Domains) ByHost(host string) string {
+	host = strings.ToLower(host)
+	if _, ok := d.byHost[host]; ok {
+		return host
+	}
+	// the port may be omitted in the base URL of the domain
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		if _, ok := d.byHost[hostname]; ok {
+			return hostname
+		}
+	}
+	return ""
+}
+
+// Served returns the domain if it is served, or the default domain if the domain has been removed from the configuration.
+func (d Domains) Served(domain string) string {
+	if _, ok := d.byHost[domain]; ok {
+		return domain
+	}
+	return ""
+}
+
+// BaseURL returns the base URL of the links of the domain.
+func (d Domains) BaseURL(domain string) string {
+	if baseURL, ok := d.byHost[domain]; ok {
+		return baseURL
+	}
+	return d.defaultURL
+}
@@ -0,0 +1,31 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// urlOptions converts the requested expiration into the options saved with the short URL.
+// Only one of ExpiresAt and TTL can be set, TTL must be positive and ExpiresAt must be in the future.
+// It returns an error wrapping models.ErrExpirationInvalid if the expiration can't be applied.
+func urlOptions(expiration models.Expiration, now time.Time) (models.URLOptions, error) {
+	var options models.URLOptions
+	switch {
+	case !expiration.ExpiresAt.IsZero() && expiration.TTL != 0:
+		return options, fmt.Errorf("%w: only one of expires_at and ttl can be set", models.ErrExpirationInvalid)
+	case expiration.TTL < 0:
+		return options, fmt.Errorf("%w: ttl must be positive", models.ErrExpirationInvalid)
+	case expiration.TTL > 0:
+		options.ExpiresAt = now.Add(expiration.TTL).UTC()
+	case !expiration.ExpiresAt.IsZero():
+		if !expiration.ExpiresAt.After(now) {
+			return options, fmt.Errorf("%w: expires_at must be in the future", models.ErrExpirationInvalid)
+		}
+		options.ExpiresAt = expiration.ExpiresAt.UTC()
+	}
+	return options, nil
+}
@@ -9,9 +9,13 @@ import (
 	"net/url"
 )
 
-// finalURLBuilder the function combines the base url and the shortened url into a single link
-func (s ShortURLServices) finalURLBuilder(shortURL string) string {
-	resultURL, err := url.JoinPath(s.baseURL, shortURL)
+// maxShortURLAttempts is the maximum number of short URLs generated for one original URL
+// when the generated short URLs collide with the already stored ones.
+const maxShortURLAttempts = 5
+
+// finalURLBuilder the function combines the base url of the domain and the shortened url into a single link
+func (s ShortURLServices) finalURLBuilder(domain, shortURL string) string {
+	resultURL, err := url.JoinPath(s.domains.BaseURL(domain), shortURL)
 	if err != nil {
 		logrus.Error(err)
 	}
@@ -0,0 +1,20 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// GetAPIKeys returns the API keys of the user from the context ordered by creation time.
+// The keys themselves aren't returned, they are known only to the clients they were given to.
+func (s ShortURLServices) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	keys, err := s.repository.GetUserAPIKeys(ctx)
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+	return keys, nil
+}
@@ -4,37 +4,149 @@ package url
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
 // GetBatchShortURL takes a slice of models.URLRequest objects, each containing a URL to be shortened,
 // and returns a slice of models.URLResponse objects, each containing the original and shortened URL.
 // This method is intended for processing multiple URLs at once, improving efficiency for bulk operations.
+// Requests with an alias use it as the short URL unless the URL has already been shortened;
+// models.ErrAliasInvalid or models.ErrAliasTaken is returned if any alias can't be used,
+// models.ErrExpirationInvalid if the expiration of any request can't be applied
+// and models.ErrRedirectCodeInvalid if the redirect code of any request isn't supported.
+// All URLs are shortened on the domain selected by the request, models.ErrDomainInvalid is returned
+// if the selected domain isn't served.
+// If a generated short URL is already taken, it is regenerated and the batch is stored again,
+// up to maxShortURLAttempts times.
 // Returns an error if any of the URLs cannot be processed or if an internal error occurs.
-func (s ShortURLServices) GetBatchShortURL(ctx context.Context,
+func (s ShortURLServices) GetBatchShortURL(ctx context.Context, domain models.RequestDomain,
 	batchURLRequests []models.URLRequest) ([]models.URLResponse, error) {
-	shortsURL, err := s.repository.GetShortBatchURL(ctx, batchURLRequests)
+	selected, err := s.domains.Select(domain)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	options := make(map[string]models.URLOptions)
+	for _, value := range batchURLRequests {
+		urlOpts, err := urlOptions(value.Expiration(), now)
+		if err != nil {
+			return nil, err
+		}
+		urlOpts.Domain = selected
+		if urlOpts.RedirectCode, err = s.redirectCodeOf(value.RedirectCode); err != nil {
+			return nil, err
+		}
+		options[value.OriginalURL] = urlOpts
+		if value.Alias == "" {
+			continue
+		}
+		if err = s.aliasPolicy.Validate(value.Alias); err != nil {
+			return nil, err
+		}
+	}
+	shortsURL, err := s.repository.GetShortBatchURL(ctx, selected, batchURLRequests)
 	if err != nil {
 		logrus.Error(err)
 		return nil, err
 	}
-	var batchURLtoStores = make(map[string]string, len(batchURLRequests))
-	var batchURLResponses []models.URLResponse
+	// aliases and generated map original URLs missing in the repository to their new short URLs
+	var aliases = make(map[string]string)
+	var generated = make(map[string]string, len(batchURLRequests))
 	for _, value := range batchURLRequests {
-		if shortURL, ok := shortsURL[value.OriginalURL]; ok {
-			batchURLResponses = append(batchURLResponses, models.URLResponse{CorrelationID: value.CorrelationID, ShortURL: s.finalURLBuilder(shortURL)})
-		} else {
-			shortURL = s.encoder.CryptoBase62Encode()
-			batchURLtoStores[shortURL] = value.OriginalURL
-			batchURLResponses = append(batchURLResponses, models.URLResponse{CorrelationID: value.CorrelationID, ShortURL: s.finalURLBuilder(shortURL)})
+		if _, ok := shortsURL[value.OriginalURL]; ok {
+			continue
+		}
+		if value.Alias != "" {
+			if alias, ok := aliases[value.OriginalURL]; ok && alias != value.Alias {
+				return nil, fmt.Errorf("%w: different aliases for %s", models.ErrAliasInvalid, value.OriginalURL)
+			}
+			aliases[value.OriginalURL] = value.Alias
+			delete(generated, value.OriginalURL)
+			continue
+		}
+		if _, ok := aliases[value.OriginalURL]; ok {
+			continue
+		}
+		if _, ok := generated[value.OriginalURL]; !ok {
+			generated[value.OriginalURL] = s.encoder.CryptoBase62Encode()
 		}
 	}
 
-	err = s.repository.StoreBatchURL(ctx, batchURLtoStores)
-	if err != nil {
+	if err = s.storeBatchWithRetry(ctx, aliases, generated, options); err != nil {
 		logrus.Error(err)
 		return nil, err
 	}
+
+	var batchURLResponses []models.URLResponse
+	for _, value := range batchURLRequests {
+		shortURL, ok := shortsURL[value.OriginalURL]
+		if !ok {
+			if shortURL, ok = aliases[value.OriginalURL]; !ok {
+				shortURL = generated[value.OriginalURL]
+			}
+		}
+		batchURLResponses = append(batchURLResponses, models.URLResponse{CorrelationID: value.CorrelationID, ShortURL: s.finalURLBuilder(selected, shortURL)})
+	}
 	return batchURLResponses, nil
 }
+
+// storeBatchWithRetry stores the new short URLs, given as maps of original to short URLs:
+// aliases are requested by the user and are never changed, generated are produced by the encoder.
+// When a generated short URL is taken, either in the repository or within the batch, it is
+// regenerated in place (all of them if the repository does not say which one) and the batch
+// is stored again. A taken alias results in models.ErrAliasTaken.
+// options maps original URLs to the options saved with their short URLs.
+func (s ShortURLServices) storeBatchWithRetry(ctx context.Context, aliases, generated map[string]string,
+	options map[string]models.URLOptions) error {
+	var err error
+	for attempt := 1; attempt <= maxShortURLAttempts; attempt++ {
+		var conflict *models.ShortURLConflictError
+		var batchURLtoStores = make(map[string]string, len(aliases)+len(generated))
+		for originalURL, alias := range aliases {
+			if _, ok := batchURLtoStores[alias]; ok {
+				return fmt.Errorf("%w: %s", models.ErrAliasTaken, alias)
+			}
+			batchURLtoStores[alias] = originalURL
+		}
+		for originalURL, shortURL := range generated {
+			if _, ok := batchURLtoStores[shortURL]; ok {
+				conflict = &models.ShortURLConflictError{ShortURL: shortURL}
+				generated[originalURL] = s.encoder.CryptoBase62Encode()
+				break
+			}
+			batchURLtoStores[shortURL] = originalURL
+		}
+		if conflict != nil {
+			err = conflict
+			logrus.Warnf("short URL collision in batch, attempt %d of %d: %v", attempt, maxShortURLAttempts, err)
+			continue
+		}
+		if err = s.repository.StoreBatchURL(ctx, batchURLtoStores, options); !errors.As(err, &conflict) {
+			return err
+		}
+		if isAlias(aliases, conflict.ShortURL) {
+			return fmt.Errorf("%w: %s", models.ErrAliasTaken, conflict.ShortURL)
+		}
+		logrus.Warnf("short URL collision in batch, attempt %d of %d: %v", attempt, maxShortURLAttempts, err)
+		for originalURL, shortURL := range generated {
+			if conflict.ShortURL == "" || conflict.ShortURL == shortURL {
+				generated[originalURL] = s.encoder.CryptoBase62Encode()
+			}
+		}
+	}
+	return fmt.Errorf("failed to generate unique short URLs in %d attempts: %w", maxShortURLAttempts, err)
+}
+
+// isAlias reports whether the short URL is one of the requested aliases.
+func isAlias(aliases map[string]string, shortURL string) bool {
+	for _, alias := range aliases {
+		if alias == shortURL {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// GetDeleteJob returns the deletion job of the user from the context.
+// It returns models.ErrDeleteJobNotFound if the job doesn't exist, has been forgotten or belongs to another user.
+func (s ShortURLServices) GetDeleteJob(ctx context.Context, jobID string) (models.DeleteJob, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.DeleteJob{}, errors.New("invalid user context")
+	}
+	if s.deletions == nil {
+		return models.DeleteJob{}, models.ErrDeleteJobNotFound
+	}
+	return s.deletions.get(userID, jobID)
+}
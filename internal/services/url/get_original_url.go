@@ -2,15 +2,29 @@
 // It includes functionality to generate, store, retrieve, and delete URLs.
 package url
 
-import "context"
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"time"
+)
 
-// GetOriginalURL takes a shortened URL and returns the original URL it points to.
+// GetOriginalURL takes a shortened URL requested on the host and returns the original URL it points to
+// together with the HTTP status code of the redirect, models.DefaultRedirectCode for URLs stored without a code.
 // If the shortened URL does not exist or is invalid, an error is returned.
+// A short URL is resolved only on its own domain, on other hosts models.ErrOriginalURLNotFound is returned.
 // Useful for redirecting shortened URLs to their original destinations.
-func (s ShortURLServices) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
-	originURL, err := s.repository.GetOriginalURL(ctx, shortURL)
+// Every successful call queues a click event with the client info to be saved in the background.
+func (s ShortURLServices) GetOriginalURL(ctx context.Context, host, shortURL string, client models.ClickInfo) (models.Redirect, error) {
+	redirect, err := s.repository.GetRedirect(ctx, shortURL)
 	if err != nil {
-		return "", err
+		return models.Redirect{}, err
 	}
-	return originURL, nil
+	if s.domains.Served(redirect.Domain) != s.domains.ByHost(host) {
+		return models.Redirect{}, models.ErrOriginalURLNotFound
+	}
+	if redirect.Code == 0 {
+		redirect.Code = models.DefaultRedirectCode
+	}
+	s.clicks.record(models.Click{ClickInfo: client, ShortURL: shortURL, Timestamp: time.Now().UTC()})
+	return redirect, nil
 }
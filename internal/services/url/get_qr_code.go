@@ -0,0 +1,30 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// GetQRCode renders the QR code image of the full shortened URL requested on the host.
+// The image is generated inside the service with the format, size, margin and error correction level
+// of the request, zero fields take the default values.
+// Like GetOriginalURL, a short URL is resolved only on its own domain and deleted or expired URLs aren't rendered,
+// but no click is recorded.
+// It returns an error wrapping models.ErrQRCodeInvalid if the parameters of the image are invalid.
+func (s ShortURLServices) GetQRCode(ctx context.Context, host, shortURL string, request models.QRRequest) (models.QRCode, error) {
+	options, err := qrOptionsOf(request)
+	if err != nil {
+		return models.QRCode{}, err
+	}
+	redirect, err := s.repository.GetRedirect(ctx, shortURL)
+	if err != nil {
+		return models.QRCode{}, err
+	}
+	if s.domains.Served(redirect.Domain) != s.domains.ByHost(host) {
+		return models.QRCode{}, models.ErrOriginalURLNotFound
+	}
+	return renderQRCode(s.finalURLBuilder(redirect.Domain, shortURL), options)
+}
@@ -4,22 +4,75 @@ package url
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+	"time"
 )
 
-// GetShortURL takes original URL and returns its shortened version.
-// If the URL has already been shortened, it returns the existing shortened URL.
-// If the URL is new, it generates a new shortened URL.
+// GetShortURL takes original URL and an optional custom alias and returns its shortened version.
+// If the URL has already been shortened, it returns the existing shortened URL with models.ErrURLFound,
+// even if an alias is given or the URL has been shortened by a concurrent request.
+// If the URL is new and the alias is given, the alias is validated against the alias policy
+// and used as the short URL; models.ErrAliasTaken is returned if it is already in use.
+// Otherwise, it generates a new shortened URL, regenerating it up to maxShortURLAttempts
+// times if the generated short URL is already taken.
+// The expiration limits the lifetime of a new short URL, models.ErrExpirationInvalid is returned
+// if it can't be applied.
+// The URL is shortened on the domain selected by the request, an original URL shortened on another domain
+// gets a new short URL; models.ErrDomainInvalid is returned if the selected domain isn't served.
+// A new short URL is followed with the redirect code or, if it is 0, with the default code of the service;
+// models.ErrRedirectCodeInvalid is returned if the code isn't a supported redirect.
 // Returns an error if the URL cannot be shortened or if any internal error occurs.
-func (s ShortURLServices) GetShortURL(ctx context.Context, URL string) (string, error) {
-	shortURL, err := s.repository.GetShortURL(ctx, URL)
+func (s ShortURLServices) GetShortURL(ctx context.Context, domain models.RequestDomain, URL, alias string,
+	expiration models.Expiration, redirectCode int) (string, error) {
+	if alias != "" {
+		if err := s.aliasPolicy.Validate(alias); err != nil {
+			return "", err
+		}
+	}
+	options, err := urlOptions(expiration, time.Now())
 	if err != nil {
-		shortURL = s.encoder.CryptoBase62Encode()
-		err = s.repository.StoreURL(ctx, URL, shortURL)
+		return "", err
+	}
+	if options.Domain, err = s.domains.Select(domain); err != nil {
+		return "", err
+	}
+	if options.RedirectCode, err = s.redirectCodeOf(redirectCode); err != nil {
+		return "", err
+	}
+	shortURL, err := s.repository.GetShortURL(ctx, options.Domain, URL)
+	if err == nil {
+		return s.finalURLBuilder(options.Domain, shortURL), models.ErrURLFound
+	}
+	var stored *models.OriginalURLConflictError
+	if alias != "" {
+		err = s.repository.StoreURL(ctx, URL, alias, options)
+		if errors.As(err, &stored) {
+			return s.finalURLBuilder(options.Domain, stored.ShortURL), models.ErrURLFound
+		}
+		if errors.Is(err, models.ErrShortURLConflict) {
+			return "", fmt.Errorf("%w: %s", models.ErrAliasTaken, alias)
+		}
 		if err != nil {
 			return "", err
 		}
-		return s.finalURLBuilder(shortURL), nil
+		return s.finalURLBuilder(options.Domain, alias), nil
+	}
+	for attempt := 1; attempt <= maxShortURLAttempts; attempt++ {
+		shortURL = s.encoder.CryptoBase62Encode()
+		err = s.repository.StoreURL(ctx, URL, shortURL, options)
+		if err == nil {
+			return s.finalURLBuilder(options.Domain, shortURL), nil
+		}
+		if errors.As(err, &stored) {
+			return s.finalURLBuilder(options.Domain, stored.ShortURL), models.ErrURLFound
+		}
+		if !errors.Is(err, models.ErrShortURLConflict) {
+			return "", err
+		}
+		logrus.Warnf("short URL collision, attempt %d of %d: %v", attempt, maxShortURLAttempts, err)
 	}
-	return s.finalURLBuilder(shortURL), models.ErrURLFound
+	return "", fmt.Errorf("failed to generate unique short URL in %d attempts: %w", maxShortURLAttempts, err)
 }
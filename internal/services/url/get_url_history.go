@@ -0,0 +1,21 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// GetURLHistory returns the former original URLs of the short URL owned by the user from the context,
+// the most recently replaced first.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (s ShortURLServices) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	history, err := s.repository.GetURLHistory(ctx, shortURL)
+	if err != nil {
+		logrus.Error(err)
+		return nil, err
+	}
+	return history, nil
+}
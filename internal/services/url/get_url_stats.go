@@ -0,0 +1,21 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// GetURLStats returns the click statistics of the short URL owned by the user from the context.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+func (s ShortURLServices) GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error) {
+	stats, err := s.repository.GetURLStats(ctx, shortURL)
+	if err != nil {
+		logrus.Error(err)
+		return models.URLStats{}, err
+	}
+	stats.ShortURL = s.finalURLBuilder(stats.Domain, shortURL)
+	return stats, nil
+}
@@ -4,21 +4,98 @@ package url
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	"github.com/sirupsen/logrus"
 )
 
-// GetUserURLs takes a slice of models.URL objects for a specific user
-func (s ShortURLServices) GetUserURLs(ctx context.Context) ([]models.URL, error) {
-	userURLS, err := s.repository.GetUserURLs(ctx)
+// Limits of the number of URLs on a page of the user URLs.
+const (
+	DefaultUserURLsLimit = 100  // DefaultUserURLsLimit is used if the request has no limit
+	MaxUserURLsLimit     = 1000 // MaxUserURLsLimit is the max limit, a greater one is reduced to it
+)
+
+// encodeCursor returns the opaque cursor of the position in the user URLs listing.
+func encodeCursor(cursor models.URLCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses the opaque cursor returned by encodeCursor.
+func decodeCursor(cursor string) (*models.URLCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor: %v", models.ErrPageQueryInvalid, err)
+	}
+	var position models.URLCursor
+	if err = json.Unmarshal(data, &position); err != nil || position.ShortURL == "" {
+		return nil, fmt.Errorf("%w: cursor is malformed", models.ErrPageQueryInvalid)
+	}
+	return &position, nil
+}
+
+// userURLsQuery converts the request of a page of the user URLs into the repository query.
+// The query asks for one URL more than the limit to find out whether there is a next page.
+func userURLsQuery(request models.UserURLsRequest) (models.UserURLsQuery, int, error) {
+	var query models.UserURLsQuery
+	limit := request.Limit
+	switch {
+	case limit < 0:
+		return query, 0, fmt.Errorf("%w: limit must not be negative", models.ErrPageQueryInvalid)
+	case limit == 0:
+		limit = DefaultUserURLsLimit
+	case limit > MaxUserURLsLimit:
+		limit = MaxUserURLsLimit
+	}
+	switch request.Sort {
+	case "", models.SortCreatedAsc:
+	case models.SortCreatedDesc:
+		query.Desc = true
+	default:
+		return query, 0, fmt.Errorf("%w: unknown sort order %q", models.ErrPageQueryInvalid, request.Sort)
+	}
+	if request.Cursor != "" {
+		after, err := decodeCursor(request.Cursor)
+		if err != nil {
+			return query, 0, err
+		}
+		query.After = after
+	}
+	query.Limit = limit + 1
+	query.Search = request.Search
+	return query, limit, nil
+}
+
+// GetUserURLs returns a page of the URLs of the user from the context ordered by creation time.
+// The page has up to request.Limit URLs (DefaultUserURLsLimit if it is zero, at most MaxUserURLsLimit),
+// the next page is requested with the same parameters and the NextCursor of the page.
+// It returns models.ErrPageQueryInvalid if the limit, the cursor or the sort order is invalid.
+func (s ShortURLServices) GetUserURLs(ctx context.Context, request models.UserURLsRequest) (models.UserURLsPage, error) {
+	query, limit, err := userURLsQuery(request)
+	if err != nil {
+		return models.UserURLsPage{}, err
+	}
+	userURLS, err := s.repository.GetUserURLs(ctx, query)
 	if err != nil {
 		logrus.Error(err)
-		return nil, err
+		return models.UserURLsPage{}, err
+	}
+	var page models.UserURLsPage
+	if len(userURLS) > limit {
+		userURLS = userURLS[:limit]
+		last := userURLS[limit-1]
+		cursor := models.URLCursor{ShortURL: last.ShortURL}
+		if last.CreatedAt != nil {
+			cursor.CreatedAt = *last.CreatedAt
+		}
+		page.NextCursor = encodeCursor(cursor)
 	}
-	allUserShortURLs := make([]models.URL, len(userURLS))
+	page.URLs = make([]models.URL, len(userURLS))
 	for i, v := range userURLS {
-		allUserShortURLs[i].ShortURL = s.finalURLBuilder(v.ShortURL)
-		allUserShortURLs[i].OriginalURL = v.OriginalURL
+		page.URLs[i] = v
+		page.URLs[i].ShortURL = s.finalURLBuilder(v.Domain, v.ShortURL)
 	}
-	return allUserShortURLs, nil
+	return page, nil
 }
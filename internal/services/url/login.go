@@ -0,0 +1,54 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Login checks the credentials and returns the account. The links of the anonymous user from the context
+// are claimed by the account, so the links created before logging in aren't lost.
+// The links of a user with another account aren't claimed.
+// It returns models.ErrLoginFailed if the login doesn't exist or the password doesn't match.
+func (s ShortURLServices) Login(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.AccountLogin{}, errors.New("invalid user context")
+	}
+	account, err := s.repository.GetAccount(ctx, normalizeLogin(credentials.Login))
+	if err != nil {
+		if errors.Is(err, models.ErrAccountNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(credentials.Password))
+			return models.AccountLogin{}, models.ErrLoginFailed
+		}
+		logrus.Error(err)
+		return models.AccountLogin{}, err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(credentials.Password)); err != nil {
+		return models.AccountLogin{}, models.ErrLoginFailed
+	}
+	login := models.AccountLogin{Account: account}
+	if userID == account.UserID {
+		return login, nil
+	}
+	_, err = s.repository.GetUserAccount(ctx)
+	if err == nil {
+		return login, nil
+	}
+	if !errors.Is(err, models.ErrAccountNotFound) {
+		logrus.Error(err)
+		return models.AccountLogin{}, err
+	}
+	accountCtx := context.WithValue(ctx, models.UserIDKey, account.UserID)
+	if login.ClaimedURLs, err = s.repository.ClaimURLs(accountCtx, userID); err != nil {
+		logrus.Error(err)
+		return models.AccountLogin{}, err
+	}
+	return login, nil
+}
@@ -7,9 +7,11 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/DenisKhanov/shorterURL/internal/models"
 	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
 )
 
 // MockRepository is a mock of Repository interface.
@@ -35,49 +37,123 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// GetOriginalURL mocks base method.
-func (m *MockRepository) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
+// ClaimURLs mocks base method.
+func (m *MockRepository) ClaimURLs(ctx context.Context, fromUserID uuid.UUID) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOriginalURL", ctx, shortURL)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "ClaimURLs", ctx, fromUserID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimURLs indicates an expected call of ClaimURLs.
+func (mr *MockRepositoryMockRecorder) ClaimURLs(ctx, fromUserID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimURLs", reflect.TypeOf((*MockRepository)(nil).ClaimURLs), ctx, fromUserID)
+}
+
+// DeleteAPIKey mocks base method.
+func (m *MockRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIKey indicates an expected call of DeleteAPIKey.
+func (mr *MockRepositoryMockRecorder) DeleteAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockRepository)(nil).DeleteAPIKey), ctx, id)
+}
+
+// DeleteExpiredURLs mocks base method.
+func (m *MockRepository) DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredURLs", ctx, before)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetOriginalURL indicates an expected call of GetOriginalURL.
-func (mr *MockRepositoryMockRecorder) GetOriginalURL(ctx, shortURL interface{}) *gomock.Call {
+// DeleteExpiredURLs indicates an expected call of DeleteExpiredURLs.
+func (mr *MockRepositoryMockRecorder) DeleteExpiredURLs(ctx, before interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOriginalURL", reflect.TypeOf((*MockRepository)(nil).GetOriginalURL), ctx, shortURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredURLs", reflect.TypeOf((*MockRepository)(nil).DeleteExpiredURLs), ctx, before)
+}
+
+// GetAPIKey mocks base method.
+func (m *MockRepository) GetAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, id)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockRepositoryMockRecorder) GetAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockRepository)(nil).GetAPIKey), ctx, id)
+}
+
+// GetAccount mocks base method.
+func (m *MockRepository) GetAccount(ctx context.Context, login string) (models.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", ctx, login)
+	ret0, _ := ret[0].(models.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockRepositoryMockRecorder) GetAccount(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockRepository)(nil).GetAccount), ctx, login)
+}
+
+// GetRedirect mocks base method.
+func (m *MockRepository) GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRedirect", ctx, shortURL)
+	ret0, _ := ret[0].(models.Redirect)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRedirect indicates an expected call of GetRedirect.
+func (mr *MockRepositoryMockRecorder) GetRedirect(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRedirect", reflect.TypeOf((*MockRepository)(nil).GetRedirect), ctx, shortURL)
 }
 
 // GetShortBatchURL mocks base method.
-func (m *MockRepository) GetShortBatchURL(ctx context.Context, batchURLRequests []models.URLRequest) (map[string]string, error) {
+func (m *MockRepository) GetShortBatchURL(ctx context.Context, domain string, batchURLRequests []models.URLRequest) (map[string]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetShortBatchURL", ctx, batchURLRequests)
+	ret := m.ctrl.Call(m, "GetShortBatchURL", ctx, domain, batchURLRequests)
 	ret0, _ := ret[0].(map[string]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetShortBatchURL indicates an expected call of GetShortBatchURL.
-func (mr *MockRepositoryMockRecorder) GetShortBatchURL(ctx, batchURLRequests interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetShortBatchURL(ctx, domain, batchURLRequests interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortBatchURL", reflect.TypeOf((*MockRepository)(nil).GetShortBatchURL), ctx, batchURLRequests)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortBatchURL", reflect.TypeOf((*MockRepository)(nil).GetShortBatchURL), ctx, domain, batchURLRequests)
 }
 
 // GetShortURL mocks base method.
-func (m *MockRepository) GetShortURL(ctx context.Context, originalURL string) (string, error) {
+func (m *MockRepository) GetShortURL(ctx context.Context, domain, originalURL string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetShortURL", ctx, originalURL)
+	ret := m.ctrl.Call(m, "GetShortURL", ctx, domain, originalURL)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetShortURL indicates an expected call of GetShortURL.
-func (mr *MockRepositoryMockRecorder) GetShortURL(ctx, originalURL interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetShortURL(ctx, domain, originalURL interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortURL", reflect.TypeOf((*MockRepository)(nil).GetShortURL), ctx, originalURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShortURL", reflect.TypeOf((*MockRepository)(nil).GetShortURL), ctx, domain, originalURL)
 }
 
 // GetStats mocks base method.
@@ -95,33 +171,93 @@ func (mr *MockRepositoryMockRecorder) GetStats(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockRepository)(nil).GetStats), ctx)
 }
 
+// GetURLHistory mocks base method.
+func (m *MockRepository) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLHistory", ctx, shortURL)
+	ret0, _ := ret[0].([]models.URLChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLHistory indicates an expected call of GetURLHistory.
+func (mr *MockRepositoryMockRecorder) GetURLHistory(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLHistory", reflect.TypeOf((*MockRepository)(nil).GetURLHistory), ctx, shortURL)
+}
+
+// GetURLStats mocks base method.
+func (m *MockRepository) GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLStats", ctx, shortURL)
+	ret0, _ := ret[0].(models.URLStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLStats indicates an expected call of GetURLStats.
+func (mr *MockRepositoryMockRecorder) GetURLStats(ctx, shortURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLStats", reflect.TypeOf((*MockRepository)(nil).GetURLStats), ctx, shortURL)
+}
+
+// GetUserAPIKeys mocks base method.
+func (m *MockRepository) GetUserAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAPIKeys", ctx)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserAPIKeys indicates an expected call of GetUserAPIKeys.
+func (mr *MockRepositoryMockRecorder) GetUserAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAPIKeys", reflect.TypeOf((*MockRepository)(nil).GetUserAPIKeys), ctx)
+}
+
+// GetUserAccount mocks base method.
+func (m *MockRepository) GetUserAccount(ctx context.Context) (models.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAccount", ctx)
+	ret0, _ := ret[0].(models.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserAccount indicates an expected call of GetUserAccount.
+func (mr *MockRepositoryMockRecorder) GetUserAccount(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAccount", reflect.TypeOf((*MockRepository)(nil).GetUserAccount), ctx)
+}
+
 // GetUserURLs mocks base method.
-func (m *MockRepository) GetUserURLs(ctx context.Context) ([]models.URL, error) {
+func (m *MockRepository) GetUserURLs(ctx context.Context, query models.UserURLsQuery) ([]models.URL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserURLs", ctx)
+	ret := m.ctrl.Call(m, "GetUserURLs", ctx, query)
 	ret0, _ := ret[0].([]models.URL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserURLS indicates an expected call of GetUserURLS.
-func (mr *MockRepositoryMockRecorder) GetUserURLS(ctx interface{}) *gomock.Call {
+// GetUserURLs indicates an expected call of GetUserURLs.
+func (mr *MockRepositoryMockRecorder) GetUserURLs(ctx, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURLs", reflect.TypeOf((*MockRepository)(nil).GetUserURLs), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserURLs", reflect.TypeOf((*MockRepository)(nil).GetUserURLs), ctx, query)
 }
 
 // MarkURLsAsDeleted mocks base method.
-func (m *MockRepository) MarkURLsAsDeleted(ctx context.Context, URLSToDel []string) error {
+func (m *MockRepository) MarkURLsAsDeleted(ctx context.Context, deletions []models.URLDeletion) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MarkURLsAsDeleted", ctx, URLSToDel)
+	ret := m.ctrl.Call(m, "MarkURLsAsDeleted", ctx, deletions)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // MarkURLsAsDeleted indicates an expected call of MarkURLsAsDeleted.
-func (mr *MockRepositoryMockRecorder) MarkURLsAsDeleted(ctx, URLSToDel interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) MarkURLsAsDeleted(ctx, deletions interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkURLsAsDeleted", reflect.TypeOf((*MockRepository)(nil).MarkURLsAsDeleted), ctx, URLSToDel)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkURLsAsDeleted", reflect.TypeOf((*MockRepository)(nil).MarkURLsAsDeleted), ctx, deletions)
 }
 
 // Ping mocks base method.
@@ -138,104 +274,325 @@ func (mr *MockRepositoryMockRecorder) Ping(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockRepository)(nil).Ping), ctx)
 }
 
+// PurgeDeletedURLs mocks base method.
+func (m *MockRepository) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedURLs", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedURLs indicates an expected call of PurgeDeletedURLs.
+func (mr *MockRepositoryMockRecorder) PurgeDeletedURLs(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedURLs", reflect.TypeOf((*MockRepository)(nil).PurgeDeletedURLs), ctx, before)
+}
+
+// RestoreURL mocks base method.
+func (m *MockRepository) RestoreURL(ctx context.Context, shortURL string, deletedAfter time.Time) (models.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreURL", ctx, shortURL, deletedAfter)
+	ret0, _ := ret[0].(models.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreURL indicates an expected call of RestoreURL.
+func (mr *MockRepositoryMockRecorder) RestoreURL(ctx, shortURL, deletedAfter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreURL", reflect.TypeOf((*MockRepository)(nil).RestoreURL), ctx, shortURL, deletedAfter)
+}
+
+// StoreAPIKey mocks base method.
+func (m *MockRepository) StoreAPIKey(ctx context.Context, key models.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreAPIKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreAPIKey indicates an expected call of StoreAPIKey.
+func (mr *MockRepositoryMockRecorder) StoreAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreAPIKey", reflect.TypeOf((*MockRepository)(nil).StoreAPIKey), ctx, key)
+}
+
+// StoreAccount mocks base method.
+func (m *MockRepository) StoreAccount(ctx context.Context, account models.Account) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreAccount", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreAccount indicates an expected call of StoreAccount.
+func (mr *MockRepositoryMockRecorder) StoreAccount(ctx, account interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreAccount", reflect.TypeOf((*MockRepository)(nil).StoreAccount), ctx, account)
+}
+
 // StoreBatchURL mocks base method.
-func (m *MockRepository) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string) error {
+func (m *MockRepository) StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string, options map[string]models.URLOptions) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StoreBatchURL", ctx, batchURLtoStores)
+	ret := m.ctrl.Call(m, "StoreBatchURL", ctx, batchURLtoStores, options)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // StoreBatchURL indicates an expected call of StoreBatchURL.
-func (mr *MockRepositoryMockRecorder) StoreBatchURL(ctx, batchURLtoStores interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) StoreBatchURL(ctx, batchURLtoStores, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreBatchURL", reflect.TypeOf((*MockRepository)(nil).StoreBatchURL), ctx, batchURLtoStores, options)
+}
+
+// StoreClicks mocks base method.
+func (m *MockRepository) StoreClicks(ctx context.Context, clicks []models.Click) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreClicks", ctx, clicks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreClicks indicates an expected call of StoreClicks.
+func (mr *MockRepositoryMockRecorder) StoreClicks(ctx, clicks interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreBatchURL", reflect.TypeOf((*MockRepository)(nil).StoreBatchURL), ctx, batchURLtoStores)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreClicks", reflect.TypeOf((*MockRepository)(nil).StoreClicks), ctx, clicks)
 }
 
 // StoreURL mocks base method.
-func (m *MockRepository) StoreURL(ctx context.Context, originalURL, shortURL string) error {
+func (m *MockRepository) StoreURL(ctx context.Context, originalURL, shortURL string, options models.URLOptions) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StoreURL", ctx, originalURL, shortURL)
+	ret := m.ctrl.Call(m, "StoreURL", ctx, originalURL, shortURL, options)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // StoreURL indicates an expected call of StoreURL.
-func (mr *MockRepositoryMockRecorder) StoreURL(ctx, originalURL, shortURL interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) StoreURL(ctx, originalURL, shortURL, options interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreURL", reflect.TypeOf((*MockRepository)(nil).StoreURL), ctx, originalURL, shortURL)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreURL", reflect.TypeOf((*MockRepository)(nil).StoreURL), ctx, originalURL, shortURL, options)
 }
 
-// MockEncoder is a mock of Encoder interface.
-type MockEncoder struct {
+// UpdateURL mocks base method.
+func (m *MockRepository) UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURL", ctx, shortURL, originalURL)
+	ret0, _ := ret[0].(models.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateURL indicates an expected call of UpdateURL.
+func (mr *MockRepositoryMockRecorder) UpdateURL(ctx, shortURL, originalURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURL", reflect.TypeOf((*MockRepository)(nil).UpdateURL), ctx, shortURL, originalURL)
+}
+
+// MockInMemoryRepository is a mock of InMemoryRepository interface.
+type MockInMemoryRepository struct {
 	ctrl     *gomock.Controller
-	recorder *MockEncoderMockRecorder
+	recorder *MockInMemoryRepositoryMockRecorder
 }
 
-// MockEncoderMockRecorder is the mock recorder for MockEncoder.
-type MockEncoderMockRecorder struct {
-	mock *MockEncoder
+// MockInMemoryRepositoryMockRecorder is the mock recorder for MockInMemoryRepository.
+type MockInMemoryRepositoryMockRecorder struct {
+	mock *MockInMemoryRepository
 }
 
-// NewMockEncoder creates a new mock instance.
-func NewMockEncoder(ctrl *gomock.Controller) *MockEncoder {
-	mock := &MockEncoder{ctrl: ctrl}
-	mock.recorder = &MockEncoderMockRecorder{mock}
+// NewMockInMemoryRepository creates a new mock instance.
+func NewMockInMemoryRepository(ctrl *gomock.Controller) *MockInMemoryRepository {
+	mock := &MockInMemoryRepository{ctrl: ctrl}
+	mock.recorder = &MockInMemoryRepositoryMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockEncoder) EXPECT() *MockEncoderMockRecorder {
+func (m *MockInMemoryRepository) EXPECT() *MockInMemoryRepositoryMockRecorder {
 	return m.recorder
 }
 
-// CryptoBase62Encode mocks base method.
-func (m *MockEncoder) CryptoBase62Encode() string {
+// RunCompaction mocks base method.
+func (m *MockInMemoryRepository) RunCompaction(ctx context.Context, interval time.Duration) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CryptoBase62Encode")
-	ret0, _ := ret[0].(string)
+	m.ctrl.Call(m, "RunCompaction", ctx, interval)
+}
+
+// RunCompaction indicates an expected call of RunCompaction.
+func (mr *MockInMemoryRepositoryMockRecorder) RunCompaction(ctx, interval interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunCompaction", reflect.TypeOf((*MockInMemoryRepository)(nil).RunCompaction), ctx, interval)
+}
+
+// RunFlusher mocks base method.
+func (m *MockInMemoryRepository) RunFlusher(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunFlusher", ctx)
+}
+
+// RunFlusher indicates an expected call of RunFlusher.
+func (mr *MockInMemoryRepositoryMockRecorder) RunFlusher(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunFlusher", reflect.TypeOf((*MockInMemoryRepository)(nil).RunFlusher), ctx)
+}
+
+// SaveBatchToFile mocks base method.
+func (m *MockInMemoryRepository) SaveBatchToFile() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveBatchToFile")
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CryptoBase62Encode indicates an expected call of CryptoBase62Encode.
-func (mr *MockEncoderMockRecorder) CryptoBase62Encode() *gomock.Call {
+// SaveBatchToFile indicates an expected call of SaveBatchToFile.
+func (mr *MockInMemoryRepositoryMockRecorder) SaveBatchToFile() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CryptoBase62Encode", reflect.TypeOf((*MockEncoder)(nil).CryptoBase62Encode))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatchToFile", reflect.TypeOf((*MockInMemoryRepository)(nil).SaveBatchToFile))
 }
 
-// MockURLInMemoryRepository is a mock of URLInMemoryRepository interface.
-type MockURLInMemoryRepository struct {
+// MockTransferRepository is a mock of TransferRepository interface.
+type MockTransferRepository struct {
 	ctrl     *gomock.Controller
-	recorder *MockURLInMemoryRepositoryMockRecorder
+	recorder *MockTransferRepositoryMockRecorder
 }
 
-// MockURLInMemoryRepositoryMockRecorder is the mock recorder for MockURLInMemoryRepository.
-type MockURLInMemoryRepositoryMockRecorder struct {
-	mock *MockURLInMemoryRepository
+// MockTransferRepositoryMockRecorder is the mock recorder for MockTransferRepository.
+type MockTransferRepositoryMockRecorder struct {
+	mock *MockTransferRepository
 }
 
-// NewMockURLInMemoryRepository creates a new mock instance.
-func NewMockURLInMemoryRepository(ctrl *gomock.Controller) *MockURLInMemoryRepository {
-	mock := &MockURLInMemoryRepository{ctrl: ctrl}
-	mock.recorder = &MockURLInMemoryRepositoryMockRecorder{mock}
+// NewMockTransferRepository creates a new mock instance.
+func NewMockTransferRepository(ctrl *gomock.Controller) *MockTransferRepository {
+	mock := &MockTransferRepository{ctrl: ctrl}
+	mock.recorder = &MockTransferRepositoryMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockURLInMemoryRepository) EXPECT() *MockURLInMemoryRepositoryMockRecorder {
+func (m *MockTransferRepository) EXPECT() *MockTransferRepositoryMockRecorder {
 	return m.recorder
 }
 
-// SaveBatchToFile mocks base method.
-func (m *MockURLInMemoryRepository) SaveBatchToFile() error {
+// ExportAPIKeys mocks base method.
+func (m *MockTransferRepository) ExportAPIKeys(ctx context.Context, fn func(models.APIKey) error) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveBatchToFile")
+	ret := m.ctrl.Call(m, "ExportAPIKeys", ctx, fn)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SaveBatchToFile indicates an expected call of SaveBatchToFile.
-func (mr *MockURLInMemoryRepositoryMockRecorder) SaveBatchToFile() *gomock.Call {
+// ExportAPIKeys indicates an expected call of ExportAPIKeys.
+func (mr *MockTransferRepositoryMockRecorder) ExportAPIKeys(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatchToFile", reflect.TypeOf((*MockURLInMemoryRepository)(nil).SaveBatchToFile))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportAPIKeys", reflect.TypeOf((*MockTransferRepository)(nil).ExportAPIKeys), ctx, fn)
+}
+
+// ExportAccounts mocks base method.
+func (m *MockTransferRepository) ExportAccounts(ctx context.Context, fn func(models.Account) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportAccounts", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportAccounts indicates an expected call of ExportAccounts.
+func (mr *MockTransferRepositoryMockRecorder) ExportAccounts(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportAccounts", reflect.TypeOf((*MockTransferRepository)(nil).ExportAccounts), ctx, fn)
+}
+
+// ExportURLs mocks base method.
+func (m *MockTransferRepository) ExportURLs(ctx context.Context, fn func(models.URLRecord) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportURLs", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportURLs indicates an expected call of ExportURLs.
+func (mr *MockTransferRepositoryMockRecorder) ExportURLs(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportURLs", reflect.TypeOf((*MockTransferRepository)(nil).ExportURLs), ctx, fn)
+}
+
+// ImportAPIKeys mocks base method.
+func (m *MockTransferRepository) ImportAPIKeys(ctx context.Context, keys []models.APIKey, dryRun bool) (models.ImportItemsReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportAPIKeys", ctx, keys, dryRun)
+	ret0, _ := ret[0].(models.ImportItemsReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportAPIKeys indicates an expected call of ImportAPIKeys.
+func (mr *MockTransferRepositoryMockRecorder) ImportAPIKeys(ctx, keys, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportAPIKeys", reflect.TypeOf((*MockTransferRepository)(nil).ImportAPIKeys), ctx, keys, dryRun)
+}
+
+// ImportAccounts mocks base method.
+func (m *MockTransferRepository) ImportAccounts(ctx context.Context, accounts []models.Account, dryRun bool) (models.ImportItemsReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportAccounts", ctx, accounts, dryRun)
+	ret0, _ := ret[0].(models.ImportItemsReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportAccounts indicates an expected call of ImportAccounts.
+func (mr *MockTransferRepositoryMockRecorder) ImportAccounts(ctx, accounts, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportAccounts", reflect.TypeOf((*MockTransferRepository)(nil).ImportAccounts), ctx, accounts, dryRun)
+}
+
+// ImportURLs mocks base method.
+func (m *MockTransferRepository) ImportURLs(ctx context.Context, records []models.URLRecord, dryRun bool) (models.ImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportURLs", ctx, records, dryRun)
+	ret0, _ := ret[0].(models.ImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportURLs indicates an expected call of ImportURLs.
+func (mr *MockTransferRepositoryMockRecorder) ImportURLs(ctx, records, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportURLs", reflect.TypeOf((*MockTransferRepository)(nil).ImportURLs), ctx, records, dryRun)
+}
+
+// MockEncoder is a mock of Encoder interface.
+type MockEncoder struct {
+	ctrl     *gomock.Controller
+	recorder *MockEncoderMockRecorder
+}
+
+// MockEncoderMockRecorder is the mock recorder for MockEncoder.
+type MockEncoderMockRecorder struct {
+	mock *MockEncoder
+}
+
+// NewMockEncoder creates a new mock instance.
+func NewMockEncoder(ctrl *gomock.Controller) *MockEncoder {
+	mock := &MockEncoder{ctrl: ctrl}
+	mock.recorder = &MockEncoderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEncoder) EXPECT() *MockEncoderMockRecorder {
+	return m.recorder
+}
+
+// CryptoBase62Encode mocks base method.
+func (m *MockEncoder) CryptoBase62Encode() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CryptoBase62Encode")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CryptoBase62Encode indicates an expected call of CryptoBase62Encode.
+func (mr *MockEncoderMockRecorder) CryptoBase62Encode() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CryptoBase62Encode", reflect.TypeOf((*MockEncoder)(nil).CryptoBase62Encode))
 }
@@ -0,0 +1,152 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/skip2/go-qrcode"
+)
+
+// Default and limit values of the QR code images.
+const (
+	defaultQRSize   = 256 // width and height of the image in pixels
+	maxQRSize       = 2048
+	defaultQRMargin = 4 // quiet zone required by the QR code specification, in modules
+	maxQRMargin     = 16
+)
+
+// qrLevels maps the names of the error correction levels to the levels of the encoder.
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// qrOptions are the validated parameters of a QR code image.
+type qrOptions struct {
+	format string
+	size   int
+	margin int
+	level  qrcode.RecoveryLevel
+}
+
+// qrOptionsOf fills the zero fields of the request with the default values and validates it.
+// It returns an error wrapping models.ErrQRCodeInvalid if a parameter is out of range.
+func qrOptionsOf(request models.QRRequest) (qrOptions, error) {
+	options := qrOptions{
+		format: strings.ToLower(request.Format),
+		size:   request.Size,
+		margin: defaultQRMargin,
+		level:  qrcode.Medium,
+	}
+	switch options.format {
+	case "":
+		options.format = models.QRFormatPNG
+	case models.QRFormatPNG, models.QRFormatSVG:
+	default:
+		return qrOptions{}, fmt.Errorf("%w: format %q, must be png or svg", models.ErrQRCodeInvalid, request.Format)
+	}
+	if options.size == 0 {
+		options.size = defaultQRSize
+	}
+	if options.size < 0 || options.size > maxQRSize {
+		return qrOptions{}, fmt.Errorf("%w: size %d, must be from 1 to %d", models.ErrQRCodeInvalid, request.Size, maxQRSize)
+	}
+	if request.Margin != nil {
+		options.margin = *request.Margin
+	}
+	if options.margin < 0 || options.margin > maxQRMargin {
+		return qrOptions{}, fmt.Errorf("%w: margin %d, must be from 0 to %d", models.ErrQRCodeInvalid, options.margin, maxQRMargin)
+	}
+	if request.Level != "" {
+		level, ok := qrLevels[strings.ToUpper(request.Level)]
+		if !ok {
+			return qrOptions{}, fmt.Errorf("%w: level %q, must be L, M, Q or H", models.ErrQRCodeInvalid, request.Level)
+		}
+		options.level = level
+	}
+	return options, nil
+}
+
+// renderQRCode encodes the content into a QR code and renders it as the image described by the options.
+// The image is square, the code with its margin is scaled to fill it. A PNG code is scaled by whole pixels
+// per module and centered, so it may have a slightly wider margin than requested.
+// It returns an error wrapping models.ErrQRCodeInvalid if the image is too small to draw every module.
+func renderQRCode(content string, options qrOptions) (models.QRCode, error) {
+	code, err := qrcode.New(content, options.level)
+	if err != nil {
+		return models.QRCode{}, err
+	}
+	code.DisableBorder = true
+	modules := code.Bitmap()
+	if width := len(modules) + 2*options.margin; options.size < width {
+		return models.QRCode{}, fmt.Errorf("%w: size %d is less than the %d modules of the code with its margin",
+			models.ErrQRCodeInvalid, options.size, width)
+	}
+	if options.format == models.QRFormatSVG {
+		return models.QRCode{ContentType: "image/svg+xml", Data: qrSVG(modules, options)}, nil
+	}
+	data, err := qrPNG(modules, options)
+	if err != nil {
+		return models.QRCode{}, err
+	}
+	return models.QRCode{ContentType: "image/png", Data: data}, nil
+}
+
+// qrPNG draws the modules of the code as a two-color PNG image.
+func qrPNG(modules [][]bool, options qrOptions) ([]byte, error) {
+	width := len(modules) + 2*options.margin
+	scale := options.size / width
+	offset := (options.size-scale*width)/2 + scale*options.margin
+	img := image.NewPaletted(image.Rect(0, 0, options.size, options.size), color.Palette{color.White, color.Black})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := offset + y*scale; py < offset+(y+1)*scale; py++ {
+				for px := offset + x*scale; px < offset+(x+1)*scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// qrSVG draws the modules of the code as an SVG image, a view box of one unit per module is scaled to the size.
+// Horizontal runs of dark modules are joined into single rectangles of one path.
+func qrSVG(modules [][]bool, options qrOptions) []byte {
+	width := len(modules) + 2*options.margin
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		options.size, options.size, width, width)
+	fmt.Fprint(&buf, `<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+options.margin, y+options.margin, run, run)
+			x += run
+		}
+	}
+	fmt.Fprint(&buf, `"/></svg>`)
+	return buf.Bytes()
+}
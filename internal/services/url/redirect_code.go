@@ -0,0 +1,22 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"fmt"
+
+	"github.com/DenisKhanov/shorterURL/internal/models"
+)
+
+// redirectCodeOf returns the HTTP status code a new short URL is followed with:
+// the requested code or, if it is 0, the default code of the service.
+// It returns an error wrapping models.ErrRedirectCodeInvalid if the requested code isn't a supported redirect.
+func (s ShortURLServices) redirectCodeOf(requested int) (int, error) {
+	if requested == 0 {
+		return s.redirectCode, nil
+	}
+	if !models.IsRedirectCode(requested) {
+		return 0, fmt.Errorf("%w: %d, must be 301, 302, 307 or 308", models.ErrRedirectCodeInvalid, requested)
+	}
+	return requested, nil
+}
@@ -0,0 +1,54 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"errors"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+// Register creates an account with the credentials. The account is created for the anonymous user from the context,
+// so the links, API keys and deletion jobs of the user stay with the account. If the user from the context
+// has an account already, the new account is created for a new user.
+// It returns models.ErrCredentialsInvalid if the login or the password don't meet the requirements
+// and models.ErrLoginTaken if another account has the login.
+func (s ShortURLServices) Register(ctx context.Context, credentials models.Credentials) (models.AccountLogin, error) {
+	userID, ok := ctx.Value(models.UserIDKey).(uuid.UUID)
+	if !ok {
+		logrus.Errorf("context value is not userID: %v", userID)
+		return models.AccountLogin{}, errors.New("invalid user context")
+	}
+	credentials, err := credentialsOf(credentials)
+	if err != nil {
+		return models.AccountLogin{}, err
+	}
+	_, err = s.repository.GetUserAccount(ctx)
+	switch {
+	case err == nil:
+		userID = uuid.New()
+	case !errors.Is(err, models.ErrAccountNotFound):
+		logrus.Error(err)
+		return models.AccountLogin{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), passwordCost)
+	if err != nil {
+		logrus.Error(err)
+		return models.AccountLogin{}, err
+	}
+	account := models.Account{
+		UserID:       userID,
+		Login:        credentials.Login,
+		CreatedAt:    time.Now().UTC().Truncate(time.Microsecond),
+		PasswordHash: string(hash),
+	}
+	if err = s.repository.StoreAccount(ctx, account); err != nil {
+		logrus.Error(err)
+		return models.AccountLogin{}, err
+	}
+	return models.AccountLogin{Account: account}, nil
+}
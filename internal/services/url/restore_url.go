@@ -0,0 +1,24 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// RestoreURL clears the deleted flag of the short URL owned by the user from the context and returns the URL,
+// so it redirects again. Only URLs deleted within the restore period can be restored.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user
+// and models.ErrRestorePeriodOver if the restore period is over.
+func (s ShortURLServices) RestoreURL(ctx context.Context, shortURL string) (models.URL, error) {
+	url, err := s.repository.RestoreURL(ctx, shortURL, time.Now().Add(-s.restorePeriod))
+	if err != nil {
+		logrus.Error(err)
+		return models.URL{}, err
+	}
+	url.ShortURL = s.finalURLBuilder(url.Domain, shortURL)
+	return url, nil
+}
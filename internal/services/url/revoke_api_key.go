@@ -0,0 +1,18 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+// RevokeAPIKey removes the API key of the user from the context, requests with the key are rejected afterwards.
+// It returns models.ErrAPIKeyNotFound if the key doesn't exist or belongs to another user.
+func (s ShortURLServices) RevokeAPIKey(ctx context.Context, id string) error {
+	if err := s.repository.DeleteAPIKey(ctx, id); err != nil {
+		logrus.Error(err)
+		return err
+	}
+	return nil
+}
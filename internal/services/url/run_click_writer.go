@@ -0,0 +1,58 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// RunClickWriter saves queued click events to the repository in batches until ctx is done.
+// A batch is saved when it is full or clickFlushInterval after the previous save.
+// When ctx is done, the clicks left in the queue are saved before returning.
+func (s ShortURLServices) RunClickWriter(ctx context.Context) {
+	if s.clicks == nil {
+		return
+	}
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+	batch := make([]models.Click, 0, clickBatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.repository.StoreClicks(ctx, batch); err != nil {
+			logrus.WithError(err).Errorf("Error saving %d clicks", len(batch))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case click := <-s.clicks.queue:
+			batch = append(batch, click)
+			if len(batch) >= clickBatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			// ctx is already canceled, so the rest is saved with a separate deadline
+			flushCtx, cancel := context.WithTimeout(context.Background(), clickFlushTimeout)
+			defer cancel()
+			for {
+				select {
+				case click := <-s.clicks.queue:
+					batch = append(batch, click)
+					if len(batch) >= clickBatchSize {
+						flush(flushCtx)
+					}
+				default:
+					flush(flushCtx)
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// RunDeleteWorkers marks queued deletion requests as deleted with the pool of workers until ctx is done.
+// Every worker takes the requests of many users waiting in the queue, up to deleteBatchSize of them,
+// and marks them with one repository call. When ctx is done, the requests left in the queue
+// are processed before returning, so the caller must stop accepting new requests first.
+// A non-positive number of workers runs one worker.
+func (s ShortURLServices) RunDeleteWorkers(ctx context.Context, workers int) {
+	if s.deletions == nil {
+		return
+	}
+	workers = max(workers, 1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			s.runDeleteWorker(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// runDeleteWorker processes batches of the deletion queue until ctx is done and the queue is empty.
+func (s ShortURLServices) runDeleteWorker(ctx context.Context) {
+	for {
+		select {
+		case task := <-s.deletions.tasks:
+			s.deleteBatch(ctx, s.deletions.collect(task))
+		case <-ctx.Done():
+			for {
+				select {
+				case task := <-s.deletions.tasks:
+					s.deleteBatch(ctx, s.deletions.collect(task))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deleteBatch marks the short URLs of the tasks as deleted and updates the statuses of their jobs.
+func (s ShortURLServices) deleteBatch(ctx context.Context, tasks []deleteTask) {
+	s.deletions.setStatus(tasks, models.DeleteJobRunning, nil)
+	deletions := make([]models.URLDeletion, 0, len(tasks))
+	for _, task := range tasks {
+		deletions = append(deletions, task.deletion)
+	}
+	// the batch is finished even if ctx is canceled on shutdown
+	deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deleteTimeout)
+	defer cancel()
+	if err := s.repository.MarkURLsAsDeleted(deleteCtx, deletions); err != nil {
+		logrus.WithError(err).Errorf("Error deleting URLs of %d jobs", len(tasks))
+		s.deletions.setStatus(tasks, models.DeleteJobFailed, err)
+		return
+	}
+	s.deletions.setStatus(tasks, models.DeleteJobDone, nil)
+}
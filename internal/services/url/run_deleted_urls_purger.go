@@ -0,0 +1,38 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// RunDeletedURLsPurger removes deleted short URLs from the repository every interval until ctx is done.
+// URLs are removed only after the restore period is over, so until then they can be restored by RestoreURL.
+// A non-positive interval disables the removal.
+func (s ShortURLServices) RunDeletedURLsPurger(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		logrus.Info("Removal of deleted URLs is disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purgeCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			purged, err := s.repository.PurgeDeletedURLs(purgeCtx, time.Now().Add(-s.restorePeriod))
+			cancel()
+			if err != nil {
+				logrus.WithError(err).Error("Error purging deleted URLs")
+				continue
+			}
+			if purged > 0 {
+				logrus.Infof("Purged %d deleted URLs", purged)
+			}
+		}
+	}
+}
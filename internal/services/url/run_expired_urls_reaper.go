@@ -0,0 +1,39 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// RunExpiredURLsReaper removes expired short URLs from the repository every interval until ctx is done.
+// URLs are removed only after they have been expired for longer than retention, so in the meantime
+// they keep answering with models.ErrURLExpired instead of being reported as not found.
+// A non-positive interval disables the removal.
+func (s ShortURLServices) RunExpiredURLsReaper(ctx context.Context, interval, retention time.Duration) {
+	if interval <= 0 {
+		logrus.Info("Removal of expired URLs is disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reapCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			deleted, err := s.repository.DeleteExpiredURLs(reapCtx, time.Now().Add(-retention))
+			cancel()
+			if err != nil {
+				logrus.WithError(err).Error("Error deleting expired URLs")
+				continue
+			}
+			if deleted > 0 {
+				logrus.Infof("Deleted %d expired URLs", deleted)
+			}
+		}
+	}
+}
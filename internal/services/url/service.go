@@ -6,8 +6,8 @@ import (
 	"context"
 	"github.com/DenisKhanov/shorterURL/internal/models"
 	url2 "github.com/DenisKhanov/shorterURL/internal/repositories/url"
-	"github.com/sirupsen/logrus"
-	"net/url"
+	"github.com/google/uuid"
+	"time"
 )
 
 // Repository defines the interface for interacting with the storage backend.
@@ -16,34 +16,113 @@ import (
 type Repository interface {
 	// Ping checks the database connection or repository created.
 	Ping(ctx context.Context) error
-	// StoreURL saves a mapping between an original URL and its shortened version in the database.
+	// StoreURL saves a mapping between an original URL and its shortened version with its options in the database.
 	// It returns an error if the saving process fails.
-	StoreURL(ctx context.Context, originalURL, shortURL string) error
-	// GetShortURL retrieves the shortened version of a given original URL from the database.
+	StoreURL(ctx context.Context, originalURL, shortURL string, options models.URLOptions) error
+	// GetShortURL retrieves the shortened version of a given original URL on the domain from the database.
 	// It returns the shortened URL and any error encountered during the retrieval.
-	GetShortURL(ctx context.Context, originalURL string) (string, error)
-	// GetOriginalURL retrieves the original URL corresponding to a given shortened URL from the database.
-	// It returns the original URL and any error encountered during the retrieval.
-	GetOriginalURL(ctx context.Context, shortURL string) (string, error)
+	GetShortURL(ctx context.Context, domain, originalURL string) (string, error)
+	// GetRedirect retrieves the original URL and the domain of a given shortened URL from the database.
+	// It returns models.ErrOriginalURLNotFound if the short URL doesn't exist, models.ErrURLDeleted if it is deleted
+	// and models.ErrURLExpired if the short URL has expired.
+	GetRedirect(ctx context.Context, shortURL string) (models.Redirect, error)
 	// StoreBatchURL saves multiple URL mappings in the database in a batch operation.
-	// The input is a map where keys are shortened URLs and values are the corresponding original URLs.
+	// The input is a map where keys are shortened URLs and values are the corresponding original URLs,
+	// and a map of original URLs to their options including the domain; URLs missing in options are saved
+	// with the zero options on the default domain.
 	// It returns an error if the batch saving process fails.
-	StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string) error
-	// GetShortBatchURL retrieves multiple shortened URLs corresponding to a batch of original URLs from the database.
+	StoreBatchURL(ctx context.Context, batchURLtoStores map[string]string, options map[string]models.URLOptions) error
+	// GetShortBatchURL retrieves multiple shortened URLs on the domain corresponding to a batch of original URLs from the database.
 	// The input is a slice of URLRequest objects containing original URLs.
 	//  It returns found in database a map of original URLs to their shortened counterparts and any error encountered during the retrieval.
-	GetShortBatchURL(ctx context.Context, batchURLRequests []models.URLRequest) (map[string]string, error)
-	// GetUserURLs takes a slice of models.URL objects for a specific user from DB
-	GetUserURLs(ctx context.Context) ([]models.URL, error)
-	// MarkURLsAsDeleted marks user URLs as deleted in DB
-	MarkURLsAsDeleted(ctx context.Context, URLSToDel []string) error
+	GetShortBatchURL(ctx context.Context, domain string, batchURLRequests []models.URLRequest) (map[string]string, error)
+	// GetUserURLs returns the URLs of the user from the context selected by the query,
+	// ordered by creation time, URLs created at the same time are ordered by short URL.
+	GetUserURLs(ctx context.Context, query models.UserURLsQuery) ([]models.URL, error)
+	// MarkURLsAsDeleted marks the short URLs of the deletions of many users as deleted at once
+	// and records when they were deleted. Only the short URLs owned by the user of a deletion are marked.
+	MarkURLsAsDeleted(ctx context.Context, deletions []models.URLDeletion) error
+	// RestoreURL clears the deleted flag of the short URL owned by the user from the context and returns the URL.
+	// A short URL that isn't deleted is returned unchanged.
+	// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user
+	// and models.ErrRestorePeriodOver if it was deleted at or before deletedAfter.
+	RestoreURL(ctx context.Context, shortURL string, deletedAfter time.Time) (models.URL, error)
+	// PurgeDeletedURLs removes short URLs deleted before the given time and returns how many were removed.
+	// Short URLs deleted before deletion times were recorded are removed as well.
+	PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error)
 	// GetStats retrieves the statistics of URLs and users from the database.
 	GetStats(ctx context.Context) (models.Stats, error)
+	// DeleteExpiredURLs removes short URLs expired before the given time and returns how many were removed.
+	DeleteExpiredURLs(ctx context.Context, before time.Time) (int64, error)
+	// StoreClicks saves click events of short URLs.
+	StoreClicks(ctx context.Context, clicks []models.Click) error
+	// GetURLStats returns the total and daily clicks of the short URL owned by the user from the context.
+	// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+	GetURLStats(ctx context.Context, shortURL string) (models.URLStats, error)
+	// UpdateURL changes the original URL of the short URL owned by the user from the context
+	// and saves the replaced original URL in the change history. It returns the updated URL.
+	// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user,
+	// models.ErrURLDeleted or models.ErrURLExpired if it can't be followed anymore
+	// and models.ErrOriginalURLTaken if another link on the domain leads to the original URL.
+	UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error)
+	// GetURLHistory returns the former original URLs of the short URL owned by the user from the context,
+	// the most recently replaced first.
+	// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user.
+	GetURLHistory(ctx context.Context, shortURL string) ([]models.URLChange, error)
+	// StoreAPIKey saves the API key bound to its user.
+	StoreAPIKey(ctx context.Context, key models.APIKey) error
+	// GetAPIKey returns the API key by its ID to authenticate a request.
+	// It returns models.ErrAPIKeyNotFound if the key doesn't exist.
+	GetAPIKey(ctx context.Context, id string) (models.APIKey, error)
+	// GetUserAPIKeys returns the API keys of the user from the context ordered by creation time.
+	GetUserAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// DeleteAPIKey removes the API key of the user from the context, so it can't authenticate requests anymore.
+	// It returns models.ErrAPIKeyNotFound if the key doesn't exist or belongs to another user.
+	DeleteAPIKey(ctx context.Context, id string) error
+	// StoreAccount saves the account of its user.
+	// It returns models.ErrLoginTaken if another account has the login.
+	StoreAccount(ctx context.Context, account models.Account) error
+	// GetAccount returns the account with the login.
+	// It returns models.ErrAccountNotFound if the account doesn't exist.
+	GetAccount(ctx context.Context, login string) (models.Account, error)
+	// GetUserAccount returns the account of the user from the context.
+	// It returns models.ErrAccountNotFound if the user hasn't registered.
+	GetUserAccount(ctx context.Context) (models.Account, error)
+	// ClaimURLs moves the short URLs of the user fromUserID to the user from the context
+	// together with their deleted flags and history, and returns how many were moved.
+	ClaimURLs(ctx context.Context, fromUserID uuid.UUID) (int64, error)
 }
 
 // InMemoryRepository defines the interface for an in-memory repository to save batch data to a file.
 type InMemoryRepository interface {
 	SaveBatchToFile() error
+	// RunFlusher periodically saves the buffered changes to the storage file until ctx is done.
+	RunFlusher(ctx context.Context)
+	// RunCompaction periodically rewrites the storage file without deleted and outdated records until ctx is done.
+	RunCompaction(ctx context.Context, interval time.Duration)
+}
+
+// TransferRepository defines the interface of a repository whose URLs can be moved to another storage
+// together with their owners and deleted flags, and with the API keys and the accounts of the users.
+type TransferRepository interface {
+	// ExportURLs calls fn for every stored short URL until fn returns an error.
+	ExportURLs(ctx context.Context, fn func(record models.URLRecord) error) error
+	// ImportURLs saves the records keeping their short URLs, owners and deleted flags.
+	// Records of already stored links and records contradicting stored links are skipped and counted in the report.
+	// In a dry run the records are only checked.
+	ImportURLs(ctx context.Context, records []models.URLRecord, dryRun bool) (models.ImportReport, error)
+	// ExportAPIKeys calls fn for every stored API key with its user and hash until fn returns an error.
+	ExportAPIKeys(ctx context.Context, fn func(key models.APIKey) error) error
+	// ImportAPIKeys saves the API keys keeping their IDs, users and hashes.
+	// Keys already stored and keys whose IDs are taken by other keys are skipped and counted in the report.
+	// In a dry run the keys are only checked.
+	ImportAPIKeys(ctx context.Context, keys []models.APIKey, dryRun bool) (models.ImportItemsReport, error)
+	// ExportAccounts calls fn for every stored account with its password hash until fn returns an error.
+	ExportAccounts(ctx context.Context, fn func(account models.Account) error) error
+	// ImportAccounts saves the accounts keeping their logins, users and password hashes.
+	// Accounts already stored and accounts whose logins are taken or whose users have other accounts
+	// are skipped and counted in the report. In a dry run the accounts are only checked.
+	ImportAccounts(ctx context.Context, accounts []models.Account, dryRun bool) (models.ImportItemsReport, error)
 }
 
 // Encoder defines the interface for encoding unique short URLs.
@@ -54,25 +133,45 @@ type Encoder interface {
 // checking interface compliance at the compiler level
 var _ Repository = (*url2.URLInMemoryRepo)(nil)
 var _ Repository = (*url2.URLInDBRepo)(nil)
+var _ Repository = (*url2.URLInBoltRepo)(nil)
+var _ InMemoryRepository = (*url2.URLInMemoryRepo)(nil)
+var _ TransferRepository = (*url2.URLInMemoryRepo)(nil)
+var _ TransferRepository = (*url2.URLInDBRepo)(nil)
+var _ TransferRepository = (*url2.URLInBoltRepo)(nil)
 
 // ShortURLServices represents the service for managing shortened URLs.
 type ShortURLServices struct {
-	repository Repository
-	save       InMemoryRepository
-	encoder    Encoder
-	baseURL    string
+	repository  Repository
+	save        InMemoryRepository
+	encoder     Encoder
+	domains     Domains
+	aliasPolicy AliasPolicy
+	clicks      *clickWriter
+	deletions   *deleteQueue
+	// restorePeriod is how long deleted URLs can be restored before they are purged
+	restorePeriod time.Duration
+	// redirectCode is the HTTP status code new short URLs are followed with unless another code is requested
+	redirectCode int
 }
 
 // NewShortURLServices creates a new instance of ShortURLServices.
-// It takes a repository for data storage, an encoder for generating short URLs, and a base URL.
-func NewShortURLServices(repository Repository, encoder Encoder, baseURL string) *ShortURLServices {
-	parsedBaseURL, err := url.Parse(baseURL)
-	if err != nil {
-		logrus.Error(err)
+// It takes a repository for data storage, an encoder for generating short URLs, the served short domains
+// a policy for validating custom aliases, how long deleted URLs can be restored and the HTTP status code
+// new short URLs are followed with by default, 0 for models.DefaultRedirectCode.
+// Click events are queued until RunClickWriter saves them, deletions are queued until RunDeleteWorkers marks them.
+func NewShortURLServices(repository Repository, encoder Encoder, domains Domains, aliasPolicy AliasPolicy,
+	restorePeriod time.Duration, redirectCode int) *ShortURLServices {
+	if redirectCode == 0 {
+		redirectCode = models.DefaultRedirectCode
 	}
 	return &ShortURLServices{
-		repository: repository,
-		encoder:    encoder,
-		baseURL:    parsedBaseURL.String(),
+		repository:    repository,
+		encoder:       encoder,
+		domains:       domains,
+		aliasPolicy:   aliasPolicy,
+		clicks:        newClickWriter(),
+		deletions:     newDeleteQueue(),
+		restorePeriod: restorePeriod,
+		redirectCode:  redirectCode,
 	}
 }
@@ -1,12 +1,23 @@
 package url
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"github.com/DenisKhanov/shorterURL/internal/models"
+	url2 "github.com/DenisKhanov/shorterURL/internal/repositories/url"
 	"github.com/DenisKhanov/shorterURL/internal/services/url/mocks"
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"image"
+	"image/png"
+	"net/http"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -17,7 +28,7 @@ func TestNewService(t *testing.T) {
 	mockRepo := mocks.NewMockRepository(ctrl)
 	mockEncoder := mocks.NewMockEncoder(ctrl)
 	baseURL := "http://localhost:8080"
-	service := NewShortURLServices(mockRepo, mockEncoder, baseURL)
+	service := NewShortURLServices(mockRepo, mockEncoder, NewDomains(baseURL, ""), AliasPolicy{}, 0, 0)
 	if service.repository != mockRepo {
 		t.Errorf("Expected repository to be set, got %v", service.repository)
 	}
@@ -51,11 +62,11 @@ func TestGetBatchShortURL(t *testing.T) {
 					"http://example1.com": "short1",
 					"http://example2.com": "short2",
 				}
-				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), gomock.Eq([]models.URLRequest{
+				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), "", gomock.Eq([]models.URLRequest{
 					{CorrelationID: "1", OriginalURL: "http://example1.com"},
 					{CorrelationID: "2", OriginalURL: "http://example2.com"},
 				})).Return(shortsURL, nil).AnyTimes()
-				mockRepo.EXPECT().StoreBatchURL(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+				mockRepo.EXPECT().StoreBatchURL(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
 			},
 		},
 		{
@@ -72,13 +83,13 @@ func TestGetBatchShortURL(t *testing.T) {
 			storeError:      nil,
 			mockSetup: func(mockRepo *mocks.MockRepository, mockEncoder *mocks.MockEncoder) {
 				shortsURL := map[string]string{}
-				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), gomock.Eq([]models.URLRequest{
+				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), "", gomock.Eq([]models.URLRequest{
 					{CorrelationID: "3", OriginalURL: "http://example3.com"},
 					{CorrelationID: "4", OriginalURL: "http://example4.com"},
 				})).Return(shortsURL, nil).AnyTimes()
 				mockEncoder.EXPECT().CryptoBase62Encode().Return("short3").AnyTimes().Times(1)
 				mockEncoder.EXPECT().CryptoBase62Encode().Return("short4").AnyTimes().Times(1)
-				mockRepo.EXPECT().StoreBatchURL(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+				mockRepo.EXPECT().StoreBatchURL(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 			},
 		},
 		{
@@ -90,7 +101,7 @@ func TestGetBatchShortURL(t *testing.T) {
 			repositoryError:   errors.New("database error"),
 			storeError:        nil,
 			mockSetup: func(mockRepo *mocks.MockRepository, mockEncoder *mocks.MockEncoder) {
-				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), gomock.Any()).Return(nil, errors.New("database error")).AnyTimes()
+				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), "", gomock.Any()).Return(nil, errors.New("database error")).AnyTimes()
 			},
 		},
 		{
@@ -103,11 +114,11 @@ func TestGetBatchShortURL(t *testing.T) {
 			storeError:        errors.New("storage error"),
 			mockSetup: func(mockRepo *mocks.MockRepository, mockEncoder *mocks.MockEncoder) {
 				shortsURL := map[string]string{}
-				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), gomock.Any()).Return(shortsURL, nil).AnyTimes()
+				mockRepo.EXPECT().GetShortBatchURL(gomock.Any(), "", gomock.Any()).Return(shortsURL, nil).AnyTimes()
 				mockEncoder.EXPECT().CryptoBase62Encode().Return("short6").AnyTimes()
 				mockRepo.EXPECT().StoreBatchURL(gomock.Any(), gomock.Eq(map[string]string{
 					"short6": "http://example6.com",
-				})).Return(errors.New("storage error")).AnyTimes()
+				}), gomock.Any()).Return(errors.New("storage error")).AnyTimes()
 			},
 		},
 	}
@@ -120,8 +131,8 @@ func TestGetBatchShortURL(t *testing.T) {
 			mockEncoder := mocks.NewMockEncoder(ctrl)
 			tt.mockSetup(mockRepo, mockEncoder)
 
-			service := ShortURLServices{repository: mockRepo, encoder: mockEncoder, baseURL: "http://localhost:8080"}
-			responses, err := service.GetBatchShortURL(context.Background(), tt.batchURLRequests)
+			service := ShortURLServices{repository: mockRepo, encoder: mockEncoder, domains: NewDomains("http://localhost:8080", "")}
+			responses, err := service.GetBatchShortURL(context.Background(), models.RequestDomain{}, tt.batchURLRequests)
 
 			if tt.expectedResponses != nil {
 				assert.NotNil(t, responses)
@@ -156,7 +167,7 @@ func TestServices_GetShortURL(t *testing.T) {
 			originalURL:      "http://original.url",
 			expectedShortURL: "http://localhost:8080/shortURL",
 			mockSetup: func(mockRepo *mocks.MockRepository, mockEncoder *mocks.MockEncoder) {
-				mockRepo.EXPECT().GetShortURL(gomock.Any(), "http://original.url").Return("shortURL", nil).AnyTimes()
+				mockRepo.EXPECT().GetShortURL(gomock.Any(), "", "http://original.url").Return("shortURL", nil).AnyTimes()
 			},
 		},
 		{
@@ -164,9 +175,9 @@ func TestServices_GetShortURL(t *testing.T) {
 			originalURL:      "http://original.url",
 			expectedShortURL: "http://localhost:8080/shortURL",
 			mockSetup: func(mockRepo *mocks.MockRepository, mockEncoder *mocks.MockEncoder) {
-				mockRepo.EXPECT().GetShortURL(context.Background(), "http://original.url").Return("", errors.New("short URL not found")).AnyTimes()
+				mockRepo.EXPECT().GetShortURL(context.Background(), "", "http://original.url").Return("", errors.New("short URL not found")).AnyTimes()
 				mockEncoder.EXPECT().CryptoBase62Encode().Return("shortURL").AnyTimes()
-				mockRepo.EXPECT().StoreURL(gomock.Any(), "http://original.url", "shortURL").Return(nil).AnyTimes()
+				mockRepo.EXPECT().StoreURL(gomock.Any(), "http://original.url", "shortURL", models.URLOptions{}).Return(nil).AnyTimes()
 			},
 		},
 		{
@@ -175,9 +186,9 @@ func TestServices_GetShortURL(t *testing.T) {
 			originalURL:      "http://original.url",
 			expectedShortURL: "",
 			mockSetup: func(mockRepo *mocks.MockRepository, mockEncoder *mocks.MockEncoder) {
-				mockRepo.EXPECT().GetShortURL(context.Background(), "http://original.url").Return("", errors.New("short URL not found")).AnyTimes()
+				mockRepo.EXPECT().GetShortURL(context.Background(), "", "http://original.url").Return("", errors.New("short URL not found")).AnyTimes()
 				mockEncoder.EXPECT().CryptoBase62Encode().Return("shortURL").AnyTimes()
-				mockRepo.EXPECT().StoreURL(gomock.Any(), "http://original.url", "shortURL").Return(errors.New("error saving shortUrl")).AnyTimes()
+				mockRepo.EXPECT().StoreURL(gomock.Any(), "http://original.url", "shortURL", models.URLOptions{}).Return(errors.New("error saving shortUrl")).AnyTimes()
 			},
 		},
 	}
@@ -188,8 +199,8 @@ func TestServices_GetShortURL(t *testing.T) {
 			mockRepo := mocks.NewMockRepository(ctrl)
 			mockEncoder := mocks.NewMockEncoder(ctrl)
 			tt.mockSetup(mockRepo, mockEncoder)
-			service := ShortURLServices{repository: mockRepo, encoder: mockEncoder, baseURL: "http://localhost:8080"}
-			result, err := service.GetShortURL(context.Background(), tt.originalURL)
+			service := ShortURLServices{repository: mockRepo, encoder: mockEncoder, domains: NewDomains("http://localhost:8080", "")}
+			result, err := service.GetShortURL(context.Background(), models.RequestDomain{}, tt.originalURL, "", models.Expiration{}, 0)
 			if tt.name == "ShortURL found in repository" {
 				assert.Equal(t, tt.expectedShortURL, result)
 				assert.EqualError(t, err, "short URL found in database")
@@ -208,50 +219,113 @@ func TestServices_GetShortURL(t *testing.T) {
 
 }
 
-func TestGetUserURLS(t *testing.T) {
-	// Создаем моки
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+func TestServices_GetShortURL_StoredConcurrently(t *testing.T) {
+	// the original URL is shortened by another request between the lookup and the store
+	for _, alias := range []string{"", "my-link"} {
+		t.Run("alias "+alias, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockEncoder := mocks.NewMockEncoder(ctrl)
+			shortURL := alias
+			if alias == "" {
+				shortURL = "generated"
+				mockEncoder.EXPECT().CryptoBase62Encode().Return(shortURL)
+			}
+			mockRepo.EXPECT().GetShortURL(gomock.Any(), "", "http://original.url").Return("", errors.New("short URL not found"))
+			mockRepo.EXPECT().StoreURL(gomock.Any(), "http://original.url", shortURL, models.URLOptions{}).
+				Return(&models.OriginalURLConflictError{ShortURL: "stored"})
+			service := ShortURLServices{repository: mockRepo, encoder: mockEncoder, domains: NewDomains("http://localhost:8080", ""),
+				aliasPolicy: NewAliasPolicy("abcdefghijklmnopqrstuvwxyz-", 3, 16, "")}
 
-	mockRepo := mocks.NewMockRepository(ctrl)
-	mockEncoder := mocks.NewMockEncoder(ctrl)
+			result, err := service.GetShortURL(context.Background(), models.RequestDomain{}, "http://original.url", alias,
+				models.Expiration{}, 0)
+			assert.ErrorIs(t, err, models.ErrURLFound)
+			assert.Equal(t, "http://localhost:8080/stored", result)
+		})
+	}
+}
 
-	// Создаем экземпляр сервиса
-	shortURLService := NewShortURLServices(mockRepo, mockEncoder, "http://localhost:8080")
+func TestGetUserURLS(t *testing.T) {
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cursor := encodeCursor(models.URLCursor{CreatedAt: createdAt, ShortURL: "short1"})
 
 	// Подготовим тестовые случаи
 	testCases := []struct {
 		name           string
+		request        models.UserURLsRequest
+		expectedQuery  models.UserURLsQuery
 		userURLSFromDB []models.URL
-		expectedOutput []models.URL
+		expectedOutput models.UserURLsPage
 		expectedError  error
 	}{
 		{
-			name: "Success case",
+			name:          "Success case",
+			expectedQuery: models.UserURLsQuery{Limit: DefaultUserURLsLimit + 1},
 			userURLSFromDB: []models.URL{
 				{ShortURL: "short1", OriginalURL: "http://example1.com"},
 				{ShortURL: "short2", OriginalURL: "http://example2.com"},
 			},
-			expectedOutput: []models.URL{
+			expectedOutput: models.UserURLsPage{URLs: []models.URL{
 				{ShortURL: "http://localhost:8080/short1", OriginalURL: "http://example1.com"},
 				{ShortURL: "http://localhost:8080/short2", OriginalURL: "http://example2.com"},
+			}},
+		},
+		{
+			name:          "Page with next cursor",
+			request:       models.UserURLsRequest{Limit: 1, Sort: models.SortCreatedDesc, Search: "example"},
+			expectedQuery: models.UserURLsQuery{Limit: 2, Desc: true, Search: "example"},
+			userURLSFromDB: []models.URL{
+				{ShortURL: "short1", OriginalURL: "http://example1.com", CreatedAt: &createdAt},
+				{ShortURL: "short2", OriginalURL: "http://example2.com", CreatedAt: &createdAt},
+			},
+			expectedOutput: models.UserURLsPage{
+				URLs:       []models.URL{{ShortURL: "http://localhost:8080/short1", OriginalURL: "http://example1.com", CreatedAt: &createdAt}},
+				NextCursor: cursor,
 			},
-			expectedError: nil,
+		},
+		{
+			name:           "Next page by cursor with reduced limit",
+			request:        models.UserURLsRequest{Limit: MaxUserURLsLimit + 1, Cursor: cursor},
+			expectedQuery:  models.UserURLsQuery{Limit: MaxUserURLsLimit + 1, After: &models.URLCursor{CreatedAt: createdAt, ShortURL: "short1"}},
+			userURLSFromDB: []models.URL{},
+			expectedOutput: models.UserURLsPage{URLs: []models.URL{}},
+		},
+		{
+			name:          "Negative limit",
+			request:       models.UserURLsRequest{Limit: -1},
+			expectedError: models.ErrPageQueryInvalid,
+		},
+		{
+			name:          "Unknown sort order",
+			request:       models.UserURLsRequest{Sort: "original_url"},
+			expectedError: models.ErrPageQueryInvalid,
+		},
+		{
+			name:          "Malformed cursor",
+			request:       models.UserURLsRequest{Cursor: "not a cursor"},
+			expectedError: models.ErrPageQueryInvalid,
 		},
 	}
 
 	// Пройдемся по каждому тестовому случаю
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			// Создаем моки
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			shortURLService := NewShortURLServices(mockRepo, mocks.NewMockEncoder(ctrl), NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+
 			// Устанавливаем ожидания моков
-			mockRepo.EXPECT().GetUserURLS(gomock.Any()).Return(tc.userURLSFromDB, tc.expectedError).AnyTimes()
+			if tc.expectedError == nil {
+				mockRepo.EXPECT().GetUserURLs(gomock.Any(), tc.expectedQuery).Return(tc.userURLSFromDB, nil)
+			}
 
 			// Вызываем тестируемый метод
-			actualOutput, actualError := shortURLService.GetUserURLs(context.Background())
+			actualOutput, actualError := shortURLService.GetUserURLs(context.Background(), tc.request)
 
 			// Проверяем результаты
-			assert.Equal(t, tc.expectedError, actualError)
-
+			assert.ErrorIs(t, actualError, tc.expectedError)
 			if tc.expectedError == nil {
 				assert.Equal(t, tc.expectedOutput, actualOutput)
 			}
@@ -261,25 +335,34 @@ func TestGetUserURLS(t *testing.T) {
 
 func TestServices_GetOriginalURL(t *testing.T) {
 	tests := []struct {
-		name                string
-		shortURL            string
-		expectedOriginalURL string
-		mockSetup           func(mockRepo *mocks.MockRepository)
+		name             string
+		shortURL         string
+		expectedRedirect models.Redirect
+		mockSetup        func(mockRepo *mocks.MockRepository)
 	}{
 		{
-			name:                "OriginalURL found in repository",
-			shortURL:            "shortURL",
-			expectedOriginalURL: "http://original.url",
+			name:             "OriginalURL found in repository",
+			shortURL:         "shortURL",
+			expectedRedirect: models.Redirect{OriginalURL: "http://original.url", Code: models.DefaultRedirectCode},
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{OriginalURL: "http://original.url"}, nil).AnyTimes()
+			},
+		},
+		{
+			name:             "redirect code of the URL",
+			shortURL:         "shortURL",
+			expectedRedirect: models.Redirect{OriginalURL: "http://original.url", Code: http.StatusPermanentRedirect},
 			mockSetup: func(mockRepo *mocks.MockRepository) {
-				mockRepo.EXPECT().GetOriginalURL(gomock.Any(), "shortURL").Return("http://original.url", nil).AnyTimes()
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").
+					Return(models.Redirect{OriginalURL: "http://original.url", Code: http.StatusPermanentRedirect}, nil)
 			},
 		},
 		{
-			name:                "OriginalURL not found in repository",
-			shortURL:            "shortURL",
-			expectedOriginalURL: "",
+			name:             "OriginalURL not found in repository",
+			shortURL:         "shortURL",
+			expectedRedirect: models.Redirect{},
 			mockSetup: func(mockRepo *mocks.MockRepository) {
-				mockRepo.EXPECT().GetOriginalURL(gomock.Any(), "shortURL").Return("", errors.New("original URL not found")).AnyTimes()
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{}, errors.New("original URL not found")).AnyTimes()
 			},
 		},
 	}
@@ -289,18 +372,160 @@ func TestServices_GetOriginalURL(t *testing.T) {
 			defer ctrl.Finish()
 			mockRepo := mocks.NewMockRepository(ctrl)
 			tt.mockSetup(mockRepo)
-			service := ShortURLServices{repository: mockRepo, baseURL: "http://localhost:8080"}
-			result, err := service.GetOriginalURL(context.Background(), tt.shortURL)
+			service := ShortURLServices{repository: mockRepo, domains: NewDomains("http://localhost:8080", "")}
+			result, err := service.GetOriginalURL(context.Background(), "", tt.shortURL, models.ClickInfo{})
 			if tt.name == "OriginalURL not found in repository" {
 				assert.EqualError(t, err, "original URL not found")
 			} else {
 				assert.NoError(t, err)
 			}
-			assert.Equal(t, tt.expectedOriginalURL, result)
+			assert.Equal(t, tt.expectedRedirect, result)
+		})
+	}
+}
+
+func TestServices_GetQRCode(t *testing.T) {
+	margin := 0
+	badMargin := 20
+	tests := []struct {
+		name        string
+		host        string
+		request     models.QRRequest
+		mockSetup   func(mockRepo *mocks.MockRepository)
+		contentType string
+		size        int
+		wantErr     error
+	}{
+		{
+			name:    "default PNG",
+			request: models.QRRequest{},
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{OriginalURL: "http://original.url"}, nil)
+			},
+			contentType: "image/png",
+			size:        defaultQRSize,
+		},
+		{
+			name:    "PNG without margin",
+			request: models.QRRequest{Format: "PNG", Size: 100, Margin: &margin, Level: "h"},
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{OriginalURL: "http://original.url"}, nil)
+			},
+			contentType: "image/png",
+			size:        100,
+		},
+		{
+			name:    "SVG",
+			request: models.QRRequest{Format: models.QRFormatSVG, Size: 512, Level: "L"},
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{OriginalURL: "http://original.url"}, nil)
+			},
+			contentType: "image/svg+xml",
+			size:        512,
+		},
+		{
+			name:    "URL of another domain",
+			host:    "go.example.com",
+			request: models.QRRequest{},
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{OriginalURL: "http://original.url"}, nil)
+			},
+			wantErr: models.ErrOriginalURLNotFound,
+		},
+		{
+			name:    "deleted URL",
+			request: models.QRRequest{},
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{}, models.ErrURLDeleted)
+			},
+			wantErr: models.ErrURLDeleted,
+		},
+		{
+			name:      "invalid format",
+			request:   models.QRRequest{Format: "gif"},
+			mockSetup: func(mockRepo *mocks.MockRepository) {},
+			wantErr:   models.ErrQRCodeInvalid,
+		},
+		{
+			name:      "size too large",
+			request:   models.QRRequest{Size: maxQRSize + 1},
+			mockSetup: func(mockRepo *mocks.MockRepository) {},
+			wantErr:   models.ErrQRCodeInvalid,
+		},
+		{
+			name:      "margin too large",
+			request:   models.QRRequest{Margin: &badMargin},
+			mockSetup: func(mockRepo *mocks.MockRepository) {},
+			wantErr:   models.ErrQRCodeInvalid,
+		},
+		{
+			name:      "invalid level",
+			request:   models.QRRequest{Level: "X"},
+			mockSetup: func(mockRepo *mocks.MockRepository) {},
+			wantErr:   models.ErrQRCodeInvalid,
+		},
+		{
+			name:    "size too small for the code",
+			request: models.QRRequest{Size: 10},
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetRedirect(gomock.Any(), "shortURL").Return(models.Redirect{OriginalURL: "http://original.url"}, nil)
+			},
+			wantErr: models.ErrQRCodeInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.mockSetup(mockRepo)
+			service := ShortURLServices{repository: mockRepo, domains: NewDomains("http://localhost:8080", "https://go.example.com")}
+			code, err := service.GetQRCode(context.Background(), tt.host, "shortURL", tt.request)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.contentType, code.ContentType)
+			if tt.contentType == "image/png" {
+				img, err := png.Decode(bytes.NewReader(code.Data))
+				require.NoError(t, err)
+				assert.Equal(t, image.Rect(0, 0, tt.size, tt.size), img.Bounds())
+				return
+			}
+			assert.True(t, strings.HasPrefix(string(code.Data), fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"`, tt.size, tt.size)))
 		})
 	}
 }
 
+func TestQRImages(t *testing.T) {
+	modules := [][]bool{
+		{true, true},
+		{false, true},
+	}
+	options := qrOptions{size: 10, margin: 1}
+
+	data, err := qrPNG(modules, options)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	// 2 pixels per module, the code with its margin is centered with a 1 pixel gap on the left and top
+	dark := func(x, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r == 0
+	}
+	assert.False(t, dark(0, 0))
+	assert.False(t, dark(2, 2))
+	assert.True(t, dark(3, 3))
+	assert.True(t, dark(6, 4))
+	assert.False(t, dark(3, 5))
+	assert.True(t, dark(5, 6))
+	assert.False(t, dark(7, 7))
+
+	assert.Equal(t, `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10" viewBox="0 0 4 4" shape-rendering="crispEdges">`+
+		`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="M1 1h2v1h-2zM2 2h1v1h-1z"/></svg>`, string(qrSVG(modules, options)))
+}
+
 func TestCryptoBase62Encode(t *testing.T) {
 	service := ShortURLServices{}
 
@@ -319,47 +544,82 @@ func TestCryptoBase62Encode(t *testing.T) {
 }
 
 func TestAsyncDeleteUserURLs(t *testing.T) {
-	// Создаем моки
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	mockRepo := mocks.NewMockRepository(ctrl)
-	mockEncoder := mocks.NewMockEncoder(ctrl)
+	userID, otherUserID := uuid.New(), uuid.New()
+	userCtx := context.WithValue(context.Background(), models.UserIDKey, userID)
+	otherCtx := context.WithValue(context.Background(), models.UserIDKey, otherUserID)
 
-	// Создаем экземпляр сервиса
-	shortURLService := NewShortURLServices(mockRepo, mockEncoder, "http://localhost:8080")
-
-	// Подготовим тестовые случаи
 	testCases := []struct {
-		name          string
-		URLsToDelete  []string
-		expectedError error
+		name       string
+		repoErr    error
+		wantStatus string
+		wantError  string
 	}{
 		{
-			name:          "Success case",
-			URLsToDelete:  []string{"short1", "short2"},
-			expectedError: nil,
+			name:       "Success case",
+			wantStatus: models.DeleteJobDone,
+		},
+		{
+			name:       "Storage error",
+			repoErr:    errors.New("storage is down"),
+			wantStatus: models.DeleteJobFailed,
+			wantError:  "storage is down",
 		},
 	}
 
-	// Пройдемся по каждому тестовому случаю
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			// Устанавливаем ожидания моков
-			mockRepo.EXPECT().MarkURLsAsDeleted(gomock.Any(), gomock.Eq(tc.URLsToDelete)).Return(tc.expectedError).AnyTimes()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			shortURLService := NewShortURLServices(mockRepo, mocks.NewMockEncoder(ctrl), NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
 
-			// Вызываем тестируемый метод
-			shortURLService.AsyncDeleteUserURLs(context.Background(), tc.URLsToDelete)
+			job, err := shortURLService.AsyncDeleteUserURLs(userCtx, []string{"short1", "short2"})
+			require.NoError(t, err)
+			assert.Equal(t, models.DeleteJobQueued, job.Status)
+			assert.Equal(t, 2, job.URLs)
+			_, err = shortURLService.AsyncDeleteUserURLs(otherCtx, []string{"short3"})
+			require.NoError(t, err)
+
+			// the queued requests of both users are marked with one repository call
+			mockRepo.EXPECT().MarkURLsAsDeleted(gomock.Any(), []models.URLDeletion{
+				{UserID: userID, ShortURLs: []string{"short1", "short2"}},
+				{UserID: otherUserID, ShortURLs: []string{"short3"}},
+			}).Return(tc.repoErr)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			shortURLService.RunDeleteWorkers(ctx, 1)
 
-			// Ждем некоторое время для завершения асинхронной операции (здесь можно использовать библиотеку для ожидания)
-			time.Sleep(time.Second) // Пример использования time.Sleep, лучше использовать специализированные библиотеки для ожидания
+			got, err := shortURLService.GetDeleteJob(userCtx, job.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantStatus, got.Status)
+			assert.Equal(t, tc.wantError, got.Error)
+			assert.NotNil(t, got.FinishedAt)
 
-			// Проверяем, что ошибок не было
-			// Мы не можем проверить успешное выполнение асинхронной операции напрямую, поэтому предполагаем, что если тест завершился без ошибок, то все в порядке.
+			_, err = shortURLService.GetDeleteJob(otherCtx, job.ID)
+			assert.ErrorIs(t, err, models.ErrDeleteJobNotFound)
+			_, err = shortURLService.GetDeleteJob(userCtx, uuid.NewString())
+			assert.ErrorIs(t, err, models.ErrDeleteJobNotFound)
 		})
 	}
 }
 
+func TestAsyncDeleteUserURLs_QueueFull(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	shortURLService := NewShortURLServices(mocks.NewMockRepository(ctrl), mocks.NewMockEncoder(ctrl), NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+	userCtx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+
+	for i := 0; i < deleteQueueSize; i++ {
+		_, err := shortURLService.AsyncDeleteUserURLs(userCtx, []string{"short"})
+		require.NoError(t, err)
+	}
+	_, err := shortURLService.AsyncDeleteUserURLs(userCtx, []string{"short"})
+	assert.ErrorIs(t, err, models.ErrDeleteQueueFull)
+
+	_, err = shortURLService.AsyncDeleteUserURLs(context.Background(), []string{"short"})
+	assert.Error(t, err)
+}
+
 func BenchmarkShortURLServices_CryptoBase62Encode(b *testing.B) {
 	service := ShortURLServices{}
 	b.ResetTimer()
@@ -367,3 +627,769 @@ func BenchmarkShortURLServices_CryptoBase62Encode(b *testing.B) {
 		service.CryptoBase62Encode()
 	}
 }
+
+// sequenceEncoder is a deterministic Encoder returning the given short URLs in order
+// and repeating the last one when the sequence is exhausted.
+type sequenceEncoder struct {
+	shortURLs []string
+	calls     int
+}
+
+// CryptoBase62Encode returns the next short URL of the sequence.
+func (e *sequenceEncoder) CryptoBase62Encode() string {
+	i := min(e.calls, len(e.shortURLs)-1)
+	e.calls++
+	return e.shortURLs[i]
+}
+
+// newCollisionRepo creates an in-memory repository where the short URL "taken" is already used.
+func newCollisionRepo(t *testing.T) (*url2.URLInMemoryRepo, context.Context) {
+	repo := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), url2.DefaultFlushPolicy)
+	ctx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+	require.NoError(t, repo.StoreURL(ctx, "http://taken.com", "taken", models.URLOptions{}))
+	return repo, ctx
+}
+
+func TestServices_GetShortURL_Collisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		shortURLs []string
+		want      string
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "collisions are retried",
+			shortURLs: []string{"taken", "taken", "fresh"},
+			want:      "http://localhost:8080/fresh",
+			wantCalls: 3,
+		},
+		{
+			name:      "attempts are bounded",
+			shortURLs: []string{"taken"},
+			wantCalls: maxShortURLAttempts,
+			wantErr:   models.ErrShortURLConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, ctx := newCollisionRepo(t)
+			encoder := &sequenceEncoder{shortURLs: tt.shortURLs}
+			service := NewShortURLServices(repo, encoder, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+
+			got, err := service.GetShortURL(ctx, models.RequestDomain{}, "http://example.com", "", models.Expiration{}, 0)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCalls, encoder.calls)
+
+			// the link taken before must not be hijacked
+			redirect, err := repo.GetRedirect(ctx, "taken")
+			require.NoError(t, err)
+			assert.Equal(t, "http://taken.com", redirect.OriginalURL)
+		})
+	}
+}
+
+func TestGetBatchShortURL_Collisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		shortURLs []string
+		want      []models.URLResponse
+		wantErr   error
+	}{
+		{
+			name:      "taken short URL is regenerated",
+			shortURLs: []string{"taken", "b", "c"},
+			want: []models.URLResponse{
+				{CorrelationID: "1", ShortURL: "http://localhost:8080/c"},
+				{CorrelationID: "2", ShortURL: "http://localhost:8080/b"},
+			},
+		},
+		{
+			name:      "duplicate short URL in batch is regenerated",
+			shortURLs: []string{"a", "a", "c"},
+			wantErr:   nil,
+		},
+		{
+			name:      "attempts are bounded",
+			shortURLs: []string{"taken"},
+			wantErr:   models.ErrShortURLConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, ctx := newCollisionRepo(t)
+			service := NewShortURLServices(repo, &sequenceEncoder{shortURLs: tt.shortURLs}, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+			requests := []models.URLRequest{
+				{CorrelationID: "1", OriginalURL: "http://example1.com"},
+				{CorrelationID: "2", OriginalURL: "http://example2.com"},
+			}
+
+			got, err := service.GetBatchShortURL(ctx, models.RequestDomain{}, requests)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want != nil {
+				assert.Equal(t, tt.want, got)
+			}
+			// every returned short URL must lead to its own original URL
+			require.Len(t, got, len(requests))
+			for i, response := range got {
+				parts := strings.Split(response.ShortURL, "/")
+				redirect, err := repo.GetRedirect(ctx, parts[len(parts)-1])
+				require.NoError(t, err)
+				assert.Equal(t, requests[i].OriginalURL, redirect.OriginalURL)
+			}
+		})
+	}
+}
+
+func TestAliasPolicy_Validate(t *testing.T) {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+	policy := NewAliasPolicy(charset, 3, 8, "ping, api,,debug")
+	tests := []struct {
+		name    string
+		alias   string
+		wantErr bool
+	}{
+		{name: "valid alias", alias: "my-link"},
+		{name: "minimal length", alias: "abc"},
+		{name: "maximal length", alias: "abcdefgh"},
+		{name: "too short", alias: "ab", wantErr: true},
+		{name: "too long", alias: "abcdefghi", wantErr: true},
+		{name: "character outside charset", alias: "my/link", wantErr: true},
+		{name: "non-ASCII character", alias: "ссылка", wantErr: true},
+		{name: "reserved word", alias: "ping", wantErr: true},
+		{name: "reserved word with spaces in config", alias: "api", wantErr: true},
+		{name: "reserved word in other case", alias: "DEBUG", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.alias)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, models.ErrAliasInvalid)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServices_GetShortURL_Alias(t *testing.T) {
+	policy := NewAliasPolicy("abcdefghijklmnopqrstuvwxyz-", 3, 16, "ping")
+	tests := []struct {
+		name        string
+		originalURL string
+		alias       string
+		want        string
+		wantErr     error
+	}{
+		{
+			name:        "alias is used as short URL",
+			originalURL: "http://example.com",
+			alias:       "my-link",
+			want:        "http://localhost:8080/my-link",
+		},
+		{
+			name:        "alias is taken by another URL",
+			originalURL: "http://example.com",
+			alias:       "taken",
+			wantErr:     models.ErrAliasTaken,
+		},
+		{
+			name:        "alias is invalid",
+			originalURL: "http://example.com",
+			alias:       "ping",
+			wantErr:     models.ErrAliasInvalid,
+		},
+		{
+			name:        "already shortened URL keeps its short URL",
+			originalURL: "http://taken.com",
+			alias:       "other",
+			want:        "http://localhost:8080/taken",
+			wantErr:     models.ErrURLFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, ctx := newCollisionRepo(t)
+			service := NewShortURLServices(repo, &sequenceEncoder{shortURLs: []string{"generated"}}, NewDomains("http://localhost:8080", ""), policy, 0, 0)
+
+			got, err := service.GetShortURL(ctx, models.RequestDomain{}, tt.originalURL, tt.alias, models.Expiration{}, 0)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetBatchShortURL_Alias(t *testing.T) {
+	policy := NewAliasPolicy("abcdefghijklmnopqrstuvwxyz-", 3, 16, "ping")
+	tests := []struct {
+		name     string
+		requests []models.URLRequest
+		want     []models.URLResponse
+		wantErr  error
+	}{
+		{
+			name: "aliases and generated short URLs are mixed",
+			requests: []models.URLRequest{
+				{CorrelationID: "1", OriginalURL: "http://example1.com", Alias: "first"},
+				{CorrelationID: "2", OriginalURL: "http://example2.com"},
+			},
+			want: []models.URLResponse{
+				{CorrelationID: "1", ShortURL: "http://localhost:8080/first"},
+				{CorrelationID: "2", ShortURL: "http://localhost:8080/generated"},
+			},
+		},
+		{
+			name: "alias is taken",
+			requests: []models.URLRequest{
+				{CorrelationID: "1", OriginalURL: "http://example1.com", Alias: "taken"},
+			},
+			wantErr: models.ErrAliasTaken,
+		},
+		{
+			name: "alias is duplicated in batch",
+			requests: []models.URLRequest{
+				{CorrelationID: "1", OriginalURL: "http://example1.com", Alias: "same"},
+				{CorrelationID: "2", OriginalURL: "http://example2.com", Alias: "same"},
+			},
+			wantErr: models.ErrAliasTaken,
+		},
+		{
+			name: "alias is invalid",
+			requests: []models.URLRequest{
+				{CorrelationID: "1", OriginalURL: "http://example1.com", Alias: "a"},
+			},
+			wantErr: models.ErrAliasInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, ctx := newCollisionRepo(t)
+			service := NewShortURLServices(repo, &sequenceEncoder{shortURLs: []string{"generated"}}, NewDomains("http://localhost:8080", ""), policy, 0, 0)
+
+			got, err := service.GetBatchShortURL(ctx, models.RequestDomain{}, tt.requests)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				// nothing from the failed batch must be saved
+				_, err = repo.GetShortURL(ctx, "", tt.requests[0].OriginalURL)
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestURLOptions(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expiration models.Expiration
+		want       models.URLOptions
+		wantErr    error
+	}{
+		{
+			name: "no expiration",
+		},
+		{
+			name:       "TTL",
+			expiration: models.Expiration{TTL: time.Hour},
+			want:       models.URLOptions{ExpiresAt: now.Add(time.Hour)},
+		},
+		{
+			name:       "fixed time",
+			expiration: models.Expiration{ExpiresAt: now.Add(24 * time.Hour)},
+			want:       models.URLOptions{ExpiresAt: now.Add(24 * time.Hour)},
+		},
+		{
+			name:       "fixed time in the past",
+			expiration: models.Expiration{ExpiresAt: now.Add(-time.Second)},
+			wantErr:    models.ErrExpirationInvalid,
+		},
+		{
+			name:       "negative TTL",
+			expiration: models.Expiration{TTL: -time.Second},
+			wantErr:    models.ErrExpirationInvalid,
+		},
+		{
+			name:       "both fixed time and TTL",
+			expiration: models.Expiration{ExpiresAt: now.Add(time.Hour), TTL: time.Hour},
+			wantErr:    models.ErrExpirationInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := urlOptions(tt.expiration, now)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServices_GetShortURL_Expiration(t *testing.T) {
+	repo, ctx := newCollisionRepo(t)
+	service := NewShortURLServices(repo, &sequenceEncoder{shortURLs: []string{"temp"}}, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+
+	_, err := service.GetShortURL(ctx, models.RequestDomain{}, "http://example.com", "", models.Expiration{TTL: time.Hour}, 0)
+	require.NoError(t, err)
+	page, err := service.GetUserURLs(ctx, models.UserURLsRequest{})
+	require.NoError(t, err)
+	for _, url := range page.URLs {
+		if url.ShortURL == "http://localhost:8080/temp" {
+			require.NotNil(t, url.ExpiresAt)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), *url.ExpiresAt, time.Minute)
+		} else {
+			assert.Nil(t, url.ExpiresAt)
+		}
+	}
+
+	_, err = service.GetBatchShortURL(ctx, models.RequestDomain{}, []models.URLRequest{
+		{CorrelationID: "1", OriginalURL: "http://example1.com", TTL: -1},
+	})
+	assert.ErrorIs(t, err, models.ErrExpirationInvalid)
+}
+
+func TestDomains(t *testing.T) {
+	domains := NewDomains("http://localhost:8080", "https://go.example.com, http://Short.io:8081/,invalid")
+	tests := []struct {
+		name        string
+		request     models.RequestDomain
+		wantDomain  string
+		wantBaseURL string
+		wantErr     error
+	}{
+		{
+			name:        "default domain",
+			request:     models.RequestDomain{Host: "127.0.0.1:8080"},
+			wantBaseURL: "http://localhost:8080",
+		},
+		{
+			name:        "domain of the host",
+			request:     models.RequestDomain{Host: "GO.example.com:443"},
+			wantDomain:  "go.example.com",
+			wantBaseURL: "https://go.example.com",
+		},
+		{
+			name:        "selected domain",
+			request:     models.RequestDomain{Domain: "short.io:8081", Host: "go.example.com"},
+			wantDomain:  "short.io:8081",
+			wantBaseURL: "http://Short.io:8081/",
+		},
+		{
+			name:        "selected default domain",
+			request:     models.RequestDomain{Domain: "localhost:8080", Host: "go.example.com"},
+			wantBaseURL: "http://localhost:8080",
+		},
+		{
+			name:    "unknown selected domain",
+			request: models.RequestDomain{Domain: "invalid"},
+			wantErr: models.ErrDomainInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain, err := domains.Select(tt.request)
+			require.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			assert.Equal(t, tt.wantDomain, domain)
+			assert.Equal(t, tt.wantBaseURL, domains.BaseURL(domain))
+		})
+	}
+
+	// links of the removed domains are served on the default domain
+	assert.Equal(t, "", domains.Served("removed.com"))
+	assert.Equal(t, "http://localhost:8080", domains.BaseURL("removed.com"))
+}
+
+func TestServices_ShortDomains(t *testing.T) {
+	repo, ctx := newCollisionRepo(t)
+	encoder := &sequenceEncoder{shortURLs: []string{"default", "domain"}}
+	service := NewShortURLServices(repo, encoder, NewDomains("http://localhost:8080", "https://go.example.com"), AliasPolicy{}, 0, 0)
+
+	got, err := service.GetShortURL(ctx, models.RequestDomain{Host: "localhost:8080"}, "http://example.com", "", models.Expiration{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/default", got)
+	// the same URL is shortened separately on another domain
+	got, err = service.GetShortURL(ctx, models.RequestDomain{Domain: "go.example.com"}, "http://example.com", "", models.Expiration{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.example.com/domain", got)
+	got, err = service.GetShortURL(ctx, models.RequestDomain{Host: "go.example.com"}, "http://example.com", "", models.Expiration{}, 0)
+	assert.ErrorIs(t, err, models.ErrURLFound)
+	assert.Equal(t, "https://go.example.com/domain", got)
+	_, err = service.GetShortURL(ctx, models.RequestDomain{Domain: "unknown.com"}, "http://example.com", "", models.Expiration{}, 0)
+	assert.ErrorIs(t, err, models.ErrDomainInvalid)
+
+	// short URLs are resolved only on their own domain
+	redirect, err := service.GetOriginalURL(ctx, "go.example.com", "domain", models.ClickInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", redirect.OriginalURL)
+	_, err = service.GetOriginalURL(ctx, "localhost:8080", "domain", models.ClickInfo{})
+	assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+	_, err = service.GetOriginalURL(ctx, "go.example.com", "default", models.ClickInfo{})
+	assert.ErrorIs(t, err, models.ErrOriginalURLNotFound)
+}
+
+func TestServices_RunExpiredURLsReaper(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	service := NewShortURLServices(mockRepo, mocks.NewMockEncoder(ctrl), NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	mockRepo.EXPECT().DeleteExpiredURLs(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, before time.Time) (int64, error) {
+			// only URLs expired longer than the retention period are removed
+			assert.WithinDuration(t, time.Now().Add(-time.Hour), before, time.Minute)
+			cancel()
+			return 1, nil
+		}).MinTimes(1)
+
+	go func() {
+		service.RunExpiredURLsReaper(ctx, time.Millisecond, time.Hour)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reaper didn't stop after context cancellation")
+	}
+}
+
+func TestServices_RunClickWriter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	service := NewShortURLServices(mockRepo, mocks.NewMockEncoder(ctrl), NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+	client := models.ClickInfo{Referrer: "http://ref.com", UserAgent: "test-agent", ClientIP: "127.0.0.1"}
+
+	mockRepo.EXPECT().GetRedirect(gomock.Any(), "short").Return(models.Redirect{OriginalURL: "http://original.url"}, nil).Times(3)
+	mockRepo.EXPECT().GetRedirect(gomock.Any(), "unknown").Return(models.Redirect{}, errors.New("original URL not found"))
+	for i := 0; i < 3; i++ {
+		_, err := service.GetOriginalURL(context.Background(), "", "short", client)
+		require.NoError(t, err)
+	}
+	_, err := service.GetOriginalURL(context.Background(), "", "unknown", client)
+	require.Error(t, err)
+
+	// clicks queued before the shutdown are still saved
+	mockRepo.EXPECT().StoreClicks(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, clicks []models.Click) error {
+			assert.NoError(t, ctx.Err())
+			require.Len(t, clicks, 3)
+			for _, click := range clicks {
+				assert.Equal(t, "short", click.ShortURL)
+				assert.Equal(t, client, click.ClickInfo)
+				assert.False(t, click.Timestamp.IsZero())
+			}
+			return nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		service.RunClickWriter(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("click writer didn't stop after context cancellation")
+	}
+}
+
+func TestServices_GetURLStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		mockSetup func(mockRepo *mocks.MockRepository)
+		wantStats models.URLStats
+		wantErr   error
+	}{
+		{
+			name: "stats found",
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetURLStats(gomock.Any(), "short").Return(models.URLStats{
+					TotalClicks: 2,
+					Daily:       []models.DailyClicks{{Date: "2024-01-01", Clicks: 2}},
+				}, nil)
+			},
+			wantStats: models.URLStats{
+				ShortURL:    "http://localhost:8080/short",
+				TotalClicks: 2,
+				Daily:       []models.DailyClicks{{Date: "2024-01-01", Clicks: 2}},
+			},
+		},
+		{
+			name: "URL not found",
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().GetURLStats(gomock.Any(), "short").Return(models.URLStats{}, models.ErrURLNotFound)
+			},
+			wantErr: models.ErrURLNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.mockSetup(mockRepo)
+			service := NewShortURLServices(mockRepo, mocks.NewMockEncoder(ctrl), NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+			stats, err := service.GetURLStats(context.Background(), "short")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStats, stats)
+		})
+	}
+}
+
+func TestServices_UpdateURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		mockSetup func(mockRepo *mocks.MockRepository)
+		wantURL   models.URL
+		wantErr   error
+	}{
+		{
+			name: "URL updated",
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().UpdateURL(gomock.Any(), "short", "http://new.url").
+					Return(models.URL{ShortURL: "short", OriginalURL: "http://new.url", Domain: "go.example.com"}, nil)
+			},
+			wantURL: models.URL{ShortURL: "https://go.example.com/short", OriginalURL: "http://new.url", Domain: "go.example.com"},
+		},
+		{
+			name: "original URL taken",
+			mockSetup: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().UpdateURL(gomock.Any(), "short", "http://new.url").Return(models.URL{}, models.ErrOriginalURLTaken)
+			},
+			wantErr: models.ErrOriginalURLTaken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.mockSetup(mockRepo)
+			service := NewShortURLServices(mockRepo, mocks.NewMockEncoder(ctrl),
+				NewDomains("http://localhost:8080", "https://go.example.com"), AliasPolicy{}, 0, 0)
+			url, err := service.UpdateURL(context.Background(), "short", "http://new.url")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantURL, url)
+		})
+	}
+}
+
+func TestServices_RestoreURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mocks.NewMockRepository(ctrl)
+	service := NewShortURLServices(mockRepo, mocks.NewMockEncoder(ctrl), NewDomains("http://localhost:8080", ""), AliasPolicy{}, time.Hour, 0)
+
+	// only URLs deleted within the restore period are restored
+	mockRepo.EXPECT().RestoreURL(gomock.Any(), "short", gomock.Any()).DoAndReturn(
+		func(_ context.Context, shortURL string, deletedAfter time.Time) (models.URL, error) {
+			assert.WithinDuration(t, time.Now().Add(-time.Hour), deletedAfter, time.Minute)
+			return models.URL{ShortURL: shortURL, OriginalURL: "http://original.url"}, nil
+		})
+	url, err := service.RestoreURL(context.Background(), "short")
+	require.NoError(t, err)
+	assert.Equal(t, models.URL{ShortURL: "http://localhost:8080/short", OriginalURL: "http://original.url"}, url)
+
+	mockRepo.EXPECT().RestoreURL(gomock.Any(), "short", gomock.Any()).Return(models.URL{}, models.ErrRestorePeriodOver)
+	_, err = service.RestoreURL(context.Background(), "short")
+	assert.ErrorIs(t, err, models.ErrRestorePeriodOver)
+}
+
+func TestServices_RedirectCodes(t *testing.T) {
+	repo := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), url2.DefaultFlushPolicy)
+	ctx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+	encoder := &sequenceEncoder{shortURLs: []string{"default", "permanent", "batch1", "batch2"}}
+	service := NewShortURLServices(repo, encoder, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, http.StatusMovedPermanently)
+
+	// the default code of the service is used unless another code is requested
+	_, err := service.GetShortURL(ctx, models.RequestDomain{}, "http://default.com", "", models.Expiration{}, 0)
+	require.NoError(t, err)
+	_, err = service.GetShortURL(ctx, models.RequestDomain{}, "http://permanent.com", "", models.Expiration{}, http.StatusPermanentRedirect)
+	require.NoError(t, err)
+	_, err = service.GetShortURL(ctx, models.RequestDomain{}, "http://other.com", "", models.Expiration{}, http.StatusSeeOther)
+	assert.ErrorIs(t, err, models.ErrRedirectCodeInvalid)
+
+	_, err = service.GetBatchShortURL(ctx, models.RequestDomain{}, []models.URLRequest{
+		{CorrelationID: "1", OriginalURL: "http://batch1.com", RedirectCode: http.StatusFound},
+		{CorrelationID: "2", OriginalURL: "http://batch2.com"},
+	})
+	require.NoError(t, err)
+	_, err = service.GetBatchShortURL(ctx, models.RequestDomain{}, []models.URLRequest{
+		{CorrelationID: "1", OriginalURL: "http://batch3.com", RedirectCode: 200},
+	})
+	assert.ErrorIs(t, err, models.ErrRedirectCodeInvalid)
+
+	for originalURL, want := range map[string]int{
+		"http://default.com":   http.StatusMovedPermanently,
+		"http://permanent.com": http.StatusPermanentRedirect,
+		"http://batch1.com":    http.StatusFound,
+		"http://batch2.com":    http.StatusMovedPermanently,
+	} {
+		shortURL, err := repo.GetShortURL(ctx, "", originalURL)
+		require.NoError(t, err)
+		redirect, err := service.GetOriginalURL(ctx, "", shortURL, models.ClickInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, want, redirect.Code, originalURL)
+	}
+}
+
+func TestServices_CreateAPIKey_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		request models.APIKeyRequest
+	}{
+		{name: "empty name", request: models.APIKeyRequest{Name: "  ", Scopes: []string{models.ScopeRead}}},
+		{name: "long name", request: models.APIKeyRequest{Name: strings.Repeat("k", maxAPIKeyName+1), Scopes: []string{models.ScopeRead}}},
+		{name: "no scopes", request: models.APIKeyRequest{Name: "backend"}},
+		{name: "unknown scope", request: models.APIKeyRequest{Name: "backend", Scopes: []string{models.ScopeRead, "admin"}}},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	service := NewShortURLServices(mocks.NewMockRepository(ctrl), nil, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+	ctx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateAPIKey(ctx, tt.request)
+			assert.ErrorIs(t, err, models.ErrAPIKeyRequestInvalid)
+		})
+	}
+}
+
+func TestServices_APIKeys(t *testing.T) {
+	repo := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), url2.DefaultFlushPolicy)
+	service := NewShortURLServices(repo, nil, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+	userID := uuid.New()
+	ctx := context.WithValue(context.Background(), models.UserIDKey, userID)
+
+	created, err := service.CreateAPIKey(ctx, models.APIKeyRequest{
+		Name:   " backend ",
+		Scopes: []string{models.ScopeRead, models.ScopeCreate, models.ScopeRead},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "backend", created.Name)
+	assert.Equal(t, []string{models.ScopeRead, models.ScopeCreate}, created.Scopes)
+	assert.True(t, strings.HasPrefix(created.Key, "shk_"+created.ID+"_"))
+	assert.Len(t, created.Hash, sha256.Size*2)
+
+	// the key authenticates as the user it is bound to
+	key, err := service.AuthenticateAPIKey(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, userID, key.UserID)
+	assert.Equal(t, created.APIKey, key)
+
+	keys, err := service.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.APIKey{created.APIKey}, keys)
+
+	// keys with another secret, unknown or malformed keys are rejected
+	forged := created.Key[:len(created.Key)-1] + "0"
+	if forged == created.Key {
+		forged = created.Key[:len(created.Key)-1] + "1"
+	}
+	unknown := "shk_" + strings.Repeat("0", 2*apiKeyIDLength) + "_" + strings.Repeat("0", 2*apiKeySecretLength)
+	for _, invalid := range []string{forged, unknown, "shk_short_key", "not a key"} {
+		_, err = service.AuthenticateAPIKey(context.Background(), invalid)
+		assert.ErrorIs(t, err, models.ErrAPIKeyInvalid, invalid)
+	}
+
+	// a revoked key doesn't authenticate anymore
+	otherCtx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+	assert.ErrorIs(t, service.RevokeAPIKey(otherCtx, created.ID), models.ErrAPIKeyNotFound)
+	require.NoError(t, service.RevokeAPIKey(ctx, created.ID))
+	_, err = service.AuthenticateAPIKey(context.Background(), created.Key)
+	assert.ErrorIs(t, err, models.ErrAPIKeyInvalid)
+}
+
+func TestServices_Register_Invalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockRepository(ctrl)
+	service := NewShortURLServices(mockRepo, nil, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+	ctx := context.WithValue(context.Background(), models.UserIDKey, uuid.New())
+	tests := []struct {
+		name        string
+		credentials models.Credentials
+	}{
+		{name: "short login", credentials: models.Credentials{Login: "al", Password: "password1"}},
+		{name: "long login", credentials: models.Credentials{Login: strings.Repeat("a", 65), Password: "password1"}},
+		{name: "login with spaces", credentials: models.Credentials{Login: "al ice", Password: "password1"}},
+		{name: "short password", credentials: models.Credentials{Login: "alice", Password: "pass"}},
+		{name: "long password", credentials: models.Credentials{Login: "alice", Password: strings.Repeat("p", 73)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Register(ctx, tt.credentials)
+			assert.ErrorIs(t, err, models.ErrCredentialsInvalid)
+		})
+	}
+}
+
+func TestServices_Accounts(t *testing.T) {
+	cost := passwordCost
+	passwordCost = bcrypt.MinCost
+	t.Cleanup(func() { passwordCost = cost })
+	repo := url2.NewURLInMemoryRepo(filepath.Join(t.TempDir(), "storage.json"), url2.DefaultFlushPolicy)
+	service := NewShortURLServices(repo, nil, NewDomains("http://localhost:8080", ""), AliasPolicy{}, 0, 0)
+	userID := uuid.New()
+	ctx := context.WithValue(context.Background(), models.UserIDKey, userID)
+
+	// the anonymous user keeps its ID when it registers
+	registered, err := service.Register(ctx, models.Credentials{Login: " Alice ", Password: "password1"})
+	require.NoError(t, err)
+	assert.Equal(t, userID, registered.UserID)
+	assert.Equal(t, "alice", registered.Login)
+	assert.NotEqual(t, "password1", registered.PasswordHash)
+	_, err = service.Register(ctx, models.Credentials{Login: "alice", Password: "password2"})
+	assert.ErrorIs(t, err, models.ErrLoginTaken)
+
+	// the registered user registering another account gets a new user
+	second, err := service.Register(ctx, models.Credentials{Login: "bob", Password: "password2"})
+	require.NoError(t, err)
+	assert.NotEqual(t, userID, second.UserID)
+
+	// the links of the anonymous user are claimed on login
+	anonymousID := uuid.New()
+	anonymousCtx := context.WithValue(context.Background(), models.UserIDKey, anonymousID)
+	require.NoError(t, repo.StoreURL(anonymousCtx, "http://example.com", "short", models.URLOptions{}))
+	login, err := service.Login(anonymousCtx, models.Credentials{Login: "ALICE", Password: "password1"})
+	require.NoError(t, err)
+	assert.Equal(t, registered.Account, login.Account)
+	assert.Equal(t, int64(1), login.ClaimedURLs)
+	urls, err := repo.GetUserURLs(ctx, models.UserURLsQuery{})
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "http://example.com", urls[0].OriginalURL)
+
+	// the links of a registered user stay with its account
+	secondCtx := context.WithValue(context.Background(), models.UserIDKey, second.UserID)
+	require.NoError(t, repo.StoreURL(secondCtx, "http://example2.com", "short2", models.URLOptions{}))
+	login, err = service.Login(secondCtx, models.Credentials{Login: "alice", Password: "password1"})
+	require.NoError(t, err)
+	assert.Zero(t, login.ClaimedURLs)
+
+	// the wrong password and the unknown login fail the same way
+	_, err = service.Login(ctx, models.Credentials{Login: "alice", Password: "password2"})
+	assert.ErrorIs(t, err, models.ErrLoginFailed)
+	_, err = service.Login(ctx, models.Credentials{Login: "carol", Password: "password1"})
+	assert.ErrorIs(t, err, models.ErrLoginFailed)
+}
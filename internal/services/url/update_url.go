@@ -0,0 +1,24 @@
+// Package url provides the business logic for managing shortened URLs.
+// It includes functionality to generate, store, retrieve, and delete URLs.
+package url
+
+import (
+	"context"
+	"github.com/DenisKhanov/shorterURL/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateURL changes the original URL the short URL owned by the user from the context leads to
+// and returns the updated URL. The replaced original URL is kept in the change history of the short URL.
+// It returns models.ErrURLNotFound if the short URL doesn't exist or belongs to another user,
+// models.ErrURLDeleted or models.ErrURLExpired if it can't be followed anymore
+// and models.ErrOriginalURLTaken if another link on the domain leads to the original URL.
+func (s ShortURLServices) UpdateURL(ctx context.Context, shortURL, originalURL string) (models.URL, error) {
+	url, err := s.repository.UpdateURL(ctx, shortURL, originalURL)
+	if err != nil {
+		logrus.Error(err)
+		return models.URL{}, err
+	}
+	url.ShortURL = s.finalURLBuilder(url.Domain, shortURL)
+	return url, nil
+}
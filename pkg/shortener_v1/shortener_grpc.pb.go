@@ -26,6 +26,17 @@ const (
 	ShortenerV1_DelUserURLs_FullMethodName      = "/shortener_v1.Shortener_v1/DelUserURLs"
 	ShortenerV1_GetServiceStats_FullMethodName  = "/shortener_v1.Shortener_v1/GetServiceStats"
 	ShortenerV1_GetStorageStatus_FullMethodName = "/shortener_v1.Shortener_v1/GetStorageStatus"
+	ShortenerV1_GetURLStats_FullMethodName      = "/shortener_v1.Shortener_v1/GetURLStats"
+	ShortenerV1_UpdateURL_FullMethodName        = "/shortener_v1.Shortener_v1/UpdateURL"
+	ShortenerV1_GetURLHistory_FullMethodName    = "/shortener_v1.Shortener_v1/GetURLHistory"
+	ShortenerV1_RestoreURL_FullMethodName       = "/shortener_v1.Shortener_v1/RestoreURL"
+	ShortenerV1_GetDeleteJob_FullMethodName     = "/shortener_v1.Shortener_v1/GetDeleteJob"
+	ShortenerV1_GetQRCode_FullMethodName        = "/shortener_v1.Shortener_v1/GetQRCode"
+	ShortenerV1_CreateAPIKey_FullMethodName     = "/shortener_v1.Shortener_v1/CreateAPIKey"
+	ShortenerV1_ListAPIKeys_FullMethodName      = "/shortener_v1.Shortener_v1/ListAPIKeys"
+	ShortenerV1_RevokeAPIKey_FullMethodName     = "/shortener_v1.Shortener_v1/RevokeAPIKey"
+	ShortenerV1_Register_FullMethodName         = "/shortener_v1.Shortener_v1/Register"
+	ShortenerV1_Login_FullMethodName            = "/shortener_v1.Shortener_v1/Login"
 )
 
 // ShortenerV1Client is the client API for ShortenerV1 service.
@@ -39,6 +50,17 @@ type ShortenerV1Client interface {
 	DelUserURLs(ctx context.Context, in *DelUserURLsRequest, opts ...grpc.CallOption) (*DelUserURLsResponse, error)
 	GetServiceStats(ctx context.Context, in *GetServiceStatsRequest, opts ...grpc.CallOption) (*GetServiceStatsResponse, error)
 	GetStorageStatus(ctx context.Context, in *GetStorageStatusRequest, opts ...grpc.CallOption) (*GetStorageStatusResponse, error)
+	GetURLStats(ctx context.Context, in *GetURLStatsRequest, opts ...grpc.CallOption) (*GetURLStatsResponse, error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error)
+	GetURLHistory(ctx context.Context, in *GetURLHistoryRequest, opts ...grpc.CallOption) (*GetURLHistoryResponse, error)
+	RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*RestoreURLResponse, error)
+	GetDeleteJob(ctx context.Context, in *GetDeleteJobRequest, opts ...grpc.CallOption) (*GetDeleteJobResponse, error)
+	GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*GetQRCodeResponse, error)
+	CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error)
+	ListAPIKeys(ctx context.Context, in *ListAPIKeysRequest, opts ...grpc.CallOption) (*ListAPIKeysResponse, error)
+	RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*RevokeAPIKeyResponse, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
 }
 
 type shortenerV1Client struct {
@@ -112,6 +134,105 @@ func (c *shortenerV1Client) GetStorageStatus(ctx context.Context, in *GetStorage
 	return out, nil
 }
 
+func (c *shortenerV1Client) GetURLStats(ctx context.Context, in *GetURLStatsRequest, opts ...grpc.CallOption) (*GetURLStatsResponse, error) {
+	out := new(GetURLStatsResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_GetURLStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error) {
+	out := new(UpdateURLResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_UpdateURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) GetURLHistory(ctx context.Context, in *GetURLHistoryRequest, opts ...grpc.CallOption) (*GetURLHistoryResponse, error) {
+	out := new(GetURLHistoryResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_GetURLHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) RestoreURL(ctx context.Context, in *RestoreURLRequest, opts ...grpc.CallOption) (*RestoreURLResponse, error) {
+	out := new(RestoreURLResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_RestoreURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) GetDeleteJob(ctx context.Context, in *GetDeleteJobRequest, opts ...grpc.CallOption) (*GetDeleteJobResponse, error) {
+	out := new(GetDeleteJobResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_GetDeleteJob_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) GetQRCode(ctx context.Context, in *GetQRCodeRequest, opts ...grpc.CallOption) (*GetQRCodeResponse, error) {
+	out := new(GetQRCodeResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_GetQRCode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error) {
+	out := new(CreateAPIKeyResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_CreateAPIKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) ListAPIKeys(ctx context.Context, in *ListAPIKeysRequest, opts ...grpc.CallOption) (*ListAPIKeysResponse, error) {
+	out := new(ListAPIKeysResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_ListAPIKeys_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*RevokeAPIKeyResponse, error) {
+	out := new(RevokeAPIKeyResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_RevokeAPIKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_Register_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerV1Client) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, ShortenerV1_Login_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerV1Server is the server API for ShortenerV1 service.
 // All implementations must embed UnimplementedShortenerV1Server
 // for forward compatibility
@@ -123,6 +244,17 @@ type ShortenerV1Server interface {
 	DelUserURLs(context.Context, *DelUserURLsRequest) (*DelUserURLsResponse, error)
 	GetServiceStats(context.Context, *GetServiceStatsRequest) (*GetServiceStatsResponse, error)
 	GetStorageStatus(context.Context, *GetStorageStatusRequest) (*GetStorageStatusResponse, error)
+	GetURLStats(context.Context, *GetURLStatsRequest) (*GetURLStatsResponse, error)
+	UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error)
+	GetURLHistory(context.Context, *GetURLHistoryRequest) (*GetURLHistoryResponse, error)
+	RestoreURL(context.Context, *RestoreURLRequest) (*RestoreURLResponse, error)
+	GetDeleteJob(context.Context, *GetDeleteJobRequest) (*GetDeleteJobResponse, error)
+	GetQRCode(context.Context, *GetQRCodeRequest) (*GetQRCodeResponse, error)
+	CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	ListAPIKeys(context.Context, *ListAPIKeysRequest) (*ListAPIKeysResponse, error)
+	RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*RevokeAPIKeyResponse, error)
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
 	mustEmbedUnimplementedShortenerV1Server()
 }
 
@@ -151,6 +283,39 @@ func (UnimplementedShortenerV1Server) GetServiceStats(context.Context, *GetServi
 func (UnimplementedShortenerV1Server) GetStorageStatus(context.Context, *GetStorageStatusRequest) (*GetStorageStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStorageStatus not implemented")
 }
+func (UnimplementedShortenerV1Server) GetURLStats(context.Context, *GetURLStatsRequest) (*GetURLStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLStats not implemented")
+}
+func (UnimplementedShortenerV1Server) UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
+func (UnimplementedShortenerV1Server) GetURLHistory(context.Context, *GetURLHistoryRequest) (*GetURLHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLHistory not implemented")
+}
+func (UnimplementedShortenerV1Server) RestoreURL(context.Context, *RestoreURLRequest) (*RestoreURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreURL not implemented")
+}
+func (UnimplementedShortenerV1Server) GetDeleteJob(context.Context, *GetDeleteJobRequest) (*GetDeleteJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeleteJob not implemented")
+}
+func (UnimplementedShortenerV1Server) GetQRCode(context.Context, *GetQRCodeRequest) (*GetQRCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQRCode not implemented")
+}
+func (UnimplementedShortenerV1Server) CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAPIKey not implemented")
+}
+func (UnimplementedShortenerV1Server) ListAPIKeys(context.Context, *ListAPIKeysRequest) (*ListAPIKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAPIKeys not implemented")
+}
+func (UnimplementedShortenerV1Server) RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*RevokeAPIKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAPIKey not implemented")
+}
+func (UnimplementedShortenerV1Server) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedShortenerV1Server) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
 func (UnimplementedShortenerV1Server) mustEmbedUnimplementedShortenerV1Server() {}
 
 // UnsafeShortenerV1Server may be embedded to opt out of forward compatibility for this service.
@@ -290,6 +455,204 @@ func _ShortenerV1_GetStorageStatus_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShortenerV1_GetURLStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).GetURLStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_GetURLStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).GetURLStats(ctx, req.(*GetURLStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_GetURLHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).GetURLHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_GetURLHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).GetURLHistory(ctx, req.(*GetURLHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_RestoreURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).RestoreURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_RestoreURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).RestoreURL(ctx, req.(*RestoreURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_GetDeleteJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDeleteJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).GetDeleteJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_GetDeleteJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).GetDeleteJob(ctx, req.(*GetDeleteJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_GetQRCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQRCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).GetQRCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_GetQRCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).GetQRCode(ctx, req.(*GetQRCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_CreateAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).CreateAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_CreateAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).CreateAPIKey(ctx, req.(*CreateAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_ListAPIKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAPIKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).ListAPIKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_ListAPIKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).ListAPIKeys(ctx, req.(*ListAPIKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_RevokeAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).RevokeAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_RevokeAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).RevokeAPIKey(ctx, req.(*RevokeAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortenerV1_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerV1Server).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShortenerV1_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerV1Server).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ShortenerV1_ServiceDesc is the grpc.ServiceDesc for ShortenerV1 service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -325,6 +688,50 @@ var ShortenerV1_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetStorageStatus",
 			Handler:    _ShortenerV1_GetStorageStatus_Handler,
 		},
+		{
+			MethodName: "GetURLStats",
+			Handler:    _ShortenerV1_GetURLStats_Handler,
+		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _ShortenerV1_UpdateURL_Handler,
+		},
+		{
+			MethodName: "GetURLHistory",
+			Handler:    _ShortenerV1_GetURLHistory_Handler,
+		},
+		{
+			MethodName: "RestoreURL",
+			Handler:    _ShortenerV1_RestoreURL_Handler,
+		},
+		{
+			MethodName: "GetDeleteJob",
+			Handler:    _ShortenerV1_GetDeleteJob_Handler,
+		},
+		{
+			MethodName: "GetQRCode",
+			Handler:    _ShortenerV1_GetQRCode_Handler,
+		},
+		{
+			MethodName: "CreateAPIKey",
+			Handler:    _ShortenerV1_CreateAPIKey_Handler,
+		},
+		{
+			MethodName: "ListAPIKeys",
+			Handler:    _ShortenerV1_ListAPIKeys_Handler,
+		},
+		{
+			MethodName: "RevokeAPIKey",
+			Handler:    _ShortenerV1_RevokeAPIKey_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _ShortenerV1_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _ShortenerV1_Login_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "shortener.proto",